@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/password"
+)
+
+// Prints the PASSWORD_* settings that make a password hash take about the target latency on this host.
+// Run it on the machine (or an identical one) that serves the API, e.g.:
+//
+//	go run ./cmd/password-calibrate -target 250ms
+func main() {
+	target := flag.Duration("target", 250*time.Millisecond, "target duration of a single password hash")
+	memory := flag.Uint("memory", 65536, "argon2id memory in KiB")
+	parallelism := flag.Uint("parallelism", 2, "argon2id parallelism")
+	flag.Parse()
+
+	argon := password.CalibrateArgon2id(*target, uint32(*memory), uint8(*parallelism))
+	bcrypt := password.CalibrateBcrypt(*target)
+
+	fmt.Printf("# argon2id, calibrated for %s\n", *target)
+	fmt.Printf("PASSWORD_HASH_ALGORITHM=%q\n", password.ALGORITHM_ARGON2ID)
+	fmt.Printf("PASSWORD_ARGON2_MEMORY=%d\n", argon.Memory)
+	fmt.Printf("PASSWORD_ARGON2_ITERATIONS=%d\n", argon.Iterations)
+	fmt.Printf("PASSWORD_ARGON2_PARALLELISM=%d\n", argon.Parallelism)
+	fmt.Println()
+	fmt.Printf("# bcrypt alternative, calibrated for %s\n", *target)
+	fmt.Printf("PASSWORD_BCRYPT_COST=%d\n", bcrypt.Cost)
+}
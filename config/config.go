@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,6 +33,120 @@ var (
 
 	ALLOWED_IPS = getEnvList("ALLOWED_IPS", []string{"127.0.0.1"})
 
+	// Password hashing. New hashes use PASSWORD_HASH_ALGORITHM (argon2id or bcrypt), existing hashes with other
+	// parameters are upgraded on the next successful login. See `make password-calibrate` for host specific values.
+	PASSWORD_HASH_ALGORITHM     = getEnv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	PASSWORD_ARGON2_MEMORY      = getEnvInt("PASSWORD_ARGON2_MEMORY", "65536")
+	PASSWORD_ARGON2_ITERATIONS  = getEnvInt("PASSWORD_ARGON2_ITERATIONS", "3")
+	PASSWORD_ARGON2_PARALLELISM = getEnvInt("PASSWORD_ARGON2_PARALLELISM", "2")
+	PASSWORD_BCRYPT_COST        = getEnvInt("PASSWORD_BCRYPT_COST", "10")
+
+	// Password policy for new passwords (registration and password change). The character classes are any of
+	// lowercase, uppercase, digit and symbol. PASSWORD_BREACHED_CORPUS_PATH points to a file or directory of
+	// breached SHA-1 hashes (see password.BreachedCorpus), the check is disabled when it is empty.
+	PASSWORD_MIN_LENGTH                 = getEnvInt("PASSWORD_MIN_LENGTH", "8")
+	PASSWORD_MAX_LENGTH                 = getEnvInt("PASSWORD_MAX_LENGTH", "100")
+	PASSWORD_REQUIRED_CHARACTER_CLASSES = getEnvList("PASSWORD_REQUIRED_CHARACTER_CLASSES", []string{})
+	PASSWORD_DISALLOW_PERSONAL_INFO     = getEnvBool("PASSWORD_DISALLOW_PERSONAL_INFO", "true")
+	PASSWORD_BREACHED_CORPUS_PATH       = getEnv("PASSWORD_BREACHED_CORPUS_PATH", "")
+
+	// Login throttling. Every failed attempt doubles the time until the next attempt is allowed,
+	// starting at LOGIN_THROTTLE_DELAY, until the max failures lock the email or IP for LOGIN_LOCKOUT_DURATION.
+	LOGIN_THROTTLE_DELAY      = getEnvTimeDurationParse("LOGIN_THROTTLE_DELAY", "1s")
+	LOGIN_FAILURE_WINDOW      = getEnvTimeDurationParse("LOGIN_FAILURE_WINDOW", "15m")
+	LOGIN_LOCKOUT_DURATION    = getEnvTimeDurationParse("LOGIN_LOCKOUT_DURATION", "15m")
+	LOGIN_MAX_FAILURES        = getEnvInt("LOGIN_MAX_FAILURES", "5")
+	LOGIN_MAX_FAILURES_PER_IP = getEnvInt("LOGIN_MAX_FAILURES_PER_IP", "20")
+
+	// WebAuthn (passkey) relying party configuration
+	WEBAUTHN_RP_ID           = getEnv("WEBAUTHN_RP_ID", "localhost")
+	WEBAUTHN_RP_DISPLAY_NAME = getEnv("WEBAUTHN_RP_DISPLAY_NAME", "Go TODO API")
+	WEBAUTHN_RP_ORIGINS      = getEnvList("WEBAUTHN_RP_ORIGINS", []string{"http://localhost:3000"})
+	WEBAUTHN_CHALLENGE_EXP   = getEnvTimeDurationParse("WEBAUTHN_CHALLENGE_EXP", "5m")
+
+	// OpenID Connect login against an external identity provider, disabled when OIDC_ISSUER_URL is empty.
+	// The OIDC_CLAIM_* variables map provider claims to account fields. Existing accounts are linked by email
+	// (only verified emails when OIDC_REQUIRE_VERIFIED_EMAIL is set), unknown emails get an account if
+	// OIDC_AUTO_PROVISION is set and REGISTRATION_MODE is open.
+	OIDC_ISSUER_URL             = getEnv("OIDC_ISSUER_URL", "")
+	OIDC_CLIENT_ID              = getEnv("OIDC_CLIENT_ID", "")
+	OIDC_CLIENT_SECRET          = getEnv("OIDC_CLIENT_SECRET", "")
+	OIDC_REDIRECT_URL           = getEnv("OIDC_REDIRECT_URL", "http://localhost:3000/auth/oidc/callback")
+	OIDC_SCOPES                 = getEnvList("OIDC_SCOPES", []string{"openid", "email", "profile"})
+	OIDC_PROVIDER_NAME          = getEnv("OIDC_PROVIDER_NAME", "SSO")
+	OIDC_CLAIM_EMAIL            = getEnv("OIDC_CLAIM_EMAIL", "email")
+	OIDC_CLAIM_FIRSTNAME        = getEnv("OIDC_CLAIM_FIRSTNAME", "given_name")
+	OIDC_CLAIM_LASTNAME         = getEnv("OIDC_CLAIM_LASTNAME", "family_name")
+	OIDC_REQUIRE_VERIFIED_EMAIL = getEnvBool("OIDC_REQUIRE_VERIFIED_EMAIL", "true")
+	OIDC_AUTO_PROVISION         = getEnvBool("OIDC_AUTO_PROVISION", "true")
+	OIDC_STATE_EXP              = getEnvTimeDurationParse("OIDC_STATE_EXP", "10m")
+
+	// OAuth2 authorization server for third party clients. Access tokens are JWTs, refresh tokens are opaque
+	// and rotated on every use.
+	OAUTH_ISSUER            = getEnv("OAUTH_ISSUER", "http://localhost:3000")
+	OAUTH_ACCESS_TOKEN_EXP  = getEnvTimeDurationParse("OAUTH_ACCESS_TOKEN_EXP", "1h")
+	OAUTH_REFRESH_TOKEN_EXP = getEnvTimeDurationParse("OAUTH_REFRESH_TOKEN_EXP", "720h")
+	OAUTH_CODE_EXP          = getEnvTimeDurationParse("OAUTH_CODE_EXP", "5m")
+
+	// Authentication by a reverse proxy (oauth2-proxy style). Requests from PROXY_AUTH_TRUSTED_CIDRS with the
+	// email header are signed in as that account, other peers can't use the header. Only enable it when the app
+	// can't be reached without going through the proxy. Unknown emails get an account if PROXY_AUTH_AUTO_PROVISION
+	// is set and REGISTRATION_MODE is open.
+	PROXY_AUTH_ENABLED        = getEnvBool("PROXY_AUTH_ENABLED", "false")
+	PROXY_AUTH_TRUSTED_CIDRS  = getEnvList("PROXY_AUTH_TRUSTED_CIDRS", []string{"127.0.0.1/32", "::1/128"})
+	PROXY_AUTH_EMAIL_HEADER   = getEnv("PROXY_AUTH_EMAIL_HEADER", "X-Forwarded-Email")
+	PROXY_AUTH_AUTO_PROVISION = getEnvBool("PROXY_AUTH_AUTO_PROVISION", "true")
+
+	// Outgoing email. MAILER is smtp, log (prints emails, for development) or memory (for tests)
+	MAILER        = getEnv("MAILER", "log")
+	MAIL_FROM     = getEnv("MAIL_FROM", "Go TODO API <no-reply@localhost>")
+	SMTP_HOST     = getEnv("SMTP_HOST", "localhost")
+	SMTP_PORT     = getEnv("SMTP_PORT", "587")
+	SMTP_USERNAME = getEnv("SMTP_USERNAME", "")
+	SMTP_PASSWORD = getEnv("SMTP_PASSWORD", "")
+
+	// Magic link sign-in. Links are single use and only work in the browser that requested them.
+	// APP_URL is used to build the links instead of the Host header, which the requester controls.
+	APP_URL             = getEnv("APP_URL", "http://localhost:3000")
+	MAGIC_LINK_EXP      = getEnvTimeDurationParse("MAGIC_LINK_EXP", "15m")
+	MAGIC_LINK_COOLDOWN = getEnvTimeDurationParse("MAGIC_LINK_COOLDOWN", "1m")
+
+	// Registration mode: open, invite-only (an invitation code is required) or closed. Admins can always
+	// create accounts through the API. INVITATION_EXP is the default lifetime of new invitations.
+	REGISTRATION_MODE = getEnv("REGISTRATION_MODE", "open")
+	INVITATION_EXP    = getEnvTimeDurationParse("INVITATION_EXP", "168h")
+
+	// File attachments. BLOB_STORE is local (files below BLOB_LOCAL_PATH) or s3 (a bucket of any S3 compatible
+	// service, addressed path-style). Uploads larger than ATTACHMENT_MAX_SIZE bytes or with a detected content type
+	// not in ATTACHMENT_ALLOWED_TYPES are rejected.
+	BLOB_STORE               = getEnv("BLOB_STORE", "local")
+	BLOB_LOCAL_PATH          = getEnv("BLOB_LOCAL_PATH", "./data/blobs")
+	S3_ENDPOINT              = getEnv("S3_ENDPOINT", "https://s3.amazonaws.com")
+	S3_REGION                = getEnv("S3_REGION", "us-east-1")
+	S3_BUCKET                = getEnv("S3_BUCKET", "")
+	S3_ACCESS_KEY_ID         = getEnv("S3_ACCESS_KEY_ID", "")
+	S3_SECRET_ACCESS_KEY     = getEnv("S3_SECRET_ACCESS_KEY", "")
+	ATTACHMENT_MAX_SIZE      = getEnvInt("ATTACHMENT_MAX_SIZE", "10485760")
+	ATTACHMENT_ALLOWED_TYPES = getEnvList("ATTACHMENT_ALLOWED_TYPES", []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"})
+
+	// Deleted todos stay in the trash for TRASH_RETENTION and are purged by a background job running every
+	// TRASH_PURGE_INTERVAL, a zero interval disables the job
+	TRASH_RETENTION      = getEnvTimeDurationParse("TRASH_RETENTION", "720h")
+	TRASH_PURGE_INTERVAL = getEnvTimeDurationParse("TRASH_PURGE_INTERVAL", "1h")
+
+	// Completed todos are archived by the auto-archive rules of the accounts every AUTO_ARCHIVE_INTERVAL, a zero
+	// interval disables it
+	AUTO_ARCHIVE_INTERVAL = getEnvTimeDurationParse("AUTO_ARCHIVE_INTERVAL", "1h")
+
+	// Todos with open blockers can't be completed if DEPENDENCIES_BLOCK_COMPLETION is set, otherwise they are
+	// only flagged as blocked
+	DEPENDENCIES_BLOCK_COMPLETION = getEnvBool("DEPENDENCIES_BLOCK_COMPLETION", "false")
+
+	// Spaces whose manual order has positions longer than POSITION_REBALANCE_LENGTH are rebalanced every
+	// POSITION_REBALANCE_INTERVAL, a zero interval disables it
+	POSITION_REBALANCE_LENGTH   = getEnvInt("POSITION_REBALANCE_LENGTH", "16")
+	POSITION_REBALANCE_INTERVAL = getEnvTimeDurationParse("POSITION_REBALANCE_INTERVAL", "1h")
+
 	// Testing environment variables
 	IS_TEST               = getEnvBool("IS_TEST", "false")
 	TEST_DB_HOST          = getEnv("TEST_DB_HOST", "localhost")
@@ -83,6 +198,22 @@ func getEnvBool(name string, fallback string) bool {
 	return value == "true" || value == "1"
 }
 
+func getEnvInt(name string, fallback string) int {
+	value := getEnv(name, fallback)
+
+	if value == "" {
+		return 0
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Println("Error parsing int for environment variable: "+name, value, err)
+		return 0
+	}
+
+	return parsed
+}
+
 func getEnvList(name string, fallback []string) []string {
 	value := getEnv(name, "")
 
@@ -21,9 +21,9 @@ func main() {
 
 	switch dbType {
 	case "mysql":
-		stmts, err = gormschema.New("mysql").Load(&model.Account{}, &model.Todo{})
+		stmts, err = gormschema.New("mysql").Load(&model.Account{}, &model.Todo{}, &model.WebAuthnCredential{}, &model.WebAuthnSession{}, &model.LoginAttempt{}, &model.OIDCIdentity{}, &model.OIDCSession{}, &model.OAuthClient{}, &model.OAuthAuthorizationCode{}, &model.OAuthToken{}, &model.Invitation{}, &model.MagicLink{}, &model.Organization{}, &model.Membership{}, &model.TodoShare{}, &model.ShareLink{}, &model.ShareLinkAttempt{}, &model.TodoHistory{}, &model.Notification{}, &model.Comment{}, &model.Attachment{}, &model.Status{}, &model.StatusTransition{}, &model.TodoDependency{}, &model.TimeEntry{}, &model.TodoTemplate{}, &model.TodoTemplateItem{})
 	case "sqlite":
-		stmts, err = gormschema.New("sqlite").Load(&model.Account{}, &model.Todo{})
+		stmts, err = gormschema.New("sqlite").Load(&model.Account{}, &model.Todo{}, &model.WebAuthnCredential{}, &model.WebAuthnSession{}, &model.LoginAttempt{}, &model.OIDCIdentity{}, &model.OIDCSession{}, &model.OAuthClient{}, &model.OAuthAuthorizationCode{}, &model.OAuthToken{}, &model.Invitation{}, &model.MagicLink{}, &model.Organization{}, &model.Membership{}, &model.TodoShare{}, &model.ShareLink{}, &model.ShareLinkAttempt{}, &model.TodoHistory{}, &model.Notification{}, &model.Comment{}, &model.Attachment{}, &model.Status{}, &model.StatusTransition{}, &model.TodoDependency{}, &model.TimeEntry{}, &model.TodoTemplate{}, &model.TodoTemplateItem{})
 	default:
 		fmt.Fprintf(os.Stderr, "unsupported database type: %s. Supported types: mysql, sqlite\n", dbType)
 		os.Exit(1)
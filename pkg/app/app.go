@@ -1,9 +1,15 @@
 package app
 
 import (
+	"context"
+
+	"github.com/nleiva/go-todo-api/config"
 	"github.com/nleiva/go-todo-api/pkg/app/handler"
 	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/blobstore"
 	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/pkg/mailer"
+	"github.com/nleiva/go-todo-api/pkg/middleware"
 	"gorm.io/gorm"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,6 +22,8 @@ func New(db *gorm.DB) *fiber.App {
 
 	app := fiber.New(fiber.Config{
 		ErrorHandler: ErrorHandler,
+		// Leave room for the multipart encoding around the largest attachment
+		BodyLimit: max(config.ATTACHMENT_MAX_SIZE+64*1024, fiber.DefaultBodyLimit),
 	})
 
 	app.Use(logger.New())
@@ -25,11 +33,74 @@ func New(db *gorm.DB) *fiber.App {
 
 	as := service.NewAccountService(db)
 	ts := service.NewTodoService(db)
+	ws := service.NewWebAuthnService(db)
+	ls := service.NewLoginAttemptService(db)
+	oidcs := service.NewOIDCService(db)
+	oas := service.NewOAuthService(db)
+	is := service.NewInvitationService(db)
+	ms := service.NewMagicLinkService(db, mailer.Configured())
+	ors := service.NewOrganizationService(db)
+	ss := service.NewShareService(db)
+	sls := service.NewShareLinkService(db)
+	ns := service.NewNotificationService(db)
+	cs := service.NewCommentService(db)
+	ats := service.NewAttachmentService(db, blobstore.Configured())
+	sts := service.NewStatusService(db)
+	ds := service.NewDependencyService(db)
+	tes := service.NewTimeEntryService(db)
+	tps := service.NewTemplateService(db)
+
+	// Revoked OAuth access tokens are rejected by the Protected middleware
+	middleware.TokenRevoked = oas.IsTokenRevoked
+
+	// Accounts asserted by the authenticating proxy, only used when PROXY_AUTH_ENABLED is set
+	middleware.ProxyAccount = func(email string) (*jwt.TokenPayload, error) {
+		account, err := as.FindOrProvisionAccount(email, config.PROXY_AUTH_AUTO_PROVISION)
+		if err != nil {
+			return nil, err
+		}
+		return jwt.ProxyPayload(account), nil
+	}
 
-	h := handler.NewHandler(db, as, ts)
+	h := handler.NewHandler(db, handler.Services{
+		Account:      as,
+		Todo:         ts,
+		WebAuthn:     ws,
+		LoginAttempt: ls,
+		OIDC:         oidcs,
+		OAuth:        oas,
+		Invitation:   is,
+		MagicLink:    ms,
+		Organization: ors,
+		Share:        ss,
+		ShareLink:    sls,
+		Notification: ns,
+		Comment:      cs,
+		Attachment:   ats,
+		Status:       sts,
+		Dependency:   ds,
+		TimeEntry:    tes,
+		Template:     tps,
+	})
 
 	h.RegisterRoutes(app)
 
+	// Background jobs stop when the app shuts down
+	ctx, cancel := context.WithCancel(context.Background())
+	app.Hooks().OnShutdown(func() error {
+		cancel()
+		return nil
+	})
+	if config.TRASH_PURGE_INTERVAL > 0 {
+		go every(ctx, "trash purge", config.TRASH_PURGE_INTERVAL, purgeTrash(ts, ats))
+	}
+	if config.AUTO_ARCHIVE_INTERVAL > 0 {
+		go every(ctx, "auto-archive", config.AUTO_ARCHIVE_INTERVAL, autoArchive(ts))
+	}
+	if config.POSITION_REBALANCE_INTERVAL > 0 {
+		go every(ctx, "position rebalance", config.POSITION_REBALANCE_INTERVAL, rebalancePositions(ts))
+	}
+
 	return app
 }
 
@@ -6,6 +6,7 @@ import (
 
 	"github.com/nleiva/go-todo-api/pkg/app/model"
 	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/app/types/pagination"
 	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
 	"github.com/nleiva/go-todo-api/pkg/permission"
 	"github.com/nleiva/go-todo-api/utils"
@@ -82,3 +83,37 @@ func (h *Handler) GetAccount(c *fiber.Ctx) error {
 		Account: *account,
 	})
 }
+
+// GetLoginAttempts   godoc
+//
+//	@Summary		List failed login attempts
+//	@Description	Failed password logins (newest first) including the ones rejected by the throttle
+//	@Tags			accounts
+//	@Accept			json
+//	@Param			meta	query	pagination.QueryParams	false	"Pagination Query Parameters"
+//	@Produce		json
+//	@Success		200	{object}	types.GetLoginAttemptsResponse
+//	@Security		BearerAuth
+//	@Router			/accounts/login-attempts [get]
+func (h *Handler) GetLoginAttempts(c *fiber.Ctx) error {
+	var attempts = &[]model.LoginAttempt{}
+	var meta = locals.Meta(c)
+
+	if !locals.Can(c, permission.ACCOUNTS_MANAGE_ALL|permission.ACCOUNTS_READ_ALL) {
+		return &utils.FORBIDDEN
+	}
+
+	meta.Order = append(meta.Order, pagination.OrderEntry{
+		Key:       "created_at",
+		Direction: "desc",
+	})
+
+	if err := h.FindWithMeta(attempts, &model.LoginAttempt{}, meta, h.db.Where("success = ?", false)).Error; err != nil {
+		return statusError(err)
+	}
+
+	return c.JSON(&types.GetLoginAttemptsResponse{
+		LoginAttempts: *attempts,
+		Meta:          *meta,
+	})
+}
@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+// withArchivedFilter narrows todo queries by the archived query param: archived todos are hidden unless it is
+// "true" (only archived todos) or "all"
+func withArchivedFilter(c *fiber.Ctx, where *gorm.DB) (*gorm.DB, error) {
+	switch c.Query("archived") {
+	case "", "false":
+		return where.Where("todos.archived_at IS NULL"), nil
+	case "true":
+		return where.Where("todos.archived_at IS NOT NULL"), nil
+	case "all":
+		return where, nil
+	default:
+		return nil, utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "archived must be true, false or all")
+	}
+}
+
+// setArchived archives or unarchives a todo the account can edit
+func (h *Handler) setArchived(c *fiber.Ctx, todo *model.Todo, archived bool) error {
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	if archived == todo.ArchivedAt.Valid {
+		return nil
+	}
+
+	actorID := locals.JwtPayload(c).AccountID
+	if archived {
+		if err := h.todoService.ArchiveTodo(todo, actorID); err != nil {
+			return &utils.INTERNAL_SERVER_ERROR
+		}
+		return nil
+	}
+
+	if err := h.todoService.UnarchiveTodo(todo, actorID); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return nil
+}
+
+// archiveCompleted archives all completed todos of the current space
+func (h *Handler) archiveCompleted(c *fiber.Ctx) (int64, error) {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return 0, err
+	}
+
+	archived, err := h.todoService.ArchiveCompleted(space, locals.JwtPayload(c).AccountID)
+	if err != nil {
+		return 0, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return archived, nil
+}
+
+// updateAutoArchive stores the auto-archive rule of the account
+func (h *Handler) updateAutoArchive(c *fiber.Ctx, days uint) error {
+	account := &model.Account{}
+	if err := h.accountService.FindAccountByID(account, locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if err := h.accountService.UpdateAutoArchive(account, days).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return nil
+}
+
+// ArchiveTodo godoc
+//
+//	@Summary		Archive todo
+//	@Description	Hides a todo from the default lists without completing or deleting it, needs the editor role
+//	@Tags			archive
+//	@Produce		json
+//	@Param			id	path		int	true	"Todo ID"
+//	@Success		200	{object}	types.UpdateTodoResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/archive [post]
+func (h *Handler) ArchiveTodo(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.setArchived(c, todo, true); err != nil {
+		return err
+	}
+
+	return c.JSON(&types.UpdateTodoResponse{
+		Todo: *todo,
+	})
+}
+
+// UnarchiveTodo godoc
+//
+//	@Summary		Unarchive todo
+//	@Description	Takes a todo out of the archive, needs the editor role
+//	@Tags			archive
+//	@Produce		json
+//	@Param			id	path		int	true	"Todo ID"
+//	@Success		200	{object}	types.UpdateTodoResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/unarchive [post]
+func (h *Handler) UnarchiveTodo(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.setArchived(c, todo, false); err != nil {
+		return err
+	}
+
+	return c.JSON(&types.UpdateTodoResponse{
+		Todo: *todo,
+	})
+}
+
+// ArchiveCompletedTodos godoc
+//
+//	@Summary		Archive completed todos
+//	@Description	Archives all completed todos of the current space
+//	@Tags			archive
+//	@Produce		json
+//	@Success		200	{object}	types.ArchiveCompletedResponse
+//	@Security		BearerAuth
+//	@Router			/todos/archive-completed [post]
+func (h *Handler) ArchiveCompletedTodos(c *fiber.Ctx) error {
+	archived, err := h.archiveCompleted(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&types.ArchiveCompletedResponse{
+		Archived: archived,
+	})
+}
+
+// GetAutoArchive godoc
+//
+//	@Summary		Get auto-archive rule
+//	@Description	Returns after how many days completed todos created by the account are archived, 0 means never
+//	@Tags			archive
+//	@Produce		json
+//	@Success		200	{object}	types.AutoArchiveResponse
+//	@Security		BearerAuth
+//	@Router			/todos/auto-archive [get]
+func (h *Handler) GetAutoArchive(c *fiber.Ctx) error {
+	account := &model.Account{}
+	if err := h.accountService.FindAccountByID(account, locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.AutoArchiveResponse{
+		Days: account.AutoArchiveDays,
+	})
+}
+
+// UpdateAutoArchive godoc
+//
+//	@Summary		Update auto-archive rule
+//	@Description	Archives completed todos created by the account the given number of days after completion, 0 turns it off. The rule is applied periodically.
+//	@Tags			archive
+//	@Accept			json
+//	@Produce		json
+//	@Param			rule	body		types.AutoArchiveDTO	true	"Rule"
+//	@Success		200		{object}	types.AutoArchiveResponse
+//	@Security		BearerAuth
+//	@Router			/todos/auto-archive [put]
+func (h *Handler) UpdateAutoArchive(c *fiber.Ctx) error {
+	remoteData := &types.AutoArchiveDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	if err := h.updateAutoArchive(c, remoteData.Days); err != nil {
+		return err
+	}
+
+	return c.JSON(&types.AutoArchiveResponse{
+		Days: remoteData.Days,
+	})
+}
+
+// VTodosArchive archives a todo and removes it from the list
+func (h *Handler) VTodosArchive(c *fiber.Ctx) error {
+	if err := h.setArchived(c, &model.Todo{}, true); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).SendString("")
+}
+
+// VTodosUnarchive takes a todo out of the archive and removes it from the archived list
+func (h *Handler) VTodosUnarchive(c *fiber.Ctx) error {
+	if err := h.setArchived(c, &model.Todo{}, false); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).SendString("")
+}
+
+// VTodosArchiveCompleted archives the completed todos and reloads the page
+func (h *Handler) VTodosArchiveCompleted(c *fiber.Ctx) error {
+	if _, err := h.archiveCompleted(c); err != nil {
+		return err
+	}
+
+	c.Set("HX-Refresh", "true")
+
+	return c.Status(http.StatusOK).SendString("")
+}
+
+func (h *Handler) VProfileAutoArchivePut(c *fiber.Ctx) error {
+	remoteData := &types.AutoArchiveDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return renderFormErrors(c, "#auto-archive-result", []string{"Enter a number of days between 0 and 3650."})
+	}
+
+	if err := h.updateAutoArchive(c, remoteData.Days); err != nil {
+		return err
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.AutoArchiveSection(remoteData.Days, true)))(c)
+}
@@ -0,0 +1,168 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestArchiveHandlerWorkflow(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "archive.owner@turbomeet.xyz", Password: pw}
+	viewer := &model.Account{Email: "archive.viewer@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+	accountService.CreateAccount(viewer)
+
+	ownerAuth, _ := jwt.Generate(owner)
+	viewerAuth, _ := jwt.Generate(viewer)
+
+	todoService := service.NewTodoService(DB)
+	space := model.Space{AccountID: owner.ID}
+	done := &model.Todo{Title: zero.StringFrom("Send the invoice"), Completed: true}
+	alsoDone := &model.Todo{Title: zero.StringFrom("Call the bank"), Completed: true}
+	open := &model.Todo{Title: zero.StringFrom("Plan the next sprint")}
+	todoService.CreateTodo(done, space)
+	todoService.CreateTodo(alsoDone, space)
+	todoService.CreateTodo(open, space)
+	service.NewShareService(DB).ShareTodo(&model.TodoShare{TodoID: done.ID, AccountID: viewer.ID, Role: model.SHARE_ROLE_VIEWER, SharedByID: owner.ID})
+
+	list := func(query string) []model.Todo {
+		res := send("GET", "/api/todos"+query, ownerAuth.Token, nil)
+		response := &types.GetTodosResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		return response.Todos
+	}
+
+	todoPath := func(todo *model.Todo) string {
+		return "/api/todos/" + strconv.FormatUint(uint64(todo.ID), 10)
+	}
+
+	t.Run("should hide archived todos unless asked for", func(t *testing.T) {
+		if res := send("POST", todoPath(done)+"/archive", viewerAuth.Token, nil); res.StatusCode != 403 {
+			t.Errorf("Expected viewers to be rejected with 403, got %d", res.StatusCode)
+		}
+
+		res := send("POST", todoPath(done)+"/archive", ownerAuth.Token, nil)
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		response := &types.UpdateTodoResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if !response.Todo.ArchivedAt.Valid || !response.Todo.Completed {
+			t.Errorf("Expected the todo to be archived and still completed, got %+v", response.Todo)
+		}
+
+		if todos := list(""); len(todos) != 2 {
+			t.Errorf("Expected 2 todos by default, got %d", len(todos))
+		}
+		if todos := list("?archived=true"); len(todos) != 1 || todos[0].ID != done.ID {
+			t.Errorf("Expected only the archived todo, got %+v", todos)
+		}
+		if todos := list("?archived=all"); len(todos) != 3 {
+			t.Errorf("Expected all 3 todos, got %d", len(todos))
+		}
+		if res := send("GET", "/api/todos?archived=maybe", ownerAuth.Token, nil); res.StatusCode != 400 {
+			t.Errorf("Expected unknown values to fail with 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should unarchive and record both in the history", func(t *testing.T) {
+		if res := send("POST", todoPath(done)+"/unarchive", ownerAuth.Token, nil); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if todos := list(""); len(todos) != 3 {
+			t.Errorf("Expected 3 todos, got %d", len(todos))
+		}
+
+		history := []model.TodoHistory{}
+		todoService.FindHistory(&history, done.ID)
+		actions := []string{}
+		for i, entry := range history {
+			if i == 0 || history[i-1].Revision != entry.Revision {
+				actions = append(actions, entry.Action)
+			}
+		}
+		if got := strings.Join(actions, ","); got != "created,archived,unarchived" {
+			t.Errorf("Expected the archive and unarchive revisions, got %s", got)
+		}
+	})
+
+	t.Run("should archive all completed todos", func(t *testing.T) {
+		res := send("POST", "/api/todos/archive-completed", ownerAuth.Token, nil)
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		response := &types.ArchiveCompletedResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if response.Archived != 2 {
+			t.Errorf("Expected 2 archived todos, got %d", response.Archived)
+		}
+
+		if todos := list(""); len(todos) != 1 || todos[0].ID != open.ID {
+			t.Errorf("Expected only the open todo, got %+v", todos)
+		}
+	})
+
+	t.Run("should auto-archive by the rule of the account", func(t *testing.T) {
+		if res := send("PUT", "/api/todos/auto-archive", ownerAuth.Token, &types.AutoArchiveDTO{Days: 7}); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		res := send("GET", "/api/todos/auto-archive", ownerAuth.Token, nil)
+		response := &types.AutoArchiveResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if response.Days != 7 {
+			t.Errorf("Expected the rule to be 7 days, got %d", response.Days)
+		}
+
+		old := &model.Todo{Title: zero.StringFrom("Old"), Completed: true, CompletedAt: null.TimeFrom(time.Now().AddDate(0, 0, -10))}
+		recent := &model.Todo{Title: zero.StringFrom("Recent"), Completed: true, CompletedAt: null.TimeFrom(time.Now().AddDate(0, 0, -1))}
+		otherOld := &model.Todo{Title: zero.StringFrom("Other"), Completed: true, CompletedAt: null.TimeFrom(time.Now().AddDate(0, 0, -10))}
+		todoService.CreateTodo(old, space)
+		todoService.CreateTodo(recent, space)
+		todoService.CreateTodo(otherOld, model.Space{AccountID: viewer.ID})
+
+		archived, err := todoService.AutoArchive(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if archived != 1 {
+			t.Errorf("Expected 1 auto-archived todo, got %d", archived)
+		}
+		DB.Take(old, old.ID)
+		if !old.ArchivedAt.Valid {
+			t.Errorf("Expected the old todo to be archived")
+		}
+	})
+
+	t.Run("should archive from the views", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/todos/"+strconv.FormatUint(uint64(open.ID), 10)+"/archive", nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: ownerAuth.Token})
+		if res, _ := App.Test(req, -1); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		req, _ = http.NewRequest("GET", "/todos?archived=true", nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: ownerAuth.Token})
+		res, _ := App.Test(req, -1)
+		body, _ := io.ReadAll(res.Body)
+		if !strings.Contains(string(body), "Plan the next sprint") || !strings.Contains(string(body), "/unarchive") {
+			t.Errorf("Expected the archived todo with an unarchive button, got %s", body)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+// withAssigneeFilter narrows todo queries by the assignee query param: "me", "none" or an account id
+func withAssigneeFilter(c *fiber.Ctx, where *gorm.DB) (*gorm.DB, error) {
+	switch assignee := c.Query("assignee"); assignee {
+	case "":
+		return where, nil
+	case "me":
+		return where.Where("todos.assignee_id = ?", locals.JwtPayload(c).AccountID), nil
+	case "none":
+		return where.Where("todos.assignee_id IS NULL"), nil
+	default:
+		id, err := strconv.ParseUint(assignee, 10, 64)
+		if err != nil {
+			return nil, utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "assignee must be me, none or an account id")
+		}
+		return where.Where("todos.assignee_id = ?", id), nil
+	}
+}
+
+// AssignTodo godoc
+//
+//	@Summary		Assign todo
+//	@Description	Assigns a todo to an account with access to it or unassigns it with null. Needs the editor role for the todo.
+//	@Tags			todos
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"Todo ID"
+//	@Param			assignee	body		types.AssignTodoDTO	true	"Assignee"
+//	@Success		200			{object}	types.UpdateTodoResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/assignee [put]
+func (h *Handler) AssignTodo(c *fiber.Ctx) error {
+	remoteData := &types.AssignTodoDTO{}
+
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	if err := h.assignTodo(c, todo, remoteData.AssigneeID); err != nil {
+		return err
+	}
+
+	return c.JSON(&types.UpdateTodoResponse{
+		Todo: *todo,
+	})
+}
+
+// assignTodo changes the assignee, records it in the history and notifies the new and the previous assignee
+func (h *Handler) assignTodo(c *fiber.Ctx, todo *model.Todo, assigneeID *uint) error {
+	previousID := todo.AssigneeID
+	if (previousID == nil && assigneeID == nil) || (previousID != nil && assigneeID != nil && *previousID == *assigneeID) {
+		return nil
+	}
+
+	if assigneeID != nil {
+		ok, err := h.todoService.CanAccess(todo, *assigneeID)
+		if err != nil {
+			return &utils.INTERNAL_SERVER_ERROR
+		}
+		if !ok {
+			return &utils.ASSIGNEE_INVALID
+		}
+	}
+
+	actorID := locals.JwtPayload(c).AccountID
+	if err := h.todoService.AssignTodo(todo, assigneeID, actorID); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	actor := &model.Account{}
+	if err := h.accountService.FindAccountByID(actor, actorID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	// The assignment is stored already, failed notifications don't fail it
+	if assigneeID != nil {
+		h.notify(&model.Notification{
+			AccountID: *assigneeID,
+			ActorID:   actorID,
+			TodoID:    &todo.ID,
+			Kind:      model.NOTIFICATION_ASSIGNED,
+			Message:   fmt.Sprintf("%s assigned you to “%s”", actor.Email, todo.Title.String),
+		})
+	}
+	if previousID != nil {
+		h.notify(&model.Notification{
+			AccountID: *previousID,
+			ActorID:   actorID,
+			TodoID:    &todo.ID,
+			Kind:      model.NOTIFICATION_UNASSIGNED,
+			Message:   fmt.Sprintf("%s unassigned you from “%s”", actor.Email, todo.Title.String),
+		})
+	}
+
+	return nil
+}
+
+// GetAssignedTodos godoc
+//
+//	@Summary		List assigned todos
+//	@Description	Lists the todos assigned to the account across all spaces and shares
+//	@Tags			todos
+//	@Produce		json
+//	@Success		200	{object}	types.GetAssignedTodosResponse
+//	@Security		BearerAuth
+//	@Router			/todos/assigned [get]
+func (h *Handler) GetAssignedTodos(c *fiber.Ctx) error {
+	todos := []model.Todo{}
+
+	if err := h.todoService.FindAssignedTodos(&todos, locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetAssignedTodosResponse{
+		Todos: todos,
+	})
+}
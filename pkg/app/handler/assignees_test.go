@@ -0,0 +1,150 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/pkg/app/handler"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestAssigneesHandlerWorkflow(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	lead := &model.Account{Email: "assignees.lead@turbomeet.xyz", Password: pw}
+	member := &model.Account{Email: "assignees.member@turbomeet.xyz", Password: pw}
+	outsider := &model.Account{Email: "assignees.outsider@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(lead)
+	accountService.CreateAccount(member)
+	accountService.CreateAccount(outsider)
+
+	leadAuth, _ := jwt.Generate(lead)
+	memberAuth, _ := jwt.Generate(member)
+	outsiderAuth, _ := jwt.Generate(outsider)
+
+	organization := &model.Organization{Name: "Assignees"}
+	organizationService := service.NewOrganizationService(DB)
+	organizationService.CreateOrganization(organization, lead.ID)
+	organizationService.AddMember(&model.Membership{OrganizationID: organization.ID, AccountID: member.ID, Role: model.ORGANIZATION_ROLE_MEMBER})
+	organizationHeader := strconv.FormatUint(uint64(organization.ID), 10)
+
+	todo := &model.Todo{Title: zero.StringFrom("Prepare release")}
+	service.NewTodoService(DB).CreateTodo(todo, model.Space{AccountID: lead.ID, OrganizationID: organization.ID})
+	todoPath := "/api/todos/" + strconv.FormatUint(uint64(todo.ID), 10)
+
+	inOrganization := map[string]string{handler.ORGANIZATION_HEADER: organizationHeader}
+
+	notifications := func(token string) []model.Notification {
+		res := sendWithHeader("GET", "/api/notifications?unread=true", token, nil, inOrganization)
+		response := &types.GetNotificationsResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		return response.Notifications
+	}
+
+	t.Run("should only assign accounts with access", func(t *testing.T) {
+		if res := sendWithHeader("PUT", todoPath+"/assignee", leadAuth.Token, &types.AssignTodoDTO{AssigneeID: &outsider.ID}, inOrganization); res.StatusCode != 400 {
+			t.Errorf("Expected status code 400, got %d", res.StatusCode)
+		}
+
+		res := sendWithHeader("PUT", todoPath+"/assignee", leadAuth.Token, &types.AssignTodoDTO{AssigneeID: &member.ID}, inOrganization)
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		response := &types.UpdateTodoResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if response.Todo.AssigneeID == nil || *response.Todo.AssigneeID != member.ID {
+			t.Errorf("Expected the member to be assigned, got %v", response.Todo.AssigneeID)
+		}
+
+		if unread := notifications(memberAuth.Token); len(unread) != 1 || unread[0].Kind != model.NOTIFICATION_ASSIGNED {
+			t.Errorf("Expected an assignment notification, got %v", unread)
+		}
+	})
+
+	t.Run("should filter and list the todos assigned to the account", func(t *testing.T) {
+		res := sendWithHeader("GET", "/api/todos?assignee=me", memberAuth.Token, nil, inOrganization)
+		response := &types.GetTodosResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if len(response.Todos) != 1 {
+			t.Errorf("Expected 1 todo assigned to the member, got %d", len(response.Todos))
+		}
+
+		res = sendWithHeader("GET", "/api/todos?assignee=me", leadAuth.Token, nil, inOrganization)
+		response = &types.GetTodosResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if len(response.Todos) != 0 {
+			t.Errorf("Expected no todos assigned to the lead, got %d", len(response.Todos))
+		}
+
+		if res := sendWithHeader("GET", "/api/todos?assignee=someone", leadAuth.Token, nil, inOrganization); res.StatusCode != 400 {
+			t.Errorf("Expected status code 400 for unknown assignee filters, got %d", res.StatusCode)
+		}
+
+		req, _ := http.NewRequest("GET", "/todos/assigned", nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: memberAuth.Token})
+		page, _ := App.Test(req, -1)
+		body, _ := io.ReadAll(page.Body)
+		if !strings.Contains(string(body), "Prepare release") {
+			t.Errorf("Expected the assigned todo on the page")
+		}
+	})
+
+	t.Run("should record reassignments in the history", func(t *testing.T) {
+		if res := sendWithHeader("PUT", todoPath+"/assignee", memberAuth.Token, &types.AssignTodoDTO{AssigneeID: &lead.ID}, inOrganization); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		if unread := notifications(memberAuth.Token); len(unread) != 1 {
+			t.Errorf("Expected no notification about the own action, got %d", len(unread))
+		}
+		if unread := notifications(leadAuth.Token); len(unread) != 1 || unread[0].Kind != model.NOTIFICATION_ASSIGNED {
+			t.Errorf("Expected the lead to be notified, got %v", unread)
+		}
+
+		res := sendWithHeader("GET", todoPath+"/history", leadAuth.Token, nil, inOrganization)
+		response := &types.GetTodoHistoryResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		assignments := []model.TodoHistory{}
+		for _, entry := range response.History {
+			if entry.Action == model.TODO_HISTORY_ASSIGNED {
+				assignments = append(assignments, entry)
+			}
+		}
+		if len(assignments) != 2 {
+			t.Fatalf("Expected 2 assignment entries, got %d", len(assignments))
+		}
+		last := assignments[1]
+		if last.ActorID != member.ID || last.From.String != strconv.FormatUint(uint64(member.ID), 10) || last.To.String != strconv.FormatUint(uint64(lead.ID), 10) {
+			t.Errorf("Expected the reassignment from the member to the lead, got %+v", last)
+		}
+
+		if res := sendWithHeader("GET", todoPath+"/history", outsiderAuth.Token, nil, inOrganization); res.StatusCode != 403 {
+			t.Errorf("Expected outsiders to be rejected with 403, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should unassign and mark notifications read", func(t *testing.T) {
+		if res := sendWithHeader("PUT", todoPath+"/assignee", leadAuth.Token, &types.AssignTodoDTO{}, inOrganization); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		if res := sendWithHeader("PUT", "/api/notifications/read", leadAuth.Token, nil, inOrganization); res.StatusCode != 204 {
+			t.Errorf("Expected status code 204, got %d", res.StatusCode)
+		}
+		if unread := notifications(leadAuth.Token); len(unread) != 0 {
+			t.Errorf("Expected no unread notifications, got %d", len(unread))
+		}
+	})
+}
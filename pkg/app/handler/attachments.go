@@ -0,0 +1,330 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+// findAttachment finds an attachment of a todo the account has at least the required role for
+func (h *Handler) findAttachment(c *fiber.Ctx, todo *model.Todo, attachment *model.Attachment, required string) error {
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), required); err != nil {
+		return err
+	}
+
+	if err := h.attachmentService.FindAttachment(attachment, c.Params("attachmentId"), todo.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &utils.NOT_FOUND
+		}
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return nil
+}
+
+// uploadAttachment stores the multipart file of the request. The content type is detected from the content, the
+// one sent by the client isn't trusted.
+func (h *Handler) uploadAttachment(c *fiber.Ctx, todo *model.Todo) (*model.Attachment, error) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return nil, utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "file is required")
+	}
+
+	if file.Size > int64(config.ATTACHMENT_MAX_SIZE) {
+		return nil, &utils.ATTACHMENT_TOO_LARGE
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, &utils.INTERNAL_SERVER_ERROR
+	}
+	defer src.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if !slices.Contains(config.ATTACHMENT_ALLOWED_TYPES, contentType) {
+		return nil, &utils.ATTACHMENT_TYPE_NOT_ALLOWED
+	}
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	attachment := &model.Attachment{
+		TodoID:      todo.ID,
+		AccountID:   locals.JwtPayload(c).AccountID,
+		Filename:    attachmentFilename(file.Filename),
+		ContentType: contentType,
+	}
+	if err := h.attachmentService.CreateAttachment(c.Context(), attachment, src); err != nil {
+		return nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return attachment, nil
+}
+
+// attachmentFilename keeps the base name of an uploaded file without control characters
+func attachmentFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+
+	if name == "." || name == "/" || name == "" {
+		name = "file"
+	}
+	if len(name) > 255 {
+		name = name[len(name)-255:]
+	}
+
+	return name
+}
+
+// parseRange parses a single range of the Range header. Missing, malformed and multiple ranges return the
+// whole content, ranges starting after the end are not satisfiable.
+func parseRange(header string, size int64) (offset int64, length int64, partial bool, err error) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, size, false, nil
+	}
+
+	startText, endText, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, size, false, nil
+	}
+
+	if startText == "" {
+		// Suffix range with the last bytes
+		suffix, err := strconv.ParseInt(endText, 10, 64)
+		if err != nil || suffix < 0 {
+			return 0, size, false, nil
+		}
+		if suffix == 0 || size == 0 {
+			return 0, 0, false, &utils.RANGE_NOT_SATISFIABLE
+		}
+		suffix = min(suffix, size)
+		return size - suffix, suffix, true, nil
+	}
+
+	start, err := strconv.ParseInt(startText, 10, 64)
+	if err != nil || start < 0 {
+		return 0, size, false, nil
+	}
+	if start >= size {
+		return 0, 0, false, &utils.RANGE_NOT_SATISFIABLE
+	}
+
+	end := size - 1
+	if endText != "" {
+		end, err = strconv.ParseInt(endText, 10, 64)
+		if err != nil || end < start {
+			return 0, size, false, nil
+		}
+		end = min(end, size-1)
+	}
+
+	return start, end - start + 1, true, nil
+}
+
+// GetAttachments godoc
+//
+//	@Summary		List attachments
+//	@Description	Lists the attachments of a todo
+//	@Tags			attachments
+//	@Produce		json
+//	@Param			id	path		int	true	"Todo ID"
+//	@Success		200	{object}	types.GetAttachmentsResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/attachments [get]
+func (h *Handler) GetAttachments(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_VIEWER); err != nil {
+		return err
+	}
+
+	attachments := []model.Attachment{}
+	if err := h.attachmentService.FindAttachments(&attachments, todo.ID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetAttachmentsResponse{
+		Attachments: attachments,
+	})
+}
+
+// CreateAttachment godoc
+//
+//	@Summary		Upload attachment
+//	@Description	Attaches a file to a todo, needs the editor role. Size and content type are limited by ATTACHMENT_MAX_SIZE and ATTACHMENT_ALLOWED_TYPES.
+//	@Tags			attachments
+//	@Accept			mpfd
+//	@Produce		json
+//	@Param			id		path		int		true	"Todo ID"
+//	@Param			file	formData	file	true	"File"
+//	@Success		201		{object}	types.AttachmentResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/attachments [post]
+func (h *Handler) CreateAttachment(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	attachment, err := h.uploadAttachment(c, todo)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&types.AttachmentResponse{
+		Attachment: *attachment,
+	})
+}
+
+// DownloadAttachment godoc
+//
+//	@Summary		Download attachment
+//	@Description	Returns the content of an attachment, single byte ranges are supported. Images and PDFs are shown inline unless download is set.
+//	@Tags			attachments
+//	@Produce		octet-stream
+//	@Param			id				path		int		true	"Todo ID"
+//	@Param			attachmentId	path		int		true	"Attachment ID"
+//	@Param			download		query		bool	false	"Always download"
+//	@Success		200				{file}		file
+//	@Success		206				{file}		file
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/attachments/{attachmentId} [get]
+func (h *Handler) DownloadAttachment(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	attachment := &model.Attachment{}
+	if err := h.findAttachment(c, todo, attachment, model.SHARE_ROLE_VIEWER); err != nil {
+		return err
+	}
+
+	offset, length, partial, err := parseRange(c.Get(fiber.HeaderRange), attachment.Size)
+	if err != nil {
+		c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", attachment.Size))
+		return err
+	}
+
+	content, err := h.attachmentService.OpenAttachment(c.Context(), attachment, offset, length)
+	if err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	disposition := "attachment"
+	if !c.QueryBool("download") && (strings.HasPrefix(attachment.ContentType, "image/") || attachment.ContentType == "application/pdf") {
+		disposition = "inline"
+	}
+
+	c.Set(fiber.HeaderContentType, attachment.ContentType)
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	if partial {
+		c.Status(fiber.StatusPartialContent)
+		c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, attachment.Size))
+	}
+
+	return c.SendStream(content, int(length))
+}
+
+// DeleteAttachment godoc
+//
+//	@Summary		Delete attachment
+//	@Description	Deletes an attachment of a todo, needs the editor role
+//	@Tags			attachments
+//	@Param			id				path		int	true	"Todo ID"
+//	@Param			attachmentId	path		int	true	"Attachment ID"
+//	@Success		204				{object}	nil	"No Content"
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/attachments/{attachmentId} [delete]
+func (h *Handler) DeleteAttachment(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	attachment := &model.Attachment{}
+	if err := h.findAttachment(c, todo, attachment, model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	if err := h.attachmentService.DeleteAttachment(c.Context(), attachment); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// VAttachments renders the attachments panel of a todo item
+func (h *Handler) VAttachments(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	role, err := h.findTodoRole(c, todo, c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return h.renderAttachments(c, todo, model.ShareRoleAllows(role, model.SHARE_ROLE_EDITOR))
+}
+
+func (h *Handler) renderAttachments(c *fiber.Ctx, todo *model.Todo, canEdit bool) error {
+	attachments := []model.Attachment{}
+	if err := h.attachmentService.FindAttachments(&attachments, todo.ID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.AttachmentsPanel(todo.ID, attachments, canEdit)))(c)
+}
+
+// VAttachmentsCreate uploads a file from the panel and renders the panel again
+func (h *Handler) VAttachmentsCreate(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	if _, err := h.uploadAttachment(c, todo); err != nil {
+		var requestError *utils.RequestError
+		if errors.As(err, &requestError) && requestError.StatusCode != fiber.StatusInternalServerError {
+			return renderFormErrors(c, fmt.Sprintf("#attachment-errors-%d", todo.ID), []string{requestError.Message})
+		}
+		return err
+	}
+
+	return h.renderAttachments(c, todo, true)
+}
+
+func (h *Handler) VAttachmentsDelete(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	attachment := &model.Attachment{}
+	if err := h.findAttachment(c, todo, attachment, model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	if err := h.attachmentService.DeleteAttachment(c.Context(), attachment); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.Status(http.StatusOK).SendString("")
+}
@@ -0,0 +1,169 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestAttachmentsHandlerLifecycle(t *testing.T) {
+	// Setup
+	defer func(size int) { config.ATTACHMENT_MAX_SIZE = size }(config.ATTACHMENT_MAX_SIZE)
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "attachments.owner@turbomeet.xyz", Password: pw}
+	viewer := &model.Account{Email: "attachments.viewer@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+	accountService.CreateAccount(viewer)
+
+	ownerAuth, _ := jwt.Generate(owner)
+	viewerAuth, _ := jwt.Generate(viewer)
+
+	todoService := service.NewTodoService(DB)
+	first := &model.Todo{Title: zero.StringFrom("Fix the login screen")}
+	second := &model.Todo{Title: zero.StringFrom("Write release notes")}
+	todoService.CreateTodo(first, model.Space{AccountID: owner.ID})
+	todoService.CreateTodo(second, model.Space{AccountID: owner.ID})
+	service.NewShareService(DB).ShareTodo(&model.TodoShare{TodoID: first.ID, AccountID: viewer.ID, Role: model.SHARE_ROLE_VIEWER, SharedByID: owner.ID})
+
+	todoPath := func(todo *model.Todo) string {
+		return "/api/todos/" + strconv.FormatUint(uint64(todo.ID), 10)
+	}
+
+	upload := func(todo *model.Todo, token string, filename string, content []byte) *http.Response {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", filename)
+		part.Write(content)
+		writer.Close()
+
+		req, _ := http.NewRequest("POST", todoPath(todo)+"/attachments", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, _ := App.Test(req, -1)
+		return res
+	}
+
+	blobs := func() int {
+		files, _ := filepath.Glob(filepath.Join(config.BLOB_LOCAL_PATH, "*", "*"))
+		return len(files)
+	}
+
+	content := []byte("Steps to reproduce: open the login screen and wait.")
+	var attachment model.Attachment
+
+	t.Run("should upload with limits on size and type", func(t *testing.T) {
+		if res := upload(first, viewerAuth.Token, "notes.txt", content); res.StatusCode != 403 {
+			t.Errorf("Expected viewers to get 403, got %d", res.StatusCode)
+		}
+		if res := upload(first, ownerAuth.Token, "page.html", []byte("<html><script>alert(1)</script></html>")); res.StatusCode != 415 {
+			t.Errorf("Expected status code 415 for html, got %d", res.StatusCode)
+		}
+
+		config.ATTACHMENT_MAX_SIZE = 10
+		if res := upload(first, ownerAuth.Token, "notes.txt", content); res.StatusCode != 413 {
+			t.Errorf("Expected status code 413, got %d", res.StatusCode)
+		}
+		config.ATTACHMENT_MAX_SIZE = 1 << 20
+
+		res := upload(first, ownerAuth.Token, "../../notes.txt", content)
+		if res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+		response := &types.AttachmentResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		attachment = response.Attachment
+
+		if attachment.Filename != "notes.txt" || attachment.ContentType != "text/plain" || attachment.Size != int64(len(content)) || len(attachment.Hash) != 64 {
+			t.Errorf("Expected the stored file details, got %+v", attachment)
+		}
+	})
+
+	t.Run("should store the same content once", func(t *testing.T) {
+		if res := upload(second, ownerAuth.Token, "copy.txt", content); res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+		if n := blobs(); n != 1 {
+			t.Errorf("Expected 1 stored blob, got %d", n)
+		}
+	})
+
+	t.Run("should download with ranges", func(t *testing.T) {
+		path := todoPath(first) + "/attachments/" + strconv.FormatUint(uint64(attachment.ID), 10)
+
+		res := send("GET", path, viewerAuth.Token, nil)
+		body, _ := io.ReadAll(res.Body)
+		if res.StatusCode != 200 || !bytes.Equal(body, content) || res.Header.Get("Accept-Ranges") != "bytes" {
+			t.Errorf("Expected the full content, got %d %q", res.StatusCode, body)
+		}
+		if !strings.HasPrefix(res.Header.Get("Content-Disposition"), "attachment") {
+			t.Errorf("Expected text files to be downloaded, got %s", res.Header.Get("Content-Disposition"))
+		}
+
+		res = sendWithHeader("GET", path, viewerAuth.Token, nil, map[string]string{"Range": "bytes=0-4"})
+		body, _ = io.ReadAll(res.Body)
+		if res.StatusCode != 206 || string(body) != "Steps" || res.Header.Get("Content-Range") != "bytes 0-4/"+strconv.Itoa(len(content)) {
+			t.Errorf("Expected the first bytes, got %d %q %s", res.StatusCode, body, res.Header.Get("Content-Range"))
+		}
+
+		res = sendWithHeader("GET", path, viewerAuth.Token, nil, map[string]string{"Range": "bytes=-5"})
+		body, _ = io.ReadAll(res.Body)
+		if res.StatusCode != 206 || string(body) != "wait." {
+			t.Errorf("Expected the last bytes, got %d %q", res.StatusCode, body)
+		}
+
+		if res := sendWithHeader("GET", path, viewerAuth.Token, nil, map[string]string{"Range": "bytes=1000-"}); res.StatusCode != 416 {
+			t.Errorf("Expected status code 416, got %d", res.StatusCode)
+		}
+
+		otherTodo := todoPath(second) + "/attachments/" + strconv.FormatUint(uint64(attachment.ID), 10)
+		if res := send("GET", otherTodo, ownerAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected attachments of other todos to be not found, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should remove blobs with the last todo using them", func(t *testing.T) {
+		for _, todo := range []*model.Todo{first, second} {
+			if res := send("DELETE", todoPath(todo), ownerAuth.Token, nil); res.StatusCode != 204 {
+				t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+			}
+		}
+		if n := blobs(); n != 1 {
+			t.Errorf("Expected the blob to be kept while the todos are in the trash, got %d", n)
+		}
+
+		trashPath := func(todo *model.Todo) string {
+			return "/api/todos/trash/" + strconv.FormatUint(uint64(todo.ID), 10)
+		}
+
+		if res := send("DELETE", trashPath(first), ownerAuth.Token, nil); res.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+		}
+		if n := blobs(); n != 1 {
+			t.Errorf("Expected the blob to be kept for the second todo, got %d", n)
+		}
+
+		if res := send("DELETE", trashPath(second), ownerAuth.Token, nil); res.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+		}
+		if n := blobs(); n != 0 {
+			t.Errorf("Expected no blobs left, got %d", n)
+		}
+	})
+}
@@ -2,12 +2,18 @@ package handler
 
 import (
 	"errors"
+	"log"
+	"math"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/nleiva/go-todo-api/config"
 	"github.com/nleiva/go-todo-api/pkg/app/model"
 	"github.com/nleiva/go-todo-api/pkg/app/types"
 	"github.com/nleiva/go-todo-api/pkg/jwt"
 	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/password"
 	"github.com/nleiva/go-todo-api/pkg/permission"
 	"github.com/nleiva/go-todo-api/utils"
 	"gorm.io/gorm"
@@ -15,13 +21,14 @@ import (
 
 // Register      godoc
 //
-//	@Summary	Register
-//	@Tags		auth
-//	@Accept		json
-//	@Produce	json
-//	@Param		account	body		types.RegisterDTO	true	"Account"
-//	@Success	200		{object}	types.AuthResponse
-//	@Router		/auth/register [post]
+//	@Summary		Register
+//	@Description	Depends on REGISTRATION_MODE: open, invite-only (invitationCode required) or closed. Admins can always create accounts.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			account	body		types.RegisterDTO	true	"Account"
+//	@Success		200		{object}	types.AuthResponse
+//	@Router			/auth/register [post]
 func (h *Handler) Register(c *fiber.Ctx) error {
 	remoteData := &types.RegisterDTO{}
 
@@ -29,36 +36,82 @@ func (h *Handler) Register(c *fiber.Ctx) error {
 		return err
 	}
 
-	err := h.accountService.FindAccountByEmail(struct{}{}, remoteData.Account.Email).Error
+	account, violations, err := h.registerAccount(c, &remoteData.Account)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return passwordPolicyError(violations)
+	}
+
+	auth, err := jwt.Generate(account)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&types.AuthResponse{
+		Auth: auth,
+	})
+}
+
+// registerAccount creates the account if the registration mode allows it. Admins can create accounts in every mode.
+// An invitation code grants the permission preset of the invitation, it is also accepted while registration is open.
+// Policy violations are returned separately so the API and the register page can present them their own way.
+func (h *Handler) registerAccount(c *fiber.Ctx, remoteData *types.RegisterDTOBody) (*model.Account, []password.Violation, error) {
+	isAdmin := locals.JwtPayload(c).Valid && locals.Can(c, permission.ACCOUNTS_MANAGE_ALL)
+	if !isAdmin {
+		switch config.REGISTRATION_MODE {
+		case model.REGISTRATION_OPEN:
+		case model.REGISTRATION_INVITE_ONLY:
+			if remoteData.InvitationCode == "" {
+				return nil, nil, &utils.INVITATION_REQUIRED
+			}
+		default:
+			// Unknown modes are treated as closed
+			return nil, nil, &utils.REGISTRATION_CLOSED
+		}
+	}
+
+	if remoteData.Password != remoteData.ConfirmPassword {
+		return nil, nil, utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "Password and confirm password do not match")
+	}
+
+	err := h.accountService.FindAccountByEmail(struct{}{}, remoteData.Email).Error
 	if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return &utils.ACCOUNT_WITH_EMAIL_ALREADY_EXISTS
+		return nil, nil, &utils.ACCOUNT_WITH_EMAIL_ALREADY_EXISTS
+	}
+
+	if violations := password.ConfiguredPolicy().Check(remoteData.Password, remoteData.Email, remoteData.Firstname, remoteData.Lastname); len(violations) > 0 {
+		return nil, violations, nil
 	}
 
 	account := &model.Account{}
-	// Convert remoteData.Account from RegisterDTOBody to Account type
-	account.New(utils.Convert(model.Account{}, &remoteData.Account))
+	// Convert remoteData from RegisterDTOBody to Account type
+	account.New(utils.Convert(model.Account{}, remoteData))
 
 	// Give new users basic permissions
 	account.Permission = permission.ACCOUNTS_READ_OWN
 	account.TokenSecret = model.GenerateSecretToken()
-	hashedPassword, err := model.HashPassword(remoteData.Account.Password)
+	hashedPassword, err := model.HashPassword(remoteData.Password)
 	if err != nil {
-		return c.SendStatus(fiber.StatusInternalServerError)
+		return nil, nil, &utils.INTERNAL_SERVER_ERROR
 	}
 	account.Password = hashedPassword
 
-	if err := h.accountService.CreateAccount(account).Error; err != nil {
-		return &utils.INTERNAL_SERVER_ERROR
+	if remoteData.InvitationCode != "" {
+		err = h.invitationService.CreateAccountWithInvitation(remoteData.InvitationCode, account)
+	} else {
+		err = h.accountService.CreateAccount(account).Error
 	}
-
-	auth, err := jwt.Generate(account)
 	if err != nil {
-		return err
+		var requestError *utils.RequestError
+		if errors.As(err, &requestError) {
+			return nil, nil, requestError
+		}
+		return nil, nil, &utils.INTERNAL_SERVER_ERROR
 	}
 
-	return c.JSON(&types.AuthResponse{
-		Auth: auth,
-	})
+	return account, nil, nil
 }
 
 // Login      godoc
@@ -77,18 +130,28 @@ func (h *Handler) Login(c *fiber.Ctx) error {
 		return err
 	}
 
+	if err := h.checkLoginThrottle(c, remoteData.Account.Email); err != nil {
+		return err
+	}
+
+	// Unknown emails and wrong passwords get the same response so the endpoint doesn't reveal which accounts exist
 	account := &model.Account{}
 	if err := h.accountService.FindAccountByEmail(account, remoteData.Account.Email).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return &utils.NOT_FOUND
+			model.CheckPasswordHash(remoteData.Account.Password, dummyPasswordHash)
+			h.recordLoginAttempt(c, remoteData.Account.Email, nil, model.LOGIN_FAILURE_UNKNOWN_ACCOUNT)
+			return &utils.AUTH_LOGIN_WRONG_PASSWORD
 		}
 		return err
 	}
 
-	if !model.CheckPasswordHash(remoteData.Account.Password, account.Password) {
+	if !h.verifyPassword(account, remoteData.Account.Password) {
+		h.recordLoginAttempt(c, remoteData.Account.Email, account, model.LOGIN_FAILURE_WRONG_PASSWORD)
 		return &utils.AUTH_LOGIN_WRONG_PASSWORD
 	}
 
+	h.recordLoginAttempt(c, remoteData.Account.Email, account, "")
+
 	auth, err := jwt.Generate(account)
 	if err != nil {
 		return err
@@ -99,6 +162,163 @@ func (h *Handler) Login(c *fiber.Ctx) error {
 	})
 }
 
+// dummyPasswordHash is compared against when the account doesn't exist, so unknown emails take as long as wrong passwords
+var dummyPasswordHash, _ = model.HashPassword("not-a-real-password")
+
+// checkLoginThrottle rejects the login when the email or the IP had too many failed attempts recently
+func (h *Handler) checkLoginThrottle(c *fiber.Ctx, email string) error {
+	return h.checkThrottle(c, email, &utils.AUTH_LOGIN_THROTTLED)
+}
+
+// checkThrottle returns throttled when the key of the login attempts or the IP had too many failures recently
+func (h *Handler) checkThrottle(c *fiber.Ctx, key string, throttled *utils.RequestError) error {
+	retryAfter, err := h.loginAttemptService.RetryAfter(key, c.IP())
+	if err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if retryAfter > 0 {
+		h.recordLoginAttempt(c, key, nil, model.LOGIN_FAILURE_THROTTLED)
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		return throttled
+	}
+
+	return nil
+}
+
+// recordLoginAttempt stores the outcome of a password login. An empty failure reason means the login succeeded.
+func (h *Handler) recordLoginAttempt(c *fiber.Ctx, email string, account *model.Account, failureReason string) {
+	attempt := &model.LoginAttempt{
+		Email:   email,
+		IP:      c.IP(),
+		Success: failureReason == "",
+		Reason:  failureReason,
+	}
+	if account != nil {
+		attempt.AccountID = &account.ID
+	}
+
+	h.loginAttemptService.RecordAttempt(attempt)
+}
+
+// verifyPassword checks the password of the account and upgrades an outdated hash when it matches
+func (h *Handler) verifyPassword(account *model.Account, password string) bool {
+	ok, needsRehash := model.VerifyPassword(password, account.Password)
+	if ok && needsRehash {
+		h.rehashPassword(account, password)
+	}
+
+	return ok
+}
+
+// rehashPassword upgrades a stored hash that was created with an outdated algorithm or parameters.
+// It runs after a successful login, the only time the plain password is known. Failures don't affect the login.
+func (h *Handler) rehashPassword(account *model.Account, password string) {
+	hash, err := model.HashPassword(password)
+	if err != nil {
+		log.Printf("rehashing the password of account %d failed: %v", account.ID, err)
+		return
+	}
+
+	if err := h.accountService.UpdateAccountPassword(account, hash).Error; err != nil {
+		log.Printf("storing the rehashed password of account %d failed: %v", account.ID, err)
+	}
+}
+
+// passwordPolicyError lists the message of every violated rule, the detail contains the rule identifiers
+func passwordPolicyError(violations []password.Violation) *utils.RequestError {
+	messages := make([]string, len(violations))
+	rules := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.Message
+		rules[i] = violation.Rule
+	}
+
+	return &utils.RequestError{
+		Code:       utils.PASSWORD_POLICY_VIOLATION.Code,
+		StatusCode: utils.PASSWORD_POLICY_VIOLATION.StatusCode,
+		Message:    strings.Join(messages, " "),
+		Detail:     strings.Join(rules, ","),
+	}
+}
+
+// ChangePassword      godoc
+//
+//	@Summary		Change password
+//	@Description	Replaces the password after checking the current one. Refresh tokens issued before are revoked.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			password	body		types.ChangePasswordDTO	true	"Passwords"
+//	@Success		200			{object}	types.AuthResponse
+//	@Security		BearerAuth
+//	@Router			/auth/password [put]
+func (h *Handler) ChangePassword(c *fiber.Ctx) error {
+	remoteData := &types.ChangePasswordDTO{}
+
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	account, violations, err := h.changePassword(c, remoteData)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return passwordPolicyError(violations)
+	}
+
+	auth, err := jwt.Generate(account)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&types.AuthResponse{
+		Auth: auth,
+	})
+}
+
+// changePassword checks the current password and the policy before storing the new password. Wrong current
+// passwords are throttled like failed logins, so a stolen session can't be used to guess the password.
+// Policy violations are returned separately so the API and the profile page can present them their own way.
+func (h *Handler) changePassword(c *fiber.Ctx, remoteData *types.ChangePasswordDTO) (*model.Account, []password.Violation, error) {
+	account := &model.Account{}
+	if err := h.accountService.FindAccountByID(account, locals.JwtPayload(c).AccountID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, &utils.NOT_FOUND
+		}
+		return nil, nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if err := h.checkThrottle(c, account.Email, &utils.PASSWORD_CURRENT_THROTTLED); err != nil {
+		return nil, nil, err
+	}
+	if !h.verifyPassword(account, remoteData.CurrentPassword) {
+		h.recordLoginAttempt(c, account.Email, account, model.LOGIN_FAILURE_WRONG_PASSWORD)
+		return nil, nil, &utils.PASSWORD_CURRENT_WRONG
+	}
+	h.recordLoginAttempt(c, account.Email, account, "")
+
+	if remoteData.Password != remoteData.ConfirmPassword {
+		return nil, nil, utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "Password and confirm password do not match")
+	}
+
+	if violations := password.ConfiguredPolicy().Check(remoteData.Password, account.Email, account.Firstname, account.Lastname); len(violations) > 0 {
+		return nil, violations, nil
+	}
+
+	hashedPassword, err := model.HashPassword(remoteData.Password)
+	if err != nil {
+		return nil, nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if err := h.accountService.ChangeAccountPassword(account, hashedPassword).Error; err != nil {
+		return nil, nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return account, nil, nil
+}
+
 // Refresh      godoc
 //
 //	@Summary	Refresh
@@ -110,16 +330,13 @@ func (h *Handler) Login(c *fiber.Ctx) error {
 func (h *Handler) Refresh(c *fiber.Ctx) error {
 	var tokenPayload = locals.JwtPayload(c)
 
-	if tokenPayload.Type != "refresh" {
+	if tokenPayload.Type != jwt.TYPE_REFRESH {
 		return &utils.WRONG_REFRESH_TOKEN
 	}
 
 	account := &model.Account{}
-	if err := h.db.Model(account).Take(account, &model.Account{
-		Model: gorm.Model{ID: tokenPayload.AccountID},
-		//BaseModel:   model.BaseModel{ID: tokenPayload.AccountID},
-		TokenSecret: tokenPayload.Secret,
-	}).Error; err != nil {
+	// Explicit conditions, a struct condition would drop an empty token secret and accept any refresh token
+	if err := h.db.Model(account).Take(account, "id = ? AND token_secret = ?", tokenPayload.AccountID, tokenPayload.Secret).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return &utils.UNAUTHORIZED
 		}
@@ -145,7 +362,7 @@ func (h *Handler) RotateJWK(c *fiber.Ctx) error {
 // Me           godoc
 //
 //	@Summary		Get current user profile
-//	@Description	Get current authenticated user's account information and refresh tokens
+//	@Description	Get current authenticated user's account information and refresh tokens. OAuth tokens with the profile scope only get the account.
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
@@ -155,7 +372,8 @@ func (h *Handler) RotateJWK(c *fiber.Ctx) error {
 func (h *Handler) Me(c *fiber.Ctx) error {
 	var account = &model.Account{}
 
-	err := h.accountService.FindAccountByID(account, locals.JwtPayload(c).AccountID).Error
+	payload := locals.JwtPayload(c)
+	err := h.accountService.FindAccountByID(account, payload.AccountID).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return &utils.NOT_FOUND
@@ -163,6 +381,13 @@ func (h *Handler) Me(c *fiber.Ctx) error {
 		return &utils.INTERNAL_SERVER_ERROR
 	}
 
+	// Third party clients must not turn a profile grant into first party tokens
+	if payload.Type == jwt.TYPE_OAUTH {
+		return c.JSON(&types.GetMeResponse{
+			Account: *account,
+		})
+	}
+
 	// Generate fresh tokens for the user
 	auth, err := jwt.Generate(account)
 	if err != nil {
@@ -171,6 +396,6 @@ func (h *Handler) Me(c *fiber.Ctx) error {
 
 	return c.JSON(&types.GetMeResponse{
 		Account: *account,
-		Auth:    auth,
+		Auth:    &auth,
 	})
 }
@@ -0,0 +1,326 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/pkg/permission"
+	"github.com/nleiva/go-todo-api/test"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func login(email string, password string) *http.Response {
+	body, _ := json.Marshal(&types.LoginDTO{
+		Account: types.LoginDTOBody{Email: email, Password: password},
+	})
+
+	req, _ := http.NewRequest("PUT", "/api/auth/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	res, _ := App.Test(req)
+
+	return res
+}
+
+func TestAuthHandlerLoginThrottle(t *testing.T) {
+	// Setup
+	pw, _ := model.HashPassword("123456")
+	account := &model.Account{
+		Email:     "auth.throttle@turbomeet.xyz",
+		Password:  pw,
+		Firstname: "Auth",
+		Lastname:  "Throttle",
+	}
+	admin := &model.Account{
+		Email:      "auth.throttle.admin@turbomeet.xyz",
+		Password:   pw,
+		Permission: permission.ACCOUNTS_READ_ALL,
+	}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(account)
+	accountService.CreateAccount(admin)
+
+	defer func(delay time.Duration, maxFailures int) {
+		config.LOGIN_THROTTLE_DELAY = delay
+		config.LOGIN_MAX_FAILURES = maxFailures
+	}(config.LOGIN_THROTTLE_DELAY, config.LOGIN_MAX_FAILURES)
+
+	t.Run("should not reveal whether the email exists", func(t *testing.T) {
+		config.LOGIN_THROTTLE_DELAY = 0
+
+		unknown := login("auth.throttle.unknown@turbomeet.xyz", "123456")
+		wrong := login("auth.throttle@turbomeet.xyz", "654321")
+
+		if unknown.StatusCode != 401 || wrong.StatusCode != 401 {
+			t.Fatalf("Expected status code 401 for both, got %d and %d", unknown.StatusCode, wrong.StatusCode)
+		}
+
+		unknownBody, _ := io.ReadAll(unknown.Body)
+		wrongBody, _ := io.ReadAll(wrong.Body)
+		if !bytes.Equal(unknownBody, wrongBody) {
+			t.Errorf("Expected identical responses, got %s and %s", unknownBody, wrongBody)
+		}
+
+		test.ClearTables(DB, []string{"login_attempts"})
+	})
+
+	t.Run("should slow down after a failure", func(t *testing.T) {
+		config.LOGIN_THROTTLE_DELAY = time.Hour
+
+		login("auth.throttle@turbomeet.xyz", "654321")
+		res := login("auth.throttle@turbomeet.xyz", "123456")
+
+		if res.StatusCode != 429 {
+			t.Errorf("Expected status code 429, got %d", res.StatusCode)
+		}
+		if res.Header.Get("Retry-After") == "" {
+			t.Errorf("Expected a Retry-After header")
+		}
+
+		test.ClearTables(DB, []string{"login_attempts"})
+	})
+
+	t.Run("should lock the account after too many failures", func(t *testing.T) {
+		config.LOGIN_THROTTLE_DELAY = 0
+		config.LOGIN_MAX_FAILURES = 3
+
+		for i := 0; i < 3; i++ {
+			if res := login("auth.throttle@turbomeet.xyz", "654321"); res.StatusCode != 401 {
+				t.Fatalf("Expected status code 401, got %d", res.StatusCode)
+			}
+		}
+
+		// Even the correct password is rejected while locked
+		if res := login("auth.throttle@turbomeet.xyz", "123456"); res.StatusCode != 429 {
+			t.Errorf("Expected status code 429, got %d", res.StatusCode)
+		}
+
+		// Unknown emails are locked the same way
+		for i := 0; i < 3; i++ {
+			login("auth.throttle.nobody@turbomeet.xyz", "654321")
+		}
+		if res := login("auth.throttle.nobody@turbomeet.xyz", "654321"); res.StatusCode != 429 {
+			t.Errorf("Expected status code 429, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should list failed attempts for admins", func(t *testing.T) {
+		userAuth, _ := jwt.Generate(account)
+		req, _ := http.NewRequest("GET", "/api/accounts/login-attempts", nil)
+		req.Header.Set("Authorization", "Bearer "+userAuth.Token)
+		if res, _ := App.Test(req); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403, got %d", res.StatusCode)
+		}
+
+		adminAuth, _ := jwt.Generate(admin)
+		req, _ = http.NewRequest("GET", "/api/accounts/login-attempts?limit=100&filter[email]=auth.throttle@turbomeet.xyz", nil)
+		req.Header.Set("Authorization", "Bearer "+adminAuth.Token)
+		res, _ := App.Test(req)
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		bodyBytes, _ := io.ReadAll(res.Body)
+		result := types.GetLoginAttemptsResponse{}
+		if err := json.Unmarshal(bodyBytes, &result); err != nil {
+			t.Fatalf("Error unmarshalling response: %v", err)
+		}
+
+		// 3 wrong passwords and 1 throttled attempt
+		if len(result.LoginAttempts) != 4 {
+			t.Errorf("Expected 4 failed attempts, got %d", len(result.LoginAttempts))
+		}
+		for _, attempt := range result.LoginAttempts {
+			if attempt.Success {
+				t.Errorf("Expected only failed attempts, got %+v", attempt)
+			}
+		}
+	})
+
+	// Cleanup
+	test.ClearTables(DB, []string{"login_attempts", "accounts"})
+	test.ClearAllTables(DB)
+}
+
+func TestAuthHandlerLoginRehash(t *testing.T) {
+	// Setup
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("123456"), 10)
+	account := &model.Account{
+		Email:     "auth.rehash@turbomeet.xyz",
+		Password:  string(legacy),
+		Firstname: "Auth",
+		Lastname:  "Rehash",
+	}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(account)
+
+	t.Run("should upgrade a legacy bcrypt hash on login", func(t *testing.T) {
+		if res := login("auth.rehash@turbomeet.xyz", "123456"); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		updated := &model.Account{}
+		DB.First(updated, account.ID)
+
+		if !strings.HasPrefix(updated.Password, "$argon2id$") {
+			t.Errorf("Expected an argon2id hash, got %s", updated.Password)
+		}
+		if !model.CheckPasswordHash("123456", updated.Password) {
+			t.Errorf("Expected the upgraded hash to match the password")
+		}
+	})
+
+	// Cleanup
+	test.ClearTables(DB, []string{"login_attempts", "accounts"})
+	test.ClearAllTables(DB)
+}
+
+func TestAuthHandlerPasswordPolicy(t *testing.T) {
+	// Setup
+	pw, _ := model.HashPassword("Old-Password-1")
+	account := &model.Account{
+		Email:     "auth.policy@turbomeet.xyz",
+		Password:  pw,
+		Firstname: "Auth",
+		Lastname:  "Policy",
+	}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(account)
+
+	auth, _ := jwt.Generate(account)
+
+	put := func(path string, body any) *http.Response {
+		bodyBytes, _ := json.Marshal(body)
+		req, _ := http.NewRequest("PUT", path, bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+		res, _ := App.Test(req)
+		return res
+	}
+
+	t.Run("should reject a weak password on register with every violated rule", func(t *testing.T) {
+		body, _ := json.Marshal(&types.RegisterDTO{
+			Account: types.RegisterDTOBody{
+				Email:           "auth.policy.new@turbomeet.xyz",
+				Password:        "policy",
+				ConfirmPassword: "policy",
+				Firstname:       "Policy",
+			},
+		})
+		req, _ := http.NewRequest("POST", "/api/auth/register", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+		res, _ := App.Test(req)
+
+		if res.StatusCode != 400 {
+			t.Fatalf("Expected status code 400, got %d", res.StatusCode)
+		}
+
+		result := map[string]any{}
+		bodyBytes, _ := io.ReadAll(res.Body)
+		json.Unmarshal(bodyBytes, &result)
+
+		if result["detail"] != "min_length,personal_info" {
+			t.Errorf("Expected min_length and personal_info violations, got %v", result["detail"])
+		}
+	})
+
+	t.Run("should require the current password", func(t *testing.T) {
+		res := put("/api/auth/password", &types.ChangePasswordDTO{
+			CurrentPassword: "Wrong-Password-1",
+			Password:        "New-Password-2",
+			ConfirmPassword: "New-Password-2",
+		})
+
+		if res.StatusCode != 400 {
+			t.Errorf("Expected status code 400, got %d", res.StatusCode)
+		}
+
+		test.ClearTables(DB, []string{"login_attempts"})
+	})
+
+	t.Run("should throttle wrong current passwords like failed logins", func(t *testing.T) {
+		defer func(delay time.Duration, maxFailures int) {
+			config.LOGIN_THROTTLE_DELAY = delay
+			config.LOGIN_MAX_FAILURES = maxFailures
+		}(config.LOGIN_THROTTLE_DELAY, config.LOGIN_MAX_FAILURES)
+		config.LOGIN_THROTTLE_DELAY = 0
+		config.LOGIN_MAX_FAILURES = 3
+
+		wrong := &types.ChangePasswordDTO{
+			CurrentPassword: "Wrong-Password-1",
+			Password:        "New-Password-2",
+			ConfirmPassword: "New-Password-2",
+		}
+		for i := 0; i < 3; i++ {
+			if res := put("/api/auth/password", wrong); res.StatusCode != 400 {
+				t.Fatalf("Expected status code 400, got %d", res.StatusCode)
+			}
+		}
+
+		// Even the correct current password is rejected while locked
+		res := put("/api/auth/password", &types.ChangePasswordDTO{
+			CurrentPassword: "Old-Password-1",
+			Password:        "New-Password-2",
+			ConfirmPassword: "New-Password-2",
+		})
+		if res.StatusCode != 429 {
+			t.Errorf("Expected status code 429, got %d", res.StatusCode)
+		}
+
+		// The failures count against logins as well
+		if res := login("auth.policy@turbomeet.xyz", "Old-Password-1"); res.StatusCode != 429 {
+			t.Errorf("Expected status code 429, got %d", res.StatusCode)
+		}
+
+		test.ClearTables(DB, []string{"login_attempts"})
+	})
+
+	t.Run("should reject a new password violating the policy", func(t *testing.T) {
+		res := put("/api/auth/password", &types.ChangePasswordDTO{
+			CurrentPassword: "Old-Password-1",
+			Password:        "short",
+			ConfirmPassword: "short",
+		})
+
+		if res.StatusCode != 400 {
+			t.Errorf("Expected status code 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should change the password and revoke refresh tokens", func(t *testing.T) {
+		res := put("/api/auth/password", &types.ChangePasswordDTO{
+			CurrentPassword: "Old-Password-1",
+			Password:        "New-Password-2",
+			ConfirmPassword: "New-Password-2",
+		})
+
+		if res.StatusCode != 200 {
+			bodyBytes, _ := io.ReadAll(res.Body)
+			t.Fatalf("Expected status code 200, got %d: %s", res.StatusCode, bodyBytes)
+		}
+
+		if res := login("auth.policy@turbomeet.xyz", "New-Password-2"); res.StatusCode != 200 {
+			t.Errorf("Expected login with the new password to succeed, got %d", res.StatusCode)
+		}
+
+		req, _ := http.NewRequest("PUT", "/api/auth/refresh", nil)
+		req.Header.Set("Authorization", "Bearer "+auth.RefreshToken)
+		if res, _ := App.Test(req); res.StatusCode != 401 {
+			t.Errorf("Expected the old refresh token to be rejected, got %d", res.StatusCode)
+		}
+	})
+
+	// Cleanup
+	test.ClearTables(DB, []string{"login_attempts", "accounts"})
+	test.ClearAllTables(DB)
+}
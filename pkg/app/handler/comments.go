@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+// findComment finds a comment of a todo the account can see. Only the author may change a comment, owners of the
+// todo may also delete it.
+func (h *Handler) findComment(c *fiber.Ctx, todo *model.Todo, comment *model.Comment, forDelete bool) error {
+	role, err := h.findTodoRole(c, todo, c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	if err := h.commentService.FindComment(comment, c.Params("commentId"), todo.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &utils.NOT_FOUND
+		}
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if comment.AccountID == locals.JwtPayload(c).AccountID || (forDelete && model.ShareRoleAllows(role, model.SHARE_ROLE_OWNER)) {
+		return nil
+	}
+
+	return &utils.COMMENT_AUTHOR_REQUIRED
+}
+
+// notifyMentions notifies the accounts mentioned in the comment that can see the todo. Mentions in previous, the
+// body before an edit, were already notified.
+func (h *Handler) notifyMentions(c *fiber.Ctx, todo *model.Todo, comment *model.Comment, previous []string) {
+	actorID := locals.JwtPayload(c).AccountID
+
+	actor := &model.Account{}
+	if err := h.accountService.FindAccountByID(actor, actorID).Error; err != nil {
+		return
+	}
+
+	for _, email := range comment.Mentions() {
+		if slices.Contains(previous, email) {
+			continue
+		}
+
+		account := &model.Account{}
+		if err := h.accountService.FindAccountByEmail(account, email).Error; err != nil {
+			continue
+		}
+		if ok, err := h.todoService.CanAccess(todo, account.ID); err != nil || !ok {
+			continue
+		}
+
+		h.notify(&model.Notification{
+			AccountID: account.ID,
+			ActorID:   actorID,
+			TodoID:    &todo.ID,
+			Kind:      model.NOTIFICATION_MENTIONED,
+			Message:   fmt.Sprintf("%s mentioned you on “%s”", actor.Email, todo.Title.String),
+		})
+	}
+}
+
+func (h *Handler) createComment(c *fiber.Ctx, todo *model.Todo, body string) (*model.Comment, error) {
+	comment := &model.Comment{
+		TodoID:    todo.ID,
+		AccountID: locals.JwtPayload(c).AccountID,
+		Body:      body,
+	}
+
+	if err := h.commentService.CreateComment(comment).Error; err != nil {
+		return nil, &utils.INTERNAL_SERVER_ERROR
+	}
+	h.notifyMentions(c, todo, comment, nil)
+
+	if err := h.commentService.FindComment(comment, fmt.Sprint(comment.ID), todo.ID).Error; err != nil {
+		return nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return comment, nil
+}
+
+func (h *Handler) updateComment(c *fiber.Ctx, todo *model.Todo, comment *model.Comment, body string) error {
+	previous := comment.Mentions()
+	comment.Body = body
+
+	if err := h.commentService.UpdateComment(comment).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+	h.notifyMentions(c, todo, comment, previous)
+
+	return nil
+}
+
+// GetComments godoc
+//
+//	@Summary		List comments
+//	@Description	Lists the comments of a todo, oldest first
+//	@Tags			comments
+//	@Produce		json
+//	@Param			id	path		int	true	"Todo ID"
+//	@Success		200	{object}	types.GetCommentsResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/comments [get]
+func (h *Handler) GetComments(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_VIEWER); err != nil {
+		return err
+	}
+
+	comments := []model.Comment{}
+	if err := h.commentService.FindComments(&comments, todo.ID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetCommentsResponse{
+		Comments: comments,
+	})
+}
+
+// CreateComment godoc
+//
+//	@Summary		Create comment
+//	@Description	Comments on a todo the account can see. Mentioned accounts (@email) with access to the todo are notified.
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Todo ID"
+//	@Param			comment	body		types.CommentDTO	true	"Comment"
+//	@Success		201		{object}	types.CommentResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/comments [post]
+func (h *Handler) CreateComment(c *fiber.Ctx) error {
+	remoteData := &types.CommentDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_VIEWER); err != nil {
+		return err
+	}
+
+	comment, err := h.createComment(c, todo, remoteData.Body)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&types.CommentResponse{
+		Comment: *comment,
+	})
+}
+
+// UpdateComment godoc
+//
+//	@Summary		Update comment
+//	@Description	Changes the body of an own comment. Only accounts newly mentioned are notified.
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"Todo ID"
+//	@Param			commentId	path		int					true	"Comment ID"
+//	@Param			comment		body		types.CommentDTO	true	"Comment"
+//	@Success		200			{object}	types.CommentResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/comments/{commentId} [put]
+func (h *Handler) UpdateComment(c *fiber.Ctx) error {
+	remoteData := &types.CommentDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	todo := &model.Todo{}
+	comment := &model.Comment{}
+	if err := h.findComment(c, todo, comment, false); err != nil {
+		return err
+	}
+
+	if err := h.updateComment(c, todo, comment, remoteData.Body); err != nil {
+		return err
+	}
+
+	return c.JSON(&types.CommentResponse{
+		Comment: *comment,
+	})
+}
+
+// DeleteComment godoc
+//
+//	@Summary		Delete comment
+//	@Description	Deletes an own comment, owners of the todo can delete all comments
+//	@Tags			comments
+//	@Param			id			path		int	true	"Todo ID"
+//	@Param			commentId	path		int	true	"Comment ID"
+//	@Success		204			{object}	nil	"No Content"
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/comments/{commentId} [delete]
+func (h *Handler) DeleteComment(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	comment := &model.Comment{}
+	if err := h.findComment(c, todo, comment, true); err != nil {
+		return err
+	}
+
+	if err := h.commentService.DeleteComment(comment).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// VComments renders the comment thread below a todo
+func (h *Handler) VComments(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_VIEWER); err != nil {
+		return err
+	}
+
+	comments := []model.Comment{}
+	if err := h.commentService.FindComments(&comments, todo.ID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.CommentThread(todo.ID, comments, locals.JwtPayload(c).AccountID)))(c)
+}
+
+// VCommentsCreate appends a new comment to the thread
+func (h *Handler) VCommentsCreate(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_VIEWER); err != nil {
+		return err
+	}
+
+	remoteData := &types.CommentDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return renderFormErrors(c, fmt.Sprintf("#comment-errors-%d", todo.ID), []string{"Please write a comment of at most 10000 characters."})
+	}
+
+	comment, err := h.createComment(c, todo, remoteData.Body)
+	if err != nil {
+		return err
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.CommentItem(*comment, comment.AccountID)))(c)
+}
+
+// VComment renders a single comment, used to cancel editing
+func (h *Handler) VComment(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	comment := &model.Comment{}
+	if err := h.findComment(c, todo, comment, false); err != nil {
+		return err
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.CommentItem(*comment, comment.AccountID)))(c)
+}
+
+// VCommentsEdit swaps a comment for a form to edit it
+func (h *Handler) VCommentsEdit(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	comment := &model.Comment{}
+	if err := h.findComment(c, todo, comment, false); err != nil {
+		return err
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.CommentEditForm(*comment)))(c)
+}
+
+func (h *Handler) VCommentsUpdate(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	comment := &model.Comment{}
+	if err := h.findComment(c, todo, comment, false); err != nil {
+		return err
+	}
+
+	remoteData := &types.CommentDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return renderFormErrors(c, fmt.Sprintf("#comment-edit-errors-%d", comment.ID), []string{"Please write a comment of at most 10000 characters."})
+	}
+
+	if err := h.updateComment(c, todo, comment, remoteData.Body); err != nil {
+		return err
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.CommentItem(*comment, comment.AccountID)))(c)
+}
+
+func (h *Handler) VCommentsDelete(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	comment := &model.Comment{}
+	if err := h.findComment(c, todo, comment, true); err != nil {
+		return err
+	}
+
+	if err := h.commentService.DeleteComment(comment).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.Status(http.StatusOK).SendString("")
+}
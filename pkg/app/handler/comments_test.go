@@ -0,0 +1,112 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestCommentsHandlerThread(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "comments.owner@turbomeet.xyz", Password: pw}
+	reader := &model.Account{Email: "comments.reader@turbomeet.xyz", Password: pw}
+	outsider := &model.Account{Email: "comments.outsider@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+	accountService.CreateAccount(reader)
+	accountService.CreateAccount(outsider)
+
+	ownerAuth, _ := jwt.Generate(owner)
+	readerAuth, _ := jwt.Generate(reader)
+	outsiderAuth, _ := jwt.Generate(outsider)
+
+	todo := &model.Todo{Title: zero.StringFrom("Plan the offsite")}
+	service.NewTodoService(DB).CreateTodo(todo, model.Space{AccountID: owner.ID})
+	service.NewShareService(DB).ShareTodo(&model.TodoShare{TodoID: todo.ID, AccountID: reader.ID, Role: model.SHARE_ROLE_VIEWER, SharedByID: owner.ID})
+	commentsPath := "/api/todos/" + strconv.FormatUint(uint64(todo.ID), 10) + "/comments"
+
+	mentions := func(account *model.Account) int {
+		notifications := []model.Notification{}
+		service.NewNotificationService(DB).FindNotifications(&notifications, account.ID, true)
+		return len(notifications)
+	}
+
+	var comment model.Comment
+
+	t.Run("should comment and notify mentioned accounts with access", func(t *testing.T) {
+		res := send("POST", commentsPath, readerAuth.Token, &types.CommentDTO{Body: "**Venue** booked, @comments.owner@turbomeet.xyz and @comments.outsider@turbomeet.xyz please check."})
+		if res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+		response := &types.CommentResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		comment = response.Comment
+
+		if n := mentions(owner); n != 1 {
+			t.Errorf("Expected the owner to be notified once, got %d", n)
+		}
+		if n := mentions(outsider); n != 0 {
+			t.Errorf("Expected no notification for accounts without access, got %d", n)
+		}
+
+		if res := send("POST", commentsPath, readerAuth.Token, &types.CommentDTO{}); res.StatusCode != 400 {
+			t.Errorf("Expected empty comments to fail with 400, got %d", res.StatusCode)
+		}
+		if res := send("GET", commentsPath, outsiderAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected outsiders to get 404, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should only let the author edit", func(t *testing.T) {
+		commentPath := commentsPath + "/" + strconv.FormatUint(uint64(comment.ID), 10)
+
+		if res := send("PUT", commentPath, ownerAuth.Token, &types.CommentDTO{Body: "changed"}); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403, got %d", res.StatusCode)
+		}
+
+		res := send("PUT", commentPath, readerAuth.Token, &types.CommentDTO{Body: "**Venue** booked, @comments.owner@turbomeet.xyz please check the date."})
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if n := mentions(owner); n != 1 {
+			t.Errorf("Expected no new notification for the same mention, got %d", n)
+		}
+
+		res = send("GET", commentsPath, ownerAuth.Token, nil)
+		response := &types.GetCommentsResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if len(response.Comments) != 1 || !strings.Contains(response.Comments[0].Body, "the date") || response.Comments[0].Account == nil {
+			t.Errorf("Expected the edited comment with its author, got %+v", response.Comments)
+		}
+	})
+
+	t.Run("should render the thread with markdown", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/todos/"+strconv.FormatUint(uint64(todo.ID), 10)+"/comments", nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: ownerAuth.Token})
+		res, _ := App.Test(req, -1)
+		body, _ := io.ReadAll(res.Body)
+
+		if !strings.Contains(string(body), "<strong>Venue</strong>") || !strings.Contains(string(body), "comments.reader@turbomeet.xyz") {
+			t.Errorf("Expected the rendered comment in the thread, got %s", body)
+		}
+	})
+
+	t.Run("should let owners of the todo delete comments", func(t *testing.T) {
+		if res := send("DELETE", commentsPath+"/"+strconv.FormatUint(uint64(comment.ID), 10), ownerAuth.Token, nil); res.StatusCode != 204 {
+			t.Errorf("Expected status code 204, got %d", res.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/utils"
+)
+
+// markBlocked sets IsBlocked of the todos before they are returned
+func (h *Handler) markBlocked(todos []model.Todo) error {
+	if err := h.dependencyService.MarkBlocked(todos); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return nil
+}
+
+// markTodoBlocked sets IsBlocked of a single todo before it is returned
+func (h *Handler) markTodoBlocked(todo *model.Todo) error {
+	todos := []model.Todo{*todo}
+	if err := h.markBlocked(todos); err != nil {
+		return err
+	}
+	todo.IsBlocked = todos[0].IsBlocked
+
+	return nil
+}
+
+// findDependencies lists the blockers of the todo and the todos it blocks that the account can see
+func (h *Handler) findDependencies(c *fiber.Ctx, todo *model.Todo) (*types.GetDependenciesResponse, error) {
+	visible := h.todoService.Accessible(locals.JwtPayload(c).AccountID)
+	response := &types.GetDependenciesResponse{BlockedBy: []model.Todo{}, Blocks: []model.Todo{}}
+
+	if err := h.dependencyService.FindBlockers(&response.BlockedBy, todo.ID, visible).Error; err != nil {
+		return nil, &utils.INTERNAL_SERVER_ERROR
+	}
+	if err := h.dependencyService.FindBlocked(&response.Blocks, todo.ID, visible).Error; err != nil {
+		return nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if err := h.markBlocked(response.BlockedBy); err != nil {
+		return nil, err
+	}
+	if err := h.markBlocked(response.Blocks); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// sendDependencyGraph sends the graph of the space or of the todo as JSON or, with format=dot, as Graphviz DOT
+func (h *Handler) sendDependencyGraph(c *fiber.Ctx, space model.Space, todoID *uint) error {
+	format := c.Query("format", "json")
+	if format != "json" && format != "dot" {
+		return utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "format must be json or dot")
+	}
+
+	todos, dependencies, err := h.dependencyService.FindGraph(space, todoID, h.todoService.Accessible(locals.JwtPayload(c).AccountID))
+	if err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if format == "dot" {
+		c.Set(fiber.HeaderContentType, "text/vnd.graphviz; charset=utf-8")
+		return c.SendString(dependencyDOT(todos, dependencies))
+	}
+
+	return c.JSON(&types.DependencyGraph{
+		Nodes: todos,
+		Edges: dependencies,
+	})
+}
+
+// dependencyDOT renders the graph with an edge from every blocker to the todo it blocks. Completed todos are
+// green, blocked todos red.
+func dependencyDOT(todos []model.Todo, dependencies []model.TodoDependency) string {
+	label := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "")
+
+	var out strings.Builder
+	out.WriteString("digraph dependencies {\n\trankdir=LR;\n\tnode [shape=box, style=\"rounded,filled\", fillcolor=\"#ffffff\"];\n")
+	for _, todo := range todos {
+		fill := ""
+		switch {
+		case todo.Completed:
+			fill = `, fillcolor="#bbf7d0"`
+		case todo.IsBlocked:
+			fill = `, fillcolor="#fecaca"`
+		}
+		fmt.Fprintf(&out, "\t\"%d\" [label=\"%s\"%s];\n", todo.ID, label.Replace(todo.Title.String), fill)
+	}
+	for _, dependency := range dependencies {
+		fmt.Fprintf(&out, "\t\"%d\" -> \"%d\";\n", dependency.BlockedByID, dependency.TodoID)
+	}
+	out.WriteString("}\n")
+
+	return out.String()
+}
+
+// GetTodoDependencies godoc
+//
+//	@Summary		List dependencies
+//	@Description	Lists the todos the todo is blocked by and the todos it blocks
+//	@Tags			dependencies
+//	@Produce		json
+//	@Param			id	path		int	true	"Todo ID"
+//	@Success		200	{object}	types.GetDependenciesResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/dependencies [get]
+func (h *Handler) GetTodoDependencies(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_VIEWER); err != nil {
+		return err
+	}
+
+	response, err := h.findDependencies(c, todo)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response)
+}
+
+// AddTodoDependency godoc
+//
+//	@Summary		Add dependency
+//	@Description	Blocks the todo by another todo of its space until that one is done. Links that would create a cycle are rejected with 409. Needs the editor role.
+//	@Tags			dependencies
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int						true	"Todo ID"
+//	@Param			dependency	body		types.AddDependencyDTO	true	"Blocker"
+//	@Success		201			{object}	types.GetDependenciesResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/dependencies [post]
+func (h *Handler) AddTodoDependency(c *fiber.Ctx) error {
+	remoteData := &types.AddDependencyDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	blocker := &model.Todo{}
+	if err := h.findTodoWithRole(c, blocker, strconv.FormatUint(uint64(remoteData.BlockedByID), 10), model.SHARE_ROLE_VIEWER); err != nil {
+		return err
+	}
+
+	if err := h.dependencyService.AddDependency(todo, blocker); err != nil {
+		return statusError(err)
+	}
+
+	response, err := h.findDependencies(c, todo)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// RemoveTodoDependency godoc
+//
+//	@Summary		Remove dependency
+//	@Description	Unblocks the todo from a blocker, needs the editor role
+//	@Tags			dependencies
+//	@Param			id			path	int	true	"Todo ID"
+//	@Param			blockerId	path	int	true	"Blocker todo ID"
+//	@Success		204
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/dependencies/{blockerId} [delete]
+func (h *Handler) RemoveTodoDependency(c *fiber.Ctx) error {
+	blockerID, err := strconv.ParseUint(c.Params("blockerId"), 10, 32)
+	if err != nil {
+		return &utils.BAD_REQUEST
+	}
+
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	if err := h.dependencyService.RemoveDependency(todo.ID, uint(blockerID)); err != nil {
+		return statusError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetTodoDependencyGraph godoc
+//
+//	@Summary		Get todo dependency graph
+//	@Description	Returns the todos connected to the todo by dependencies, as JSON or with format=dot as Graphviz DOT. Edges point from the blocker to the blocked todo.
+//	@Tags			dependencies
+//	@Produce		json
+//	@Produce		plain
+//	@Param			id		path		int		true	"Todo ID"
+//	@Param			format	query		string	false	"json (default) or dot"
+//	@Success		200		{object}	types.DependencyGraph
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/dependencies/graph [get]
+func (h *Handler) GetTodoDependencyGraph(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_VIEWER); err != nil {
+		return err
+	}
+
+	return h.sendDependencyGraph(c, todo.Space(), &todo.ID)
+}
+
+// GetDependencyGraph godoc
+//
+//	@Summary		Get dependency graph
+//	@Description	Returns all dependencies between the todos of the current space, as JSON or with format=dot as Graphviz DOT. Edges point from the blocker to the blocked todo.
+//	@Tags			dependencies
+//	@Produce		json
+//	@Produce		plain
+//	@Param			format	query		string	false	"json (default) or dot"
+//	@Success		200		{object}	types.DependencyGraph
+//	@Security		BearerAuth
+//	@Router			/todos/dependencies/graph [get]
+func (h *Handler) GetDependencyGraph(c *fiber.Ctx) error {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+
+	return h.sendDependencyGraph(c, space, nil)
+}
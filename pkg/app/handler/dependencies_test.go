@@ -0,0 +1,152 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestDependenciesHandlerGraph(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "dependency.owner@turbomeet.xyz", Password: pw}
+	other := &model.Account{Email: "dependency.other@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+	accountService.CreateAccount(other)
+
+	ownerAuth, _ := jwt.Generate(owner)
+
+	todoService := service.NewTodoService(DB)
+	space := model.Space{AccountID: owner.ID}
+	design := &model.Todo{Title: zero.StringFrom("Design")}
+	build := &model.Todo{Title: zero.StringFrom("Build")}
+	ship := &model.Todo{Title: zero.StringFrom(`Ship "v1"`)}
+	unrelated := &model.Todo{Title: zero.StringFrom("Unrelated")}
+	foreign := &model.Todo{Title: zero.StringFrom("Foreign")}
+	todoService.CreateTodo(design, space)
+	todoService.CreateTodo(build, space)
+	todoService.CreateTodo(ship, space)
+	todoService.CreateTodo(unrelated, space)
+	todoService.CreateTodo(foreign, model.Space{AccountID: other.ID})
+
+	todoPath := func(todo *model.Todo) string {
+		return "/api/todos/" + strconv.FormatUint(uint64(todo.ID), 10)
+	}
+
+	block := func(todo *model.Todo, blocker *model.Todo) *http.Response {
+		return send("POST", todoPath(todo)+"/dependencies", ownerAuth.Token, &types.AddDependencyDTO{BlockedByID: blocker.ID})
+	}
+
+	t.Run("should link todos and flag blocked ones", func(t *testing.T) {
+		if res := block(build, design); res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+		res := block(ship, build)
+		if res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+		response := &types.GetDependenciesResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if len(response.BlockedBy) != 1 || response.BlockedBy[0].ID != build.ID || !response.BlockedBy[0].IsBlocked {
+			t.Errorf("Expected ship to be blocked by the blocked build, got %+v", response.BlockedBy)
+		}
+
+		res = send("GET", todoPath(build), ownerAuth.Token, nil)
+		todo := &types.GetTodoResponse{}
+		json.NewDecoder(res.Body).Decode(todo)
+		if !todo.Todo.IsBlocked {
+			t.Errorf("Expected build to be blocked")
+		}
+
+		res = send("GET", todoPath(design)+"/dependencies", ownerAuth.Token, nil)
+		response = &types.GetDependenciesResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if len(response.BlockedBy) != 0 || len(response.Blocks) != 1 {
+			t.Errorf("Expected design to block one todo, got %+v", response)
+		}
+	})
+
+	t.Run("should reject cycles and foreign todos", func(t *testing.T) {
+		if res := block(design, ship); res.StatusCode != 409 {
+			t.Errorf("Expected a cycle to fail with 409, got %d", res.StatusCode)
+		}
+		if res := block(design, design); res.StatusCode != 400 {
+			t.Errorf("Expected a todo blocking itself to fail with 400, got %d", res.StatusCode)
+		}
+		if res := block(design, foreign); res.StatusCode != 404 {
+			t.Errorf("Expected todos of other accounts to get 404, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should prevent completing blocked todos if configured", func(t *testing.T) {
+		config.DEPENDENCIES_BLOCK_COMPLETION = true
+		defer func() { config.DEPENDENCIES_BLOCK_COMPLETION = false }()
+
+		res := send("PUT", todoPath(build), ownerAuth.Token, &types.UpdateTodoRequest{Todo: model.Todo{Title: build.Title, Completed: true}})
+		if res.StatusCode != 409 {
+			t.Errorf("Expected completing a blocked todo to fail with 409, got %d", res.StatusCode)
+		}
+
+		res = send("PUT", todoPath(design), ownerAuth.Token, &types.UpdateTodoRequest{Todo: model.Todo{Title: design.Title, Completed: true}})
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		res = send("PUT", todoPath(build), ownerAuth.Token, &types.UpdateTodoRequest{Todo: model.Todo{Title: build.Title, Completed: true}})
+		if res.StatusCode != 200 {
+			t.Errorf("Expected the unblocked todo to complete, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should return the graph as JSON and DOT", func(t *testing.T) {
+		res := send("GET", todoPath(design)+"/dependencies/graph", ownerAuth.Token, nil)
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		graph := &types.DependencyGraph{}
+		json.NewDecoder(res.Body).Decode(graph)
+		if len(graph.Nodes) != 3 || len(graph.Edges) != 2 {
+			t.Errorf("Expected 3 connected todos with 2 edges, got %d and %d", len(graph.Nodes), len(graph.Edges))
+		}
+
+		res = send("GET", "/api/todos/dependencies/graph?format=dot", ownerAuth.Token, nil)
+		if contentType := res.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/vnd.graphviz") {
+			t.Errorf("Expected a graphviz content type, got %s", contentType)
+		}
+		body, _ := io.ReadAll(res.Body)
+		dot := string(body)
+		edge := strconv.FormatUint(uint64(design.ID), 10) + `" -> "` + strconv.FormatUint(uint64(build.ID), 10)
+		if !strings.HasPrefix(dot, "digraph dependencies {") || !strings.Contains(dot, edge) || !strings.Contains(dot, `Ship \"v1\"`) {
+			t.Errorf("Expected the DOT graph with escaped labels, got %s", dot)
+		}
+		if strings.Contains(dot, "Unrelated") {
+			t.Errorf("Expected todos without dependencies to be left out, got %s", dot)
+		}
+
+		if res := send("GET", "/api/todos/dependencies/graph?format=svg", ownerAuth.Token, nil); res.StatusCode != 400 {
+			t.Errorf("Expected unknown formats to fail with 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should remove dependencies", func(t *testing.T) {
+		if res := send("DELETE", todoPath(ship)+"/dependencies/"+strconv.FormatUint(uint64(build.ID), 10), ownerAuth.Token, nil); res.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+		}
+		if res := send("DELETE", todoPath(ship)+"/dependencies/"+strconv.FormatUint(uint64(build.ID), 10), ownerAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected missing dependencies to get 404, got %d", res.StatusCode)
+		}
+	})
+}
@@ -2,28 +2,84 @@ package handler
 
 import (
 	"reflect"
+	"slices"
 
 	"github.com/nleiva/go-todo-api/pkg/app/service"
 	"github.com/nleiva/go-todo-api/pkg/app/types/pagination"
 	"github.com/nleiva/go-todo-api/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Handler struct {
-	accountService service.IAccountService
-	todoService    service.ITodoService
-	db             *gorm.DB
-	validator      *Validator
+	accountService      service.IAccountService
+	todoService         service.ITodoService
+	webAuthnService     service.IWebAuthnService
+	loginAttemptService service.ILoginAttemptService
+	oidcService         service.IOIDCService
+	oauthService        service.IOAuthService
+	invitationService   service.IInvitationService
+	magicLinkService    service.IMagicLinkService
+	organizationService service.IOrganizationService
+	shareService        service.IShareService
+	shareLinkService    service.IShareLinkService
+	notificationService service.INotificationService
+	commentService      service.ICommentService
+	attachmentService   service.IAttachmentService
+	statusService       service.IStatusService
+	dependencyService   service.IDependencyService
+	timeEntryService    service.ITimeEntryService
+	templateService     service.ITemplateService
+	db                  *gorm.DB
+	validator           *Validator
 }
 
-func NewHandler(db *gorm.DB, as service.IAccountService, ts service.ITodoService) *Handler {
+// Services are the services the handlers work with
+type Services struct {
+	Account      service.IAccountService
+	Todo         service.ITodoService
+	WebAuthn     service.IWebAuthnService
+	LoginAttempt service.ILoginAttemptService
+	OIDC         service.IOIDCService
+	OAuth        service.IOAuthService
+	Invitation   service.IInvitationService
+	MagicLink    service.IMagicLinkService
+	Organization service.IOrganizationService
+	Share        service.IShareService
+	ShareLink    service.IShareLinkService
+	Notification service.INotificationService
+	Comment      service.ICommentService
+	Attachment   service.IAttachmentService
+	Status       service.IStatusService
+	Dependency   service.IDependencyService
+	TimeEntry    service.ITimeEntryService
+	Template     service.ITemplateService
+}
+
+func NewHandler(db *gorm.DB, services Services) *Handler {
 	v := NewValidator()
 
 	return &Handler{
-		accountService: as,
-		todoService:    ts,
-		db:             db,
-		validator:      v,
+		accountService:      services.Account,
+		todoService:         services.Todo,
+		webAuthnService:     services.WebAuthn,
+		loginAttemptService: services.LoginAttempt,
+		oidcService:         services.OIDC,
+		oauthService:        services.OAuth,
+		invitationService:   services.Invitation,
+		magicLinkService:    services.MagicLink,
+		organizationService: services.Organization,
+		shareService:        services.Share,
+		shareLinkService:    services.ShareLink,
+		notificationService: services.Notification,
+		commentService:      services.Comment,
+		attachmentService:   services.Attachment,
+		statusService:       services.Status,
+		dependencyService:   services.Dependency,
+		timeEntryService:    services.TimeEntry,
+		templateService:     services.Template,
+		db:                  db,
+		validator:           v,
 	}
 }
 
@@ -37,6 +93,17 @@ func (h *Handler) FindWithMeta(dest any, model any, meta *pagination.Meta, where
 	}
 
 	filters := &meta.Filters
+	if filterer, ok := model.(filterer); ok {
+		plain := []pagination.FilterEntry{}
+		for _, filter := range *filters {
+			if expr, ok := filterer.FilterBy(filter); ok {
+				query = query.Where(expr)
+				continue
+			}
+			plain = append(plain, filter)
+		}
+		filters = &plain
+	}
 	if len(*filters) > 0 {
 		query = query.Where(filtersToQuery(filters))
 	}
@@ -45,7 +112,12 @@ func (h *Handler) FindWithMeta(dest any, model any, meta *pagination.Meta, where
 
 	orders := &meta.Order
 	if len(*orders) > 0 {
-		query = query.Order(ordersToQuery(orders))
+		order, err := ordersToQuery(orders, model)
+		if err != nil {
+			query.AddError(err)
+			return query
+		}
+		query = query.Clauses(clause.OrderBy{Expression: order})
 	}
 
 	countMeta(meta, query)
@@ -53,22 +125,65 @@ func (h *Handler) FindWithMeta(dest any, model any, meta *pagination.Meta, where
 	return query.Find(dest)
 }
 
-func ordersToQuery(orders *[]pagination.OrderEntry) string {
-	query := ""
+// orderer is implemented by models with order keys that are not plain columns, such as computed scores
+type orderer interface {
+	OrderBy(key string, direction string) (clause.Expr, bool)
+}
+
+// sortable is implemented by models that can be ordered by plain columns, keys that are neither one of the
+// columns nor handled by orderer are rejected
+type sortable interface {
+	SortColumns() []string
+}
+
+// filterer is implemented by models with filters that are not plain comparisons
+type filterer interface {
+	FilterBy(filter pagination.FilterEntry) (clause.Expr, bool)
+}
+
+// ordersToQuery builds the ORDER BY of the orders, keys and directions are allowlisted because they can't be
+// bound as parameters
+func ordersToQuery(orders *[]pagination.OrderEntry, model any) (clause.Expr, error) {
+	query := clause.Expr{}
 
 	firstOrder := true
 
 	for _, order := range *orders {
+		if order.Direction != "asc" && order.Direction != "desc" {
+			return query, utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "order direction must be asc or desc")
+		}
+
 		if !firstOrder {
-			query += ", "
+			query.SQL += ", "
 		} else {
 			firstOrder = false
 		}
 
-		query += order.Key + " " + order.Direction
+		if orderer, ok := model.(orderer); ok {
+			if expr, ok := orderer.OrderBy(order.Key, order.Direction); ok {
+				query.SQL += expr.SQL
+				query.Vars = append(query.Vars, expr.Vars...)
+				continue
+			}
+		}
+
+		if !sortColumn(model, order.Key) {
+			return query, utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "can't order by "+order.Key)
+		}
+
+		query.SQL += order.Key + " " + order.Direction
 	}
 
-	return query
+	return query, nil
+}
+
+func sortColumn(model any, key string) bool {
+	sortable, ok := model.(sortable)
+	if !ok {
+		return false
+	}
+
+	return slices.Contains(sortable.SortColumns(), key)
 }
 
 func filtersToQuery(filters *[]pagination.FilterEntry) string {
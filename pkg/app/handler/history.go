@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+)
+
+// revertTodo restores the todo to the revision of the revision param
+func (h *Handler) revertTodo(c *fiber.Ctx, todo *model.Todo) error {
+	revision, err := strconv.ParseUint(c.Params("revision"), 10, 32)
+	if err != nil {
+		return &utils.BAD_REQUEST
+	}
+
+	if err := h.todoService.RevertTodo(todo, uint(revision), locals.JwtPayload(c).AccountID); err != nil {
+		return statusError(err)
+	}
+
+	return nil
+}
+
+// GetTodoHistory godoc
+//
+//	@Summary		Get todo history
+//	@Description	Lists the recorded changes of a todo with their actors, oldest first. Entries of one change share the revision.
+//	@Tags			todos
+//	@Produce		json
+//	@Param			id	path		int	true	"Todo ID"
+//	@Success		200	{object}	types.GetTodoHistoryResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/history [get]
+func (h *Handler) GetTodoHistory(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_VIEWER); err != nil {
+		return err
+	}
+
+	history := []model.TodoHistory{}
+	if err := h.todoService.FindHistory(&history, todo.ID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetTodoHistoryResponse{
+		History: history,
+	})
+}
+
+// RevertTodo godoc
+//
+//	@Summary		Revert todo
+//	@Description	Restores title, description, completion, due date, priority, estimate and tags of a todo to their values after the revision, needs the editor role. The revert is recorded as a new revision.
+//	@Tags			todos
+//	@Produce		json
+//	@Param			id			path		int	true	"Todo ID"
+//	@Param			revision	path		int	true	"Revision"
+//	@Success		200			{object}	types.UpdateTodoResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/revert/{revision} [post]
+func (h *Handler) RevertTodo(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	if err := h.revertTodo(c, todo); err != nil {
+		return err
+	}
+
+	return c.JSON(&types.UpdateTodoResponse{
+		Todo: *todo,
+	})
+}
+
+// VTodoHistory renders the history drawer of a todo
+func (h *Handler) VTodoHistory(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	role, err := h.findTodoRole(c, todo, c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	history := []model.TodoHistory{}
+	if err := h.todoService.FindHistory(&history, todo.ID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.TodoHistoryDrawer(*todo, history, model.ShareRoleAllows(role, model.SHARE_ROLE_EDITOR))))(c)
+}
+
+// VTodoRevert reverts a todo from the history drawer and reloads the page
+func (h *Handler) VTodoRevert(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	if err := h.revertTodo(c, todo); err != nil {
+		return err
+	}
+
+	c.Set("HX-Refresh", "true")
+
+	return c.Status(http.StatusOK).SendString("")
+}
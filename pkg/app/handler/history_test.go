@@ -0,0 +1,144 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestHistoryHandlerRevisions(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "history.owner@turbomeet.xyz", Password: pw}
+	viewer := &model.Account{Email: "history.viewer@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+	accountService.CreateAccount(viewer)
+
+	ownerAuth, _ := jwt.Generate(owner)
+	viewerAuth, _ := jwt.Generate(viewer)
+
+	history := func(todoPath string) []model.TodoHistory {
+		res := send("GET", todoPath+"/history", ownerAuth.Token, nil)
+		response := &types.GetTodoHistoryResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		return response.History
+	}
+
+	todo := *createTodo(t, ownerAuth.Token, model.Todo{Title: zero.StringFrom("Draft the agenda")})
+	todoPath := "/api/todos/" + strconv.FormatUint(uint64(todo.ID), 10)
+	service.NewShareService(DB).ShareTodo(&model.TodoShare{TodoID: todo.ID, AccountID: viewer.ID, Role: model.SHARE_ROLE_VIEWER, SharedByID: owner.ID})
+
+	t.Run("should record create, update and complete as revisions", func(t *testing.T) {
+		todo.Title = zero.StringFrom("Finalize the agenda")
+		todo.Description = zero.StringFrom("Send it around on friday")
+		if res := send("PUT", todoPath, ownerAuth.Token, &types.UpdateTodoRequest{Todo: todo}); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		req, _ := http.NewRequest("PUT", "/todos/"+strconv.FormatUint(uint64(todo.ID), 10)+"/complete", nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: ownerAuth.Token})
+		if res, _ := App.Test(req, -1); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		entries := history(todoPath)
+		actions := map[uint]string{}
+		for _, entry := range entries {
+			actions[entry.Revision] = entry.Action
+			if entry.ActorID != owner.ID {
+				t.Errorf("Expected the owner as actor, got %d", entry.ActorID)
+			}
+		}
+		if actions[1] != model.TODO_HISTORY_CREATED || actions[2] != model.TODO_HISTORY_UPDATED || actions[3] != model.TODO_HISTORY_COMPLETED {
+			t.Fatalf("Expected created, updated and completed revisions, got %v", actions)
+		}
+
+		for _, entry := range entries {
+			if entry.Revision == 2 && entry.Field == "title" && (entry.From.String != "Draft the agenda" || entry.To.String != "Finalize the agenda") {
+				t.Errorf("Expected the title change with before and after values, got %+v", entry)
+			}
+		}
+
+		duplicate := &model.TodoHistory{TodoID: todo.ID, Revision: 2, ActorID: owner.ID, Action: model.TODO_HISTORY_UPDATED, Field: "title"}
+		if err := DB.Create(duplicate).Error; err == nil {
+			t.Errorf("Expected a field to be changed once per revision")
+		}
+	})
+
+	t.Run("should revert to an earlier revision", func(t *testing.T) {
+		if res := send("POST", todoPath+"/revert/1", viewerAuth.Token, nil); res.StatusCode != 403 {
+			t.Errorf("Expected viewers to be rejected with 403, got %d", res.StatusCode)
+		}
+		if res := send("POST", todoPath+"/revert/42", ownerAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected unknown revisions to fail with 404, got %d", res.StatusCode)
+		}
+
+		res := send("POST", todoPath+"/revert/1", ownerAuth.Token, nil)
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		response := &types.UpdateTodoResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if response.Todo.Title.String != "Draft the agenda" || response.Todo.Description.String != "" || response.Todo.Completed {
+			t.Errorf("Expected the todo as it was created, got %+v", response.Todo)
+		}
+
+		entries := history(todoPath)
+		if last := entries[len(entries)-1]; last.Action != model.TODO_HISTORY_REVERTED || last.Revision != 4 {
+			t.Errorf("Expected the revert to be recorded as revision 4, got %+v", last)
+		}
+	})
+
+	t.Run("should render the history drawer", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/todos/"+strconv.FormatUint(uint64(todo.ID), 10)+"/history", nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: viewerAuth.Token})
+		res, _ := App.Test(req, -1)
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		body, _ := io.ReadAll(res.Body)
+		if !strings.Contains(string(body), "Finalize the agenda") || !strings.Contains(string(body), "history.owner@turbomeet.xyz") {
+			t.Errorf("Expected the changes and the actor in the drawer, got %s", body)
+		}
+		if strings.Contains(string(body), "/revert/") {
+			t.Errorf("Expected no restore buttons for viewers")
+		}
+	})
+
+	t.Run("should record imported todos", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "todos.csv")
+		part.Write([]byte("title,description,completed,completed_at\nImported,,false,\n"))
+		writer.Close()
+
+		req, _ := http.NewRequest("POST", "/api/todos/csv", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+ownerAuth.Token)
+		if res, _ := App.Test(req, -1); res.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+		}
+
+		imported := &model.Todo{}
+		DB.Where("title = ?", "Imported").Take(imported)
+		entries := history("/api/todos/" + strconv.FormatUint(uint64(imported.ID), 10))
+		if len(entries) != 1 || entries[0].Action != model.TODO_HISTORY_CREATED || entries[0].To.String != "Imported" {
+			t.Errorf("Expected the import to be recorded, got %+v", entries)
+		}
+	})
+}
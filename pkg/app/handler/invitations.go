@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/permission"
+	"github.com/nleiva/go-todo-api/utils"
+)
+
+// CreateInvitation godoc
+//
+//	@Summary		Create invitation
+//	@Description	Creates an invitation code for registration. Members can only grant permissions they have themselves.
+//	@Tags			invitations
+//	@Accept			json
+//	@Produce		json
+//	@Param			invitation	body		types.CreateInvitationDTO	true	"Invitation"
+//	@Success		201			{object}	types.CreateInvitationResponse
+//	@Security		BearerAuth
+//	@Router			/invitations [post]
+func (h *Handler) CreateInvitation(c *fiber.Ctx) error {
+	remoteData := &types.CreateInvitationDTO{}
+
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	payload := locals.JwtPayload(c)
+
+	invitation := &model.Invitation{
+		CreatedByID: payload.AccountID,
+		Permission:  permission.ACCOUNTS_READ_OWN,
+		MaxUses:     1,
+		ExpiresAt:   time.Now().Add(config.INVITATION_EXP),
+	}
+
+	if remoteData.MaxUses > 0 {
+		invitation.MaxUses = remoteData.MaxUses
+	}
+
+	if remoteData.ExpiresIn != "" {
+		expiresIn, err := time.ParseDuration(remoteData.ExpiresIn)
+		if err != nil || expiresIn <= 0 {
+			return utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "expiresIn must be a positive duration like 72h")
+		}
+		invitation.ExpiresAt = time.Now().Add(expiresIn)
+	}
+
+	if remoteData.Permission != nil {
+		invitation.Permission = *remoteData.Permission
+	}
+	if invitation.Permission&^payload.Permission != 0 && !locals.Can(c, permission.ACCOUNTS_MANAGE_ALL) {
+		return &utils.INVITATION_PERMISSION_EXCEEDED
+	}
+
+	code, err := h.invitationService.CreateInvitation(invitation)
+	if err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&types.CreateInvitationResponse{
+		Invitation: *invitation,
+		Code:       code,
+		URL:        c.BaseURL() + "/register?invite=" + code,
+	})
+}
+
+// GetInvitations godoc
+//
+//	@Summary		List invitations
+//	@Description	Lists the invitations created by the account, admins see all invitations
+//	@Tags			invitations
+//	@Produce		json
+//	@Success		200	{object}	types.GetInvitationsResponse
+//	@Security		BearerAuth
+//	@Router			/invitations [get]
+func (h *Handler) GetInvitations(c *fiber.Ctx) error {
+	invitations := []model.Invitation{}
+
+	err := h.invitationService.FindInvitations(&invitations, locals.JwtPayload(c).AccountID, locals.Can(c, permission.ACCOUNTS_MANAGE_ALL)).Error
+	if err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetInvitationsResponse{
+		Invitations: invitations,
+	})
+}
+
+// DeleteInvitation godoc
+//
+//	@Summary	Delete invitation
+//	@Tags		invitations
+//	@Param		id	path		int	true	"Invitation ID"
+//	@Success	204	{object}	nil	"No Content"
+//	@Security	BearerAuth
+//	@Router		/invitations/{id} [delete]
+func (h *Handler) DeleteInvitation(c *fiber.Ctx) error {
+	remoteId := c.Params("id")
+	if remoteId == "" {
+		return &utils.BAD_REQUEST
+	}
+
+	result := h.invitationService.DeleteInvitationByID(remoteId, locals.JwtPayload(c).AccountID, locals.Can(c, permission.ACCOUNTS_MANAGE_ALL))
+	if result.Error != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+	if result.RowsAffected == 0 {
+		return &utils.NOT_FOUND
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,191 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/pkg/permission"
+	"github.com/nleiva/go-todo-api/test"
+)
+
+func TestInvitationsHandlerRegistration(t *testing.T) {
+	// Setup
+	defer func(mode string) { config.REGISTRATION_MODE = mode }(config.REGISTRATION_MODE)
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	member := &model.Account{
+		Email:      "invitations.member@turbomeet.xyz",
+		Password:   pw,
+		Permission: permission.ACCOUNTS_READ_OWN,
+	}
+	admin := &model.Account{
+		Email:      "invitations.admin@turbomeet.xyz",
+		Password:   pw,
+		Permission: permission.ACCOUNTS_READ_OWN | permission.ACCOUNTS_READ_ALL | permission.ACCOUNTS_MANAGE_ALL,
+	}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(member)
+	accountService.CreateAccount(admin)
+
+	memberAuth, _ := jwt.Generate(member)
+	adminAuth, _ := jwt.Generate(admin)
+
+	register := func(email string, code string, token string) *http.Response {
+		return send("POST", "/api/auth/register", token, &types.RegisterDTO{
+			Account: types.RegisterDTOBody{
+				Email:           email,
+				Password:        "Correct-Horse-9",
+				ConfirmPassword: "Correct-Horse-9",
+				InvitationCode:  code,
+			},
+		})
+	}
+
+	invite := func(token string, dto *types.CreateInvitationDTO) *types.CreateInvitationResponse {
+		res := send("POST", "/api/invitations", token, dto)
+		if res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+
+		response := &types.CreateInvitationResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		return response
+	}
+
+	t.Run("should register without auth while open", func(t *testing.T) {
+		config.REGISTRATION_MODE = model.REGISTRATION_OPEN
+
+		if res := register("invitations.open@turbomeet.xyz", "", ""); res.StatusCode != 200 {
+			t.Errorf("Expected status code 200, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should reject registration while closed except for admins", func(t *testing.T) {
+		config.REGISTRATION_MODE = model.REGISTRATION_CLOSED
+
+		if res := register("invitations.closed@turbomeet.xyz", "", ""); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403, got %d", res.StatusCode)
+		}
+		if res := register("invitations.closed@turbomeet.xyz", "", memberAuth.Token); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403 for members, got %d", res.StatusCode)
+		}
+		if res := register("invitations.closed@turbomeet.xyz", "", adminAuth.Token); res.StatusCode != 200 {
+			t.Errorf("Expected status code 200 for admins, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should require a usable invitation while invite-only", func(t *testing.T) {
+		config.REGISTRATION_MODE = model.REGISTRATION_INVITE_ONLY
+
+		if res := register("invitations.none@turbomeet.xyz", "", ""); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403 without code, got %d", res.StatusCode)
+		}
+		if res := register("invitations.none@turbomeet.xyz", "made-up", ""); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403 for unknown code, got %d", res.StatusCode)
+		}
+
+		invitation := invite(memberAuth.Token, &types.CreateInvitationDTO{})
+		if !strings.HasSuffix(invitation.URL, "/register?invite="+invitation.Code) {
+			t.Errorf("Expected a registration url, got %s", invitation.URL)
+		}
+
+		if res := register("invitations.first@turbomeet.xyz", invitation.Code, ""); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if res := register("invitations.second@turbomeet.xyz", invitation.Code, ""); res.StatusCode != 403 {
+			t.Errorf("Expected a used up invitation to fail with 403, got %d", res.StatusCode)
+		}
+
+		expired := invite(memberAuth.Token, &types.CreateInvitationDTO{MaxUses: 5})
+		DB.Model(&model.Invitation{}).Where("id = ?", expired.Invitation.ID).Update("expires_at", time.Now().Add(-time.Minute))
+		if res := register("invitations.expired@turbomeet.xyz", expired.Code, ""); res.StatusCode != 403 {
+			t.Errorf("Expected an expired invitation to fail with 403, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should not use up invitations on failed registrations", func(t *testing.T) {
+		config.REGISTRATION_MODE = model.REGISTRATION_INVITE_ONLY
+
+		invitation := invite(memberAuth.Token, &types.CreateInvitationDTO{})
+		res := send("POST", "/api/auth/register", "", &types.RegisterDTO{
+			Account: types.RegisterDTOBody{
+				Email:           "invitations.weak@turbomeet.xyz",
+				Password:        "short",
+				ConfirmPassword: "short",
+				InvitationCode:  invitation.Code,
+			},
+		})
+		if res.StatusCode != 400 {
+			t.Fatalf("Expected status code 400, got %d", res.StatusCode)
+		}
+
+		if res := register("invitations.weak@turbomeet.xyz", invitation.Code, ""); res.StatusCode != 200 {
+			t.Errorf("Expected the invitation to still be usable, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should grant the permission of the invitation", func(t *testing.T) {
+		config.REGISTRATION_MODE = model.REGISTRATION_INVITE_ONLY
+
+		readAll := permission.ACCOUNTS_READ_OWN | permission.ACCOUNTS_READ_ALL
+		if res := send("POST", "/api/invitations", memberAuth.Token, &types.CreateInvitationDTO{Permission: &readAll}); res.StatusCode != 403 {
+			t.Errorf("Expected members not to grant more than they have, got %d", res.StatusCode)
+		}
+
+		invitation := invite(adminAuth.Token, &types.CreateInvitationDTO{Permission: &readAll})
+		if res := register("invitations.reader@turbomeet.xyz", invitation.Code, ""); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		account := &model.Account{}
+		DB.Take(account, "email = ?", "invitations.reader@turbomeet.xyz")
+		if account.Permission != readAll {
+			t.Errorf("Expected permission %d, got %d", readAll, account.Permission)
+		}
+	})
+
+	t.Run("should list and delete own invitations", func(t *testing.T) {
+		res := send("GET", "/api/invitations", memberAuth.Token, nil)
+		response := &types.GetInvitationsResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if len(response.Invitations) != 3 {
+			t.Fatalf("Expected 3 invitations of the member, got %d", len(response.Invitations))
+		}
+
+		adminInvitation := invite(adminAuth.Token, &types.CreateInvitationDTO{})
+		if res := send("DELETE", "/api/invitations/"+strconv.FormatUint(uint64(adminInvitation.Invitation.ID), 10), memberAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected members not to delete other invitations, got %d", res.StatusCode)
+		}
+		if res := send("DELETE", "/api/invitations/"+strconv.FormatUint(uint64(response.Invitations[0].ID), 10), memberAuth.Token, nil); res.StatusCode != 204 {
+			t.Errorf("Expected status code 204, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should show the register page errors in the form", func(t *testing.T) {
+		config.REGISTRATION_MODE = model.REGISTRATION_CLOSED
+
+		form := url.Values{
+			"email":           {"invitations.view@turbomeet.xyz"},
+			"password":        {"Correct-Horse-9"},
+			"confirmPassword": {"Correct-Horse-9"},
+		}
+		req, _ := http.NewRequest("POST", "/register", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		res, _ := App.Test(req, -1)
+
+		if res.Header.Get("HX-Retarget") != "#register-errors" {
+			t.Errorf("Expected the error to be swapped into the form, got %d", res.StatusCode)
+		}
+	})
+}
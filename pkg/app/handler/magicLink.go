@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+)
+
+// MAGIC_LINK_COOKIE binds sign-in links to the browser that requested them
+const MAGIC_LINK_COOKIE = "go-todo-api_magic"
+
+// VMagicLinkRequest emails a sign-in link. The response is the same for unknown emails.
+func (h *Handler) VMagicLinkRequest(c *fiber.Ctx) error {
+	remoteData := &types.MagicLinkRequestDTO{}
+
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return renderFormErrors(c, "#magic-link-result", []string{"Please enter a valid email address."})
+	}
+
+	nonce, err := h.magicLinkService.RequestLink(c.Context(), remoteData.Email, c.Cookies(MAGIC_LINK_COOKIE))
+	if err != nil {
+		return renderFormErrors(c, "#magic-link-result", []string{"The sign-in link could not be sent. Please try again later."})
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     MAGIC_LINK_COOKIE,
+		Value:    nonce,
+		Path:     "/auth/magic",
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		// Lax, the link is opened with a top level GET from the email client
+		SameSite: "Lax",
+		MaxAge:   int(config.MAGIC_LINK_EXP.Seconds()),
+	})
+
+	return adaptor.HTTPHandler(templ.Handler(view.MagicLinkSent(remoteData.Email)))(c)
+}
+
+// VMagicLinkCallback signs the account in with the auth cookies, exactly like VLoginPost
+func (h *Handler) VMagicLinkCallback(c *fiber.Ctx) error {
+	account, err := h.magicLinkService.RedeemLink(c.Query("token"), c.Cookies(MAGIC_LINK_COOKIE))
+	if err != nil {
+		message := "An error occurred. Please try again."
+		status := fiber.StatusInternalServerError
+
+		var requestError *utils.RequestError
+		if errors.As(err, &requestError) {
+			message = requestError.Message
+			status = requestError.StatusCode
+		}
+
+		return adaptor.HTTPHandler(templ.Handler(view.LoginPageWithError(h.GetBaseData(c), message), templ.WithStatus(status)))(c)
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     MAGIC_LINK_COOKIE,
+		Value:    "",
+		Path:     "/auth/magic",
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: "Lax",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+	})
+
+	h.recordLoginAttempt(c, account.Email, account, "")
+
+	auth, err := jwt.Generate(account)
+	if err != nil {
+		return err
+	}
+
+	setAuthCookies(c, auth)
+
+	return c.Redirect(loginRedirect(c), fiber.StatusFound)
+}
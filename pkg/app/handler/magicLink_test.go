@@ -0,0 +1,131 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/handler"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/mailer"
+	"github.com/nleiva/go-todo-api/test"
+)
+
+func TestMagicLinkHandlerLogin(t *testing.T) {
+	// Setup
+	defer func(cooldown time.Duration) { config.MAGIC_LINK_COOLDOWN = cooldown }(config.MAGIC_LINK_COOLDOWN)
+	defer test.ClearAllTables(DB)
+	config.MAGIC_LINK_COOLDOWN = 0
+
+	pw, _ := model.HashPassword("123456")
+	account := &model.Account{
+		Email:     "magic.link@turbomeet.xyz",
+		Password:  pw,
+		Firstname: "Magic",
+		Lastname:  "Link",
+	}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(account)
+
+	memory := mailer.Configured().(*mailer.MemoryMailer)
+	linkPattern := regexp.MustCompile(`http\S+/auth/magic/callback\?token=\S+`)
+
+	// requestLink asks for a link like the login page does and returns the browser cookie and the emailed link
+	requestLink := func(email string, cookie *http.Cookie) (*http.Cookie, string) {
+		req, _ := http.NewRequest("POST", "/auth/magic", strings.NewReader(url.Values{"email": {email}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		res, _ := App.Test(req, -1)
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		var browser *http.Cookie
+		for _, c := range res.Cookies() {
+			if c.Name == handler.MAGIC_LINK_COOKIE {
+				browser = c
+			}
+		}
+
+		message, _ := memory.Last(email)
+		link, _ := url.Parse(linkPattern.FindString(message.Text))
+		return browser, link.RequestURI()
+	}
+
+	open := func(link string, cookie *http.Cookie) *http.Response {
+		req, _ := http.NewRequest("GET", link, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		res, _ := App.Test(req, -1)
+		return res
+	}
+
+	t.Run("should sign in with the link in the requesting browser once", func(t *testing.T) {
+		browser, link := requestLink(account.Email, nil)
+		if browser == nil || !strings.HasPrefix(link, "/auth/magic/callback?token=") {
+			t.Fatalf("Expected a browser cookie and a link, got %v %q", browser, link)
+		}
+
+		if res := open(link, nil); res.StatusCode != 403 {
+			t.Errorf("Expected a forwarded link to fail with 403, got %d", res.StatusCode)
+		}
+
+		res := open(link, browser)
+		if res.StatusCode != 302 || res.Header.Get("Location") != "/" {
+			t.Fatalf("Expected redirect to /, got %d %s", res.StatusCode, res.Header.Get("Location"))
+		}
+
+		cookies := map[string]string{}
+		for _, c := range res.Cookies() {
+			cookies[c.Name] = c.Value
+		}
+		if cookies["go-todo-api_auth"] == "" || cookies["go-todo-api_refresh"] == "" {
+			t.Errorf("Expected auth and refresh cookies, got %v", cookies)
+		}
+
+		if res := open(link, browser); res.StatusCode != 400 {
+			t.Errorf("Expected a used link to fail with 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should keep the browser binding on repeated requests", func(t *testing.T) {
+		browser, _ := requestLink(account.Email, nil)
+		again, link := requestLink(account.Email, browser)
+
+		if again.Value != browser.Value {
+			t.Errorf("Expected the browser nonce to be kept")
+		}
+		if res := open(link, browser); res.StatusCode != 302 {
+			t.Errorf("Expected status code 302, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should not send links during the cooldown", func(t *testing.T) {
+		config.MAGIC_LINK_COOLDOWN = time.Hour
+		defer func() { config.MAGIC_LINK_COOLDOWN = 0 }()
+
+		_, first := requestLink(account.Email, nil)
+		_, second := requestLink(account.Email, nil)
+		if first != second {
+			t.Errorf("Expected no new email during the cooldown")
+		}
+	})
+
+	t.Run("should answer the same for unknown emails", func(t *testing.T) {
+		browser, _ := requestLink("magic.unknown@turbomeet.xyz", nil)
+		if browser == nil {
+			t.Errorf("Expected a browser cookie for unknown emails as well")
+		}
+		if _, found := memory.Last("magic.unknown@turbomeet.xyz"); found {
+			t.Errorf("Expected no email for unknown accounts")
+		}
+	})
+}
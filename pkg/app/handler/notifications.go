@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+)
+
+// notify stores the notification. Failures are logged, they must not fail the change the notification is about.
+func (h *Handler) notify(notification *model.Notification) {
+	if err := h.notificationService.Notify(notification).Error; err != nil {
+		log.Printf("notifying account %d of %s failed: %v", notification.AccountID, notification.Kind, err)
+	}
+}
+
+// GetNotifications godoc
+//
+//	@Summary		List notifications
+//	@Description	Lists the latest notifications of the account, newest first
+//	@Tags			notifications
+//	@Produce		json
+//	@Param			unread	query		bool	false	"Only unread notifications"
+//	@Success		200		{object}	types.GetNotificationsResponse
+//	@Security		BearerAuth
+//	@Router			/notifications [get]
+func (h *Handler) GetNotifications(c *fiber.Ctx) error {
+	notifications := []model.Notification{}
+
+	if err := h.notificationService.FindNotifications(&notifications, locals.JwtPayload(c).AccountID, c.QueryBool("unread")).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetNotificationsResponse{
+		Notifications: notifications,
+	})
+}
+
+// ReadNotification godoc
+//
+//	@Summary	Mark notification read
+//	@Tags		notifications
+//	@Param		id	path		int	true	"Notification ID"
+//	@Success	204	{object}	nil	"No Content"
+//	@Security	BearerAuth
+//	@Router		/notifications/{id}/read [put]
+func (h *Handler) ReadNotification(c *fiber.Ctx) error {
+	result := h.notificationService.MarkRead(c.Params("id"), locals.JwtPayload(c).AccountID)
+	if result.Error != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+	if result.RowsAffected == 0 {
+		return &utils.NOT_FOUND
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ReadAllNotifications godoc
+//
+//	@Summary	Mark all notifications read
+//	@Tags		notifications
+//	@Success	204	{object}	nil	"No Content"
+//	@Security	BearerAuth
+//	@Router		/notifications/read [put]
+func (h *Handler) ReadAllNotifications(c *fiber.Ctx) error {
+	if err := h.notificationService.MarkAllRead(locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *Handler) VNotifications(c *fiber.Ctx) error {
+	notifications := []model.Notification{}
+
+	if err := h.notificationService.FindNotifications(&notifications, locals.JwtPayload(c).AccountID, false).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.NotificationsPage(h.GetBaseData(c), notifications)))(c)
+}
+
+func (h *Handler) VNotificationsRead(c *fiber.Ctx) error {
+	if err := h.notificationService.MarkAllRead(locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	c.Set("HX-Refresh", "true")
+
+	return c.Status(http.StatusOK).SendString("")
+}
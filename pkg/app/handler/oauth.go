@@ -0,0 +1,407 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/permission"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+// LOGIN_REDIRECT_COOKIE remembers the authorization request while the account signs in
+const LOGIN_REDIRECT_COOKIE = "go-todo-api_next"
+
+// OAUTH_CSRF_COOKIE holds the token the consent form has to post back, so other sites can't approve requests
+// for the signed in account
+const OAUTH_CSRF_COOKIE = "go-todo-api_oauth_csrf"
+
+// CreateOAuthClient godoc
+//
+//	@Summary		Register OAuth client
+//	@Description	Registers a third party application. The secret of confidential clients is only returned once.
+//	@Tags			oauth
+//	@Accept			json
+//	@Produce		json
+//	@Param			client	body		types.CreateOAuthClientDTO	true	"Client"
+//	@Success		201		{object}	types.CreateOAuthClientResponse
+//	@Security		BearerAuth
+//	@Router			/oauth/clients [post]
+func (h *Handler) CreateOAuthClient(c *fiber.Ctx) error {
+	remoteData := &types.CreateOAuthClientDTO{}
+
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	scopes := permission.ParseScope(strings.Join(remoteData.Scopes, " "))
+	for _, scope := range scopes {
+		if !permission.IsKnownScope(scope) {
+			return utils.RequestErrorFrom(&utils.OAUTH_CLIENT_INVALID, "unknown scope "+scope)
+		}
+	}
+
+	// Public clients can only use the authorization code grant, which needs a redirect uri
+	if !remoteData.Confidential && len(remoteData.RedirectURIs) == 0 {
+		return utils.RequestErrorFrom(&utils.OAUTH_CLIENT_INVALID, "public clients need at least one redirect uri")
+	}
+
+	client := &model.OAuthClient{
+		AccountID:    locals.JwtPayload(c).AccountID,
+		Name:         remoteData.Name,
+		RedirectURIs: strings.Join(remoteData.RedirectURIs, " "),
+		Scope:        strings.Join(scopes, " "),
+		Confidential: remoteData.Confidential,
+	}
+
+	secret, err := h.oauthService.CreateClient(client)
+	if err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&types.CreateOAuthClientResponse{
+		Client:       *client,
+		ClientSecret: secret,
+	})
+}
+
+// GetOAuthClients godoc
+//
+//	@Summary	List OAuth clients
+//	@Tags		oauth
+//	@Produce	json
+//	@Success	200	{object}	types.GetOAuthClientsResponse
+//	@Security	BearerAuth
+//	@Router		/oauth/clients [get]
+func (h *Handler) GetOAuthClients(c *fiber.Ctx) error {
+	clients := []model.OAuthClient{}
+
+	if err := h.oauthService.FindClients(&clients, locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetOAuthClientsResponse{
+		Clients: clients,
+	})
+}
+
+// DeleteOAuthClient godoc
+//
+//	@Summary		Delete OAuth client
+//	@Description	Deletes the client and revokes all tokens issued to it
+//	@Tags			oauth
+//	@Param			id	path		int	true	"Client ID"
+//	@Success		204	{object}	nil	"No Content"
+//	@Security		BearerAuth
+//	@Router			/oauth/clients/{id} [delete]
+func (h *Handler) DeleteOAuthClient(c *fiber.Ctx) error {
+	remoteId := c.Params("id")
+	if remoteId == "" {
+		return &utils.BAD_REQUEST
+	}
+
+	if err := h.oauthService.DeleteClientByID(remoteId, locals.JwtPayload(c).AccountID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &utils.NOT_FOUND
+		}
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// VOAuthAuthorize shows the consent page of an authorization request. Accounts that aren't signed in
+// are sent to the login page first and come back here afterwards.
+func (h *Handler) VOAuthAuthorize(c *fiber.Ctx) error {
+	consent := &types.OAuthConsent{}
+	if err := c.QueryParser(consent); err != nil {
+		return &utils.BAD_REQUEST
+	}
+
+	client, oauthErr, redirect := h.oauthService.ValidateAuthorizationRequest(consent)
+	if oauthErr != nil {
+		return h.oauthAuthorizeError(c, consent, oauthErr, redirect)
+	}
+
+	if !locals.JwtPayload(c).Valid {
+		c.Cookie(&fiber.Cookie{
+			Name:     LOGIN_REDIRECT_COOKIE,
+			Value:    c.OriginalURL(),
+			HTTPOnly: true,
+			Secure:   c.Protocol() == "https",
+			SameSite: "Lax",
+			MaxAge:   int((10 * time.Minute).Seconds()),
+		})
+
+		return c.Redirect("/login", fiber.StatusFound)
+	}
+
+	csrfToken := utils.RandomString(32, "")
+	c.Cookie(&fiber.Cookie{
+		Name:     OAUTH_CSRF_COOKIE,
+		Value:    csrfToken,
+		Path:     "/oauth/authorize",
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: "Strict",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+	})
+
+	return adaptor.HTTPHandler(templ.Handler(view.OAuthConsentPage(h.GetBaseData(c), *client, *consent, csrfToken)))(c)
+}
+
+// VOAuthAuthorizePost handles the decision of the consent page and redirects back to the client.
+// The decision needs the CSRF token of the consent page in addition to the SameSite=Lax auth cookie.
+func (h *Handler) VOAuthAuthorizePost(c *fiber.Ctx) error {
+	consent := &types.OAuthConsent{}
+	if err := c.BodyParser(consent); err != nil {
+		return &utils.BAD_REQUEST
+	}
+
+	client, oauthErr, redirect := h.oauthService.ValidateAuthorizationRequest(consent)
+	if oauthErr != nil {
+		return h.oauthAuthorizeError(c, consent, oauthErr, redirect)
+	}
+
+	if !locals.JwtPayload(c).Valid {
+		return c.Redirect("/login", fiber.StatusFound)
+	}
+
+	cookie := c.Cookies(OAUTH_CSRF_COOKIE)
+	c.ClearCookie(OAUTH_CSRF_COOKIE)
+	if cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(c.FormValue("csrf_token"))) != 1 {
+		return h.oauthAuthorizeError(c, consent, &types.OAuthError{Code: "invalid_request", Description: "the consent form expired, please try again"}, false)
+	}
+
+	if c.FormValue("decision") != "approve" {
+		return h.oauthAuthorizeError(c, consent, &types.OAuthError{Code: "access_denied", Description: "the account denied the request"}, true)
+	}
+
+	code, err := h.oauthService.CreateAuthorizationCode(client, locals.JwtPayload(c).AccountID, consent)
+	if err != nil {
+		return h.oauthAuthorizeError(c, consent, &types.OAuthError{Code: "server_error"}, true)
+	}
+
+	return c.Redirect(oauthRedirectURI(consent, url.Values{"code": {code}}), fiber.StatusFound)
+}
+
+// oauthAuthorizeError redirects the error back to the client once its redirect uri is verified, else it is shown on a page
+func (h *Handler) oauthAuthorizeError(c *fiber.Ctx, consent *types.OAuthConsent, oauthErr *types.OAuthError, redirect bool) error {
+	if !redirect {
+		return adaptor.HTTPHandler(templ.Handler(view.OAuthErrorPage(h.GetBaseData(c), oauthErr.Description), templ.WithStatus(fiber.StatusBadRequest)))(c)
+	}
+
+	query := url.Values{"error": {oauthErr.Code}}
+	if oauthErr.Description != "" {
+		query.Set("error_description", oauthErr.Description)
+	}
+
+	return c.Redirect(oauthRedirectURI(consent, query), fiber.StatusFound)
+}
+
+// oauthRedirectURI adds the values and the state of the request to the redirect uri of the client
+func oauthRedirectURI(consent *types.OAuthConsent, values url.Values) string {
+	redirectURI, _ := url.Parse(consent.RedirectURI)
+
+	query := redirectURI.Query()
+	for name := range values {
+		query.Set(name, values.Get(name))
+	}
+	if consent.State != "" {
+		query.Set("state", consent.State)
+	}
+	redirectURI.RawQuery = query.Encode()
+
+	return redirectURI.String()
+}
+
+// loginRedirect returns where to continue after a login and clears the cookie. Only authorization requests
+// are accepted so the cookie can't be used as an open redirect.
+func loginRedirect(c *fiber.Ctx) string {
+	next := c.Cookies(LOGIN_REDIRECT_COOKIE)
+	if next == "" {
+		return "/"
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     LOGIN_REDIRECT_COOKIE,
+		Value:    "",
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: "Lax",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+	})
+
+	if !strings.HasPrefix(next, "/oauth/authorize?") {
+		return "/"
+	}
+
+	return next
+}
+
+// OAuthToken godoc
+//
+//	@Summary		OAuth token endpoint
+//	@Description	Issues tokens for the authorization_code, client_credentials and refresh_token grants (RFC 6749).
+//	@Description	Clients authenticate with HTTP Basic or the client_id and client_secret form fields.
+//	@Tags			oauth
+//	@Accept			x-www-form-urlencoded
+//	@Produce		json
+//	@Success		200	{object}	types.OAuthTokenResponse
+//	@Failure		400	{object}	types.OAuthError
+//	@Router			/oauth/token [post]
+func (h *Handler) OAuthToken(c *fiber.Ctx) error {
+	client, err := h.authenticateOAuthClient(c)
+	if err != nil {
+		return oauthErrorResponse(c, err)
+	}
+
+	var response *types.OAuthTokenResponse
+
+	switch c.FormValue("grant_type") {
+	case "authorization_code":
+		response, err = h.oauthService.ExchangeAuthorizationCode(client, c.FormValue("code"), c.FormValue("redirect_uri"), c.FormValue("code_verifier"))
+	case "client_credentials":
+		response, err = h.oauthService.ClientCredentials(client, c.FormValue("scope"))
+	case "refresh_token":
+		response, err = h.oauthService.RefreshToken(client, c.FormValue("refresh_token"), c.FormValue("scope"))
+	default:
+		err = &types.OAuthError{Code: "unsupported_grant_type", StatusCode: fiber.StatusBadRequest}
+	}
+	if err != nil {
+		return oauthErrorResponse(c, err)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	return c.JSON(response)
+}
+
+// OAuthIntrospect godoc
+//
+//	@Summary		OAuth token introspection
+//	@Description	Reports whether a token issued to the calling confidential client is active (RFC 7662)
+//	@Tags			oauth
+//	@Accept			x-www-form-urlencoded
+//	@Produce		json
+//	@Success		200	{object}	types.OAuthIntrospectionResponse
+//	@Router			/oauth/introspect [post]
+func (h *Handler) OAuthIntrospect(c *fiber.Ctx) error {
+	client, err := h.authenticateOAuthClient(c)
+	if err != nil {
+		return oauthErrorResponse(c, err)
+	}
+	if !client.Confidential {
+		return oauthErrorResponse(c, &types.OAuthError{Code: "unauthorized_client", Description: "only confidential clients may introspect tokens", StatusCode: fiber.StatusUnauthorized})
+	}
+
+	response := h.oauthService.Introspect(c.FormValue("token"))
+
+	// Clients only learn about their own tokens
+	if response.ClientID != client.ClientID {
+		response = &types.OAuthIntrospectionResponse{Active: false}
+	}
+
+	return c.JSON(response)
+}
+
+// OAuthRevoke godoc
+//
+//	@Summary		OAuth token revocation
+//	@Description	Revokes an access or refresh token of the calling client (RFC 7009). Revoking a refresh token revokes its whole grant.
+//	@Tags			oauth
+//	@Accept			x-www-form-urlencoded
+//	@Success		200
+//	@Router			/oauth/revoke [post]
+func (h *Handler) OAuthRevoke(c *fiber.Ctx) error {
+	client, err := h.authenticateOAuthClient(c)
+	if err != nil {
+		return oauthErrorResponse(c, err)
+	}
+
+	if err := h.oauthService.Revoke(client, c.FormValue("token")); err != nil {
+		return oauthErrorResponse(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// OAuthMetadata godoc
+//
+//	@Summary	OAuth authorization server metadata (RFC 8414)
+//	@Tags		oauth
+//	@Produce	json
+//	@Success	200	{object}	map[string]any
+//	@Router		/.well-known/oauth-authorization-server [get]
+func (h *Handler) OAuthMetadata(c *fiber.Ctx) error {
+	issuer := strings.TrimSuffix(config.OAUTH_ISSUER, "/")
+
+	scopes := make([]string, len(permission.SCOPES))
+	for i, scope := range permission.SCOPES {
+		scopes[i] = scope.Name
+	}
+
+	return c.JSON(fiber.Map{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/oauth/authorize",
+		"token_endpoint":                        issuer + "/oauth/token",
+		"introspection_endpoint":                issuer + "/oauth/introspect",
+		"revocation_endpoint":                   issuer + "/oauth/revoke",
+		"scopes_supported":                      scopes,
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "client_credentials", "refresh_token"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
+
+// authenticateOAuthClient reads the client credentials from the Authorization header (HTTP Basic) or the form
+func (h *Handler) authenticateOAuthClient(c *fiber.Ctx) (*model.OAuthClient, error) {
+	clientID, secret := c.FormValue("client_id"), c.FormValue("client_secret")
+
+	if header := c.Get(fiber.HeaderAuthorization); strings.HasPrefix(header, "Basic ") {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+		id, password, found := strings.Cut(string(decoded), ":")
+		if err != nil || !found {
+			return nil, &types.OAuthError{Code: "invalid_client", Description: "malformed basic authorization", StatusCode: fiber.StatusUnauthorized}
+		}
+
+		// RFC 6749 section 2.3.1 form encodes the credentials before they are base64 encoded
+		clientID, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(password)
+	}
+
+	if clientID == "" {
+		return nil, &types.OAuthError{Code: "invalid_client", Description: "missing client authentication", StatusCode: fiber.StatusUnauthorized}
+	}
+
+	return h.oauthService.AuthenticateClient(clientID, secret)
+}
+
+// oauthErrorResponse writes errors in the OAuth format, unexpected errors are not exposed
+func oauthErrorResponse(c *fiber.Ctx, err error) error {
+	var oauthErr *types.OAuthError
+	if !errors.As(err, &oauthErr) {
+		oauthErr = &types.OAuthError{Code: "server_error", StatusCode: fiber.StatusInternalServerError}
+	}
+
+	if oauthErr.Code == "invalid_client" {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	return c.Status(oauthErr.StatusCode).JSON(oauthErr)
+}
@@ -0,0 +1,437 @@
+package handler_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/pkg/app/handler"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+)
+
+func TestOAuthHandlerFlows(t *testing.T) {
+	// Setup
+	pw, _ := model.HashPassword("123456")
+	account := &model.Account{
+		Email:     "oauth.owner@turbomeet.xyz",
+		Password:  pw,
+		Firstname: "OAuth",
+		Lastname:  "Owner",
+	}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(account)
+	defer test.ClearAllTables(DB)
+
+	auth, _ := jwt.Generate(account)
+
+	createClient := func(dto *types.CreateOAuthClientDTO) *types.CreateOAuthClientResponse {
+		body, _ := json.Marshal(dto)
+		req, _ := http.NewRequest("POST", "/api/oauth/clients", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+		res, _ := App.Test(req, -1)
+		if res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+
+		response := &types.CreateOAuthClientResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		return response
+	}
+
+	tokenRequest := func(form url.Values, clientID string, secret string) (*http.Response, *types.OAuthTokenResponse) {
+		req, _ := http.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if secret != "" {
+			req.SetBasicAuth(clientID, secret)
+		}
+		res, _ := App.Test(req, -1)
+
+		response := &types.OAuthTokenResponse{}
+		if res.StatusCode == 200 {
+			json.NewDecoder(res.Body).Decode(response)
+		}
+		return res, response
+	}
+
+	apiRequest := func(method string, path string, token string) int {
+		req, _ := http.NewRequest(method, path, strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, _ := App.Test(req, -1)
+		return res.StatusCode
+	}
+
+	public := createClient(&types.CreateOAuthClientDTO{
+		Name:         "Mobile App",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		Scopes:       []string{"todos:read", "profile"},
+	})
+
+	verifier := "a-sufficiently-long-code-verifier-for-the-test-flow"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	authorizeQuery := url.Values{
+		"client_id":             {public.Client.ClientID},
+		"redirect_uri":          {"https://app.example.com/callback"},
+		"response_type":         {"code"},
+		"scope":                 {"todos:read"},
+		"state":                 {"xyz"},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	// consentForm opens the consent page as the signed in account and returns its CSRF token
+	consentForm := func(query url.Values) string {
+		req, _ := http.NewRequest("GET", "/oauth/authorize?"+query.Encode(), nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: auth.Token})
+		res, _ := App.Test(req, -1)
+		for _, cookie := range res.Cookies() {
+			if cookie.Name == handler.OAUTH_CSRF_COOKIE {
+				return cookie.Value
+			}
+		}
+		t.Fatalf("Expected a CSRF cookie on the consent page")
+		return ""
+	}
+
+	postConsent := func(query url.Values, decision string, csrfCookie string, csrfToken string) *http.Response {
+		form := url.Values{"decision": {decision}, "csrf_token": {csrfToken}}
+		for name := range query {
+			form.Set(name, query.Get(name))
+		}
+
+		req, _ := http.NewRequest("POST", "/oauth/authorize", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: auth.Token})
+		if csrfCookie != "" {
+			req.AddCookie(&http.Cookie{Name: handler.OAUTH_CSRF_COOKIE, Value: csrfCookie})
+		}
+		res, _ := App.Test(req, -1)
+		return res
+	}
+
+	// approve posts the consent form as the signed in account and returns the redirect to the client
+	approve := func(query url.Values, decision string) *url.URL {
+		csrfToken := consentForm(query)
+		res := postConsent(query, decision, csrfToken, csrfToken)
+		if res.StatusCode != 302 {
+			t.Fatalf("Expected redirect to the client, got %d", res.StatusCode)
+		}
+
+		location, _ := url.Parse(res.Header.Get("Location"))
+		return location
+	}
+
+	t.Run("should not create clients without auth", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/api/oauth/clients", nil)
+		res, _ := App.Test(req, -1)
+		if res.StatusCode != 401 {
+			t.Errorf("Expected status code 401, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should reject unknown scopes", func(t *testing.T) {
+		body, _ := json.Marshal(&types.CreateOAuthClientDTO{
+			Name:         "Bad",
+			RedirectURIs: []string{"https://app.example.com/callback"},
+			Scopes:       []string{"todos:delete-everything"},
+		})
+		req, _ := http.NewRequest("POST", "/api/oauth/clients", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+		res, _ := App.Test(req, -1)
+		if res.StatusCode != 400 {
+			t.Errorf("Expected status code 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should send unauthenticated accounts to the login", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/oauth/authorize?"+authorizeQuery.Encode(), nil)
+		res, _ := App.Test(req, -1)
+		if res.StatusCode != 302 || res.Header.Get("Location") != "/login" {
+			t.Fatalf("Expected redirect to /login, got %d %s", res.StatusCode, res.Header.Get("Location"))
+		}
+
+		found := false
+		for _, cookie := range res.Cookies() {
+			found = found || (cookie.Name == handler.LOGIN_REDIRECT_COOKIE && strings.HasPrefix(cookie.Value, "/oauth/authorize?"))
+		}
+		if !found {
+			t.Errorf("Expected the authorization request to be remembered")
+		}
+	})
+
+	t.Run("should show the consent page", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/oauth/authorize?"+authorizeQuery.Encode(), nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: auth.Token})
+		res, _ := App.Test(req, -1)
+		if res.StatusCode != 200 {
+			t.Errorf("Expected status code 200, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should not redirect to unregistered uris", func(t *testing.T) {
+		query := url.Values{}
+		for name := range authorizeQuery {
+			query.Set(name, authorizeQuery.Get(name))
+		}
+		query.Set("redirect_uri", "https://evil.example.com/callback")
+
+		req, _ := http.NewRequest("GET", "/oauth/authorize?"+query.Encode(), nil)
+		res, _ := App.Test(req, -1)
+		if res.StatusCode != 400 {
+			t.Errorf("Expected status code 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should reject consent posted without the CSRF token", func(t *testing.T) {
+		if res := postConsent(authorizeQuery, "approve", "", ""); res.StatusCode != 400 {
+			t.Errorf("Expected a missing token to fail with 400, got %d", res.StatusCode)
+		}
+		csrfToken := consentForm(authorizeQuery)
+		if res := postConsent(authorizeQuery, "approve", csrfToken, "forged"); res.StatusCode != 400 {
+			t.Errorf("Expected a wrong token to fail with 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should redirect a denied consent", func(t *testing.T) {
+		location := approve(authorizeQuery, "deny")
+		if location.Query().Get("error") != "access_denied" || location.Query().Get("state") != "xyz" {
+			t.Errorf("Expected access_denied with state, got %s", location)
+		}
+	})
+
+	var accessToken, refreshToken string
+
+	t.Run("should issue tokens for an approved code with PKCE", func(t *testing.T) {
+		location := approve(authorizeQuery, "approve")
+		code := location.Query().Get("code")
+		if code == "" || location.Query().Get("state") != "xyz" {
+			t.Fatalf("Expected code with state, got %s", location)
+		}
+
+		exchange := url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {public.Client.ClientID},
+			"code":          {code},
+			"redirect_uri":  {"https://app.example.com/callback"},
+			"code_verifier": {"wrong-verifier"},
+		}
+		if res, _ := tokenRequest(exchange, "", ""); res.StatusCode != 400 {
+			t.Errorf("Expected a wrong verifier to fail with 400, got %d", res.StatusCode)
+		}
+
+		// The failed attempt used up the code
+		location = approve(authorizeQuery, "approve")
+		exchange.Set("code", location.Query().Get("code"))
+		exchange.Set("code_verifier", verifier)
+
+		res, tokens := tokenRequest(exchange, "", "")
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if tokens.Scope != "todos:read" || tokens.AccessToken == "" || tokens.RefreshToken == "" {
+			t.Fatalf("Unexpected token response %+v", tokens)
+		}
+
+		if res, _ := tokenRequest(exchange, "", ""); res.StatusCode != 400 {
+			t.Errorf("Expected a reused code to fail with 400, got %d", res.StatusCode)
+		}
+
+		accessToken, refreshToken = tokens.AccessToken, tokens.RefreshToken
+	})
+
+	t.Run("should enforce the scope of the token", func(t *testing.T) {
+		if status := apiRequest("GET", "/api/todos", accessToken); status != 200 {
+			t.Errorf("Expected todos:read to list todos, got %d", status)
+		}
+		if status := apiRequest("POST", "/api/todos", accessToken); status != 403 {
+			t.Errorf("Expected todos:read not to create todos, got %d", status)
+		}
+		if status := apiRequest("GET", "/api/auth/me", accessToken); status != 403 {
+			t.Errorf("Expected profile not granted, got %d", status)
+		}
+		if status := apiRequest("GET", "/api/oauth/clients", accessToken); status != 403 {
+			t.Errorf("Expected OAuth tokens not to manage clients, got %d", status)
+		}
+		if status := apiRequest("GET", "/api/todos/trash", accessToken); status != 403 {
+			t.Errorf("Expected todo routes that aren't listed to be first party only, got %d", status)
+		}
+	})
+
+	t.Run("should only return the profile to OAuth tokens", func(t *testing.T) {
+		query := url.Values{}
+		for name := range authorizeQuery {
+			query.Set(name, authorizeQuery.Get(name))
+		}
+		query.Set("scope", "profile")
+
+		location := approve(query, "approve")
+		_, tokens := tokenRequest(url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {public.Client.ClientID},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {"https://app.example.com/callback"},
+			"code_verifier": {verifier},
+		}, "", "")
+
+		req, _ := http.NewRequest("GET", "/api/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		res, _ := App.Test(req, -1)
+		response := &types.GetMeResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if res.StatusCode != 200 || response.Account.Email != account.Email {
+			t.Fatalf("Expected the profile, got %d", res.StatusCode)
+		}
+		if response.Auth != nil {
+			t.Errorf("Expected no first party tokens for an OAuth token")
+		}
+	})
+
+	t.Run("should rotate refresh tokens", func(t *testing.T) {
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {public.Client.ClientID},
+			"refresh_token": {refreshToken},
+		}
+
+		res, tokens := tokenRequest(form, "", "")
+		if res.StatusCode != 200 || tokens.RefreshToken == refreshToken {
+			t.Fatalf("Expected a new refresh token, got %d", res.StatusCode)
+		}
+
+		// Reusing the old refresh token revokes the whole grant
+		if res, _ := tokenRequest(form, "", ""); res.StatusCode != 400 {
+			t.Errorf("Expected a reused refresh token to fail with 400, got %d", res.StatusCode)
+		}
+		if status := apiRequest("GET", "/api/todos", tokens.AccessToken); status != 401 {
+			t.Errorf("Expected the grant to be revoked, got %d", status)
+		}
+	})
+
+	t.Run("should rotate a refresh token used twice at the same time only once", func(t *testing.T) {
+		location := approve(authorizeQuery, "approve")
+		_, tokens := tokenRequest(url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {public.Client.ClientID},
+			"code":          {location.Query().Get("code")},
+			"redirect_uri":  {"https://app.example.com/callback"},
+			"code_verifier": {verifier},
+		}, "", "")
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {public.Client.ClientID},
+			"refresh_token": {tokens.RefreshToken},
+		}
+
+		statuses := make(chan int, 2)
+		var wg sync.WaitGroup
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, _ := tokenRequest(form, "", "")
+				statuses <- res.StatusCode
+			}()
+		}
+		wg.Wait()
+		close(statuses)
+
+		rotated := 0
+		for status := range statuses {
+			if status == 200 {
+				rotated++
+			}
+		}
+		if rotated > 1 {
+			t.Errorf("Expected the refresh token to be rotated once, got %d new token pairs", rotated)
+		}
+	})
+
+	confidential := createClient(&types.CreateOAuthClientDTO{
+		Name:         "Sync Service",
+		Scopes:       []string{"todos:read", "todos:write"},
+		Confidential: true,
+	})
+
+	t.Run("should issue client credentials tokens to confidential clients", func(t *testing.T) {
+		form := url.Values{"grant_type": {"client_credentials"}, "scope": {"todos:write"}}
+
+		if res, _ := tokenRequest(form, confidential.Client.ClientID, "wrong"); res.StatusCode != 401 {
+			t.Errorf("Expected a wrong secret to fail with 401, got %d", res.StatusCode)
+		}
+
+		res, tokens := tokenRequest(form, confidential.Client.ClientID, confidential.ClientSecret)
+		if res.StatusCode != 200 || tokens.RefreshToken != "" {
+			t.Fatalf("Expected an access token only, got %d", res.StatusCode)
+		}
+
+		if status := apiRequest("POST", "/api/todos/random", tokens.AccessToken); status != 200 && status != 201 {
+			t.Errorf("Expected todos:write to create todos, got %d", status)
+		}
+		if status := apiRequest("DELETE", "/api/todos/trash", tokens.AccessToken); status != 403 {
+			t.Errorf("Expected todos:write not to empty the trash, got %d", status)
+		}
+
+		publicForm := url.Values{"grant_type": {"client_credentials"}, "client_id": {public.Client.ClientID}}
+		if res, _ := tokenRequest(publicForm, "", ""); res.StatusCode != 400 {
+			t.Errorf("Expected public clients to be rejected, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should introspect and revoke tokens", func(t *testing.T) {
+		_, tokens := tokenRequest(url.Values{"grant_type": {"client_credentials"}}, confidential.Client.ClientID, confidential.ClientSecret)
+
+		introspect := func() *types.OAuthIntrospectionResponse {
+			req, _ := http.NewRequest("POST", "/oauth/introspect", strings.NewReader(url.Values{"token": {tokens.AccessToken}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth(confidential.Client.ClientID, confidential.ClientSecret)
+			res, _ := App.Test(req, -1)
+
+			response := &types.OAuthIntrospectionResponse{}
+			json.NewDecoder(res.Body).Decode(response)
+			return response
+		}
+
+		if response := introspect(); !response.Active || response.Username != account.Email || response.Scope != "todos:read todos:write" {
+			t.Errorf("Expected an active token, got %+v", response)
+		}
+
+		req, _ := http.NewRequest("POST", "/oauth/revoke", strings.NewReader(url.Values{"token": {tokens.AccessToken}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(confidential.Client.ClientID, confidential.ClientSecret)
+		if res, _ := App.Test(req, -1); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		if response := introspect(); response.Active {
+			t.Errorf("Expected the revoked token to be inactive")
+		}
+		if status := apiRequest("GET", "/api/todos", tokens.AccessToken); status != 401 {
+			t.Errorf("Expected the revoked token to be rejected, got %d", status)
+		}
+	})
+
+	t.Run("should publish the server metadata", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/.well-known/oauth-authorization-server", nil)
+		res, _ := App.Test(req, -1)
+
+		metadata := map[string]any{}
+		json.NewDecoder(res.Body).Decode(&metadata)
+		if res.StatusCode != 200 || metadata["token_endpoint"] == nil {
+			t.Errorf("Expected metadata, got %d %v", res.StatusCode, metadata)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+)
+
+// OIDC_STATE_COOKIE binds a running single sign-on flow to the browser that started it
+const OIDC_STATE_COOKIE = "go-todo-api_oidc"
+
+// VOIDCLogin redirects the browser to the login page of the identity provider
+func (h *Handler) VOIDCLogin(c *fiber.Ctx) error {
+	state, authURL, err := h.oidcService.BeginLogin(c.Context())
+	if err != nil {
+		return h.oidcLoginError(c, err)
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     OIDC_STATE_COOKIE,
+		Value:    state,
+		Path:     "/auth/oidc",
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		// Lax, the provider redirects back with a top level GET
+		SameSite: "Lax",
+		MaxAge:   int(config.OIDC_STATE_EXP.Seconds()),
+	})
+
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// VOIDCCallback finishes the flow the provider redirected back from and signs the account in with the auth cookies
+func (h *Handler) VOIDCCallback(c *fiber.Ctx) error {
+	state := c.Query("state")
+	cookieState := c.Cookies(OIDC_STATE_COOKIE)
+
+	c.Cookie(&fiber.Cookie{
+		Name:     OIDC_STATE_COOKIE,
+		Value:    "",
+		Path:     "/auth/oidc",
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: "Lax",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+	})
+
+	// The provider reports errors like a denied consent through the redirect
+	if providerError := c.Query("error"); providerError != "" {
+		return h.oidcLoginError(c, utils.RequestErrorFrom(&utils.OIDC_LOGIN_FAILED, providerError))
+	}
+
+	if state == "" || state != cookieState {
+		return h.oidcLoginError(c, &utils.OIDC_STATE_INVALID)
+	}
+
+	account, err := h.oidcService.FinishLogin(c.Context(), state, c.Query("code"))
+	if err != nil {
+		return h.oidcLoginError(c, err)
+	}
+
+	auth, err := jwt.Generate(account)
+	if err != nil {
+		return err
+	}
+
+	setAuthCookies(c, auth)
+
+	return c.Redirect(loginRedirect(c), fiber.StatusFound)
+}
+
+// oidcLoginError shows the login page with the message of the request error, other errors are not exposed
+func (h *Handler) oidcLoginError(c *fiber.Ctx, err error) error {
+	message := utils.OIDC_LOGIN_FAILED.Message
+	status := fiber.StatusInternalServerError
+
+	var requestError *utils.RequestError
+	if errors.As(err, &requestError) {
+		message = requestError.Message
+		status = requestError.StatusCode
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.LoginPageWithError(h.GetBaseData(c), message), templ.WithStatus(status)))(c)
+}
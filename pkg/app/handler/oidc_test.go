@@ -0,0 +1,208 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/handler"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+)
+
+func TestOIDCHandlerLogin(t *testing.T) {
+	// Setup
+	idp := test.NewMockIdP("go-todo-api", "secret")
+	defer idp.Close()
+
+	defer func(issuer, clientID, clientSecret string, autoProvision bool) {
+		config.OIDC_ISSUER_URL = issuer
+		config.OIDC_CLIENT_ID = clientID
+		config.OIDC_CLIENT_SECRET = clientSecret
+		config.OIDC_AUTO_PROVISION = autoProvision
+	}(config.OIDC_ISSUER_URL, config.OIDC_CLIENT_ID, config.OIDC_CLIENT_SECRET, config.OIDC_AUTO_PROVISION)
+
+	defer func(mode string) { config.REGISTRATION_MODE = mode }(config.REGISTRATION_MODE)
+
+	config.OIDC_ISSUER_URL = idp.Issuer()
+	config.OIDC_CLIENT_ID = idp.ClientID
+	config.OIDC_CLIENT_SECRET = idp.ClientSecret
+
+	pw, _ := model.HashPassword("123456")
+	existing := &model.Account{
+		Email:     "oidc.existing@turbomeet.xyz",
+		Password:  pw,
+		Firstname: "OIDC",
+		Lastname:  "Existing",
+	}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(existing)
+
+	// login runs the whole flow: start at the app, approve at the provider and return to the callback
+	login := func(withStateCookie bool) *http.Response {
+		req, _ := http.NewRequest("GET", "/auth/oidc/login", nil)
+		res, _ := App.Test(req, -1)
+		if res.StatusCode != 302 {
+			t.Fatalf("Expected redirect to the provider, got %d", res.StatusCode)
+		}
+
+		callback, err := idp.Authorize(res.Header.Get("Location"))
+		if err != nil {
+			t.Fatalf("Mock provider failed: %v", err)
+		}
+
+		req, _ = http.NewRequest("GET", callback.RequestURI(), nil)
+		if withStateCookie {
+			for _, cookie := range res.Cookies() {
+				if cookie.Name == handler.OIDC_STATE_COOKIE {
+					req.AddCookie(cookie)
+				}
+			}
+		}
+
+		res, _ = App.Test(req, -1)
+		return res
+	}
+
+	authCookie := func(res *http.Response) *jwt.TokenPayload {
+		for _, cookie := range res.Cookies() {
+			if cookie.Name == "go-todo-api_auth" && cookie.Value != "" {
+				payload, err := jwt.Verify(cookie.Value)
+				if err != nil {
+					t.Fatalf("Invalid auth cookie: %v", err)
+				}
+				return payload
+			}
+		}
+
+		t.Fatalf("Expected an auth cookie")
+		return nil
+	}
+
+	t.Run("should provision a new account", func(t *testing.T) {
+		idp.Claims = map[string]any{
+			"sub":            "new-user",
+			"email":          "OIDC.New@turbomeet.xyz",
+			"email_verified": true,
+			"given_name":     "New",
+			"family_name":    "User",
+		}
+
+		res := login(true)
+		if res.StatusCode != 302 || res.Header.Get("Location") != "/" {
+			t.Fatalf("Expected redirect to /, got %d %s", res.StatusCode, res.Header.Get("Location"))
+		}
+
+		account := &model.Account{}
+		if err := DB.Take(account, "email = ?", "oidc.new@turbomeet.xyz").Error; err != nil {
+			t.Fatalf("Expected a provisioned account: %v", err)
+		}
+		if account.Firstname != "New" || account.Lastname != "User" {
+			t.Errorf("Expected mapped names, got %s %s", account.Firstname, account.Lastname)
+		}
+		if payload := authCookie(res); payload.AccountID != account.ID {
+			t.Errorf("Expected token for account %d, got %d", account.ID, payload.AccountID)
+		}
+
+		// Signing in again uses the linked identity instead of creating another account
+		res = login(true)
+		if payload := authCookie(res); payload.AccountID != account.ID {
+			t.Errorf("Expected token for account %d, got %d", account.ID, payload.AccountID)
+		}
+
+		var count int64
+		DB.Model(&model.OIDCIdentity{}).Where("subject = ?", "new-user").Count(&count)
+		if count != 1 {
+			t.Errorf("Expected 1 linked identity, got %d", count)
+		}
+	})
+
+	t.Run("should link an existing account by verified email", func(t *testing.T) {
+		idp.Claims = map[string]any{
+			"sub":            "existing-user",
+			"email":          "oidc.existing@turbomeet.xyz",
+			"email_verified": true,
+		}
+
+		res := login(true)
+		if payload := authCookie(res); payload.AccountID != existing.ID {
+			t.Errorf("Expected token for account %d, got %d", existing.ID, payload.AccountID)
+		}
+	})
+
+	t.Run("should not link an unverified email", func(t *testing.T) {
+		idp.Claims = map[string]any{
+			"sub":            "attacker",
+			"email":          "oidc.existing@turbomeet.xyz",
+			"email_verified": false,
+		}
+
+		if res := login(true); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should not provision when disabled", func(t *testing.T) {
+		config.OIDC_AUTO_PROVISION = false
+		defer func() { config.OIDC_AUTO_PROVISION = true }()
+
+		idp.Claims = map[string]any{
+			"sub":            "unknown-user",
+			"email":          "oidc.unknown@turbomeet.xyz",
+			"email_verified": true,
+		}
+
+		if res := login(true); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should not provision unless registration is open", func(t *testing.T) {
+		config.REGISTRATION_MODE = model.REGISTRATION_CLOSED
+		defer func() { config.REGISTRATION_MODE = model.REGISTRATION_OPEN }()
+
+		idp.Claims = map[string]any{
+			"sub":            "closed-user",
+			"email":          "oidc.closed@turbomeet.xyz",
+			"email_verified": true,
+		}
+
+		if res := login(true); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should reject a callback from another browser", func(t *testing.T) {
+		idp.Claims = map[string]any{"sub": "new-user"}
+
+		if res := login(false); res.StatusCode != 400 {
+			t.Errorf("Expected status code 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should read missing claims from userinfo", func(t *testing.T) {
+		idp.Claims = map[string]any{
+			"sub":            "userinfo-user",
+			"email":          "oidc.userinfo@turbomeet.xyz",
+			"email_verified": true,
+		}
+		idp.UserinfoOnlyClaims = []string{"email", "email_verified"}
+		defer func() { idp.UserinfoOnlyClaims = nil }()
+
+		res := login(true)
+		if res.StatusCode != 302 {
+			t.Fatalf("Expected status code 302, got %d", res.StatusCode)
+		}
+
+		account := &model.Account{}
+		if err := DB.Take(account, "email = ?", "oidc.userinfo@turbomeet.xyz").Error; err != nil {
+			t.Errorf("Expected a provisioned account: %v", err)
+		}
+	})
+
+	// Cleanup
+	test.ClearTables(DB, []string{"oidc_identities", "oidc_sessions"})
+	test.ClearAllTables(DB)
+}
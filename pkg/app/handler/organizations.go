@@ -0,0 +1,424 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// ORGANIZATION_HEADER selects the organization of API requests, without it the personal space is used
+	ORGANIZATION_HEADER = "X-Organization-ID"
+	// ORGANIZATION_COOKIE remembers the organization selected with the switcher of the navigation
+	ORGANIZATION_COOKIE = "go-todo-api_org"
+)
+
+// currentSpace returns the space the todos of the request belong to. API clients select an organization with
+// the ORGANIZATION_HEADER and get an error if they aren't a member. The views use the ORGANIZATION_COOKIE and
+// fall back to the personal space if the membership is gone.
+func (h *Handler) currentSpace(c *fiber.Ctx) (model.Space, error) {
+	space := model.Space{AccountID: locals.JwtPayload(c).AccountID}
+
+	if header := c.Get(ORGANIZATION_HEADER); header != "" {
+		membership, err := h.findMembership(c, header)
+		if err != nil {
+			return space, err
+		}
+		space.OrganizationID = membership.OrganizationID
+		return space, nil
+	}
+
+	if cookie := c.Cookies(ORGANIZATION_COOKIE); cookie != "" {
+		if membership, err := h.findMembership(c, cookie); err == nil {
+			space.OrganizationID = membership.OrganizationID
+		}
+	}
+
+	return space, nil
+}
+
+// findMembership returns the membership of the account in the organization
+func (h *Handler) findMembership(c *fiber.Ctx, organizationID string) (*model.Membership, error) {
+	membership := &model.Membership{}
+
+	if err := h.organizationService.FindMembership(membership, organizationID, locals.JwtPayload(c).AccountID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.ORGANIZATION_NOT_MEMBER
+		}
+		return nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return membership, nil
+}
+
+// requireRole returns the membership of the account in the organization of the id param if it has at least the
+// role. Organizations of other accounts are reported as not found.
+func (h *Handler) requireRole(c *fiber.Ctx, role string) (*model.Membership, error) {
+	membership, err := h.findMembership(c, c.Params("id"))
+	if err != nil {
+		if errors.Is(err, &utils.ORGANIZATION_NOT_MEMBER) {
+			return nil, &utils.NOT_FOUND
+		}
+		return nil, err
+	}
+
+	if !membership.HasRole(role) {
+		return nil, &utils.ORGANIZATION_ROLE_REQUIRED
+	}
+
+	return membership, nil
+}
+
+// CreateOrganization godoc
+//
+//	@Summary		Create organization
+//	@Description	Creates an organization with the account as its owner
+//	@Tags			organizations
+//	@Accept			json
+//	@Produce		json
+//	@Param			organization	body		types.CreateOrganizationDTO	true	"Organization"
+//	@Success		201				{object}	types.OrganizationResponse
+//	@Security		BearerAuth
+//	@Router			/organizations [post]
+func (h *Handler) CreateOrganization(c *fiber.Ctx) error {
+	remoteData := &types.CreateOrganizationDTO{}
+
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	organization := &model.Organization{Name: remoteData.Organization.Name}
+
+	if err := h.organizationService.CreateOrganization(organization, locals.JwtPayload(c).AccountID); err != nil {
+		return utils.RequestErrorFrom(&utils.INTERNAL_SERVER_ERROR, err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&types.OrganizationResponse{
+		Organization: *organization,
+	})
+}
+
+// GetOrganizations godoc
+//
+//	@Summary		List organizations
+//	@Description	Lists the memberships of the account with their organizations
+//	@Tags			organizations
+//	@Produce		json
+//	@Success		200	{object}	types.GetOrganizationsResponse
+//	@Security		BearerAuth
+//	@Router			/organizations [get]
+func (h *Handler) GetOrganizations(c *fiber.Ctx) error {
+	memberships := []model.Membership{}
+
+	if err := h.organizationService.FindMemberships(&memberships, locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetOrganizationsResponse{
+		Memberships: memberships,
+	})
+}
+
+// GetOrganization godoc
+//
+//	@Summary		Get organization
+//	@Description	Gets an organization of the account with its members
+//	@Tags			organizations
+//	@Produce		json
+//	@Param			id	path		int	true	"Organization ID"
+//	@Success		200	{object}	types.GetOrganizationResponse
+//	@Security		BearerAuth
+//	@Router			/organizations/{id} [get]
+func (h *Handler) GetOrganization(c *fiber.Ctx) error {
+	membership, err := h.requireRole(c, model.ORGANIZATION_ROLE_MEMBER)
+	if err != nil {
+		return err
+	}
+
+	members := []model.Membership{}
+	if err := h.organizationService.FindMembers(&members, membership.OrganizationID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetOrganizationResponse{
+		Organization: *membership.Organization,
+		Members:      members,
+	})
+}
+
+// UpdateOrganization godoc
+//
+//	@Summary		Update organization
+//	@Description	Renames an organization, needs the admin role
+//	@Tags			organizations
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int							true	"Organization ID"
+//	@Param			organization	body		types.UpdateOrganizationDTO	true	"Organization"
+//	@Success		200				{object}	types.OrganizationResponse
+//	@Security		BearerAuth
+//	@Router			/organizations/{id} [put]
+func (h *Handler) UpdateOrganization(c *fiber.Ctx) error {
+	remoteData := &types.UpdateOrganizationDTO{}
+
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	membership, err := h.requireRole(c, model.ORGANIZATION_ROLE_ADMIN)
+	if err != nil {
+		return err
+	}
+
+	organization := membership.Organization
+	organization.Name = remoteData.Organization.Name
+
+	if err := h.organizationService.UpdateOrganization(organization).Error; err != nil {
+		return utils.RequestErrorFrom(&utils.INTERNAL_SERVER_ERROR, err.Error())
+	}
+
+	return c.JSON(&types.OrganizationResponse{
+		Organization: *organization,
+	})
+}
+
+// DeleteOrganization godoc
+//
+//	@Summary		Delete organization
+//	@Description	Deletes an organization with its memberships, todos and their attachments, needs the owner role
+//	@Tags			organizations
+//	@Param			id	path		int	true	"Organization ID"
+//	@Success		204	{object}	nil	"No Content"
+//	@Security		BearerAuth
+//	@Router			/organizations/{id} [delete]
+func (h *Handler) DeleteOrganization(c *fiber.Ctx) error {
+	membership, err := h.requireRole(c, model.ORGANIZATION_ROLE_OWNER)
+	if err != nil {
+		return err
+	}
+
+	todos := []model.Todo{}
+	if err := h.todoService.FindTodos(&todos, model.Space{AccountID: membership.AccountID, OrganizationID: membership.OrganizationID}).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if err := h.organizationService.DeleteOrganization(membership.OrganizationID); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	todoIDs := make([]uint, len(todos))
+	for i, todo := range todos {
+		todoIDs[i] = todo.ID
+	}
+	if err := h.attachmentService.DeleteTodoAttachments(c.Context(), todoIDs...); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AddOrganizationMember godoc
+//
+//	@Summary		Add member
+//	@Description	Adds an account to the organization by email, needs the admin role. Only owners can add owners.
+//	@Tags			organizations
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Organization ID"
+//	@Param			member	body		types.AddMemberDTO	true	"Member"
+//	@Success		201		{object}	types.MemberResponse
+//	@Security		BearerAuth
+//	@Router			/organizations/{id}/members [post]
+func (h *Handler) AddOrganizationMember(c *fiber.Ctx) error {
+	remoteData := &types.AddMemberDTO{}
+
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	membership, err := h.requireRole(c, model.ORGANIZATION_ROLE_ADMIN)
+	if err != nil {
+		return err
+	}
+
+	role := remoteData.Role
+	if role == "" {
+		role = model.ORGANIZATION_ROLE_MEMBER
+	}
+	if role == model.ORGANIZATION_ROLE_OWNER && !membership.HasRole(model.ORGANIZATION_ROLE_OWNER) {
+		return &utils.ORGANIZATION_ROLE_REQUIRED
+	}
+
+	account := &model.Account{}
+	if err := h.accountService.FindAccountByEmail(account, remoteData.Email).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &utils.NOT_FOUND
+		}
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	member := &model.Membership{
+		OrganizationID: membership.OrganizationID,
+		AccountID:      account.ID,
+		Role:           role,
+	}
+	if err := h.organizationService.AddMember(member); err != nil {
+		var requestError *utils.RequestError
+		if errors.As(err, &requestError) {
+			return requestError
+		}
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&types.MemberResponse{
+		Member: *member,
+	})
+}
+
+// UpdateOrganizationMember godoc
+//
+//	@Summary		Update member
+//	@Description	Changes the role of a member, needs the admin role. Only owners can change the role of owners or grant it.
+//	@Tags			organizations
+//	@Accept			json
+//	@Param			id			path		int						true	"Organization ID"
+//	@Param			accountId	path		int						true	"Account ID of the member"
+//	@Param			member		body		types.UpdateMemberDTO	true	"Role"
+//	@Success		204			{object}	nil						"No Content"
+//	@Security		BearerAuth
+//	@Router			/organizations/{id}/members/{accountId} [put]
+func (h *Handler) UpdateOrganizationMember(c *fiber.Ctx) error {
+	remoteData := &types.UpdateMemberDTO{}
+
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	membership, err := h.requireRole(c, model.ORGANIZATION_ROLE_ADMIN)
+	if err != nil {
+		return err
+	}
+
+	member, err := h.findMember(c, membership.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	if (member.Role == model.ORGANIZATION_ROLE_OWNER || remoteData.Role == model.ORGANIZATION_ROLE_OWNER) && !membership.HasRole(model.ORGANIZATION_ROLE_OWNER) {
+		return &utils.ORGANIZATION_ROLE_REQUIRED
+	}
+
+	if err := h.organizationService.UpdateMemberRole(member.OrganizationID, member.AccountID, remoteData.Role); err != nil {
+		return organizationError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RemoveOrganizationMember godoc
+//
+//	@Summary		Remove member
+//	@Description	Removes a member, needs the admin role (owners can only be removed by owners). Every member can leave.
+//	@Tags			organizations
+//	@Param			id			path		int	true	"Organization ID"
+//	@Param			accountId	path		int	true	"Account ID of the member"
+//	@Success		204			{object}	nil	"No Content"
+//	@Security		BearerAuth
+//	@Router			/organizations/{id}/members/{accountId} [delete]
+func (h *Handler) RemoveOrganizationMember(c *fiber.Ctx) error {
+	membership, err := h.requireRole(c, model.ORGANIZATION_ROLE_MEMBER)
+	if err != nil {
+		return err
+	}
+
+	member, err := h.findMember(c, membership.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	if member.AccountID != membership.AccountID {
+		required := model.ORGANIZATION_ROLE_ADMIN
+		if member.Role == model.ORGANIZATION_ROLE_OWNER {
+			required = model.ORGANIZATION_ROLE_OWNER
+		}
+		if !membership.HasRole(required) {
+			return &utils.ORGANIZATION_ROLE_REQUIRED
+		}
+	}
+
+	if err := h.organizationService.RemoveMember(member.OrganizationID, member.AccountID); err != nil {
+		return organizationError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// findMember returns the membership of the account of the accountId param in the organization
+func (h *Handler) findMember(c *fiber.Ctx, organizationID uint) (*model.Membership, error) {
+	accountID, err := strconv.ParseUint(c.Params("accountId"), 10, 64)
+	if err != nil {
+		return nil, &utils.BAD_REQUEST
+	}
+
+	member := &model.Membership{}
+	if err := h.organizationService.FindMembership(member, strconv.FormatUint(uint64(organizationID), 10), uint(accountID)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.NOT_FOUND
+		}
+		return nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return member, nil
+}
+
+func organizationError(err error) error {
+	var requestError *utils.RequestError
+	if errors.As(err, &requestError) {
+		return requestError
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &utils.NOT_FOUND
+	}
+	return &utils.INTERNAL_SERVER_ERROR
+}
+
+// VOrganizationSwitch selects the space of the views from the switcher of the navigation and reloads the page
+func (h *Handler) VOrganizationSwitch(c *fiber.Ctx) error {
+	remoteData := &types.SwitchOrganizationDTO{}
+
+	if err := c.BodyParser(remoteData); err != nil {
+		return &utils.BAD_REQUEST
+	}
+
+	value := ""
+	if remoteData.OrganizationID != 0 {
+		membership, err := h.findMembership(c, strconv.FormatUint(uint64(remoteData.OrganizationID), 10))
+		if err != nil {
+			return err
+		}
+		value = strconv.FormatUint(uint64(membership.OrganizationID), 10)
+	}
+
+	cookie := &fiber.Cookie{
+		Name:     ORGANIZATION_COOKIE,
+		Value:    value,
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: "Lax",
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	c.Cookie(cookie)
+
+	c.Set("HX-Refresh", "true")
+
+	return c.Status(http.StatusOK).SendString("")
+}
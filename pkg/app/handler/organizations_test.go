@@ -0,0 +1,213 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/pkg/app/handler"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestOrganizationsHandlerTenantIsolation(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "organizations.owner@turbomeet.xyz", Password: pw}
+	member := &model.Account{Email: "organizations.member@turbomeet.xyz", Password: pw}
+	outsider := &model.Account{Email: "organizations.outsider@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+	accountService.CreateAccount(member)
+	accountService.CreateAccount(outsider)
+
+	ownerAuth, _ := jwt.Generate(owner)
+	memberAuth, _ := jwt.Generate(member)
+	outsiderAuth, _ := jwt.Generate(outsider)
+
+	// inOrganization selects the space of the organization, the personal space without one
+	inOrganization := func(organizationID uint) map[string]string {
+		if organizationID == 0 {
+			return nil
+		}
+		return map[string]string{handler.ORGANIZATION_HEADER: strconv.FormatUint(uint64(organizationID), 10)}
+	}
+
+	listTodos := func(token string, organizationID uint) []model.Todo {
+		res := sendWithHeader("GET", "/api/todos", token, nil, inOrganization(organizationID))
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		response := &types.GetTodosResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		return response.Todos
+	}
+
+	res := send("POST", "/api/organizations", ownerAuth.Token, &types.CreateOrganizationDTO{
+		Organization: types.OrganizationDTOBody{Name: "Turbomeet"},
+	})
+	if res.StatusCode != 201 {
+		t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+	}
+	created := &types.OrganizationResponse{}
+	json.NewDecoder(res.Body).Decode(created)
+	organizationID := created.Organization.ID
+
+	todoService := service.NewTodoService(DB)
+	todoService.CreateTodo(&model.Todo{Title: zero.StringFrom("Personal todo")}, model.Space{AccountID: owner.ID})
+
+	t.Run("should keep organization and personal todos apart", func(t *testing.T) {
+		res := sendWithHeader("POST", "/api/todos", ownerAuth.Token, &types.CreateTodoRequest{
+			Todo: model.Todo{Title: zero.StringFrom("Team todo")},
+		}, inOrganization(organizationID))
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		if todos := listTodos(ownerAuth.Token, organizationID); len(todos) != 1 || todos[0].Title.String != "Team todo" {
+			t.Errorf("Expected only the team todo in the organization, got %v", todos)
+		}
+		if todos := listTodos(ownerAuth.Token, 0); len(todos) != 1 || todos[0].Title.String != "Personal todo" {
+			t.Errorf("Expected only the personal todo in the personal space, got %v", todos)
+		}
+	})
+
+	t.Run("should reject spaces of organizations the account isn't a member of", func(t *testing.T) {
+		if res := sendWithHeader("GET", "/api/todos", outsiderAuth.Token, nil, inOrganization(organizationID)); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403, got %d", res.StatusCode)
+		}
+		if res := send("GET", "/api/organizations/"+strconv.FormatUint(uint64(organizationID), 10), outsiderAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected status code 404, got %d", res.StatusCode)
+		}
+
+		// Even a space built for a foreign organization matches nothing
+		todos := []model.Todo{}
+		todoService.FindTodos(&todos, model.Space{AccountID: outsider.ID, OrganizationID: organizationID})
+		if len(todos) != 0 {
+			t.Errorf("Expected no todos outside the memberships, got %d", len(todos))
+		}
+	})
+
+	t.Run("should share the organization todos with members", func(t *testing.T) {
+		path := "/api/organizations/" + strconv.FormatUint(uint64(organizationID), 10) + "/members"
+		if res := send("POST", path, memberAuth.Token, &types.AddMemberDTO{Email: outsider.Email}); res.StatusCode != 404 {
+			t.Errorf("Expected non members to get 404, got %d", res.StatusCode)
+		}
+		if res := send("POST", path, ownerAuth.Token, &types.AddMemberDTO{Email: member.Email}); res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+		if res := send("POST", path, ownerAuth.Token, &types.AddMemberDTO{Email: member.Email}); res.StatusCode != 400 {
+			t.Errorf("Expected adding a member twice to fail with 400, got %d", res.StatusCode)
+		}
+
+		todos := listTodos(memberAuth.Token, organizationID)
+		if len(todos) != 1 {
+			t.Fatalf("Expected the team todo, got %d todos", len(todos))
+		}
+		teamTodo := strconv.FormatUint(uint64(todos[0].ID), 10)
+
+		// The todo is only found in its own space
+		if res := send("GET", "/api/todos/"+teamTodo, memberAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected status code 404 in the personal space, got %d", res.StatusCode)
+		}
+		if res := send("DELETE", "/api/todos/"+teamTodo, outsiderAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected status code 404 for outsiders, got %d", res.StatusCode)
+		}
+
+		// Members edit the todos of the organization, only admins and owners delete them
+		if res := sendWithHeader("PUT", "/api/todos/"+teamTodo, memberAuth.Token, &types.UpdateTodoRequest{Todo: model.Todo{Title: zero.StringFrom("Team todo")}}, inOrganization(organizationID)); res.StatusCode != 200 {
+			t.Errorf("Expected members to edit the todo, got %d", res.StatusCode)
+		}
+		if res := sendWithHeader("DELETE", "/api/todos/"+teamTodo, memberAuth.Token, nil, inOrganization(organizationID)); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403 for members, got %d", res.StatusCode)
+		}
+
+		// Members can't grant roles
+		if res := send("POST", path, memberAuth.Token, &types.AddMemberDTO{Email: outsider.Email}); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403 for members, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should keep at least one owner", func(t *testing.T) {
+		path := "/api/organizations/" + strconv.FormatUint(uint64(organizationID), 10) + "/members/"
+		if res := send("DELETE", path+strconv.FormatUint(uint64(owner.ID), 10), ownerAuth.Token, nil); res.StatusCode != 400 {
+			t.Errorf("Expected the last owner not to leave, got %d", res.StatusCode)
+		}
+
+		if res := send("PUT", path+strconv.FormatUint(uint64(member.ID), 10), ownerAuth.Token, &types.UpdateMemberDTO{Role: model.ORGANIZATION_ROLE_OWNER}); res.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+		}
+		if res := send("DELETE", path+strconv.FormatUint(uint64(owner.ID), 10), ownerAuth.Token, nil); res.StatusCode != 204 {
+			t.Errorf("Expected the owner to leave once another owner exists, got %d", res.StatusCode)
+		}
+		if res := sendWithHeader("GET", "/api/todos", ownerAuth.Token, nil, inOrganization(organizationID)); res.StatusCode != 403 {
+			t.Errorf("Expected former members to lose access, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should switch the space of the views", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/organizations/switch", strings.NewReader(url.Values{"organizationId": {strconv.FormatUint(uint64(organizationID), 10)}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: memberAuth.Token})
+		res, _ := App.Test(req, -1)
+
+		var selected *http.Cookie
+		for _, c := range res.Cookies() {
+			if c.Name == handler.ORGANIZATION_COOKIE {
+				selected = c
+			}
+		}
+		if selected == nil {
+			t.Fatalf("Expected the organization cookie, got %d", res.StatusCode)
+		}
+
+		req, _ = http.NewRequest("GET", "/todos", nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: memberAuth.Token})
+		req.AddCookie(selected)
+		res, _ = App.Test(req, -1)
+		body, _ := io.ReadAll(res.Body)
+
+		if !strings.Contains(string(body), "Team todo") || !strings.Contains(string(body), "Turbomeet") {
+			t.Errorf("Expected the organization todos and the switcher")
+		}
+	})
+
+	t.Run("should delete the organization with its todos", func(t *testing.T) {
+		DB.Create(&model.ShareLink{TokenHash: "organization-link", AccountID: member.ID, OrganizationID: &organizationID, Name: "Team", Status: model.SHARE_LINK_STATUS_ALL})
+		DB.Create(&model.TodoTemplate{AccountID: member.ID, OrganizationID: &organizationID, Name: "Team", Items: []model.TodoTemplateItem{{Title: "Team todo"}}})
+		DB.Create(&model.Status{AccountID: member.ID, OrganizationID: &organizationID, Name: "Review"})
+
+		path := "/api/organizations/" + strconv.FormatUint(uint64(organizationID), 10)
+		if res := send("DELETE", path, memberAuth.Token, nil); res.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+		}
+
+		var count int64
+		DB.Model(&model.Todo{}).Where("organization_id = ?", organizationID).Count(&count)
+		if count != 0 {
+			t.Errorf("Expected the organization todos to be deleted, got %d", count)
+		}
+
+		for _, table := range []any{&model.ShareLink{}, &model.TodoTemplate{}, &model.Status{}} {
+			DB.Unscoped().Model(table).Where("organization_id = ?", organizationID).Count(&count)
+			if count != 0 {
+				t.Errorf("Expected the %T rows of the organization to be deleted, got %d", table, count)
+			}
+		}
+		DB.Model(&model.TodoTemplateItem{}).Count(&count)
+		if count != 0 {
+			t.Errorf("Expected the template items to be deleted, got %d", count)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestPriorityHandlerSmartOrder(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "priority.owner@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+
+	ownerAuth, _ := jwt.Generate(owner)
+
+	titles := func(query string) string {
+		res := send("GET", "/api/todos?"+query, ownerAuth.Token, nil)
+		response := &types.GetTodosResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		titles := []string{}
+		for _, todo := range response.Todos {
+			titles = append(titles, todo.Title.String)
+		}
+		return strings.Join(titles, ",")
+	}
+
+	someday := createTodo(t, ownerAuth.Token, model.Todo{Title: zero.StringFrom("someday")})
+	old := createTodo(t, ownerAuth.Token, model.Todo{Title: zero.StringFrom("old")})
+	createTodo(t, ownerAuth.Token, model.Todo{Title: zero.StringFrom("urgent"), Priority: model.TODO_PRIORITY_URGENT})
+	createTodo(t, ownerAuth.Token, model.Todo{Title: zero.StringFrom("overdue"), Priority: model.TODO_PRIORITY_MEDIUM, DueAt: null.TimeFrom(time.Now().Add(-time.Hour))})
+	createTodo(t, ownerAuth.Token, model.Todo{Title: zero.StringFrom("done"), Priority: model.TODO_PRIORITY_URGENT, Completed: true})
+	DB.Model(&model.Todo{}).Where("id = ?", old.ID).UpdateColumn("created_at", time.Now().Add(-40*24*time.Hour))
+
+	t.Run("should default to no priority and reject unknown ones", func(t *testing.T) {
+		if someday.Priority != model.TODO_PRIORITY_NONE {
+			t.Errorf("Expected priority none, got %q", someday.Priority)
+		}
+
+		res := send("POST", "/api/todos", ownerAuth.Token, &types.CreateTodoRequest{Todo: model.Todo{Title: zero.StringFrom("critical"), Priority: "critical"}})
+		if res.StatusCode != 400 {
+			t.Errorf("Expected unknown priorities to fail with 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should filter and order by priority level", func(t *testing.T) {
+		if got := titles("filter[priority]=medium"); got != "overdue" {
+			t.Errorf("Expected the medium todo, got %s", got)
+		}
+		if got := titles("filter[priority][gte]=medium&order[id]=asc"); got != "urgent,overdue,done" {
+			t.Errorf("Expected todos of medium priority and above, got %s", got)
+		}
+		if got := titles("filter[completed]=0&order[priority]=desc&order[id]=asc"); got != "urgent,overdue,someday,old" {
+			t.Errorf("Expected todos from urgent to none, got %s", got)
+		}
+	})
+
+	t.Run("should sort by the smart score", func(t *testing.T) {
+		if got := titles("order=smart"); got != "overdue,urgent,old,someday,done" {
+			t.Errorf("Expected open todos by score, got %s", got)
+		}
+	})
+
+	t.Run("should reject unknown order keys and directions", func(t *testing.T) {
+		for _, query := range []string{"order=title%3BDROP%20TABLE%20todos", "order[account_id]=asc", "order[id]=asc,title", "order[title]=sideways"} {
+			if res := send("GET", "/api/todos?"+query, ownerAuth.Token, nil); res.StatusCode != 400 {
+				t.Errorf("Expected %q to fail with 400, got %d", query, res.StatusCode)
+			}
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/test"
+)
+
+func TestProxyAuthHandler(t *testing.T) {
+	// Setup
+	defer func(enabled bool, cidrs []string, provision bool) {
+		config.PROXY_AUTH_ENABLED = enabled
+		config.PROXY_AUTH_TRUSTED_CIDRS = cidrs
+		config.PROXY_AUTH_AUTO_PROVISION = provision
+	}(config.PROXY_AUTH_ENABLED, config.PROXY_AUTH_TRUSTED_CIDRS, config.PROXY_AUTH_AUTO_PROVISION)
+	defer func(mode string) { config.REGISTRATION_MODE = mode }(config.REGISTRATION_MODE)
+	defer test.ClearAllTables(DB)
+
+	config.PROXY_AUTH_ENABLED = true
+	// Requests of App.Test come from 0.0.0.0
+	config.PROXY_AUTH_TRUSTED_CIDRS = []string{"0.0.0.0/32"}
+
+	me := func(email string) *http.Response {
+		req, _ := http.NewRequest("GET", "/api/auth/me", nil)
+		req.Header.Set(config.PROXY_AUTH_EMAIL_HEADER, email)
+		res, _ := App.Test(req, -1)
+		return res
+	}
+
+	t.Run("should provision and sign in the account of the header", func(t *testing.T) {
+		res := me("Proxy.User@turbomeet.xyz")
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		response := &types.GetMeResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if response.Account.Email != "proxy.user@turbomeet.xyz" {
+			t.Errorf("Expected the provisioned account, got %q", response.Account.Email)
+		}
+
+		// The same account is used for later requests
+		me("proxy.user@turbomeet.xyz")
+		var count int64
+		DB.Model(&model.Account{}).Where("email = ?", "proxy.user@turbomeet.xyz").Count(&count)
+		if count != 1 {
+			t.Errorf("Expected 1 account, got %d", count)
+		}
+	})
+
+	t.Run("should ignore the header of untrusted peers", func(t *testing.T) {
+		config.PROXY_AUTH_TRUSTED_CIDRS = []string{"10.0.0.0/8"}
+		defer func() { config.PROXY_AUTH_TRUSTED_CIDRS = []string{"0.0.0.0/32"} }()
+
+		if res := me("proxy.user@turbomeet.xyz"); res.StatusCode != 401 {
+			t.Errorf("Expected status code 401, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should ignore the header when disabled", func(t *testing.T) {
+		config.PROXY_AUTH_ENABLED = false
+		defer func() { config.PROXY_AUTH_ENABLED = true }()
+
+		if res := me("proxy.user@turbomeet.xyz"); res.StatusCode != 401 {
+			t.Errorf("Expected status code 401, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should reject unknown emails without auto provisioning", func(t *testing.T) {
+		config.PROXY_AUTH_AUTO_PROVISION = false
+		defer func() { config.PROXY_AUTH_AUTO_PROVISION = true }()
+
+		if res := me("proxy.unknown@turbomeet.xyz"); res.StatusCode != 401 {
+			t.Errorf("Expected status code 401, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should not provision unless registration is open", func(t *testing.T) {
+		config.REGISTRATION_MODE = model.REGISTRATION_INVITE_ONLY
+		defer func() { config.REGISTRATION_MODE = model.REGISTRATION_OPEN }()
+
+		if res := me("proxy.invited@turbomeet.xyz"); res.StatusCode != 401 {
+			t.Errorf("Expected status code 401, got %d", res.StatusCode)
+		}
+		if res := me("proxy.user@turbomeet.xyz"); res.StatusCode != 200 {
+			t.Errorf("Expected existing accounts to sign in, got %d", res.StatusCode)
+		}
+	})
+}
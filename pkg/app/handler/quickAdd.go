@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/quickadd"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+	"gopkg.in/guregu/null.v4"
+	"gopkg.in/guregu/null.v4/zero"
+	"gorm.io/gorm"
+)
+
+// parseQuickAdd parses text relative to the current time in the account's timezone
+func (h *Handler) parseQuickAdd(c *fiber.Ctx, text string) (quickadd.Result, error) {
+	account := &model.Account{}
+	if err := h.accountService.FindAccountByID(account, locals.JwtPayload(c).AccountID).Error; err != nil {
+		return quickadd.Result{}, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return quickadd.Parse(text, time.Now().In(account.Location()), model.TodoPriorities), nil
+}
+
+// quickAddSpace returns the organization space named by the project, the current space without a project
+func (h *Handler) quickAddSpace(c *fiber.Ctx, project string) (model.Space, error) {
+	if project == "" {
+		return h.currentSpace(c)
+	}
+
+	accountID := locals.JwtPayload(c).AccountID
+	memberships := []model.Membership{}
+	if err := h.organizationService.FindMemberships(&memberships, accountID).Error; err != nil {
+		return model.Space{}, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	for _, membership := range memberships {
+		if membership.Organization != nil && strings.EqualFold(membership.Organization.Name, project) {
+			return model.Space{AccountID: accountID, OrganizationID: membership.OrganizationID}, nil
+		}
+	}
+
+	return model.Space{}, &utils.QUICK_ADD_PROJECT_UNKNOWN
+}
+
+// quickAdd creates a todo from the text and returns it with the recognized tokens and its space
+func (h *Handler) quickAdd(c *fiber.Ctx, text string) (*model.Todo, quickadd.Result, model.Space, error) {
+	result, err := h.parseQuickAdd(c, text)
+	if err != nil {
+		return nil, result, model.Space{}, err
+	}
+	if result.Title == "" {
+		return nil, result, model.Space{}, &utils.QUICK_ADD_TITLE_REQUIRED
+	}
+
+	space, err := h.quickAddSpace(c, result.Project)
+	if err != nil {
+		return nil, result, space, err
+	}
+
+	todo := &model.Todo{
+		Title:    zero.StringFrom(result.Title),
+		Priority: result.Priority,
+		Tags:     model.NewTags(result.Tags),
+	}
+	if result.DueAt != nil {
+		todo.DueAt = null.TimeFrom(*result.DueAt)
+	}
+
+	if err := h.validator.Validate(todo); err != nil {
+		return nil, result, space, err
+	}
+
+	if err := h.todoService.CreateTodo(todo, space); err != nil {
+		return nil, result, space, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return todo, result, space, nil
+}
+
+// QuickAddTodo godoc
+//
+//	@Summary		Quick add todo
+//	@Description	Creates a todo from a line of text like "Buy milk tomorrow 5pm !high #errands +home". Dates are relative to the account's timezone, "!" sets the priority, "#" adds tags and "+" creates the todo in the organization with that name. The recognized tokens are returned with their rune offsets, the rest of the text is the title.
+//	@Tags			todos
+//	@Accept			json
+//	@Produce		json
+//	@Param			text	body		types.QuickAddDTO	true	"Text"
+//	@Success		201		{object}	types.QuickAddResponse
+//	@Security		BearerAuth
+//	@Router			/todos/quick [post]
+func (h *Handler) QuickAddTodo(c *fiber.Ctx) error {
+	remoteData := &types.QuickAddDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	todo, result, _, err := h.quickAdd(c, remoteData.Text)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(&types.QuickAddResponse{
+		Todo:   *todo,
+		Tokens: result.Tokens,
+	})
+}
+
+// UpdateTimezone godoc
+//
+//	@Summary		Update timezone
+//	@Description	Sets the IANA timezone of the account, e.g. Europe/Berlin. Quick-add dates are relative to it, empty means UTC.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			timezone	body		types.TimezoneDTO	true	"Timezone"
+//	@Success		200			{object}	types.TimezoneResponse
+//	@Security		BearerAuth
+//	@Router			/auth/timezone [put]
+func (h *Handler) UpdateTimezone(c *fiber.Ctx) error {
+	remoteData := &types.TimezoneDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	if err := h.updateTimezone(c, remoteData.Timezone); err != nil {
+		return err
+	}
+
+	return c.JSON(&types.TimezoneResponse{
+		Timezone: remoteData.Timezone,
+	})
+}
+
+// updateTimezone stores the timezone of the account
+func (h *Handler) updateTimezone(c *fiber.Ctx, timezone string) error {
+	account := &model.Account{}
+	if err := h.accountService.FindAccountByID(account, locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if err := h.accountService.UpdateTimezone(account, timezone).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return nil
+}
+
+// VTodosQuickPreview highlights the tokens recognized in the title of the create form
+func (h *Handler) VTodosQuickPreview(c *fiber.Ctx) error {
+	result, err := h.parseQuickAdd(c, c.Query("title"))
+	if err != nil {
+		return err
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.QuickAddPreview(result.Tokens)))(c)
+}
+
+func (h *Handler) VProfileTimezonePut(c *fiber.Ctx) error {
+	remoteData := &types.TimezoneDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return renderFormErrors(c, "#timezone-result", []string{"Enter a timezone like Europe/Berlin or leave it empty for UTC."})
+	}
+
+	if err := h.updateTimezone(c, remoteData.Timezone); err != nil {
+		return err
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.TimezoneSection(remoteData.Timezone, true)))(c)
+}
+
+// withTagFilter narrows todo queries by the tag query param
+func withTagFilter(c *fiber.Ctx, where *gorm.DB) (*gorm.DB, error) {
+	tag := c.Query("tag")
+	if tag == "" {
+		return where, nil
+	}
+	if !model.ValidTag(tag) {
+		return nil, utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "tag may only contain up to "+strconv.Itoa(model.TAG_MAX_LENGTH)+" letters, digits, _ and -")
+	}
+
+	return where.Where("todos.tags LIKE ? ESCAPE '!'", model.TagCondition(tag)), nil
+}
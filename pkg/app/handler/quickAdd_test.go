@@ -0,0 +1,134 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/pkg/quickadd"
+	"github.com/nleiva/go-todo-api/test"
+)
+
+func TestQuickAddHandler(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "quick.owner@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+
+	organization := &model.Organization{Name: "Work"}
+	service.NewOrganizationService(DB).CreateOrganization(organization, owner.ID)
+
+	ownerAuth, _ := jwt.Generate(owner)
+
+	t.Run("should parse dates in the timezone of the account", func(t *testing.T) {
+		if res := send("PUT", "/api/auth/timezone", ownerAuth.Token, &types.TimezoneDTO{Timezone: "Mars/Olympus"}); res.StatusCode != 400 {
+			t.Errorf("Expected unknown timezones to fail with 400, got %d", res.StatusCode)
+		}
+		if res := send("PUT", "/api/auth/timezone", ownerAuth.Token, &types.TimezoneDTO{Timezone: "America/New_York"}); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		res := send("POST", "/api/todos/quick", ownerAuth.Token, &types.QuickAddDTO{Text: "Buy milk tomorrow 5pm !high #errands"})
+		if res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+		response := &types.QuickAddResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+
+		location, _ := time.LoadLocation("America/New_York")
+		tomorrow := time.Now().In(location).AddDate(0, 0, 1)
+		due := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 17, 0, 0, 0, location)
+		if response.Todo.Title.String != "Buy milk" || !response.Todo.DueAt.Time.Equal(due) {
+			t.Errorf("Expected Buy milk due %s, got %q due %s", due, response.Todo.Title.String, response.Todo.DueAt.Time)
+		}
+		if response.Todo.Priority != model.TODO_PRIORITY_HIGH || len(response.Todo.Tags) != 1 || response.Todo.Tags[0] != "errands" {
+			t.Errorf("Expected high priority and the errands tag, got %+v", response.Todo)
+		}
+
+		kinds := []string{}
+		for _, token := range response.Tokens {
+			kinds = append(kinds, token.Kind+"="+token.Text)
+		}
+		if got := strings.Join(kinds, ","); got != "date=tomorrow 5pm,priority=!high,tag=#errands" {
+			t.Errorf("Expected the recognized tokens, got %s", got)
+		}
+	})
+
+	t.Run("should add todos to the organization of the project", func(t *testing.T) {
+		res := send("POST", "/api/todos/quick", ownerAuth.Token, &types.QuickAddDTO{Text: "Send report +work"})
+		if res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+		response := &types.QuickAddResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+
+		todo := &model.Todo{}
+		DB.First(todo, response.Todo.ID)
+		if todo.OrganizationID == nil || *todo.OrganizationID != organization.ID {
+			t.Errorf("Expected the todo in the Work organization, got %v", todo.OrganizationID)
+		}
+
+		if res := send("POST", "/api/todos/quick", ownerAuth.Token, &types.QuickAddDTO{Text: "Send report +garden"}); res.StatusCode != 400 {
+			t.Errorf("Expected unknown projects to fail with 400, got %d", res.StatusCode)
+		}
+		if res := send("POST", "/api/todos/quick", ownerAuth.Token, &types.QuickAddDTO{Text: "tomorrow !high"}); res.StatusCode != 400 {
+			t.Errorf("Expected texts without a title to fail with 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should filter by tag", func(t *testing.T) {
+		res := send("GET", "/api/todos?tag=Errands", ownerAuth.Token, nil)
+		response := &types.GetTodosResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if len(response.Todos) != 1 || response.Todos[0].Title.String != "Buy milk" {
+			t.Errorf("Expected the todo tagged errands, got %d todos", len(response.Todos))
+		}
+
+		if res := send("GET", "/api/todos?tag=a,b", ownerAuth.Token, nil); res.StatusCode != 400 {
+			t.Errorf("Expected invalid tags to fail with 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should match underscores in tags literally", func(t *testing.T) {
+		send("POST", "/api/todos/quick", ownerAuth.Token, &types.QuickAddDTO{Text: "Build a snowman #snow_day"})
+		send("POST", "/api/todos/quick", ownerAuth.Token, &types.QuickAddDTO{Text: "Shovel the drive #snowyday"})
+
+		res := send("GET", "/api/todos?tag=snow_day", ownerAuth.Token, nil)
+		response := &types.GetTodosResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if len(response.Todos) != 1 || response.Todos[0].Title.String != "Build a snowman" {
+			t.Errorf("Expected only the todo tagged snow_day, got %d todos", len(response.Todos))
+		}
+	})
+
+	t.Run("should limit the length of tags", func(t *testing.T) {
+		tag := strings.Repeat("a", model.TAG_MAX_LENGTH+1)
+		if res := send("POST", "/api/todos/quick", ownerAuth.Token, &types.QuickAddDTO{Text: "Too long #" + tag}); res.StatusCode != 400 {
+			t.Errorf("Expected long tags to fail with 400, got %d", res.StatusCode)
+		}
+		if res := send("GET", "/api/todos?tag="+tag, ownerAuth.Token, nil); res.StatusCode != 400 {
+			t.Errorf("Expected long tag filters to fail with 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should preview the tokens of the create form", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/todos/quick?title="+url.QueryEscape("Call mom next fri #family"), nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: ownerAuth.Token})
+		res, _ := App.Test(req, -1)
+		body, _ := io.ReadAll(res.Body)
+		if !strings.Contains(string(body), quickadd.TOKEN_DATE+": next fri") || !strings.Contains(string(body), "#family") {
+			t.Errorf("Expected the date and tag to be highlighted, got %s", body)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/utils"
+)
+
+// reorderTodo moves the todo of the id param in the manual order of its space, it needs the editor role
+func (h *Handler) reorderTodo(c *fiber.Ctx, todo *model.Todo) error {
+	remoteData := &types.ReorderTodoDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	if err := h.todoService.ReorderTodo(todo, remoteData.AfterID, remoteData.BeforeID); err != nil {
+		return statusError(err)
+	}
+
+	return nil
+}
+
+// ReorderTodo godoc
+//
+//	@Summary		Move todo in the list
+//	@Description	Places a todo between two neighbors in the manual order of its space, one neighbor is enough to put it right after or before a todo. List todos in this order with order[position]=asc. Needs the editor role.
+//	@Tags			todos
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int						true	"Todo ID"
+//	@Param			neighbors	body		types.ReorderTodoDTO	true	"Neighbors"
+//	@Success		200			{object}	types.UpdateTodoResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/move [post]
+func (h *Handler) ReorderTodo(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.reorderTodo(c, todo); err != nil {
+		return err
+	}
+
+	return c.JSON(&types.UpdateTodoResponse{
+		Todo: *todo,
+	})
+}
+
+// VTodosMove stores the order after a todo was dragged in the list, the list itself is already in place
+func (h *Handler) VTodosMove(c *fiber.Ctx) error {
+	if err := h.reorderTodo(c, &model.Todo{}); err != nil {
+		var requestError *utils.RequestError
+		if errors.As(err, &requestError) && requestError.StatusCode < http.StatusInternalServerError {
+			// Reload the list so it shows the stored order again
+			c.Set("HX-Refresh", "true")
+		}
+		return err
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
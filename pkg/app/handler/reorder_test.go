@@ -0,0 +1,141 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestReorderHandlerManualOrder(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "reorder.owner@turbomeet.xyz", Password: pw}
+	other := &model.Account{Email: "reorder.other@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+	accountService.CreateAccount(other)
+
+	ownerAuth, _ := jwt.Generate(owner)
+
+	todoService := service.NewTodoService(DB)
+	space := model.Space{AccountID: owner.ID}
+	first := &model.Todo{Title: zero.StringFrom("first")}
+	second := &model.Todo{Title: zero.StringFrom("second")}
+	third := &model.Todo{Title: zero.StringFrom("third")}
+	foreign := &model.Todo{Title: zero.StringFrom("foreign")}
+	todoService.CreateTodo(first, space)
+	todoService.CreateTodo(second, space)
+	todoService.CreateTodo(third, space)
+	todoService.CreateTodo(foreign, model.Space{AccountID: other.ID})
+
+	order := func() string {
+		res := send("GET", "/api/todos?order[position]=asc", ownerAuth.Token, nil)
+		response := &types.GetTodosResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		titles := []string{}
+		for _, todo := range response.Todos {
+			titles = append(titles, todo.Title.String)
+		}
+		return strings.Join(titles, ",")
+	}
+
+	movePath := func(todo *model.Todo) string {
+		return "/api/todos/" + strconv.FormatUint(uint64(todo.ID), 10) + "/move"
+	}
+
+	t.Run("should put new todos on top", func(t *testing.T) {
+		if got := order(); got != "third,second,first" {
+			t.Errorf("Expected the newest todo first, got %s", got)
+		}
+	})
+
+	t.Run("should move todos between their neighbors", func(t *testing.T) {
+		var before, after model.Todo
+		DB.Take(&before, second.ID)
+
+		res := send("POST", movePath(first), ownerAuth.Token, &types.ReorderTodoDTO{AfterID: &third.ID, BeforeID: &second.ID})
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if got := order(); got != "third,first,second" {
+			t.Errorf("Expected the todo between its neighbors, got %s", got)
+		}
+
+		DB.Take(&after, second.ID)
+		if before.Position != after.Position {
+			t.Errorf("Expected other todos to keep their position, got %s and %s", before.Position, after.Position)
+		}
+	})
+
+	t.Run("should move todos with one neighbor", func(t *testing.T) {
+		send("POST", movePath(third), ownerAuth.Token, &types.ReorderTodoDTO{AfterID: &second.ID})
+		if got := order(); got != "first,second,third" {
+			t.Errorf("Expected the todo at the end, got %s", got)
+		}
+
+		send("POST", movePath(third), ownerAuth.Token, &types.ReorderTodoDTO{BeforeID: &first.ID})
+		if got := order(); got != "third,first,second" {
+			t.Errorf("Expected the todo at the start, got %s", got)
+		}
+	})
+
+	t.Run("should reject invalid neighbors", func(t *testing.T) {
+		if res := send("POST", movePath(first), ownerAuth.Token, &types.ReorderTodoDTO{}); res.StatusCode != 400 {
+			t.Errorf("Expected a missing neighbor to fail with 400, got %d", res.StatusCode)
+		}
+		if res := send("POST", movePath(first), ownerAuth.Token, &types.ReorderTodoDTO{AfterID: &second.ID, BeforeID: &third.ID}); res.StatusCode != 400 {
+			t.Errorf("Expected neighbors out of order to fail with 400, got %d", res.StatusCode)
+		}
+		if res := send("POST", movePath(first), ownerAuth.Token, &types.ReorderTodoDTO{AfterID: &foreign.ID}); res.StatusCode != 404 {
+			t.Errorf("Expected todos of other spaces to get 404, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should rebalance long positions", func(t *testing.T) {
+		DB.Model(&model.Todo{}).Where("id = ?", second.ID).UpdateColumn("position", "t"+strings.Repeat("z", 40))
+
+		rebalanced, err := todoService.RebalancePositions(16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rebalanced != 1 {
+			t.Errorf("Expected 1 rebalanced space, got %d", rebalanced)
+		}
+		if got := order(); got != "third,first,second" {
+			t.Errorf("Expected the order to be kept, got %s", got)
+		}
+	})
+
+	t.Run("should give todos without a position one on the first move", func(t *testing.T) {
+		DB.Model(&model.Todo{}).Where("account_id = ?", owner.ID).UpdateColumn("position", "")
+
+		values := url.Values{"afterId": {strconv.FormatUint(uint64(third.ID), 10)}}
+		req, _ := http.NewRequest("POST", "/todos/"+strconv.FormatUint(uint64(first.ID), 10)+"/move", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: ownerAuth.Token})
+		if res, _ := App.Test(req, -1); res.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+		}
+
+		var missing int64
+		DB.Model(&model.Todo{}).Where("account_id = ? AND position = ''", owner.ID).Count(&missing)
+		if missing != 0 {
+			t.Errorf("Expected all todos to have a position, got %d without", missing)
+		}
+		if got := order(); got != "third,first,second" {
+			t.Errorf("Expected the newest first with the moved todo after third, got %s", got)
+		}
+	})
+}
@@ -10,6 +10,7 @@ import (
 func (h *Handler) RegisterRoutes(app *fiber.App) {
 	h.RegisterHyperMediaRoutes(app)
 	h.RegisterApiRoutes(app)
+	h.RegisterOAuthRoutes(app)
 }
 
 // RegisterHyperMediaRoutes registers all routes for the application that are used for rendering views
@@ -23,17 +24,71 @@ func (h *Handler) RegisterHyperMediaRoutes(app *fiber.App) {
 
 	app.Get("/login", h.VLogin)
 	app.Post("/login", h.VLoginPost)
+	app.Post("/login/passkey", h.VLoginPasskeyPost)
 	app.Post("/logout", h.VLogout)
 
+	app.Get("/auth/oidc/login", h.VOIDCLogin)
+	app.Get("/auth/oidc/callback", h.VOIDCCallback)
+
+	app.Post("/auth/magic", h.VMagicLinkRequest)
+	app.Get("/auth/magic/callback", h.VMagicLinkCallback)
+
+	app.Get("/oauth/authorize", middleware.LoadAuth, h.VOAuthAuthorize)
+	app.Post("/oauth/authorize", middleware.LoadAuth, h.VOAuthAuthorizePost)
+
 	app.Get("/register", h.VRegister)
 	app.Post("/register", h.VRegisterPost)
 
 	app.Get("/profile", middleware.Protected, h.VProfile)
+	app.Delete("/profile/passkeys/:id", middleware.Protected, h.VProfilePasskeyDelete)
+	app.Put("/profile/password", middleware.Protected, h.VProfilePasswordPut)
+	app.Put("/profile/auto-archive", middleware.Protected, h.VProfileAutoArchivePut)
+	app.Put("/profile/timezone", middleware.Protected, h.VProfileTimezonePut)
+
+	app.Post("/organizations/switch", middleware.Protected, h.VOrganizationSwitch)
 
 	app.Get("/todos", middleware.Pagination, middleware.Protected, middleware.Pagination, h.VTodosIndex)
+	app.Post("/share-links", middleware.Protected, h.VShareLinksCreate)
+	app.Delete("/share-links/:id", middleware.Protected, h.VShareLinksRevoke)
+	app.Get("/share/:token", h.VPublicShareLink)
+	app.Post("/share/:token", h.VPublicShareLink)
+
+	app.Get("/notifications", middleware.Protected, h.VNotifications)
+	app.Post("/notifications/read", middleware.Protected, h.VNotificationsRead)
+
+	app.Get("/todos/shared", middleware.Protected, h.VTodosShared)
+	app.Get("/todos/assigned", middleware.Protected, h.VTodosAssigned)
+	app.Get("/board", middleware.Protected, h.VBoard)
+	app.Post("/statuses", middleware.Protected, h.VStatusesCreate)
+	app.Delete("/statuses/:id", middleware.Protected, h.VStatusesDelete)
+	app.Get("/trash", middleware.Protected, h.VTrash)
+	app.Delete("/trash", middleware.Protected, h.VTrashEmpty)
+	app.Post("/trash/:id/restore", middleware.Protected, h.VTrashRestore)
+	app.Delete("/trash/:id", middleware.Protected, h.VTrashPurge)
 	app.Post("/todos", middleware.Protected, h.VTodosCreate)
+	app.Get("/todos/quick", middleware.Protected, h.VTodosQuickPreview)
+	app.Post("/templates/instantiate", middleware.Protected, h.VTemplatesInstantiate)
+	app.Post("/todos/archive-completed", middleware.Protected, h.VTodosArchiveCompleted)
 	app.Put("/todos/:id/complete", middleware.Protected, h.VTodosComplete)
+	app.Put("/todos/:id/status", middleware.Protected, h.VBoardMove)
+	app.Post("/todos/:id/move", middleware.Protected, h.VTodosMove)
+	app.Post("/todos/:id/timer", middleware.Protected, h.VTodoTimerToggle)
+	app.Post("/todos/:id/template", middleware.Protected, h.VTodoSaveTemplate)
+	app.Post("/todos/:id/archive", middleware.Protected, h.VTodosArchive)
+	app.Post("/todos/:id/unarchive", middleware.Protected, h.VTodosUnarchive)
 	app.Delete("/todos/:id", middleware.Protected, h.VTodosDelete)
+	app.Get("/todos/:id/history", middleware.Protected, h.VTodoHistory)
+	app.Post("/todos/:id/revert/:revision", middleware.Protected, h.VTodoRevert)
+	app.Get("/todos/:id/comments", middleware.Protected, h.VComments)
+	app.Post("/todos/:id/comments", middleware.Protected, h.VCommentsCreate)
+	app.Get("/todos/:id/comments/:commentId", middleware.Protected, h.VComment)
+	app.Get("/todos/:id/comments/:commentId/edit", middleware.Protected, h.VCommentsEdit)
+	app.Put("/todos/:id/comments/:commentId", middleware.Protected, h.VCommentsUpdate)
+	app.Delete("/todos/:id/comments/:commentId", middleware.Protected, h.VCommentsDelete)
+	app.Get("/todos/:id/attachments", middleware.Protected, h.VAttachments)
+	app.Post("/todos/:id/attachments", middleware.Protected, h.VAttachmentsCreate)
+	app.Get("/todos/:id/attachments/:attachmentId", middleware.Protected, h.DownloadAttachment)
+	app.Delete("/todos/:id/attachments/:attachmentId", middleware.Protected, h.VAttachmentsDelete)
 }
 
 func (h *Handler) RegisterApiRoutes(app *fiber.App) {
@@ -69,24 +124,134 @@ func (h *Handler) RegisterApiRoutes(app *fiber.App) {
 
 	auth := api.Group("/auth")
 	auth.Put("/login", h.Login)
-	auth.Post("/register", middleware.Protected, h.Register)
+	// LoadAuth lets admins create accounts regardless of the registration mode
+	auth.Post("/register", middleware.LoadAuth, h.Register)
 	auth.Put("/refresh", middleware.Protected, h.Refresh)
 	auth.Get("/me", middleware.Protected, h.Me)
+	auth.Put("/password", middleware.Protected, h.ChangePassword)
+	auth.Put("/timezone", middleware.Protected, h.UpdateTimezone)
 
 	auth.Put("/jwk-rotate", middleware.AllowedIps, h.RotateJWK)
 
+	webAuthn := auth.Group("/webauthn")
+	webAuthn.Post("/register/begin", middleware.Protected, h.WebAuthnRegisterBegin)
+	webAuthn.Post("/register/finish", middleware.Protected, h.WebAuthnRegisterFinish)
+	webAuthn.Post("/login/begin", h.WebAuthnLoginBegin)
+	webAuthn.Post("/login/finish", h.WebAuthnLoginFinish)
+	webAuthn.Get("/credentials", middleware.Protected, h.GetWebAuthnCredentials)
+	webAuthn.Delete("/credentials/:id", middleware.Protected, h.DeleteWebAuthnCredential)
+
+	oauthClients := api.Group("/oauth/clients")
+	oauthClients.Post("/", middleware.Protected, h.CreateOAuthClient)
+	oauthClients.Get("/", middleware.Protected, h.GetOAuthClients)
+	oauthClients.Delete("/:id", middleware.Protected, h.DeleteOAuthClient)
+
+	invitations := api.Group("/invitations")
+	invitations.Post("/", middleware.Protected, h.CreateInvitation)
+	invitations.Get("/", middleware.Protected, h.GetInvitations)
+	invitations.Delete("/:id", middleware.Protected, h.DeleteInvitation)
+
+	organizations := api.Group("/organizations")
+	organizations.Post("/", middleware.Protected, h.CreateOrganization)
+	organizations.Get("/", middleware.Protected, h.GetOrganizations)
+	organizations.Get("/:id", middleware.Protected, h.GetOrganization)
+	organizations.Put("/:id", middleware.Protected, h.UpdateOrganization)
+	organizations.Delete("/:id", middleware.Protected, h.DeleteOrganization)
+	organizations.Post("/:id/members", middleware.Protected, h.AddOrganizationMember)
+	organizations.Put("/:id/members/:accountId", middleware.Protected, h.UpdateOrganizationMember)
+	organizations.Delete("/:id/members/:accountId", middleware.Protected, h.RemoveOrganizationMember)
+
+	shareLinks := api.Group("/share-links")
+	shareLinks.Post("/", middleware.Protected, h.CreateShareLink)
+	shareLinks.Get("/", middleware.Protected, h.GetShareLinks)
+	shareLinks.Delete("/:id", middleware.Protected, h.RevokeShareLink)
+
+	api.Get("/public/share/:token", h.GetPublicShareLink)
+
+	notifications := api.Group("/notifications")
+	notifications.Get("/", middleware.Protected, h.GetNotifications)
+	notifications.Put("/read", middleware.Protected, h.ReadAllNotifications)
+	notifications.Put("/:id/read", middleware.Protected, h.ReadNotification)
+
+	statuses := api.Group("/statuses")
+	statuses.Get("/", middleware.Protected, h.GetStatuses)
+	statuses.Post("/", middleware.Protected, h.CreateStatus)
+	statuses.Put("/:id", middleware.Protected, h.UpdateStatus)
+	statuses.Delete("/:id", middleware.Protected, h.DeleteStatus)
+	statuses.Put("/:id/transitions", middleware.Protected, h.SetStatusTransitions)
+
+	templates := api.Group("/templates")
+	templates.Get("/", middleware.Protected, h.GetTemplates)
+	templates.Post("/", middleware.Protected, h.CreateTemplate)
+	templates.Get("/:id", middleware.Protected, h.GetTemplate)
+	templates.Delete("/:id", middleware.Protected, h.DeleteTemplate)
+	templates.Post("/:id/instantiate", middleware.Protected, h.InstantiateTemplate)
+
+	timeTracking := api.Group("/time")
+	timeTracking.Get("/timer", middleware.Protected, h.GetTimer)
+	timeTracking.Get("/report", middleware.Protected, h.GetTimeReport)
+
 	accounts := api.Group("/accounts")
 	accounts.Get("/", middleware.Protected, middleware.Pagination, h.GetAccounts)
+	accounts.Get("/login-attempts", middleware.Protected, middleware.Pagination, h.GetLoginAttempts)
 	accounts.Get("/:id", middleware.Protected, h.GetAccount)
 
 	todos := api.Group("/todos")
 	todos.Get("/", middleware.Protected, middleware.Pagination, h.GetTodos)
 	todos.Get("/csv", middleware.Protected, h.ExportCSVTodos)
+	todos.Get("/shared", middleware.Protected, h.GetSharedTodos)
+	todos.Get("/assigned", middleware.Protected, h.GetAssignedTodos)
+	todos.Get("/trash", middleware.Protected, h.GetTrash)
+	todos.Delete("/trash", middleware.Protected, h.EmptyTrash)
+	todos.Post("/trash/:id/restore", middleware.Protected, h.RestoreTodo)
+	todos.Delete("/trash/:id", middleware.Protected, h.PurgeTodo)
+	todos.Post("/archive-completed", middleware.Protected, h.ArchiveCompletedTodos)
+	todos.Get("/auto-archive", middleware.Protected, h.GetAutoArchive)
+	todos.Get("/dependencies/graph", middleware.Protected, h.GetDependencyGraph)
+	todos.Put("/auto-archive", middleware.Protected, h.UpdateAutoArchive)
 	todos.Get("/:id", middleware.Protected, h.GetTodo)
 	todos.Post("/", middleware.Protected, h.CreateTodo)
+	todos.Post("/quick", middleware.Protected, h.QuickAddTodo)
 	todos.Post("/csv", middleware.Protected, h.ImportCSVTodos)
 	todos.Put("/:id", middleware.Protected, h.UpdateTodo)
 	todos.Delete("/:id", middleware.Protected, h.DeleteTodo)
+	todos.Put("/:id/assignee", middleware.Protected, h.AssignTodo)
+	todos.Put("/:id/status", middleware.Protected, h.MoveTodo)
+	todos.Post("/:id/move", middleware.Protected, h.ReorderTodo)
+	todos.Get("/:id/dependencies", middleware.Protected, h.GetTodoDependencies)
+	todos.Post("/:id/dependencies", middleware.Protected, h.AddTodoDependency)
+	todos.Get("/:id/dependencies/graph", middleware.Protected, h.GetTodoDependencyGraph)
+	todos.Delete("/:id/dependencies/:blockerId", middleware.Protected, h.RemoveTodoDependency)
+	todos.Get("/:id/time", middleware.Protected, h.GetTodoTime)
+	todos.Post("/:id/time", middleware.Protected, h.CreateTimeEntry)
+	todos.Delete("/:id/time/:entryId", middleware.Protected, h.DeleteTimeEntry)
+	todos.Post("/:id/timer", middleware.Protected, h.StartTimer)
+	todos.Delete("/:id/timer", middleware.Protected, h.StopTimer)
+	todos.Post("/:id/archive", middleware.Protected, h.ArchiveTodo)
+	todos.Post("/:id/unarchive", middleware.Protected, h.UnarchiveTodo)
+	todos.Get("/:id/history", middleware.Protected, h.GetTodoHistory)
+	todos.Post("/:id/revert/:revision", middleware.Protected, h.RevertTodo)
+	todos.Get("/:id/comments", middleware.Protected, h.GetComments)
+	todos.Post("/:id/comments", middleware.Protected, h.CreateComment)
+	todos.Put("/:id/comments/:commentId", middleware.Protected, h.UpdateComment)
+	todos.Delete("/:id/comments/:commentId", middleware.Protected, h.DeleteComment)
+	todos.Get("/:id/attachments", middleware.Protected, h.GetAttachments)
+	todos.Post("/:id/attachments", middleware.Protected, h.CreateAttachment)
+	todos.Get("/:id/attachments/:attachmentId", middleware.Protected, h.DownloadAttachment)
+	todos.Delete("/:id/attachments/:attachmentId", middleware.Protected, h.DeleteAttachment)
+	todos.Post("/:id/shares", middleware.Protected, h.ShareTodo)
+	todos.Get("/:id/shares", middleware.Protected, h.GetTodoShares)
+	todos.Delete("/:id/shares/:accountId", middleware.Protected, h.DeleteTodoShare)
 
 	todos.Post("/random", middleware.Protected, h.CreateRandomTodo)
 }
+
+// RegisterOAuthRoutes registers the protocol endpoints of the OAuth2 authorization server used by third party clients
+func (h *Handler) RegisterOAuthRoutes(app *fiber.App) {
+	app.Get("/.well-known/oauth-authorization-server", h.OAuthMetadata)
+
+	oauth := app.Group("/oauth")
+	oauth.Post("/token", h.OAuthToken)
+	oauth.Post("/introspect", h.OAuthIntrospect)
+	oauth.Post("/revoke", h.OAuthRevoke)
+}
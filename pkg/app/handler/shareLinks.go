@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// SHARE_LINK_PASSWORD_HEADER carries the password of protected share links for JSON requests
+const SHARE_LINK_PASSWORD_HEADER = "X-Share-Password"
+
+// createShareLink stores a link to the todos of the current space and returns it with its public URL
+func (h *Handler) createShareLink(c *fiber.Ctx, remoteData *types.CreateShareLinkDTO) (*model.ShareLink, string, error) {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return nil, "", err
+	}
+
+	link := &model.ShareLink{
+		Name:   remoteData.Name,
+		Status: remoteData.Status,
+		Search: remoteData.Search,
+	}
+	if link.Status == "" {
+		link.Status = model.SHARE_LINK_STATUS_ALL
+	}
+
+	if remoteData.ExpiresIn != "" {
+		expiresIn, err := time.ParseDuration(remoteData.ExpiresIn)
+		if err != nil || expiresIn <= 0 {
+			return nil, "", utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "expiresIn must be a positive duration like 72h")
+		}
+		link.ExpiresAt = null.TimeFrom(time.Now().Add(expiresIn))
+	}
+
+	if remoteData.Password != "" {
+		hash, err := model.HashPassword(remoteData.Password)
+		if err != nil {
+			return nil, "", &utils.INTERNAL_SERVER_ERROR
+		}
+		link.PasswordHash = hash
+	}
+
+	token, err := h.shareLinkService.CreateLink(link, space)
+	if err != nil {
+		return nil, "", &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return link, strings.TrimSuffix(config.APP_URL, "/") + "/share/" + token, nil
+}
+
+// openShareLink checks the link and its password, counts the view and returns the todos of the link.
+// Unknown, revoked and expired links are all reported as not found. Wrong passwords are throttled per link and IP
+// like logins, on counters of their own.
+func (h *Handler) openShareLink(c *fiber.Ctx, token string, password string) (*model.ShareLink, []model.Todo, error) {
+	link := &model.ShareLink{}
+	if err := h.shareLinkService.FindLinkByToken(link, token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, &utils.NOT_FOUND
+		}
+		return nil, nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if !link.Active() {
+		return nil, nil, &utils.NOT_FOUND
+	}
+
+	if link.HasPassword() {
+		if password == "" {
+			return link, nil, &utils.SHARE_LINK_PASSWORD_REQUIRED
+		}
+		if err := h.checkShareLinkThrottle(c, link); err != nil {
+			return link, nil, err
+		}
+		if !model.CheckPasswordHash(password, link.PasswordHash) {
+			h.recordShareLinkAttempt(c, link, model.LOGIN_FAILURE_WRONG_PASSWORD)
+			return link, nil, &utils.SHARE_LINK_PASSWORD_WRONG
+		}
+		h.recordShareLinkAttempt(c, link, "")
+	}
+
+	todos := []model.Todo{}
+	if err := h.shareLinkService.FindLinkTodos(&todos, link, h.todoService.InSpace(link.Space())).Error; err != nil {
+		return nil, nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if err := h.shareLinkService.RecordView(link).Error; err != nil {
+		return nil, nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return link, todos, nil
+}
+
+// checkShareLinkThrottle rejects the password of the link while the link or IP has to wait after wrong passwords
+func (h *Handler) checkShareLinkThrottle(c *fiber.Ctx, link *model.ShareLink) error {
+	retryAfter, err := h.shareLinkService.RetryAfter(link, c.IP())
+	if err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if retryAfter > 0 {
+		h.recordShareLinkAttempt(c, link, model.LOGIN_FAILURE_THROTTLED)
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		return &utils.SHARE_LINK_PASSWORD_THROTTLED
+	}
+
+	return nil
+}
+
+// recordShareLinkAttempt stores the outcome of a password entered for the link. An empty failure reason means it matched.
+func (h *Handler) recordShareLinkAttempt(c *fiber.Ctx, link *model.ShareLink, failureReason string) {
+	h.shareLinkService.RecordAttempt(&model.ShareLinkAttempt{
+		ShareLinkID: link.ID,
+		IP:          c.IP(),
+		Success:     failureReason == "",
+		Reason:      failureReason,
+	})
+}
+
+// CreateShareLink godoc
+//
+//	@Summary		Create share link
+//	@Description	Creates a public read-only link to the todos of the current space matching the filter
+//	@Tags			share-links
+//	@Accept			json
+//	@Produce		json
+//	@Param			shareLink	body		types.CreateShareLinkDTO	true	"Share link"
+//	@Success		201			{object}	types.CreateShareLinkResponse
+//	@Security		BearerAuth
+//	@Router			/share-links [post]
+func (h *Handler) CreateShareLink(c *fiber.Ctx) error {
+	remoteData := &types.CreateShareLinkDTO{}
+
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	link, url, err := h.createShareLink(c, remoteData)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&types.CreateShareLinkResponse{
+		ShareLink: *link,
+		URL:       url,
+	})
+}
+
+// GetShareLinks godoc
+//
+//	@Summary		List share links
+//	@Description	Lists the share links the account created in the current space, including revoked ones
+//	@Tags			share-links
+//	@Produce		json
+//	@Success		200	{object}	types.GetShareLinksResponse
+//	@Security		BearerAuth
+//	@Router			/share-links [get]
+func (h *Handler) GetShareLinks(c *fiber.Ctx) error {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+
+	links := []model.ShareLink{}
+	if err := h.shareLinkService.FindLinks(&links, space).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetShareLinksResponse{
+		ShareLinks: links,
+	})
+}
+
+// RevokeShareLink godoc
+//
+//	@Summary	Revoke share link
+//	@Tags		share-links
+//	@Param		id	path		int	true	"Share link ID"
+//	@Success	204	{object}	nil	"No Content"
+//	@Security	BearerAuth
+//	@Router		/share-links/{id} [delete]
+func (h *Handler) RevokeShareLink(c *fiber.Ctx) error {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+
+	result := h.shareLinkService.RevokeLink(c.Params("id"), space)
+	if result.Error != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+	if result.RowsAffected == 0 {
+		return &utils.NOT_FOUND
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetPublicShareLink godoc
+//
+//	@Summary		Open share link
+//	@Description	Returns the todos of a share link without authentication. Protected links need the password in the X-Share-Password header, wrong passwords are throttled like logins.
+//	@Tags			share-links
+//	@Produce		json
+//	@Param			token				path		string	true	"Token of the share link"
+//	@Param			X-Share-Password	header		string	false	"Password of protected links"
+//	@Success		200					{object}	types.PublicShareLinkResponse
+//	@Router			/public/share/{token} [get]
+func (h *Handler) GetPublicShareLink(c *fiber.Ctx) error {
+	link, todos, err := h.openShareLink(c, c.Params("token"), c.Get(SHARE_LINK_PASSWORD_HEADER))
+	if err != nil {
+		return err
+	}
+
+	publicTodos := make([]types.PublicTodo, len(todos))
+	for i, todo := range todos {
+		publicTodos[i] = types.PublicTodo{
+			Title:       todo.Title.String,
+			Description: todo.Description.String,
+			Completed:   todo.Completed,
+			CompletedAt: todo.CompletedAt,
+		}
+	}
+
+	return c.JSON(&types.PublicShareLinkResponse{
+		Name:  link.Name,
+		Todos: publicTodos,
+	})
+}
+
+// VShareLinksCreate creates a share link from the panel of the todos page and shows its URL once
+func (h *Handler) VShareLinksCreate(c *fiber.Ctx) error {
+	remoteData := &types.CreateShareLinkDTO{}
+
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return renderFormErrors(c, "#share-link-errors", []string{"Please enter a name, passwords need at least 8 characters."})
+	}
+
+	link, url, err := h.createShareLink(c, remoteData)
+	if err != nil {
+		var requestError *utils.RequestError
+		if errors.As(err, &requestError) && requestError.StatusCode == fiber.StatusBadRequest {
+			return renderFormErrors(c, "#share-link-errors", []string{requestError.Detail})
+		}
+		return err
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.ShareLinkCreated(*link, url)))(c)
+}
+
+// VShareLinksRevoke revokes a share link from the panel of the todos page
+func (h *Handler) VShareLinksRevoke(c *fiber.Ctx) error {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.shareLinkService.RevokeLink(c.Params("id"), space).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	link := &model.ShareLink{}
+	if err := h.shareLinkService.FindLinkByID(link, c.Params("id"), space).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &utils.NOT_FOUND
+		}
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.ShareLinkRow(*link)))(c)
+}
+
+// VPublicShareLink shows the read-only page of a share link, protected links ask for the password first
+func (h *Handler) VPublicShareLink(c *fiber.Ctx) error {
+	token := c.Params("token")
+
+	password := ""
+	if c.Method() == fiber.MethodPost {
+		password = c.FormValue("password")
+	}
+
+	link, todos, err := h.openShareLink(c, token, password)
+	if err != nil {
+		switch {
+		case errors.Is(err, &utils.SHARE_LINK_PASSWORD_REQUIRED):
+			return adaptor.HTTPHandler(templ.Handler(view.ShareLinkPasswordPage(link.Name, token, "")))(c)
+		case errors.Is(err, &utils.SHARE_LINK_PASSWORD_WRONG):
+			return adaptor.HTTPHandler(templ.Handler(view.ShareLinkPasswordPage(link.Name, token, "The password is incorrect."), templ.WithStatus(fiber.StatusUnauthorized)))(c)
+		case errors.Is(err, &utils.SHARE_LINK_PASSWORD_THROTTLED):
+			return adaptor.HTTPHandler(templ.Handler(view.ShareLinkPasswordPage(link.Name, token, "Too many wrong passwords, please try again later."), templ.WithStatus(fiber.StatusTooManyRequests)))(c)
+		case errors.Is(err, &utils.NOT_FOUND):
+			return adaptor.HTTPHandler(templ.Handler(view.ShareLinkNotFoundPage(), templ.WithStatus(fiber.StatusNotFound)))(c)
+		}
+		return err
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.PublicShareLinkPage(link.Name, todos)))(c)
+}
@@ -0,0 +1,203 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/handler"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestShareLinksHandlerPublicAccess(t *testing.T) {
+	// Setup
+	defer func(delay time.Duration) { config.LOGIN_THROTTLE_DELAY = delay }(config.LOGIN_THROTTLE_DELAY)
+	defer test.ClearAllTables(DB)
+
+	config.LOGIN_THROTTLE_DELAY = 0
+
+	pw, _ := model.HashPassword("123456")
+	account := &model.Account{Email: "sharelinks.owner@turbomeet.xyz", Password: pw}
+	service.NewAccountService(DB).CreateAccount(account)
+	auth, _ := jwt.Generate(account)
+
+	space := model.Space{AccountID: account.ID}
+	todoService := service.NewTodoService(DB)
+	todoService.CreateTodo(&model.Todo{Title: zero.StringFrom("Draft proposal")}, space)
+	todoService.CreateTodo(&model.Todo{Title: zero.StringFrom("Send invoice"), Completed: true}, space)
+
+	create := func(dto *types.CreateShareLinkDTO) *types.CreateShareLinkResponse {
+		res := send("POST", "/api/share-links", auth.Token, dto)
+		if res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+
+		response := &types.CreateShareLinkResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		return response
+	}
+
+	open := func(link string, password string) (*http.Response, *types.PublicShareLinkResponse) {
+		u, _ := url.Parse(link)
+		res := sendWithHeader("GET", "/api/public"+u.Path, "", nil, map[string]string{handler.SHARE_LINK_PASSWORD_HEADER: password})
+
+		response := &types.PublicShareLinkResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		return res, response
+	}
+
+	t.Run("should show the filtered list and count views", func(t *testing.T) {
+		created := create(&types.CreateShareLinkDTO{Name: "Client", Status: model.SHARE_LINK_STATUS_ACTIVE})
+		if !strings.HasPrefix(created.URL, config.APP_URL+"/share/") {
+			t.Errorf("Expected the link on APP_URL, got %s", created.URL)
+		}
+
+		res, response := open(created.URL, "")
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if response.Name != "Client" || len(response.Todos) != 1 || response.Todos[0].Title != "Draft proposal" {
+			t.Errorf("Expected only the active todo, got %v", response)
+		}
+
+		u, _ := url.Parse(created.URL)
+		req, _ := http.NewRequest("GET", u.Path, nil)
+		page, _ := App.Test(req, -1)
+		body, _ := io.ReadAll(page.Body)
+		if !strings.Contains(string(body), "Draft proposal") || strings.Contains(string(body), "Send invoice") {
+			t.Errorf("Expected the page to show the active todo only")
+		}
+
+		link := &model.ShareLink{}
+		DB.Take(link, created.ShareLink.ID)
+		if link.Views != 2 {
+			t.Errorf("Expected 2 views, got %d", link.Views)
+		}
+	})
+
+	t.Run("should require the password of protected links", func(t *testing.T) {
+		created := create(&types.CreateShareLinkDTO{Name: "Protected", Password: "s3cret-list"})
+
+		if res, _ := open(created.URL, ""); res.StatusCode != 401 {
+			t.Errorf("Expected status code 401 without password, got %d", res.StatusCode)
+		}
+		if res, _ := open(created.URL, "wrong"); res.StatusCode != 401 {
+			t.Errorf("Expected status code 401 for a wrong password, got %d", res.StatusCode)
+		}
+		if res, response := open(created.URL, "s3cret-list"); res.StatusCode != 200 || len(response.Todos) != 2 {
+			t.Errorf("Expected both todos with the password, got %d", res.StatusCode)
+		}
+
+		u, _ := url.Parse(created.URL)
+		req, _ := http.NewRequest("POST", u.Path, strings.NewReader(url.Values{"password": {"s3cret-list"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		page, _ := App.Test(req, -1)
+		body, _ := io.ReadAll(page.Body)
+		if page.StatusCode != 200 || !strings.Contains(string(body), "Send invoice") {
+			t.Errorf("Expected the page to open with the password, got %d", page.StatusCode)
+		}
+	})
+
+	t.Run("should stop working once revoked or expired", func(t *testing.T) {
+		created := create(&types.CreateShareLinkDTO{Name: "Temporary"})
+
+		// Links can only be revoked from the space they were created in
+		organization := &model.Organization{Name: "Share links"}
+		service.NewOrganizationService(DB).CreateOrganization(organization, account.ID)
+		id := strconv.FormatUint(uint64(created.ShareLink.ID), 10)
+		inOrganization := map[string]string{handler.ORGANIZATION_HEADER: strconv.FormatUint(uint64(organization.ID), 10)}
+		if res := sendWithHeader("DELETE", "/api/share-links/"+id, auth.Token, nil, inOrganization); res.StatusCode != 404 {
+			t.Fatalf("Expected status code 404 from another space, got %d", res.StatusCode)
+		}
+
+		if res := send("DELETE", "/api/share-links/"+id, auth.Token, nil); res.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+		}
+		if res, _ := open(created.URL, ""); res.StatusCode != 404 {
+			t.Errorf("Expected revoked links to 404, got %d", res.StatusCode)
+		}
+
+		expired := create(&types.CreateShareLinkDTO{Name: "Expired", ExpiresIn: "1ns"})
+		if res, _ := open(expired.URL, ""); res.StatusCode != 404 {
+			t.Errorf("Expected expired links to 404, got %d", res.StatusCode)
+		}
+
+		if res, _ := open("http://localhost/share/made-up", ""); res.StatusCode != 404 {
+			t.Errorf("Expected unknown links to 404, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should list the links on the todos page", func(t *testing.T) {
+		res := send("GET", "/api/share-links", auth.Token, nil)
+		response := &types.GetShareLinksResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if len(response.ShareLinks) != 4 {
+			t.Errorf("Expected 4 links, got %d", len(response.ShareLinks))
+		}
+
+		req, _ := http.NewRequest("GET", "/todos", nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: auth.Token})
+		page, _ := App.Test(req, -1)
+		body, _ := io.ReadAll(page.Body)
+		if !strings.Contains(string(body), "Share links") || !strings.Contains(string(body), "Protected") {
+			t.Errorf("Expected the share links panel")
+		}
+	})
+
+	t.Run("should reject short passwords", func(t *testing.T) {
+		if res := send("POST", "/api/share-links", auth.Token, &types.CreateShareLinkDTO{Name: "Weak", Password: "1234"}); res.StatusCode != 400 {
+			t.Errorf("Expected status code 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should throttle wrong passwords", func(t *testing.T) {
+		// Failures of the other links count for the IP
+		test.ClearTables(DB, []string{"share_link_attempts"})
+		config.LOGIN_THROTTLE_DELAY = time.Hour
+		defer func() { config.LOGIN_THROTTLE_DELAY = 0 }()
+
+		created := create(&types.CreateShareLinkDTO{Name: "Guarded", Password: "s3cret-list"})
+		if res, _ := open(created.URL, "wrong-password"); res.StatusCode != 401 {
+			t.Fatalf("Expected status code 401, got %d", res.StatusCode)
+		}
+
+		res, _ := open(created.URL, "s3cret-list")
+		if res.StatusCode != 429 || res.Header.Get("Retry-After") == "" {
+			t.Errorf("Expected status code 429 with Retry-After, got %d", res.StatusCode)
+		}
+
+		// The attempts are kept apart from the logins
+		var logins int64
+		DB.Model(&model.LoginAttempt{}).Count(&logins)
+		if logins != 0 {
+			t.Errorf("Expected no login attempts, got %d", logins)
+		}
+	})
+
+	t.Run("should match wildcards in the search literally", func(t *testing.T) {
+		created := create(&types.CreateShareLinkDTO{Name: "Wildcard", Search: "%"})
+		if _, response := open(created.URL, ""); len(response.Todos) != 0 {
+			t.Errorf("Expected no todos, got %v", response.Todos)
+		}
+	})
+
+	t.Run("should leave out archived todos", func(t *testing.T) {
+		DB.Model(&model.Todo{}).Where("title = ?", "Send invoice").Update("archived_at", time.Now())
+
+		created := create(&types.CreateShareLinkDTO{Name: "Current"})
+		if _, response := open(created.URL, ""); len(response.Todos) != 1 || response.Todos[0].Title != "Draft proposal" {
+			t.Errorf("Expected only the todo that isn't archived, got %v", response.Todos)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+// findTodoRole finds a todo of the current space or one shared with the account and returns the role of the
+// account for it. Todos the account can't see at all are reported as not found.
+func (h *Handler) findTodoRole(c *fiber.Ctx, todo *model.Todo, id string) (string, error) {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return "", err
+	}
+
+	role, err := h.todoService.FindTodoWithRole(todo, id, space)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", &utils.NOT_FOUND
+		}
+		return "", &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return role, nil
+}
+
+// findTodoWithRole finds a todo like findTodoRole and checks that the account has at least the required role for it
+func (h *Handler) findTodoWithRole(c *fiber.Ctx, todo *model.Todo, id string, required string) error {
+	role, err := h.findTodoRole(c, todo, id)
+	if err != nil {
+		return err
+	}
+
+	if !model.ShareRoleAllows(role, required) {
+		return &utils.SHARE_ROLE_REQUIRED
+	}
+
+	return nil
+}
+
+// GetSharedTodos godoc
+//
+//	@Summary		List shared todos
+//	@Description	Lists the todos other accounts shared with the account, with the role of each share
+//	@Tags			todos
+//	@Produce		json
+//	@Success		200	{object}	types.GetSharedTodosResponse
+//	@Security		BearerAuth
+//	@Router			/todos/shared [get]
+func (h *Handler) GetSharedTodos(c *fiber.Ctx) error {
+	todos := []model.SharedTodo{}
+
+	if err := h.todoService.FindSharedTodos(&todos, locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetSharedTodosResponse{
+		Todos: todos,
+	})
+}
+
+// ShareTodo godoc
+//
+//	@Summary		Share todo
+//	@Description	Shares a todo with another account by email, sharing again changes the role. Needs the owner role for the todo.
+//	@Tags			todos
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Todo ID"
+//	@Param			share	body		types.ShareTodoDTO	true	"Share"
+//	@Success		201		{object}	types.ShareTodoResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/shares [post]
+func (h *Handler) ShareTodo(c *fiber.Ctx) error {
+	remoteData := &types.ShareTodoDTO{}
+
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_OWNER); err != nil {
+		return err
+	}
+
+	account := &model.Account{}
+	if err := h.accountService.FindAccountByEmail(account, remoteData.Email).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &utils.NOT_FOUND
+		}
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if account.ID == locals.JwtPayload(c).AccountID {
+		return &utils.SHARE_WITH_SELF
+	}
+
+	role := remoteData.Role
+	if role == "" {
+		role = model.SHARE_ROLE_VIEWER
+	}
+
+	share := &model.TodoShare{
+		TodoID:     todo.ID,
+		AccountID:  account.ID,
+		Role:       role,
+		SharedByID: locals.JwtPayload(c).AccountID,
+	}
+	if err := h.shareService.ShareTodo(share); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&types.ShareTodoResponse{
+		Share: *share,
+	})
+}
+
+// GetTodoShares godoc
+//
+//	@Summary		List todo shares
+//	@Description	Lists the accounts a todo is shared with. Needs the owner role for the todo.
+//	@Tags			todos
+//	@Produce		json
+//	@Param			id	path		int	true	"Todo ID"
+//	@Success		200	{object}	types.GetTodoSharesResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/shares [get]
+func (h *Handler) GetTodoShares(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_OWNER); err != nil {
+		return err
+	}
+
+	shares := []model.TodoShare{}
+	if err := h.shareService.FindShares(&shares, todo.ID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetTodoSharesResponse{
+		Shares: shares,
+	})
+}
+
+// DeleteTodoShare godoc
+//
+//	@Summary		Unshare todo
+//	@Description	Removes the share of a todo with an account. Needs the owner role for the todo, accounts can always remove their own share.
+//	@Tags			todos
+//	@Param			id			path		int	true	"Todo ID"
+//	@Param			accountId	path		int	true	"Account ID of the share"
+//	@Success		204			{object}	nil	"No Content"
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/shares/{accountId} [delete]
+func (h *Handler) DeleteTodoShare(c *fiber.Ctx) error {
+	accountID, err := strconv.ParseUint(c.Params("accountId"), 10, 64)
+	if err != nil {
+		return &utils.BAD_REQUEST
+	}
+
+	required := model.SHARE_ROLE_OWNER
+	if uint(accountID) == locals.JwtPayload(c).AccountID {
+		required = model.SHARE_ROLE_VIEWER
+	}
+
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), required); err != nil {
+		return err
+	}
+
+	result := h.shareService.DeleteShare(todo.ID, uint(accountID))
+	if result.Error != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+	if result.RowsAffected == 0 {
+		return &utils.NOT_FOUND
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,135 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestSharesHandlerGrants(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "shares.owner@turbomeet.xyz", Password: pw}
+	colleague := &model.Account{Email: "shares.colleague@turbomeet.xyz", Password: pw}
+	stranger := &model.Account{Email: "shares.stranger@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+	accountService.CreateAccount(colleague)
+	accountService.CreateAccount(stranger)
+
+	ownerAuth, _ := jwt.Generate(owner)
+	colleagueAuth, _ := jwt.Generate(colleague)
+	strangerAuth, _ := jwt.Generate(stranger)
+
+	todo := &model.Todo{Title: zero.StringFrom("Hand over")}
+	service.NewTodoService(DB).CreateTodo(todo, model.Space{AccountID: owner.ID})
+	todoPath := "/api/todos/" + strconv.FormatUint(uint64(todo.ID), 10)
+
+	share := func(role string) {
+		res := send("POST", todoPath+"/shares", ownerAuth.Token, &types.ShareTodoDTO{Email: colleague.Email, Role: role})
+		if res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+	}
+
+	update := &types.UpdateTodoRequest{Todo: model.Todo{Title: zero.StringFrom("Handed over")}}
+
+	t.Run("should hide todos that aren't shared", func(t *testing.T) {
+		if res := send("GET", todoPath, colleagueAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected status code 404, got %d", res.StatusCode)
+		}
+		if res := send("POST", todoPath+"/shares", colleagueAuth.Token, &types.ShareTodoDTO{Email: stranger.Email}); res.StatusCode != 404 {
+			t.Errorf("Expected status code 404 for sharing foreign todos, got %d", res.StatusCode)
+		}
+		if res := send("POST", todoPath+"/shares", ownerAuth.Token, &types.ShareTodoDTO{Email: owner.Email}); res.StatusCode != 400 {
+			t.Errorf("Expected status code 400 for sharing with yourself, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should let viewers only read", func(t *testing.T) {
+		share(model.SHARE_ROLE_VIEWER)
+
+		if res := send("GET", todoPath, colleagueAuth.Token, nil); res.StatusCode != 200 {
+			t.Errorf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if res := send("PUT", todoPath, colleagueAuth.Token, update); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403 for updates, got %d", res.StatusCode)
+		}
+		if res := send("PUT", "/todos/"+strconv.FormatUint(uint64(todo.ID), 10)+"/complete", colleagueAuth.Token, nil); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403 for completing in the views, got %d", res.StatusCode)
+		}
+		if res := send("GET", todoPath, strangerAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected status code 404 for others, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should let editors update but not delete", func(t *testing.T) {
+		share(model.SHARE_ROLE_EDITOR)
+
+		if res := send("PUT", todoPath, colleagueAuth.Token, update); res.StatusCode != 200 {
+			t.Errorf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if res := send("DELETE", todoPath, colleagueAuth.Token, nil); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403 for deleting, got %d", res.StatusCode)
+		}
+		if res := send("POST", todoPath+"/shares", colleagueAuth.Token, &types.ShareTodoDTO{Email: stranger.Email}); res.StatusCode != 403 {
+			t.Errorf("Expected status code 403 for sharing further, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should list the todos shared with the account", func(t *testing.T) {
+		res := send("GET", "/api/todos/shared", colleagueAuth.Token, nil)
+		response := &types.GetSharedTodosResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if len(response.Todos) != 1 || response.Todos[0].ShareRole != model.SHARE_ROLE_EDITOR || response.Todos[0].Title.String != "Handed over" {
+			t.Errorf("Expected the todo shared as editor, got %v", response.Todos)
+		}
+
+		req, _ := http.NewRequest("GET", "/todos/shared", nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: colleagueAuth.Token})
+		res, _ = App.Test(req, -1)
+		body, _ := io.ReadAll(res.Body)
+		if !strings.Contains(string(body), "Handed over") {
+			t.Errorf("Expected the shared todo on the page")
+		}
+
+		res = send("GET", "/api/todos", colleagueAuth.Token, nil)
+		todos := &types.GetTodosResponse{}
+		json.NewDecoder(res.Body).Decode(todos)
+		if len(todos.Todos) != 0 {
+			t.Errorf("Expected shared todos to stay out of the own space, got %d", len(todos.Todos))
+		}
+	})
+
+	t.Run("should let owners delete and remove shares", func(t *testing.T) {
+		shares := send("GET", todoPath+"/shares", ownerAuth.Token, nil)
+		response := &types.GetTodoSharesResponse{}
+		json.NewDecoder(shares.Body).Decode(response)
+		if len(response.Shares) != 1 || response.Shares[0].Account.Email != colleague.Email {
+			t.Fatalf("Expected one share with the colleague, got %v", response.Shares)
+		}
+
+		share(model.SHARE_ROLE_OWNER)
+		if res := send("DELETE", todoPath, colleagueAuth.Token, nil); res.StatusCode != 204 {
+			t.Errorf("Expected status code 204, got %d", res.StatusCode)
+		}
+
+		var count int64
+		DB.Model(&model.TodoShare{}).Where("todo_id = ?", todo.ID).Count(&count)
+		if count != 0 {
+			t.Errorf("Expected the shares to be deleted with the todo, got %d", count)
+		}
+	})
+}
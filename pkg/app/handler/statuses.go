@@ -0,0 +1,382 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+// statusSpace returns the current space. Changing the workflow of an organization needs the admin role.
+func (h *Handler) statusSpace(c *fiber.Ctx, manage bool) (model.Space, error) {
+	space, err := h.currentSpace(c)
+	if err != nil || space.IsPersonal() || !manage {
+		return space, err
+	}
+
+	membership, err := h.findMembership(c, strconv.FormatUint(uint64(space.OrganizationID), 10))
+	if err != nil {
+		return space, err
+	}
+	if !membership.HasRole(model.ORGANIZATION_ROLE_ADMIN) {
+		return space, &utils.ORGANIZATION_ROLE_REQUIRED
+	}
+
+	return space, nil
+}
+
+// findStatus finds the status of the id param in the current space
+func (h *Handler) findStatus(c *fiber.Ctx, status *model.Status, manage bool) error {
+	space, err := h.statusSpace(c, manage)
+	if err != nil {
+		return err
+	}
+
+	if err := h.statusService.FindStatus(status, c.Params("id"), space).Error; err != nil {
+		return statusError(err)
+	}
+
+	return nil
+}
+
+// findStatuses lists the statuses of the space with the number of todos in each
+func (h *Handler) findStatuses(space model.Space) ([]types.StatusWithCount, error) {
+	statuses, err := h.statusService.FindStatuses(space)
+	if err != nil {
+		return nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	counts, err := h.statusService.CountTodos(space)
+	if err != nil {
+		return nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	result := make([]types.StatusWithCount, len(statuses))
+	for i, status := range statuses {
+		result[i] = types.StatusWithCount{Status: status, Todos: counts[status.ID]}
+	}
+
+	return result, nil
+}
+
+// moveTodo moves the todo of the id param to another status, it needs the editor role
+func (h *Handler) moveTodo(c *fiber.Ctx, todo *model.Todo, statusID uint) error {
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	if err := h.todoService.MoveTodo(todo, statusID, locals.JwtPayload(c).AccountID); err != nil {
+		return statusError(err)
+	}
+
+	return nil
+}
+
+func statusError(err error) error {
+	var requestError *utils.RequestError
+	if errors.As(err, &requestError) {
+		return requestError
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &utils.NOT_FOUND
+	}
+	return &utils.INTERNAL_SERVER_ERROR
+}
+
+// GetStatuses godoc
+//
+//	@Summary		List statuses
+//	@Description	Lists the workflow statuses of the current space with their todo counts and the allowed transitions. Spaces without statuses get Backlog, In progress, Review and Done.
+//	@Tags			statuses
+//	@Produce		json
+//	@Success		200	{object}	types.GetStatusesResponse
+//	@Security		BearerAuth
+//	@Router			/statuses [get]
+func (h *Handler) GetStatuses(c *fiber.Ctx) error {
+	space, err := h.statusSpace(c, false)
+	if err != nil {
+		return err
+	}
+
+	statuses, err := h.findStatuses(space)
+	if err != nil {
+		return err
+	}
+
+	transitions, err := h.statusService.FindTransitions(space)
+	if err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetStatusesResponse{
+		Statuses:    statuses,
+		Transitions: transitions,
+	})
+}
+
+// CreateStatus godoc
+//
+//	@Summary		Create status
+//	@Description	Adds a status to the workflow of the current space, organizations need the admin role
+//	@Tags			statuses
+//	@Accept			json
+//	@Produce		json
+//	@Param			status	body		types.StatusDTO	true	"Status"
+//	@Success		201		{object}	types.StatusResponse
+//	@Security		BearerAuth
+//	@Router			/statuses [post]
+func (h *Handler) CreateStatus(c *fiber.Ctx) error {
+	remoteData := &types.StatusDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	space, err := h.statusSpace(c, true)
+	if err != nil {
+		return err
+	}
+
+	status := &model.Status{Name: remoteData.Name, Position: remoteData.Position, Color: remoteData.Color, IsDone: remoteData.IsDone}
+	if err := h.statusService.CreateStatus(status, space); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&types.StatusResponse{
+		Status: *status,
+	})
+}
+
+// UpdateStatus godoc
+//
+//	@Summary		Update status
+//	@Description	Updates a status of the current space. Changing whether it counts as done completes or reopens its todos, each with a revision in its history.
+//	@Tags			statuses
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int				true	"Status ID"
+//	@Param			status	body		types.StatusDTO	true	"Status"
+//	@Success		200		{object}	types.StatusResponse
+//	@Security		BearerAuth
+//	@Router			/statuses/{id} [put]
+func (h *Handler) UpdateStatus(c *fiber.Ctx) error {
+	remoteData := &types.StatusDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	status := &model.Status{}
+	if err := h.findStatus(c, status, true); err != nil {
+		return err
+	}
+
+	status.Name = remoteData.Name
+	status.Position = remoteData.Position
+	status.Color = remoteData.Color
+	status.IsDone = remoteData.IsDone
+	if err := h.statusService.UpdateStatus(status, locals.JwtPayload(c).AccountID); err != nil {
+		return statusError(err)
+	}
+
+	return c.JSON(&types.StatusResponse{
+		Status: *status,
+	})
+}
+
+// DeleteStatus godoc
+//
+//	@Summary		Delete status
+//	@Description	Deletes a status without todos from the workflow of the current space
+//	@Tags			statuses
+//	@Param			id	path	int	true	"Status ID"
+//	@Success		204
+//	@Security		BearerAuth
+//	@Router			/statuses/{id} [delete]
+func (h *Handler) DeleteStatus(c *fiber.Ctx) error {
+	status := &model.Status{}
+	if err := h.findStatus(c, status, true); err != nil {
+		return err
+	}
+
+	if err := h.statusService.DeleteStatus(status); err != nil {
+		return statusError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SetStatusTransitions godoc
+//
+//	@Summary		Set status transitions
+//	@Description	Replaces the statuses todos in the status can be moved to, an empty list allows all statuses and a list of only the status itself none
+//	@Tags			statuses
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int							true	"Status ID"
+//	@Param			transitions	body		types.SetTransitionsDTO	true	"Transitions"
+//	@Success		200			{object}	types.GetStatusesResponse
+//	@Security		BearerAuth
+//	@Router			/statuses/{id}/transitions [put]
+func (h *Handler) SetStatusTransitions(c *fiber.Ctx) error {
+	remoteData := &types.SetTransitionsDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	status := &model.Status{}
+	if err := h.findStatus(c, status, true); err != nil {
+		return err
+	}
+
+	if err := h.statusService.SetTransitions(status, remoteData.ToStatusIDs); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "toStatusIds must be statuses of the same space")
+		}
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return h.GetStatuses(c)
+}
+
+// MoveTodo godoc
+//
+//	@Summary		Move todo
+//	@Description	Moves a todo to another status of its space if the workflow allows it, the todo is completed or reopened by the status. Needs the editor role.
+//	@Tags			statuses
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int					true	"Todo ID"
+//	@Param			status	body		types.MoveTodoDTO	true	"Status"
+//	@Success		200		{object}	types.UpdateTodoResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/status [put]
+func (h *Handler) MoveTodo(c *fiber.Ctx) error {
+	remoteData := &types.MoveTodoDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	todo := &model.Todo{}
+	if err := h.moveTodo(c, todo, remoteData.StatusID); err != nil {
+		return err
+	}
+
+	return c.JSON(&types.UpdateTodoResponse{
+		Todo: *todo,
+	})
+}
+
+// renderBoard renders the board of the current space, message explains a change that was rejected
+func (h *Handler) renderBoard(c *fiber.Ctx, page bool, message string) error {
+	space, err := h.statusSpace(c, false)
+	if err != nil {
+		return err
+	}
+
+	statuses, err := h.findStatuses(space)
+	if err != nil {
+		return err
+	}
+
+	todos := []model.Todo{}
+	if err := h.db.Model(&model.Todo{}).Where(h.todoService.InSpace(space)).Where("archived_at IS NULL").Order("created_at desc").Find(&todos).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if err := h.markBlocked(todos); err != nil {
+		return err
+	}
+
+	_, manageErr := h.statusSpace(c, true)
+	board := view.BoardData{Statuses: statuses, Todos: todos, CanManage: manageErr == nil, Message: message}
+
+	if page {
+		return adaptor.HTTPHandler(templ.Handler(view.BoardPage(h.GetBaseData(c), board)))(c)
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.Board(board)))(c)
+}
+
+// boardMessage returns the message of request errors the board shows instead of failing
+func boardMessage(err error) (string, error) {
+	var requestError *utils.RequestError
+	if errors.As(err, &requestError) && requestError.StatusCode < fiber.StatusInternalServerError {
+		return requestError.Message, nil
+	}
+
+	return "", err
+}
+
+// VBoard shows the todos of the current space in a column per status
+func (h *Handler) VBoard(c *fiber.Ctx) error {
+	return h.renderBoard(c, true, "")
+}
+
+// VBoardMove moves a todo dropped on a column and renders the board again
+func (h *Handler) VBoardMove(c *fiber.Ctx) error {
+	remoteData := &types.MoveTodoDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	message, err := boardMessage(h.moveTodo(c, &model.Todo{}, remoteData.StatusID))
+	if err != nil {
+		return err
+	}
+
+	return h.renderBoard(c, false, message)
+}
+
+// VStatusesCreate adds a column to the end of the board
+func (h *Handler) VStatusesCreate(c *fiber.Ctx) error {
+	remoteData := &types.StatusDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return h.renderBoard(c, false, "Enter a name of up to 64 characters and a hex color.")
+	}
+
+	space, err := h.statusSpace(c, true)
+	if err != nil {
+		return err
+	}
+
+	statuses, err := h.statusService.FindStatuses(space)
+	if err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	position := 0
+	if len(statuses) > 0 {
+		position = statuses[len(statuses)-1].Position + 1
+	}
+
+	status := &model.Status{Name: remoteData.Name, Position: position, Color: remoteData.Color, IsDone: remoteData.IsDone}
+	if err := h.statusService.CreateStatus(status, space); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return h.renderBoard(c, false, "")
+}
+
+// VStatusesDelete removes an empty column from the board
+func (h *Handler) VStatusesDelete(c *fiber.Ctx) error {
+	status := &model.Status{}
+	if err := h.findStatus(c, status, true); err != nil {
+		return err
+	}
+
+	message := ""
+	if err := h.statusService.DeleteStatus(status); err != nil {
+		if message, err = boardMessage(statusError(err)); err != nil {
+			return err
+		}
+	}
+
+	return h.renderBoard(c, false, message)
+}
@@ -0,0 +1,269 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestStatusesHandlerWorkflow(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "status.owner@turbomeet.xyz", Password: pw}
+	viewer := &model.Account{Email: "status.viewer@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+	accountService.CreateAccount(viewer)
+
+	ownerAuth, _ := jwt.Generate(owner)
+	viewerAuth, _ := jwt.Generate(viewer)
+
+	todoService := service.NewTodoService(DB)
+	space := model.Space{AccountID: owner.ID}
+	todo := &model.Todo{Title: zero.StringFrom("Write the release notes")}
+	todoService.CreateTodo(todo, space)
+	service.NewShareService(DB).ShareTodo(&model.TodoShare{TodoID: todo.ID, AccountID: viewer.ID, Role: model.SHARE_ROLE_VIEWER, SharedByID: owner.ID})
+
+	statuses := func() types.GetStatusesResponse {
+		res := send("GET", "/api/statuses", ownerAuth.Token, nil)
+		response := types.GetStatusesResponse{}
+		json.NewDecoder(res.Body).Decode(&response)
+		return response
+	}
+
+	named := func(name string) types.StatusWithCount {
+		for _, status := range statuses().Statuses {
+			if status.Name == name {
+				return status
+			}
+		}
+		t.Fatalf("Expected a status named %s", name)
+		return types.StatusWithCount{}
+	}
+
+	statusPath := func(status types.StatusWithCount) string {
+		return "/api/statuses/" + strconv.FormatUint(uint64(status.ID), 10)
+	}
+	todoPath := "/api/todos/" + strconv.FormatUint(uint64(todo.ID), 10)
+
+	move := func(token string, status types.StatusWithCount) *http.Response {
+		return send("PUT", todoPath+"/status", token, &types.MoveTodoDTO{StatusID: status.ID})
+	}
+
+	t.Run("should start with the default workflow", func(t *testing.T) {
+		response := statuses()
+		if len(response.Statuses) != len(model.DefaultStatuses) {
+			t.Fatalf("Expected %d default statuses, got %d", len(model.DefaultStatuses), len(response.Statuses))
+		}
+		if backlog := response.Statuses[0]; backlog.Name != "Backlog" || backlog.Todos != 1 {
+			t.Errorf("Expected the todo in the backlog, got %+v", backlog)
+		}
+	})
+
+	t.Run("should complete todos moved to a done status", func(t *testing.T) {
+		if res := move(viewerAuth.Token, named("Done")); res.StatusCode != 403 {
+			t.Errorf("Expected viewers to be rejected with 403, got %d", res.StatusCode)
+		}
+
+		res := move(ownerAuth.Token, named("Done"))
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		response := &types.UpdateTodoResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if !response.Todo.Completed || !response.Todo.CompletedAt.Valid {
+			t.Errorf("Expected the todo to be completed, got %+v", response.Todo)
+		}
+
+		history := []model.TodoHistory{}
+		todoService.FindHistory(&history, todo.ID)
+		last := history[len(history)-1]
+		if last.Action != model.TODO_HISTORY_MOVED {
+			t.Errorf("Expected the move in the history, got %s", last.Action)
+		}
+	})
+
+	t.Run("should move todos that are reopened to an open status", func(t *testing.T) {
+		res := send("PUT", todoPath, ownerAuth.Token, &types.UpdateTodoRequest{Todo: model.Todo{Title: todo.Title, Completed: false}})
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		response := &types.UpdateTodoResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if response.Todo.StatusID == nil || *response.Todo.StatusID != named("Backlog").ID {
+			t.Errorf("Expected the todo back in the backlog, got %+v", response.Todo.StatusID)
+		}
+	})
+
+	t.Run("should enforce transition rules", func(t *testing.T) {
+		backlog, review, done := named("Backlog"), named("Review"), named("Done")
+		res := send("PUT", statusPath(backlog)+"/transitions", ownerAuth.Token, &types.SetTransitionsDTO{ToStatusIDs: []uint{named("In progress").ID}})
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if transitions := statuses().Transitions; len(transitions) != 1 {
+			t.Errorf("Expected 1 transition, got %+v", transitions)
+		}
+
+		if res := move(ownerAuth.Token, review); res.StatusCode != 422 {
+			t.Errorf("Expected the move to be rejected with 422, got %d", res.StatusCode)
+		}
+		res = send("PUT", todoPath, ownerAuth.Token, &types.UpdateTodoRequest{Todo: model.Todo{Title: todo.Title, Completed: true}})
+		if res.StatusCode != 422 {
+			t.Errorf("Expected completing to be rejected with 422, got %d", res.StatusCode)
+		}
+		if res := move(ownerAuth.Token, named("In progress")); res.StatusCode != 200 {
+			t.Errorf("Expected the allowed move to succeed, got %d", res.StatusCode)
+		}
+		if res := move(ownerAuth.Token, done); res.StatusCode != 200 {
+			t.Errorf("Expected statuses without rules to allow all moves, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should manage statuses", func(t *testing.T) {
+		res := send("POST", "/api/statuses", ownerAuth.Token, &types.StatusDTO{Name: "Blocked", Position: 5, Color: "#dc2626"})
+		if res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+		if res := send("POST", "/api/statuses", ownerAuth.Token, &types.StatusDTO{Name: "Bad", Color: "red"}); res.StatusCode != 400 {
+			t.Errorf("Expected invalid colors to fail with 400, got %d", res.StatusCode)
+		}
+
+		if res := send("DELETE", statusPath(named("Done")), ownerAuth.Token, nil); res.StatusCode != 409 {
+			t.Errorf("Expected statuses with todos to fail with 409, got %d", res.StatusCode)
+		}
+		if res := send("DELETE", statusPath(named("Blocked")), ownerAuth.Token, nil); res.StatusCode != 204 {
+			t.Errorf("Expected status code 204, got %d", res.StatusCode)
+		}
+
+		review := named("Review")
+		res = send("PUT", statusPath(review), ownerAuth.Token, &types.StatusDTO{Name: "Review", Position: review.Position, IsDone: true})
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if res := send("DELETE", statusPath(named("Done")), viewerAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected statuses of other spaces to get 404, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should move todos on the board", func(t *testing.T) {
+		values := url.Values{"statusId": {strconv.FormatUint(uint64(named("Backlog").ID), 10)}}
+		req, _ := http.NewRequest("PUT", "/todos/"+strconv.FormatUint(uint64(todo.ID), 10)+"/status", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: ownerAuth.Token})
+		res, _ := App.Test(req, -1)
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		body, _ := io.ReadAll(res.Body)
+		if !strings.Contains(string(body), `id="board"`) || !strings.Contains(string(body), "Write the release notes") {
+			t.Errorf("Expected the board with the todo, got %s", body)
+		}
+		if named("Backlog").Todos != 1 {
+			t.Errorf("Expected the todo in the backlog")
+		}
+
+		req, _ = http.NewRequest("GET", "/board", nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: ownerAuth.Token})
+		res, _ = App.Test(req, -1)
+		body, _ = io.ReadAll(res.Body)
+		if res.StatusCode != 200 || !strings.Contains(string(body), "In progress") {
+			t.Errorf("Expected the board page with its columns, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should record todos completed by their status", func(t *testing.T) {
+		backlog := named("Backlog")
+		res := send("PUT", statusPath(backlog), ownerAuth.Token, &types.StatusDTO{Name: "Backlog", Position: backlog.Position, IsDone: true})
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		history := []model.TodoHistory{}
+		todoService.FindHistory(&history, todo.ID)
+		completed := false
+		for _, entry := range history {
+			completed = completed || (entry.Revision == history[len(history)-1].Revision && entry.Field == "completed")
+		}
+		if last := history[len(history)-1]; last.Action != model.TODO_HISTORY_COMPLETED || last.ActorID != owner.ID || !completed {
+			t.Errorf("Expected the completion in the history, got %+v", history)
+		}
+
+		send("PUT", statusPath(backlog), ownerAuth.Token, &types.StatusDTO{Name: "Backlog", Position: backlog.Position})
+		todoService.FindHistory(&history, todo.ID)
+		if last := history[len(history)-1]; last.Action != model.TODO_HISTORY_REOPENED {
+			t.Errorf("Expected the todo to be reopened in the history, got %+v", last)
+		}
+	})
+
+	t.Run("should not complete blocked todos if configured", func(t *testing.T) {
+		config.DEPENDENCIES_BLOCK_COMPLETION = true
+		defer func() { config.DEPENDENCIES_BLOCK_COMPLETION = false }()
+
+		blocker := &model.Todo{Title: zero.StringFrom("Collect the changes")}
+		todoService.CreateTodo(blocker, space)
+		blockerPath := "/api/todos/" + strconv.FormatUint(uint64(blocker.ID), 10)
+		if res := send("PUT", blockerPath+"/status", ownerAuth.Token, &types.MoveTodoDTO{StatusID: named("In progress").ID}); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if res := send("POST", todoPath+"/dependencies", ownerAuth.Token, &types.AddDependencyDTO{BlockedByID: blocker.ID}); res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+
+		backlog := named("Backlog")
+		res := send("PUT", statusPath(backlog), ownerAuth.Token, &types.StatusDTO{Name: "Backlog", Position: backlog.Position, IsDone: true})
+		if res.StatusCode != 409 {
+			t.Errorf("Expected status code 409, got %d", res.StatusCode)
+		}
+		if stored := (&model.Todo{}); DB.Take(stored, todo.ID).Error != nil || stored.Completed {
+			t.Errorf("Expected the blocked todo to stay open")
+		}
+
+		// Blockers completed by the same status don't count
+		if res := send("PUT", blockerPath+"/status", ownerAuth.Token, &types.MoveTodoDTO{StatusID: backlog.ID}); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		res = send("PUT", statusPath(backlog), ownerAuth.Token, &types.StatusDTO{Name: "Backlog", Position: backlog.Position, IsDone: true})
+		if res.StatusCode != 200 {
+			t.Errorf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		send("PUT", statusPath(backlog), ownerAuth.Token, &types.StatusDTO{Name: "Backlog", Position: backlog.Position})
+	})
+
+	t.Run("should keep todos in statuses that only allow themselves", func(t *testing.T) {
+		inProgress := named("In progress")
+		if res := move(ownerAuth.Token, inProgress); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		res := send("PUT", statusPath(inProgress)+"/transitions", ownerAuth.Token, &types.SetTransitionsDTO{ToStatusIDs: []uint{inProgress.ID, inProgress.ID}})
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if res := move(ownerAuth.Token, named("Done")); res.StatusCode != 422 {
+			t.Errorf("Expected the move to be rejected with 422, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should seed the default statuses once", func(t *testing.T) {
+		seedKey := model.StatusSeedKey(space, "Backlog")
+		if err := DB.Create(&model.Status{AccountID: owner.ID, Name: "Backlog", SeedKey: &seedKey}).Error; err == nil {
+			t.Errorf("Expected a second default backlog to be rejected")
+		}
+	})
+}
@@ -1,11 +1,17 @@
 package handler_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
 	"os"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/nleiva/go-todo-api/config"
 	"github.com/nleiva/go-todo-api/pkg/app"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
 	"github.com/nleiva/go-todo-api/test"
 	"gorm.io/gorm"
 )
@@ -15,6 +21,13 @@ var DB *gorm.DB
 
 func TestMain(m *testing.M) {
 	// Setup
+	config.MAILER = "memory"
+	config.BLOB_STORE = "local"
+	config.BLOB_LOCAL_PATH = config.TEST_FILE_PATH + "blobs"
+	// Tests run the jobs themselves, background runs would change the tables under them
+	config.TRASH_PURGE_INTERVAL = 0
+	config.AUTO_ARCHIVE_INTERVAL = 0
+	config.POSITION_REBALANCE_INTERVAL = 0
 	DB = test.Setup()
 	if DB == nil {
 		panic("Failed to setup database")
@@ -33,3 +46,34 @@ func TestMain(m *testing.M) {
 
 	os.Exit(code)
 }
+
+// send sends the body as JSON to the app with the token as bearer
+func send(method string, path string, token string, body any) *http.Response {
+	return sendWithHeader(method, path, token, body, nil)
+}
+
+// sendWithHeader sends the body as JSON to the app with the header, requests without a token are anonymous
+func sendWithHeader(method string, path string, token string, body any, header map[string]string) *http.Response {
+	bodyBytes, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, path, bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	res, _ := App.Test(req, -1)
+	return res
+}
+
+// createTodo creates the todo through the API in the personal space of the account of the token
+func createTodo(t *testing.T, token string, todo model.Todo) *model.Todo {
+	res := send("POST", "/api/todos", token, &types.CreateTodoRequest{Todo: todo})
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+	}
+	response := &types.CreateTodoResponse{}
+	json.NewDecoder(res.Body).Decode(response)
+	return &response.Todo
+}
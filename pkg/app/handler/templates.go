@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+)
+
+const TEMPLATE_BASE_DATE_FORMAT = "2006-01-02"
+
+// findTemplate finds the template of the id in the current space
+func (h *Handler) findTemplate(c *fiber.Ctx, template *model.TodoTemplate, id string) (model.Space, error) {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return space, err
+	}
+
+	if err := h.templateService.FindTemplate(template, id, space).Error; err != nil {
+		return space, statusError(err)
+	}
+
+	return space, nil
+}
+
+// templateBase parses the base date of an instantiation, dates without a time are the end of the day in the
+// account's timezone like in quick-add
+func (h *Handler) templateBase(c *fiber.Ctx, value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	if base, err := time.Parse(time.RFC3339, value); err == nil {
+		return base, nil
+	}
+
+	account := &model.Account{}
+	if err := h.accountService.FindAccountByID(account, locals.JwtPayload(c).AccountID).Error; err != nil {
+		return time.Time{}, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	date, err := time.ParseInLocation(TEMPLATE_BASE_DATE_FORMAT, value, account.Location())
+	if err != nil {
+		return time.Time{}, utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "baseDate must be a time in RFC 3339 or a date like 2006-01-02")
+	}
+
+	return time.Date(date.Year(), date.Month(), date.Day(), 23, 59, 59, 0, date.Location()), nil
+}
+
+// createTemplate captures the todo of the id in the current space
+func (h *Handler) createTemplate(c *fiber.Ctx, todoID string, name string) (*model.TodoTemplate, error) {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return nil, err
+	}
+
+	todo := &model.Todo{}
+	if err := h.todoService.FindTodoByID(todo, todoID, space).Error; err != nil {
+		return nil, statusError(err)
+	}
+
+	template := &model.TodoTemplate{Name: name}
+	if err := h.templateService.CreateTemplate(template, todo); err != nil {
+		return nil, statusError(err)
+	}
+
+	return template, nil
+}
+
+// instantiateTemplate creates the todos of the template of the id in the current space
+func (h *Handler) instantiateTemplate(c *fiber.Ctx, id string, baseDate string) ([]model.Todo, error) {
+	template := &model.TodoTemplate{}
+	space, err := h.findTemplate(c, template, id)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := h.templateBase(c, baseDate)
+	if err != nil {
+		return nil, err
+	}
+
+	todos, err := h.templateService.InstantiateTemplate(template, space, base)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return todos, nil
+}
+
+// GetTemplates godoc
+//
+//	@Summary		List templates
+//	@Description	Lists the todo templates of the current space with their items
+//	@Tags			templates
+//	@Produce		json
+//	@Success		200	{object}	types.GetTemplatesResponse
+//	@Security		BearerAuth
+//	@Router			/templates [get]
+func (h *Handler) GetTemplates(c *fiber.Ctx) error {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+
+	templates := []model.TodoTemplate{}
+	if err := h.templateService.FindTemplates(&templates, space).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetTemplatesResponse{
+		Templates: templates,
+	})
+}
+
+// GetTemplate godoc
+//
+//	@Summary		Get template
+//	@Description	Gets a todo template of the current space with its items
+//	@Tags			templates
+//	@Produce		json
+//	@Param			id	path		int	true	"Template ID"
+//	@Success		200	{object}	types.TemplateResponse
+//	@Security		BearerAuth
+//	@Router			/templates/{id} [get]
+func (h *Handler) GetTemplate(c *fiber.Ctx) error {
+	template := &model.TodoTemplate{}
+	if _, err := h.findTemplate(c, template, c.Params("id")); err != nil {
+		return err
+	}
+
+	return c.JSON(&types.TemplateResponse{
+		Template: *template,
+	})
+}
+
+// CreateTemplate godoc
+//
+//	@Summary		Create template
+//	@Description	Captures a todo of the current space with its subitems as a template: titles, descriptions, priorities, tags, estimates and due dates relative to the due date of the todo, or its creation without one
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@Param			template	body		types.CreateTemplateDTO	true	"Template"
+//	@Success		201			{object}	types.TemplateResponse
+//	@Security		BearerAuth
+//	@Router			/templates [post]
+func (h *Handler) CreateTemplate(c *fiber.Ctx) error {
+	remoteData := &types.CreateTemplateDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	template, err := h.createTemplate(c, strconv.FormatUint(uint64(remoteData.TodoID), 10), remoteData.Name)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(&types.TemplateResponse{
+		Template: *template,
+	})
+}
+
+// DeleteTemplate godoc
+//
+//	@Summary		Delete template
+//	@Description	Deletes a todo template of the current space, todos created from it are kept
+//	@Tags			templates
+//	@Param			id	path	int	true	"Template ID"
+//	@Success		204
+//	@Security		BearerAuth
+//	@Router			/templates/{id} [delete]
+func (h *Handler) DeleteTemplate(c *fiber.Ctx) error {
+	template := &model.TodoTemplate{}
+	if _, err := h.findTemplate(c, template, c.Params("id")); err != nil {
+		return err
+	}
+
+	if err := h.templateService.DeleteTemplate(template); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// InstantiateTemplate godoc
+//
+//	@Summary		Instantiate template
+//	@Description	Creates the todos of a template in the current space, subitems are linked to their parents and due dates are relative to the base date
+//	@Tags			templates
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int								true	"Template ID"
+//	@Param			base	body		types.InstantiateTemplateDTO	false	"Base date"
+//	@Success		201		{object}	types.InstantiateTemplateResponse
+//	@Security		BearerAuth
+//	@Router			/templates/{id}/instantiate [post]
+func (h *Handler) InstantiateTemplate(c *fiber.Ctx) error {
+	remoteData := &types.InstantiateTemplateDTO{}
+	if len(c.Body()) > 0 {
+		if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+			return err
+		}
+	}
+
+	todos, err := h.instantiateTemplate(c, c.Params("id"), remoteData.BaseDate)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(&types.InstantiateTemplateResponse{
+		Todos: todos,
+	})
+}
+
+// VTemplatesInstantiate creates the todos of the template picked in the create form and adds them to the list
+func (h *Handler) VTemplatesInstantiate(c *fiber.Ctx) error {
+	todos, err := h.instantiateTemplate(c, c.FormValue("templateId"), c.FormValue("baseDate"))
+	if err != nil {
+		return err
+	}
+
+	items := make([]templ.Component, len(todos))
+	for i, todo := range todos {
+		items[i] = view.TodoItem(todo)
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(templ.Join(items...)))(c)
+}
+
+// VTodoSaveTemplate captures the todo as a template named by the prompt and reloads the page to show it in the
+// picker
+func (h *Handler) VTodoSaveTemplate(c *fiber.Ctx) error {
+	name := strings.TrimSpace(c.Get("HX-Prompt"))
+	if name == "" || len(name) > 128 {
+		return utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "name must have 1 to 128 characters")
+	}
+
+	if _, err := h.createTemplate(c, c.Params("id"), name); err != nil {
+		return err
+	}
+
+	c.Set("HX-Refresh", "true")
+
+	return c.Status(http.StatusOK).SendString("")
+}
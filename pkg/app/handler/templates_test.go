@@ -0,0 +1,138 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestTemplatesHandlerChecklist(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "template.owner@turbomeet.xyz", Password: pw}
+	other := &model.Account{Email: "template.other@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+	accountService.CreateAccount(other)
+
+	ownerAuth, _ := jwt.Generate(owner)
+	otherAuth, _ := jwt.Generate(other)
+
+	releaseAt := time.Date(2024, 3, 8, 17, 0, 0, 0, time.UTC)
+	release := createTodo(t, ownerAuth.Token, model.Todo{Title: zero.StringFrom("Release"), DueAt: null.TimeFrom(releaseAt), Tags: model.Tags{"release"}})
+	freeze := createTodo(t, ownerAuth.Token, model.Todo{Title: zero.StringFrom("Code freeze"), DueAt: null.TimeFrom(releaseAt.AddDate(0, 0, -2)), ParentID: &release.ID})
+	createTodo(t, ownerAuth.Token, model.Todo{Title: zero.StringFrom("Changelog"), Priority: model.TODO_PRIORITY_HIGH, ParentID: &freeze.ID})
+
+	var template model.TodoTemplate
+
+	t.Run("should only link subitems to todos of the space", func(t *testing.T) {
+		if freeze.ParentID == nil || *freeze.ParentID != release.ID {
+			t.Errorf("Expected code freeze to be a subitem of release, got %v", freeze.ParentID)
+		}
+
+		res := send("POST", "/api/todos", otherAuth.Token, &types.CreateTodoRequest{Todo: model.Todo{Title: zero.StringFrom("Foreign"), ParentID: &release.ID}})
+		if res.StatusCode != 400 {
+			t.Errorf("Expected parents of other spaces to fail with 400, got %d", res.StatusCode)
+		}
+
+		// A subitem of another space that got past the check must not be captured either
+		DB.Create(&model.Todo{AccountID: other.ID, Title: zero.StringFrom("Foreign"), ParentID: &release.ID})
+	})
+
+	t.Run("should capture the todo tree", func(t *testing.T) {
+		res := send("POST", "/api/templates", ownerAuth.Token, &types.CreateTemplateDTO{TodoID: release.ID, Name: "Weekly release"})
+		if res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+		response := &types.TemplateResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		template = response.Template
+
+		items := template.Items
+		if len(items) != 3 || items[0].Title != "Release" || items[1].Title != "Code freeze" || items[2].Title != "Changelog" {
+			t.Fatalf("Expected the release tree depth first, got %+v", items)
+		}
+		if items[0].ParentID != nil || *items[1].ParentID != items[0].ID || *items[2].ParentID != items[1].ID {
+			t.Errorf("Expected the items to keep their parents, got %+v", items)
+		}
+		if items[0].DueOffsetMinutes.Int64 != 0 || items[1].DueOffsetMinutes.Int64 != -2*24*60 || items[2].DueOffsetMinutes.Valid {
+			t.Errorf("Expected due dates relative to the release, got %+v", items)
+		}
+		if items[0].Tags[0] != "release" || items[2].Priority != model.TODO_PRIORITY_HIGH {
+			t.Errorf("Expected tags and priorities to be kept, got %+v", items)
+		}
+
+		if res := send("POST", "/api/templates", otherAuth.Token, &types.CreateTemplateDTO{TodoID: release.ID, Name: "Stolen"}); res.StatusCode != 404 {
+			t.Errorf("Expected todos of other spaces to get 404, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should instantiate the template with a base date", func(t *testing.T) {
+		path := "/api/templates/" + strconv.FormatUint(uint64(template.ID), 10)
+		res := send("POST", path+"/instantiate", ownerAuth.Token, &types.InstantiateTemplateDTO{BaseDate: "2024-04-05T12:00:00Z"})
+		if res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+		response := &types.InstantiateTemplateResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+
+		todos := response.Todos
+		if len(todos) != 3 || *todos[1].ParentID != todos[0].ID || *todos[2].ParentID != todos[1].ID {
+			t.Fatalf("Expected a new tree, got %+v", todos)
+		}
+		base := time.Date(2024, 4, 5, 12, 0, 0, 0, time.UTC)
+		if !todos[0].DueAt.Time.Equal(base) || !todos[1].DueAt.Time.Equal(base.AddDate(0, 0, -2)) || todos[2].DueAt.Valid {
+			t.Errorf("Expected due dates relative to the base date, got %v, %v, %v", todos[0].DueAt, todos[1].DueAt, todos[2].DueAt)
+		}
+
+		if res := send("POST", path+"/instantiate", ownerAuth.Token, &types.InstantiateTemplateDTO{BaseDate: "next week"}); res.StatusCode != 400 {
+			t.Errorf("Expected invalid base dates to fail with 400, got %d", res.StatusCode)
+		}
+		if res := send("POST", path+"/instantiate", otherAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected templates of other spaces to get 404, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should instantiate from the picker of the create form", func(t *testing.T) {
+		form := url.Values{"templateId": {strconv.FormatUint(uint64(template.ID), 10)}, "baseDate": {"2024-05-10"}}
+		req, _ := http.NewRequest("POST", "/templates/instantiate", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: ownerAuth.Token})
+		res, _ := App.Test(req, -1)
+		body, _ := io.ReadAll(res.Body)
+		if !strings.Contains(string(body), "Release") || !strings.Contains(string(body), "Changelog") {
+			t.Errorf("Expected the new todos, got %s", body)
+		}
+
+		var count int64
+		DB.Model(&model.Todo{}).Where("title = ? AND due_at IS NOT NULL", "Release").Count(&count)
+		if count != 3 {
+			t.Errorf("Expected 3 releases, got %d", count)
+		}
+	})
+
+	t.Run("should delete templates", func(t *testing.T) {
+		path := "/api/templates/" + strconv.FormatUint(uint64(template.ID), 10)
+		if res := send("DELETE", path, ownerAuth.Token, nil); res.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+		}
+		if res := send("GET", path, ownerAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected the template to be gone, got %d", res.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,457 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+	"gopkg.in/guregu/null.v4"
+)
+
+const TIME_REPORT_DATE_FORMAT = "2006-01-02"
+
+// markTimers sets TimerRunning of the todo the account tracks time on before the todos are returned
+func (h *Handler) markTimers(c *fiber.Ctx, todos []model.Todo) error {
+	if err := h.timeEntryService.MarkRunning(todos, locals.JwtPayload(c).AccountID); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return nil
+}
+
+// markTodoTimer sets TimerRunning of a single todo before it is returned
+func (h *Handler) markTodoTimer(c *fiber.Ctx, todo *model.Todo) error {
+	todos := []model.Todo{*todo}
+	if err := h.markTimers(c, todos); err != nil {
+		return err
+	}
+	todo.TimerRunning = todos[0].TimerRunning
+
+	return nil
+}
+
+// timeTotal sums up the entries of the todo and compares them with its estimate
+func timeTotal(todo *model.Todo, entries []model.TimeEntry, now time.Time) types.TimeTotal {
+	total := types.TimeTotal{TodoID: todo.ID, Title: todo.Title.String}
+	for _, entry := range entries {
+		total.TrackedSeconds += int64(entry.Duration(now) / time.Second)
+	}
+
+	if todo.EstimatedMinutes.Valid {
+		total.EstimatedSeconds = null.IntFrom(todo.EstimatedMinutes.Int64 * 60)
+		total.OverEstimate = total.TrackedSeconds > total.EstimatedSeconds.Int64
+	}
+
+	return total
+}
+
+// timeReportTotals sums up the entries per todo in the order their first entry started. The parents of the todos
+// are added before their subitems and the time of subitems is rolled up into all of their parents.
+func (h *Handler) timeReportTotals(space model.Space, entries []model.TimeEntry, now time.Time) ([]types.TimeReportTotal, error) {
+	todos := map[uint]*model.Todo{}
+	todoEntries := map[uint][]model.TimeEntry{}
+	order := []uint{}
+	for _, entry := range entries {
+		if entry.Todo == nil {
+			continue
+		}
+		if _, ok := todos[entry.TodoID]; !ok {
+			todos[entry.TodoID] = entry.Todo
+			order = append(order, entry.TodoID)
+		}
+		todoEntries[entry.TodoID] = append(todoEntries[entry.TodoID], entry)
+	}
+
+	// Parents are loaded one level at a time until every todo has its parents
+	requested := map[uint]bool{}
+	for {
+		missing := []uint{}
+		for _, todo := range todos {
+			if todo.ParentID != nil && todos[*todo.ParentID] == nil && !requested[*todo.ParentID] {
+				requested[*todo.ParentID] = true
+				missing = append(missing, *todo.ParentID)
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+
+		parents := []model.Todo{}
+		if err := h.timeEntryService.FindReportTodos(&parents, space, missing).Error; err != nil {
+			return nil, &utils.INTERNAL_SERVER_ERROR
+		}
+		for i := range parents {
+			todos[parents[i].ID] = &parents[i]
+		}
+	}
+
+	totals := []types.TimeReportTotal{}
+	positions := map[uint]int{}
+	var add func(id uint)
+	add = func(id uint) {
+		if _, ok := positions[id]; ok {
+			return
+		}
+		positions[id] = -1
+		todo := todos[id]
+		if todo.ParentID != nil && todos[*todo.ParentID] != nil {
+			add(*todo.ParentID)
+		}
+		positions[id] = len(totals)
+		totals = append(totals, types.TimeReportTotal{TimeTotal: timeTotal(todo, todoEntries[id], now), ParentID: todo.ParentID})
+	}
+	for _, id := range order {
+		add(id)
+	}
+
+	for _, id := range order {
+		seconds := totals[positions[id]].TrackedSeconds
+		visited := map[uint]bool{id: true}
+		for parentID := todos[id].ParentID; parentID != nil && todos[*parentID] != nil && !visited[*parentID]; parentID = todos[*parentID].ParentID {
+			visited[*parentID] = true
+			totals[positions[*parentID]].SubitemSeconds += seconds
+		}
+	}
+	for i, total := range totals {
+		if total.EstimatedSeconds.Valid {
+			totals[i].OverEstimate = total.TrackedSeconds+total.SubitemSeconds > total.EstimatedSeconds.Int64
+		}
+	}
+
+	return totals, nil
+}
+
+// timeReportRange parses the from and to dates of a report in the timezone of the account, to is inclusive. The
+// range defaults to the current month up to today.
+func timeReportRange(c *fiber.Ctx, location *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(location)
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, location)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	for _, param := range []struct {
+		name string
+		date *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if value := c.Query(param.name); value != "" {
+			date, err := time.ParseInLocation(TIME_REPORT_DATE_FORMAT, value, location)
+			if err != nil {
+				return from, to, utils.RequestErrorFrom(&utils.VALIDATION_ERROR, param.name+" must be a date like 2006-01-02")
+			}
+			*param.date = date
+		}
+	}
+
+	if to.Before(from) {
+		return from, to, utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "to must not be before from")
+	}
+
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+// sendTimesheet writes one line per entry dated in the timezone of the report, running timers count until now
+func sendTimesheet(c *fiber.Ctx, report *types.TimeReportResponse, now time.Time) error {
+	location := report.From.Location()
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=timesheet-%s-%s.csv",
+		report.From.Format(TIME_REPORT_DATE_FORMAT), report.To.AddDate(0, 0, -1).Format(TIME_REPORT_DATE_FORMAT)))
+
+	cw := csv.NewWriter(c.Response().BodyWriter())
+	cw.Write([]string{"date", "account", "todo_id", "todo", "started_at", "ended_at", "hours", "note"})
+	for _, entry := range report.Entries {
+		account, title, endedAt := "", "", ""
+		if entry.Account != nil {
+			account = entry.Account.Email
+		}
+		if entry.Todo != nil {
+			title = entry.Todo.Title.String
+		}
+		if entry.EndedAt.Valid {
+			endedAt = entry.EndedAt.Time.UTC().Format(time.RFC3339)
+		}
+
+		cw.Write([]string{
+			entry.StartedAt.In(location).Format(TIME_REPORT_DATE_FORMAT),
+			account,
+			strconv.FormatUint(uint64(entry.TodoID), 10),
+			title,
+			entry.StartedAt.UTC().Format(time.RFC3339),
+			endedAt,
+			strconv.FormatFloat(entry.Duration(now).Hours(), 'f', 2, 64),
+			entry.Note,
+		})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// GetTodoTime godoc
+//
+//	@Summary		Get tracked time
+//	@Description	Lists the time entries of the todo and compares their total with the estimate
+//	@Tags			time
+//	@Produce		json
+//	@Param			id	path		int	true	"Todo ID"
+//	@Success		200	{object}	types.GetTodoTimeResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/time [get]
+func (h *Handler) GetTodoTime(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_VIEWER); err != nil {
+		return err
+	}
+
+	entries := []model.TimeEntry{}
+	if err := h.timeEntryService.FindEntries(&entries, todo.ID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetTodoTimeResponse{
+		TimeTotal: timeTotal(todo, entries, time.Now()),
+		Entries:   entries,
+	})
+}
+
+// CreateTimeEntry godoc
+//
+//	@Summary		Add time entry
+//	@Description	Adds time spent on the todo without a timer, needs the editor role
+//	@Tags			time
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int							true	"Todo ID"
+//	@Param			entry	body		types.CreateTimeEntryDTO	true	"Time entry"
+//	@Success		201		{object}	types.TimeEntryResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/time [post]
+func (h *Handler) CreateTimeEntry(c *fiber.Ctx) error {
+	remoteData := &types.CreateTimeEntryDTO{}
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return err
+	}
+
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	entry := &model.TimeEntry{
+		TodoID:    todo.ID,
+		AccountID: locals.JwtPayload(c).AccountID,
+		StartedAt: remoteData.StartedAt,
+		EndedAt:   null.TimeFromPtr(remoteData.EndedAt),
+		Note:      remoteData.Note,
+	}
+	if remoteData.EndedAt == nil {
+		entry.EndedAt = null.TimeFrom(remoteData.StartedAt.Add(time.Duration(remoteData.Minutes) * time.Minute))
+	}
+
+	if err := h.timeEntryService.CreateEntry(entry); err != nil {
+		return statusError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&types.TimeEntryResponse{
+		Entry: *entry,
+	})
+}
+
+// DeleteTimeEntry godoc
+//
+//	@Summary		Delete time entry
+//	@Description	Deletes a time entry the account tracked on the todo
+//	@Tags			time
+//	@Param			id		path	int	true	"Todo ID"
+//	@Param			entryId	path	int	true	"Time entry ID"
+//	@Success		204
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/time/{entryId} [delete]
+func (h *Handler) DeleteTimeEntry(c *fiber.Ctx) error {
+	entryID, err := strconv.ParseUint(c.Params("entryId"), 10, 32)
+	if err != nil {
+		return &utils.BAD_REQUEST
+	}
+
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	if err := h.timeEntryService.DeleteEntry(todo.ID, uint(entryID), locals.JwtPayload(c).AccountID); err != nil {
+		return statusError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// StartTimer godoc
+//
+//	@Summary		Start timer
+//	@Description	Starts tracking time on the todo, a timer running on another todo is stopped. Needs the editor role.
+//	@Tags			time
+//	@Produce		json
+//	@Param			id	path		int	true	"Todo ID"
+//	@Success		200	{object}	types.TimerResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/timer [post]
+func (h *Handler) StartTimer(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	timer, stopped, err := h.timeEntryService.StartTimer(todo, locals.JwtPayload(c).AccountID)
+	if err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.TimerResponse{
+		Timer:   timer,
+		Stopped: stopped,
+	})
+}
+
+// StopTimer godoc
+//
+//	@Summary		Stop timer
+//	@Description	Stops the timer the account runs on the todo, also once the todo is in the trash or no longer accessible
+//	@Tags			time
+//	@Produce		json
+//	@Param			id	path		int	true	"Todo ID"
+//	@Success		200	{object}	types.TimerResponse
+//	@Security		BearerAuth
+//	@Router			/todos/{id}/timer [delete]
+func (h *Handler) StopTimer(c *fiber.Ctx) error {
+	todoID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return &utils.BAD_REQUEST
+	}
+
+	// The timer belongs to the account, so it can be stopped without access to the todo
+	stopped, err := h.timeEntryService.StopTimer(uint(todoID), locals.JwtPayload(c).AccountID)
+	if err != nil {
+		return statusError(err)
+	}
+
+	return c.JSON(&types.TimerResponse{
+		Stopped: stopped,
+	})
+}
+
+// GetTimer godoc
+//
+//	@Summary		Get running timer
+//	@Description	Returns the timer the account runs, timer is null if there is none
+//	@Tags			time
+//	@Produce		json
+//	@Success		200	{object}	types.TimerResponse
+//	@Security		BearerAuth
+//	@Router			/time/timer [get]
+func (h *Handler) GetTimer(c *fiber.Ctx) error {
+	timer, err := h.timeEntryService.FindRunning(locals.JwtPayload(c).AccountID)
+	if err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.TimerResponse{
+		Timer: timer,
+	})
+}
+
+// GetTimeReport godoc
+//
+//	@Summary		Get timesheet
+//	@Description	Returns the time tracked on todos of the current space between two dates with totals per todo that roll up the time of subitems into their parents, as JSON or with format=csv as a timesheet with one line per entry. Projects are organizations, the report of an organization space covers its project.
+//	@Tags			time
+//	@Produce		json
+//	@Produce		text/csv
+//	@Param			from	query		string	false	"First day, e.g. 2024-01-01. Defaults to the start of the month."
+//	@Param			to		query		string	false	"Last day, inclusive. Defaults to today."
+//	@Param			format	query		string	false	"json (default) or csv"
+//	@Success		200		{object}	types.TimeReportResponse
+//	@Security		BearerAuth
+//	@Router			/time/report [get]
+func (h *Handler) GetTimeReport(c *fiber.Ctx) error {
+	format := c.Query("format", "json")
+	if format != "json" && format != "csv" {
+		return utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "format must be json or csv")
+	}
+
+	account := &model.Account{}
+	if err := h.accountService.FindAccountByID(account, locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	from, to, err := timeReportRange(c, account.Location())
+	if err != nil {
+		return err
+	}
+
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+
+	report := &types.TimeReportResponse{From: from, To: to, Entries: []model.TimeEntry{}}
+	if err := h.timeEntryService.FindTimesheet(&report.Entries, space, from, to).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	now := time.Now()
+	if format == "csv" {
+		return sendTimesheet(c, report, now)
+	}
+
+	if report.Todos, err = h.timeReportTotals(space, report.Entries, now); err != nil {
+		return err
+	}
+	for _, total := range report.Todos {
+		report.TrackedSeconds += total.TrackedSeconds
+	}
+
+	return c.JSON(report)
+}
+
+// VTodoTimerToggle starts or stops the timer of the account on the todo. A timer that was stopped on another todo
+// is swapped out of band so the list shows a single running timer.
+func (h *Handler) VTodoTimerToggle(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, c.Params("id"), model.SHARE_ROLE_EDITOR); err != nil {
+		return err
+	}
+
+	accountID := locals.JwtPayload(c).AccountID
+	running, err := h.timeEntryService.FindRunning(accountID)
+	if err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if running != nil && running.TodoID == todo.ID {
+		if _, err := h.timeEntryService.StopTimer(todo.ID, accountID); err != nil {
+			return statusError(err)
+		}
+
+		return adaptor.HTTPHandler(templ.Handler(view.TodoTimerToggle(todo.ID, false, false)))(c)
+	}
+
+	_, stopped, err := h.timeEntryService.StartTimer(todo, accountID)
+	if err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	toggles := []templ.Component{view.TodoTimerToggle(todo.ID, true, false)}
+	if stopped != nil {
+		toggles = append(toggles, view.TodoTimerToggle(stopped.TodoID, false, true))
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(templ.Join(toggles...)))(c)
+}
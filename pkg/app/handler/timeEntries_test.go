@@ -0,0 +1,233 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestTimeEntriesHandlerTracking(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "time.owner@turbomeet.xyz", Password: pw}
+	other := &model.Account{Email: "time.other@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+	accountService.CreateAccount(other)
+
+	ownerAuth, _ := jwt.Generate(owner)
+
+	todoService := service.NewTodoService(DB)
+	space := model.Space{AccountID: owner.ID}
+	design := &model.Todo{Title: zero.StringFrom("Design"), EstimatedMinutes: null.IntFrom(60)}
+	build := &model.Todo{Title: zero.StringFrom("Build, test")}
+	foreign := &model.Todo{Title: zero.StringFrom("Foreign")}
+	todoService.CreateTodo(design, space)
+	todoService.CreateTodo(build, space)
+	todoService.CreateTodo(foreign, model.Space{AccountID: other.ID})
+
+	todoPath := func(todo *model.Todo) string {
+		return "/api/todos/" + strconv.FormatUint(uint64(todo.ID), 10)
+	}
+
+	t.Run("should run one timer per account", func(t *testing.T) {
+		res := send("POST", todoPath(design)+"/timer", ownerAuth.Token, nil)
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		res = send("POST", todoPath(build)+"/timer", ownerAuth.Token, nil)
+		response := &types.TimerResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if response.Timer == nil || response.Timer.TodoID != build.ID {
+			t.Fatalf("Expected a timer on build, got %+v", response.Timer)
+		}
+		if response.Stopped == nil || response.Stopped.TodoID != design.ID || !response.Stopped.EndedAt.Valid {
+			t.Errorf("Expected the timer on design to be stopped, got %+v", response.Stopped)
+		}
+
+		var running int64
+		DB.Model(&model.TimeEntry{}).Where("account_id = ? AND ended_at IS NULL", owner.ID).Count(&running)
+		if running != 1 {
+			t.Errorf("Expected 1 running timer, got %d", running)
+		}
+		second := &model.TimeEntry{TodoID: design.ID, AccountID: owner.ID}
+		second.Start(time.Now())
+		if err := DB.Create(second).Error; err == nil {
+			t.Errorf("Expected a second running timer of the account to conflict")
+		}
+
+		res = send("GET", todoPath(build), ownerAuth.Token, nil)
+		todo := &types.GetTodoResponse{}
+		json.NewDecoder(res.Body).Decode(todo)
+		if !todo.Todo.TimerRunning {
+			t.Errorf("Expected build to show the running timer")
+		}
+
+		if res := send("DELETE", todoPath(design)+"/timer", ownerAuth.Token, nil); res.StatusCode != 409 {
+			t.Errorf("Expected stopping a stopped timer to fail with 409, got %d", res.StatusCode)
+		}
+		if res := send("DELETE", todoPath(build)+"/timer", ownerAuth.Token, nil); res.StatusCode != 200 {
+			t.Errorf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if res := send("POST", todoPath(foreign)+"/timer", ownerAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected todos of other accounts to get 404, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should add manual entries and compare them with the estimate", func(t *testing.T) {
+		startedAt := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+		res := send("POST", todoPath(design)+"/time", ownerAuth.Token, &types.CreateTimeEntryDTO{StartedAt: startedAt, Minutes: 90, Note: "Workshop"})
+		if res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+
+		endedAt := startedAt.Add(-time.Hour)
+		if res := send("POST", todoPath(design)+"/time", ownerAuth.Token, &types.CreateTimeEntryDTO{StartedAt: startedAt, EndedAt: &endedAt}); res.StatusCode != 400 {
+			t.Errorf("Expected entries ending before they start to fail with 400, got %d", res.StatusCode)
+		}
+
+		res = send("GET", todoPath(design)+"/time", ownerAuth.Token, nil)
+		response := &types.GetTodoTimeResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		if len(response.Entries) != 2 || response.TrackedSeconds < 90*60 {
+			t.Errorf("Expected the timer and the manual entry, got %d entries with %ds", len(response.Entries), response.TrackedSeconds)
+		}
+		if response.EstimatedSeconds.Int64 != 3600 || !response.OverEstimate {
+			t.Errorf("Expected the 1h estimate to be exceeded, got %+v", response.TimeTotal)
+		}
+	})
+
+	t.Run("should report the time of the space", func(t *testing.T) {
+		res := send("GET", "/api/time/report?from=2024-03-01&to=2024-03-04", ownerAuth.Token, nil)
+		report := &types.TimeReportResponse{}
+		json.NewDecoder(res.Body).Decode(report)
+		if len(report.Todos) != 1 || report.Todos[0].TodoID != design.ID || report.TrackedSeconds != 90*60 {
+			t.Errorf("Expected 90 minutes on design, got %+v", report)
+		}
+
+		res = send("GET", "/api/time/report?from=2024-03-01&to=2024-03-03", ownerAuth.Token, nil)
+		report = &types.TimeReportResponse{}
+		json.NewDecoder(res.Body).Decode(report)
+		if len(report.Entries) != 0 {
+			t.Errorf("Expected entries after the range to be left out, got %d", len(report.Entries))
+		}
+
+		res = send("GET", "/api/time/report?format=csv", ownerAuth.Token, nil)
+		if contentType := res.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/csv") {
+			t.Errorf("Expected a CSV timesheet, got %s", contentType)
+		}
+		body, _ := io.ReadAll(res.Body)
+		records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 3 || records[0][6] != "hours" || records[2][1] != owner.Email || records[2][3] != "Build, test" {
+			t.Errorf("Expected the header and this month's timers, got %v", records)
+		}
+
+		if res := send("GET", "/api/time/report?from=2024-03-04&to=2024-03-01", ownerAuth.Token, nil); res.StatusCode != 400 {
+			t.Errorf("Expected an inverted range to fail with 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should roll up the time of subitems into their parents", func(t *testing.T) {
+		wireframes := &model.Todo{Title: zero.StringFrom("Wireframes"), ParentID: &design.ID}
+		todoService.CreateTodo(wireframes, space)
+		sketch := &model.Todo{Title: zero.StringFrom("Sketch"), ParentID: &wireframes.ID}
+		todoService.CreateTodo(sketch, space)
+
+		startedAt := time.Date(2024, 2, 5, 9, 0, 0, 0, time.UTC)
+		if res := send("POST", todoPath(sketch)+"/time", ownerAuth.Token, &types.CreateTimeEntryDTO{StartedAt: startedAt, Minutes: 30}); res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+
+		res := send("GET", "/api/time/report?from=2024-02-01&to=2024-02-29", ownerAuth.Token, nil)
+		report := &types.TimeReportResponse{}
+		json.NewDecoder(res.Body).Decode(report)
+		if len(report.Todos) != 3 || report.Todos[0].TodoID != design.ID || report.Todos[1].TodoID != wireframes.ID || report.Todos[2].TodoID != sketch.ID {
+			t.Fatalf("Expected the parents before the sketch, got %+v", report.Todos)
+		}
+		if report.Todos[0].TrackedSeconds != 0 || report.Todos[0].SubitemSeconds != 30*60 || report.Todos[1].SubitemSeconds != 30*60 {
+			t.Errorf("Expected 30 minutes rolled up into the parents, got %+v", report.Todos)
+		}
+		if report.TrackedSeconds != 30*60 {
+			t.Errorf("Expected the subitem time to be counted once, got %ds", report.TrackedSeconds)
+		}
+	})
+
+	t.Run("should toggle the timer from the list", func(t *testing.T) {
+		toggle := func(todo *model.Todo) string {
+			req, _ := http.NewRequest("POST", "/todos/"+strconv.FormatUint(uint64(todo.ID), 10)+"/timer", nil)
+			req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: ownerAuth.Token})
+			res, _ := App.Test(req, -1)
+			body, _ := io.ReadAll(res.Body)
+			return string(body)
+		}
+
+		if body := toggle(design); !strings.Contains(body, "Stop timer") {
+			t.Errorf("Expected a running timer, got %s", body)
+		}
+		body := toggle(build)
+		if !strings.Contains(body, "hx-swap-oob") || !strings.Contains(body, "timer-"+strconv.FormatUint(uint64(design.ID), 10)) {
+			t.Errorf("Expected the stopped timer to be swapped out of band, got %s", body)
+		}
+		if body := toggle(build); !strings.Contains(body, "Start timer") {
+			t.Errorf("Expected the timer to stop, got %s", body)
+		}
+	})
+
+	t.Run("should date the report in the timezone of the account", func(t *testing.T) {
+		if res := send("PUT", "/api/auth/timezone", ownerAuth.Token, &types.TimezoneDTO{Timezone: "America/New_York"}); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		// The evening of March 4th in New York is March 5th in UTC
+		startedAt := time.Date(2024, 3, 5, 2, 0, 0, 0, time.UTC)
+		if res := send("POST", todoPath(build)+"/time", ownerAuth.Token, &types.CreateTimeEntryDTO{StartedAt: startedAt, Minutes: 30}); res.StatusCode != 201 {
+			t.Fatalf("Expected status code 201, got %d", res.StatusCode)
+		}
+
+		res := send("GET", "/api/time/report?from=2024-03-04&to=2024-03-04&format=csv", ownerAuth.Token, nil)
+		body, _ := io.ReadAll(res.Body)
+		records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 3 || records[2][0] != "2024-03-04" || records[2][3] != "Build, test" {
+			t.Errorf("Expected the evening entry on March 4th, got %v", records)
+		}
+	})
+
+	t.Run("should stop a timer on a todo in the trash", func(t *testing.T) {
+		if res := send("POST", todoPath(design)+"/timer", ownerAuth.Token, nil); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if res := send("DELETE", todoPath(design), ownerAuth.Token, nil); res.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+		}
+
+		if res := send("DELETE", todoPath(design)+"/timer", ownerAuth.Token, nil); res.StatusCode != 200 {
+			t.Errorf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if res := send("DELETE", todoPath(foreign)+"/timer", ownerAuth.Token, nil); res.StatusCode != 409 {
+			t.Errorf("Expected stopping a timer the account doesn't run to fail with 409, got %d", res.StatusCode)
+		}
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"strconv"
 	"time"
 
 	"github.com/nleiva/go-todo-api/pkg/app/model"
@@ -20,19 +21,51 @@ import (
 
 // GetTodos   godoc
 //
-//	@Summary	List todos
-//	@Tags		todos
-//	@Accept		json
-//	@Param		meta	query	pagination.QueryParams	false	"Pagination Query Parameters"
-//	@Produce	json
-//	@Success	200	{object}	types.GetTodosResponse
-//	@Router		/todos [get]
+//	@Summary		List todos
+//	@Description	Lists the todos of the current space. Priorities filter and sort by level, order=smart sorts open todos by a score of priority, due date and age.
+//	@Tags			todos
+//	@Accept			json
+//	@Param			meta		query	pagination.QueryParams	false	"Pagination Query Parameters"
+//	@Param			assignee	query	string					false	"Assignee: me, none or an account id"
+//	@Param			archived	query	string					false	"Archived todos: false (default), true or all"
+//	@Param			tag			query	string					false	"Only todos with the tag"
+//	@Produce		json
+//	@Success		200	{object}	types.GetTodosResponse
+//	@Router			/todos [get]
 func (h *Handler) GetTodos(c *fiber.Ctx) error {
 	var meta = locals.Meta(c)
 
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+
+	where, err := withAssigneeFilter(c, h.todoService.InSpace(space))
+	if err != nil {
+		return err
+	}
+
+	where, err = withArchivedFilter(c, where)
+	if err != nil {
+		return err
+	}
+
+	where, err = withTagFilter(c, where)
+	if err != nil {
+		return err
+	}
+
 	var todos = &[]model.Todo{}
-	if err := h.FindWithMeta(todos, &model.Todo{}, meta, h.db.Where("account_id = ?", locals.JwtPayload(c).AccountID)).Error; err != nil {
-		return &utils.INTERNAL_SERVER_ERROR
+	if err := h.FindWithMeta(todos, &model.Todo{}, meta, where).Error; err != nil {
+		return statusError(err)
+	}
+
+	if err := h.markBlocked(*todos); err != nil {
+		return err
+	}
+
+	if err := h.markTimers(c, *todos); err != nil {
+		return err
 	}
 
 	return c.JSON(&types.GetTodosResponse{
@@ -44,7 +77,7 @@ func (h *Handler) GetTodos(c *fiber.Ctx) error {
 // GetTodo    godoc
 //
 //	@Summary		Get todo
-//	@Description	get string by ID
+//	@Description	Gets a todo of the current space or one shared with the account
 //	@Tags			todos
 //	@Accept			json
 //	@Produce		json
@@ -59,11 +92,16 @@ func (h *Handler) GetTodo(c *fiber.Ctx) error {
 		return &utils.BAD_REQUEST
 	}
 
-	if err := h.todoService.FindTodoByID(todo, remoteId, locals.JwtPayload(c).AccountID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return &utils.NOT_FOUND
-		}
-		return &utils.INTERNAL_SERVER_ERROR
+	if err := h.findTodoWithRole(c, todo, remoteId, model.SHARE_ROLE_VIEWER); err != nil {
+		return err
+	}
+
+	if err := h.markTodoBlocked(todo); err != nil {
+		return err
+	}
+
+	if err := h.markTodoTimer(c, todo); err != nil {
+		return err
 	}
 
 	return c.JSON(&types.GetTodoResponse{
@@ -72,13 +110,18 @@ func (h *Handler) GetTodo(c *fiber.Ctx) error {
 }
 
 func (h *Handler) CreateRandomTodo(c *fiber.Ctx) error {
-	return h.todoService.CreateRandomTodo(locals.JwtPayload(c).AccountID).Error
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+
+	return h.todoService.CreateRandomTodo(space)
 }
 
 // CreateTodo    godoc
 //
 //	@Summary		Create todo
-//	@Description	Create todo
+//	@Description	Creates a todo in the current space, fkParentId makes it a subitem of another todo of the space
 //	@Tags			todos
 //	@Accept			json
 //	@Produce		json
@@ -92,11 +135,26 @@ func (h *Handler) CreateTodo(c *fiber.Ctx) error {
 		return err
 	}
 
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+
 	var todo = &model.Todo{}
 	todo.New(remoteData.Todo)
-	todo.AccountID = locals.JwtPayload(c).AccountID
 
-	if err := h.todoService.CreateTodo(todo).Error; err != nil {
+	if remoteData.Todo.ParentID != nil {
+		parent := &model.Todo{}
+		if err := h.todoService.FindTodoByID(parent, strconv.FormatUint(uint64(*remoteData.Todo.ParentID), 10), space).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &utils.TODO_PARENT_INVALID
+			}
+			return &utils.INTERNAL_SERVER_ERROR
+		}
+		todo.ParentID = &parent.ID
+	}
+
+	if err := h.todoService.CreateTodo(todo, space); err != nil {
 		return utils.RequestErrorFrom(&utils.INTERNAL_SERVER_ERROR, err.Error())
 	}
 
@@ -108,7 +166,7 @@ func (h *Handler) CreateTodo(c *fiber.Ctx) error {
 // UpdateTodo    godoc
 //
 //	@Summary		Update todo
-//	@Description	Update todo
+//	@Description	Updates a todo of the current space or one shared with the account as editor
 //	@Tags			todos
 //	@Accept			json
 //	@Produce		json
@@ -129,19 +187,24 @@ func (h *Handler) UpdateTodo(c *fiber.Ctx) error {
 		return err
 	}
 
-	if err := h.todoService.FindTodoByID(todo, remoteId, locals.JwtPayload(c).AccountID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return &utils.NOT_FOUND
-		}
-		return &utils.INTERNAL_SERVER_ERROR
+	if err := h.findTodoWithRole(c, todo, remoteId, model.SHARE_ROLE_EDITOR); err != nil {
+		return err
 	}
 
 	todo.New(remoteData.Todo)
 
-	if err := h.todoService.UpdateTodo(todo).Error; err != nil {
+	if err := h.todoService.UpdateTodo(todo, locals.JwtPayload(c).AccountID); err != nil {
+		var requestError *utils.RequestError
+		if errors.As(err, &requestError) {
+			return requestError
+		}
 		return utils.RequestErrorFrom(&utils.INTERNAL_SERVER_ERROR, err.Error())
 	}
 
+	if err := h.markTodoBlocked(todo); err != nil {
+		return err
+	}
+
 	return c.JSON(&types.UpdateTodoResponse{
 		Todo: *todo,
 	})
@@ -150,7 +213,7 @@ func (h *Handler) UpdateTodo(c *fiber.Ctx) error {
 // DeleteTodo    godoc
 //
 //	@Summary		Delete todo
-//	@Description	Delete todo
+//	@Description	Moves a todo of the current space or one shared with the account as owner to the trash
 //	@Tags			todos
 //	@Accept			json
 //	@Produce		json
@@ -165,24 +228,35 @@ func (h *Handler) DeleteTodo(c *fiber.Ctx) error {
 		return &utils.BAD_REQUEST
 	}
 
-	if err := h.todoService.FindTodoByID(todo, remoteId, locals.JwtPayload(c).AccountID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return &utils.NOT_FOUND
-		}
-		return &utils.INTERNAL_SERVER_ERROR
+	if err := h.findTodoWithRole(c, todo, remoteId, model.SHARE_ROLE_OWNER); err != nil {
+		return err
 	}
 
-	if err := h.todoService.DeleteTodoByID(remoteId).Error; err != nil {
-		return &utils.INTERNAL_SERVER_ERROR
+	if err := h.deleteTodo(c, todo); err != nil {
+		return err
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
+// deleteTodo moves the todo to the trash, its attachments are kept until it is purged
+func (h *Handler) deleteTodo(c *fiber.Ctx, todo *model.Todo) error {
+	if err := h.todoService.DeleteTodo(todo, locals.JwtPayload(c).AccountID); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return nil
+}
+
 func (h *Handler) ExportCSVTodos(c *fiber.Ctx) error {
 	var todos = []model.Todo{}
 
-	if err := h.todoService.FindTodos(&todos, locals.JwtPayload(c).AccountID).Error; err != nil {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.todoService.FindTodos(&todos, space).Error; err != nil {
 		return &utils.INTERNAL_SERVER_ERROR
 	}
 
@@ -227,6 +301,11 @@ func (h *Handler) ExportCSVTodos(c *fiber.Ctx) error {
 }
 
 func (h *Handler) ImportCSVTodos(c *fiber.Ctx) error {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+
 	file, err := c.FormFile("file")
 	if err != nil {
 		return err
@@ -238,10 +317,20 @@ func (h *Handler) ImportCSVTodos(c *fiber.Ctx) error {
 	}
 	defer src.Close()
 
+	// Columns are mapped by the header, files exported before columns were added still import
 	cr := csv.NewReader(src)
-	cr.FieldsPerRecord = len(model.TodoColumns)
+	cr.FieldsPerRecord = -1
 
-	cr.Read() // Skip header
+	header, err := cr.Read()
+	if err == io.EOF {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+	if err == nil {
+		header, err = model.ParseTodoHeader(header)
+	}
+	if err != nil {
+		return utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "the first row must name the columns: "+err.Error())
+	}
 
 	errors := []error{}
 
@@ -257,14 +346,12 @@ func (h *Handler) ImportCSVTodos(c *fiber.Ctx) error {
 		}
 
 		todo := &model.Todo{}
-		if err := todo.UnmarshalRecord(record); err != nil {
+		if err := todo.UnmarshalNamedRecord(header, record); err != nil {
 			errors = append(errors, err)
 			continue
 		}
 
-		todo.AccountID = locals.JwtPayload(c).AccountID
-
-		if err := h.todoService.CreateTodo(todo).Error; err != nil {
+		if err := h.todoService.CreateTodo(todo, space); err != nil {
 			errors = append(errors, err)
 			continue
 		}
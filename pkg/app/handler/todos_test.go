@@ -26,32 +26,29 @@ func TestTodosHandlerExportCSV(t *testing.T) {
 	auth, _ := jwt.Generate(account)
 	authToken := auth.Token
 
+	space := model.Space{AccountID: account.ID}
 	todoService := service.NewTodoService(DB)
 	todoService.CreateTodo(&model.Todo{
 		Title:     zero.NewString("Todo 1", true),
 		Completed: false,
-		AccountID: account.ID,
-	})
+	}, space)
 
 	todoService.CreateTodo(&model.Todo{
 		Title:       zero.NewString("Todo 2", true),
 		Completed:   false,
-		AccountID:   account.ID,
 		Description: zero.NewString("some description", true),
-	})
+	}, space)
 
 	todoService.CreateTodo(&model.Todo{
 		Title:       zero.NewString("Todo 3", true),
 		Completed:   true,
-		AccountID:   account.ID,
 		Description: zero.NewString("some longer description with a \n line-break", true),
-	})
+	}, space)
 
 	todoService.CreateTodo(&model.Todo{
 		Title:     zero.NewString("Todo 4", true),
 		Completed: true,
-		AccountID: account.ID,
-	})
+	}, space)
 
 	t.Run("should be unauthorized", func(t *testing.T) {
 		req, _ := http.NewRequest("GET", "/api/todos/csv", nil)
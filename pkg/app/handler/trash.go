@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/view"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+// findTrashedTodo finds a deleted todo of the current space
+func (h *Handler) findTrashedTodo(c *fiber.Ctx, todo *model.Todo) error {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.todoService.FindTrashedTodo(todo, c.Params("id"), space).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &utils.NOT_FOUND
+		}
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return nil
+}
+
+// findTrash lists the deleted todos of the current space
+func (h *Handler) findTrash(c *fiber.Ctx) ([]model.Todo, error) {
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return nil, err
+	}
+
+	todos := []model.Todo{}
+	if err := h.todoService.FindTrash(&todos, space).Error; err != nil {
+		return nil, &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return todos, nil
+}
+
+// purgeTodos permanently deletes the todos together with their attachments
+func (h *Handler) purgeTodos(c *fiber.Ctx, todos ...model.Todo) error {
+	todoIDs := make([]uint, len(todos))
+	for i, todo := range todos {
+		todoIDs[i] = todo.ID
+	}
+
+	if err := h.attachmentService.DeleteTodoAttachments(c.Context(), todoIDs...); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	if err := h.todoService.PurgeTodos(todoIDs...); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return nil
+}
+
+// GetTrash godoc
+//
+//	@Summary		List trash
+//	@Description	Lists the deleted todos of the current space, most recently deleted first. Todos are purged TRASH_RETENTION after they were deleted.
+//	@Tags			trash
+//	@Produce		json
+//	@Success		200	{object}	types.GetTrashResponse
+//	@Security		BearerAuth
+//	@Router			/todos/trash [get]
+func (h *Handler) GetTrash(c *fiber.Ctx) error {
+	todos, err := h.findTrash(c)
+	if err != nil {
+		return err
+	}
+
+	trashed := make([]types.TrashedTodo, len(todos))
+	for i, todo := range todos {
+		trashed[i] = types.TrashedTodo{Todo: todo, PurgeAt: todo.PurgeAt(config.TRASH_RETENTION)}
+	}
+
+	return c.JSON(&types.GetTrashResponse{
+		Todos: trashed,
+	})
+}
+
+// RestoreTodo godoc
+//
+//	@Summary		Restore todo
+//	@Description	Takes a deleted todo of the current space out of the trash, its shares are restored as well
+//	@Tags			trash
+//	@Produce		json
+//	@Param			id	path		int	true	"Todo ID"
+//	@Success		200	{object}	types.GetTodoResponse
+//	@Security		BearerAuth
+//	@Router			/todos/trash/{id}/restore [post]
+func (h *Handler) RestoreTodo(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTrashedTodo(c, todo); err != nil {
+		return err
+	}
+
+	if err := h.todoService.RestoreTodo(todo, locals.JwtPayload(c).AccountID); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetTodoResponse{
+		Todo: *todo,
+	})
+}
+
+// PurgeTodo godoc
+//
+//	@Summary		Delete todo permanently
+//	@Description	Deletes a todo in the trash of the current space together with its history, comments and attachments
+//	@Tags			trash
+//	@Param			id	path		int	true	"Todo ID"
+//	@Success		204	{object}	nil	"No Content"
+//	@Security		BearerAuth
+//	@Router			/todos/trash/{id} [delete]
+func (h *Handler) PurgeTodo(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTrashedTodo(c, todo); err != nil {
+		return err
+	}
+
+	if err := h.purgeTodos(c, *todo); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// EmptyTrash godoc
+//
+//	@Summary		Empty trash
+//	@Description	Permanently deletes all todos in the trash of the current space
+//	@Tags			trash
+//	@Success		204	{object}	nil	"No Content"
+//	@Security		BearerAuth
+//	@Router			/todos/trash [delete]
+func (h *Handler) EmptyTrash(c *fiber.Ctx) error {
+	todos, err := h.findTrash(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.purgeTodos(c, todos...); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// VTrash lists the deleted todos of the current space
+func (h *Handler) VTrash(c *fiber.Ctx) error {
+	todos, err := h.findTrash(c)
+	if err != nil {
+		return err
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.TrashPage(h.GetBaseData(c), todos, config.TRASH_RETENTION)))(c)
+}
+
+func (h *Handler) VTrashRestore(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTrashedTodo(c, todo); err != nil {
+		return err
+	}
+
+	if err := h.todoService.RestoreTodo(todo, locals.JwtPayload(c).AccountID); err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.Status(http.StatusOK).SendString("")
+}
+
+func (h *Handler) VTrashPurge(c *fiber.Ctx) error {
+	todo := &model.Todo{}
+	if err := h.findTrashedTodo(c, todo); err != nil {
+		return err
+	}
+
+	if err := h.purgeTodos(c, *todo); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).SendString("")
+}
+
+// VTrashEmpty empties the trash and reloads the page
+func (h *Handler) VTrashEmpty(c *fiber.Ctx) error {
+	todos, err := h.findTrash(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.purgeTodos(c, todos...); err != nil {
+		return err
+	}
+
+	c.Set("HX-Refresh", "true")
+
+	return c.Status(http.StatusOK).SendString("")
+}
@@ -0,0 +1,164 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+	"gopkg.in/guregu/null.v4/zero"
+)
+
+func TestTrashHandlerLifecycle(t *testing.T) {
+	// Setup
+	defer test.ClearAllTables(DB)
+
+	pw, _ := model.HashPassword("123456")
+	owner := &model.Account{Email: "trash.owner@turbomeet.xyz", Password: pw}
+	viewer := &model.Account{Email: "trash.viewer@turbomeet.xyz", Password: pw}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(owner)
+	accountService.CreateAccount(viewer)
+
+	ownerAuth, _ := jwt.Generate(owner)
+	viewerAuth, _ := jwt.Generate(viewer)
+
+	todoService := service.NewTodoService(DB)
+	space := model.Space{AccountID: owner.ID}
+	first := &model.Todo{Title: zero.StringFrom("Book the flights")}
+	second := &model.Todo{Title: zero.StringFrom("Renew the passport")}
+	third := &model.Todo{Title: zero.StringFrom("Pack the bags")}
+	todoService.CreateTodo(first, space)
+	todoService.CreateTodo(second, space)
+	todoService.CreateTodo(third, space)
+	service.NewShareService(DB).ShareTodo(&model.TodoShare{TodoID: first.ID, AccountID: viewer.ID, Role: model.SHARE_ROLE_VIEWER, SharedByID: owner.ID})
+
+	todoPath := func(todo *model.Todo) string {
+		return "/api/todos/" + strconv.FormatUint(uint64(todo.ID), 10)
+	}
+	trashPath := func(todo *model.Todo) string {
+		return "/api/todos/trash/" + strconv.FormatUint(uint64(todo.ID), 10)
+	}
+
+	trash := func(token string) []types.TrashedTodo {
+		res := send("GET", "/api/todos/trash", token, nil)
+		response := &types.GetTrashResponse{}
+		json.NewDecoder(res.Body).Decode(response)
+		return response.Todos
+	}
+
+	t.Run("should move deleted todos to the trash", func(t *testing.T) {
+		if res := send("DELETE", todoPath(first), ownerAuth.Token, nil); res.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+		}
+
+		todos := trash(ownerAuth.Token)
+		if len(todos) != 1 || todos[0].ID != first.ID {
+			t.Fatalf("Expected the deleted todo in the trash, got %+v", todos)
+		}
+		if purgeAt := todos[0].DeletedAt.Time.Add(config.TRASH_RETENTION); !todos[0].PurgeAt.Equal(purgeAt) {
+			t.Errorf("Expected the todo to be purged at %s, got %s", purgeAt, todos[0].PurgeAt)
+		}
+
+		if res := send("GET", todoPath(first), viewerAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected the todo to be gone for the viewer, got %d", res.StatusCode)
+		}
+		if todos := trash(viewerAuth.Token); len(todos) != 0 {
+			t.Errorf("Expected the trash of other accounts to be empty, got %d", len(todos))
+		}
+	})
+
+	t.Run("should restore todos with their shares", func(t *testing.T) {
+		if res := send("POST", trashPath(first)+"/restore", viewerAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected other accounts to get 404, got %d", res.StatusCode)
+		}
+
+		if res := send("POST", trashPath(first)+"/restore", ownerAuth.Token, nil); res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		if todos := trash(ownerAuth.Token); len(todos) != 0 {
+			t.Errorf("Expected the trash to be empty, got %d", len(todos))
+		}
+		if res := send("GET", todoPath(first), viewerAuth.Token, nil); res.StatusCode != 200 {
+			t.Errorf("Expected the viewer to see the todo again, got %d", res.StatusCode)
+		}
+
+		history := []model.TodoHistory{}
+		todoService.FindHistory(&history, first.ID)
+		if last := history[len(history)-1]; last.Action != model.TODO_HISTORY_RESTORED {
+			t.Errorf("Expected the restore in the history, got %s", last.Action)
+		}
+	})
+
+	t.Run("should delete todos permanently", func(t *testing.T) {
+		if res := send("DELETE", trashPath(first), ownerAuth.Token, nil); res.StatusCode != 404 {
+			t.Errorf("Expected todos outside of the trash to get 404, got %d", res.StatusCode)
+		}
+
+		send("DELETE", todoPath(first), ownerAuth.Token, nil)
+		if res := send("DELETE", trashPath(first), ownerAuth.Token, nil); res.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+		}
+
+		var todos, history, shares int64
+		DB.Unscoped().Model(&model.Todo{}).Where("id = ?", first.ID).Count(&todos)
+		DB.Model(&model.TodoHistory{}).Where("todo_id = ?", first.ID).Count(&history)
+		DB.Unscoped().Model(&model.TodoShare{}).Where("todo_id = ?", first.ID).Count(&shares)
+		if todos != 0 || history != 0 || shares != 0 {
+			t.Errorf("Expected the todo with its history and shares to be gone, got %d, %d and %d", todos, history, shares)
+		}
+	})
+
+	t.Run("should empty the trash", func(t *testing.T) {
+		send("DELETE", todoPath(second), ownerAuth.Token, nil)
+		send("DELETE", todoPath(third), ownerAuth.Token, nil)
+
+		if res := send("DELETE", "/api/todos/trash", ownerAuth.Token, nil); res.StatusCode != 204 {
+			t.Fatalf("Expected status code 204, got %d", res.StatusCode)
+		}
+		if todos := trash(ownerAuth.Token); len(todos) != 0 {
+			t.Errorf("Expected the trash to be empty, got %d", len(todos))
+		}
+	})
+
+	t.Run("should find todos past the retention", func(t *testing.T) {
+		old := &model.Todo{Title: zero.StringFrom("Old")}
+		recent := &model.Todo{Title: zero.StringFrom("Recent")}
+		todoService.CreateTodo(old, space)
+		todoService.CreateTodo(recent, space)
+		todoService.DeleteTodo(old, owner.ID)
+		todoService.DeleteTodo(recent, owner.ID)
+		DB.Unscoped().Model(old).Update("deleted_at", time.Now().Add(-config.TRASH_RETENTION-time.Hour))
+
+		expired, err := todoService.FindExpiredTrash(time.Now().Add(-config.TRASH_RETENTION))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(expired, []uint{old.ID}) {
+			t.Errorf("Expected only the old todo to expire, got %v", expired)
+		}
+	})
+
+	t.Run("should render the trash page", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/trash", nil)
+		req.AddCookie(&http.Cookie{Name: "go-todo-api_auth", Value: ownerAuth.Token})
+		res, _ := App.Test(req, -1)
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+		body, _ := io.ReadAll(res.Body)
+		if !strings.Contains(string(body), "Recent") || !strings.Contains(string(body), "Empty trash") {
+			t.Errorf("Expected the deleted todos on the page, got %s", body)
+		}
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"gopkg.in/guregu/null.v4/zero"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
 	"github.com/nleiva/go-todo-api/utils"
 )
 
@@ -60,6 +61,19 @@ func (v *Validator) RegisterCustomValidators() {
 	v.validator.RegisterCustomTypeFunc(ValidateNullBool, null.Bool{})
 	v.validator.RegisterCustomTypeFunc(ValidateNullFloat, null.Float{})
 	v.validator.RegisterCustomTypeFunc(ValidateNullTime, null.Time{})
+
+	v.validator.RegisterValidation("priority", ValidatePriority)
+	v.validator.RegisterValidation("tag", ValidateTag)
+}
+
+// ValidateTag checks the field is a valid tag name
+func ValidateTag(fl validator.FieldLevel) bool {
+	return model.ValidTag(fl.Field().String())
+}
+
+// ValidatePriority checks the field is one of the priority levels of todos
+func ValidatePriority(fl validator.FieldLevel) bool {
+	return model.ValidPriority(fl.Field().String())
 }
 
 func ValidateZeroString(field reflect.Value) any {
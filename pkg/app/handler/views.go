@@ -8,25 +8,37 @@ import (
 	"github.com/a-h/templ"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/nleiva/go-todo-api/config"
 	"github.com/nleiva/go-todo-api/pkg/app/model"
 	"github.com/nleiva/go-todo-api/pkg/app/types"
 	"github.com/nleiva/go-todo-api/pkg/app/types/pagination"
 	"github.com/nleiva/go-todo-api/pkg/jwt"
 	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/password"
 	"github.com/nleiva/go-todo-api/pkg/view"
 	"github.com/nleiva/go-todo-api/utils"
+	"gopkg.in/guregu/null.v4"
 	"gorm.io/gorm"
 )
 
 func (h *Handler) GetBaseData(c *fiber.Ctx) view.BaseData {
 	account := &model.Account{}
+	memberships := []model.Membership{}
+	space := model.Space{}
+	unread := int64(0)
 	if locals.JwtPayload(c).Valid {
 		h.accountService.FindAccountByID(account, locals.JwtPayload(c).AccountID)
+		h.organizationService.FindMemberships(&memberships, locals.JwtPayload(c).AccountID)
+		space, _ = h.currentSpace(c)
+		unread, _ = h.notificationService.CountUnread(locals.JwtPayload(c).AccountID)
 	}
 
 	return view.BaseData{
-		IsAuthenticated: locals.JwtPayload(c).Valid,
-		Account:         account,
+		IsAuthenticated:     locals.JwtPayload(c).Valid,
+		Account:             account,
+		Memberships:         memberships,
+		OrganizationID:      space.OrganizationID,
+		UnreadNotifications: unread,
 	}
 }
 
@@ -47,21 +59,14 @@ func (h *Handler) VProfile(c *fiber.Ctx) error {
 		return &utils.INTERNAL_SERVER_ERROR
 	}
 
-	// Calculate todo statistics
-	var totalTodos, completedTodos, pendingTodos int64
-
-	// Get total todos count
-	if err := h.db.Model(&model.Todo{}).Where("account_id = ?", accountID).Count(&totalTodos).Error; err != nil {
-		return &utils.INTERNAL_SERVER_ERROR
-	}
-
-	// Get completed todos count
-	if err := h.db.Model(&model.Todo{}).Where("account_id = ? AND completed = ?", accountID, true).Count(&completedTodos).Error; err != nil {
+	// Calculate todo statistics of the personal space
+	totalTodos, completedTodos, err := h.todoService.CountTodos(model.Space{AccountID: accountID})
+	if err != nil {
 		return &utils.INTERNAL_SERVER_ERROR
 	}
 
 	// Calculate pending todos
-	pendingTodos = totalTodos - completedTodos
+	pendingTodos := totalTodos - completedTodos
 
 	// Calculate completion rate
 	completionRate := 0
@@ -80,41 +85,151 @@ func (h *Handler) VProfile(c *fiber.Ctx) error {
 		},
 	}
 
+	passkeys := []model.WebAuthnCredential{}
+	if err := h.webAuthnService.FindCredentials(&passkeys, accountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
 	pageData := view.ProfilePageData{
 		BaseData:    h.GetBaseData(c),
 		ProfileData: profileData,
+		Passkeys:    passkeys,
 	}
 
 	return adaptor.HTTPHandler(templ.Handler(view.ProfilePage(pageData)))(c)
 }
 
+func (h *Handler) VProfilePasskeyDelete(c *fiber.Ctx) error {
+	if err := h.webAuthnService.DeleteCredentialByID(c.Params("id"), locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.Status(http.StatusOK).SendString("")
+}
+
+func (h *Handler) VProfilePasswordPut(c *fiber.Ctx) error {
+	remoteData := &types.ChangePasswordDTO{}
+
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+		return renderFormErrors(c, "#password-change-result", []string{"Please fill in all fields."})
+	}
+
+	account, violations, err := h.changePassword(c, remoteData)
+	if err != nil {
+		var requestError *utils.RequestError
+		if errors.As(err, &requestError) && requestError.StatusCode == fiber.StatusBadRequest {
+			message := requestError.Message
+			if requestError.Detail != "" {
+				message = requestError.Detail
+			}
+			return renderFormErrors(c, "#password-change-result", []string{message})
+		}
+		return err
+	}
+	if len(violations) > 0 {
+		return renderFormErrors(c, "#password-change-result", violationMessages(violations))
+	}
+
+	// The token secret changed, the current refresh cookie is no longer valid
+	auth, err := jwt.Generate(account)
+	if err != nil {
+		return err
+	}
+	setAuthCookies(c, auth)
+
+	return adaptor.HTTPHandler(templ.Handler(view.PasswordChangeSuccess()))(c)
+}
+
+// renderFormErrors swaps the messages into the error container of a form. HTMX doesn't swap error responses,
+// so the response is a 200 retargeted with HX-Retarget.
+func renderFormErrors(c *fiber.Ctx, target string, messages []string) error {
+	c.Set("HX-Retarget", target)
+	c.Set("HX-Reswap", "innerHTML")
+
+	return adaptor.HTTPHandler(templ.Handler(view.FormErrors(messages)))(c)
+}
+
+func violationMessages(violations []password.Violation) []string {
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.Message
+	}
+
+	return messages
+}
+
 func (h *Handler) VTodosIndex(c *fiber.Ctx) error {
 	var meta = locals.Meta(c)
 
 	meta.Order = append(meta.Order, pagination.OrderEntry{
+		Key:       "position",
+		Direction: "asc",
+	}, pagination.OrderEntry{
 		Key:       "created_at",
 		Direction: "desc",
 	})
 
+	space, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+
+	where, err := withArchivedFilter(c, h.todoService.InSpace(space))
+	if err != nil {
+		return err
+	}
+
+	where, err = withTagFilter(c, where)
+	if err != nil {
+		return err
+	}
+
 	var todos = []model.Todo{}
-	if err := h.FindWithMeta(&todos, &model.Todo{}, meta, h.db.Where("account_id = ?", locals.JwtPayload(c).AccountID)).Error; err != nil {
+	if err := h.FindWithMeta(&todos, &model.Todo{}, meta, where).Error; err != nil {
+		return statusError(err)
+	}
+
+	if err := h.markBlocked(todos); err != nil {
+		return err
+	}
+
+	if err := h.markTimers(c, todos); err != nil {
+		return err
+	}
+
+	links := []model.ShareLink{}
+	if err := h.shareLinkService.FindLinks(&links, space).Error; err != nil {
 		return &utils.INTERNAL_SERVER_ERROR
 	}
 
-	return adaptor.HTTPHandler(templ.Handler(view.TodosIndexPage(h.GetBaseData(c), todos)))(c)
+	templates := []model.TodoTemplate{}
+	if err := h.templateService.FindTemplates(&templates, space).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.TodosIndexPage(h.GetBaseData(c), todos, links, templates)))(c)
 }
 
+// VTodosCreate quick-adds the title of the form, see QuickAddTodo. Todos created in another organization are not
+// added to the list.
 func (h *Handler) VTodosCreate(c *fiber.Ctx) error {
-	todo := &model.Todo{}
+	remoteData := &model.Todo{}
 
-	if err := ParseBodyAndValidate(c, todo, *h.validator); err != nil {
+	if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
 		return err
 	}
 
-	todo.AccountID = locals.JwtPayload(c).AccountID
+	todo, _, space, err := h.quickAdd(c, remoteData.Title.String)
+	if err != nil {
+		return err
+	}
 
-	if err := h.todoService.CreateTodo(todo).Error; err != nil {
-		return &utils.INTERNAL_SERVER_ERROR
+	current, err := h.currentSpace(c)
+	if err != nil {
+		return err
+	}
+	if space != current {
+		c.Set("HX-Reswap", "none")
 	}
 
 	return adaptor.HTTPHandler(templ.Handler(view.TodoItem(*todo)))(c)
@@ -124,17 +239,18 @@ func (h *Handler) VTodosComplete(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	todo := &model.Todo{}
-	if err := h.todoService.FindTodoByID(todo, id, locals.JwtPayload(c).AccountID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return &utils.NOT_FOUND
-		}
-		return &utils.INTERNAL_SERVER_ERROR
+	if err := h.findTodoWithRole(c, todo, id, model.SHARE_ROLE_EDITOR); err != nil {
+		return err
 	}
 
 	todo.Completed = !todo.Completed
+	todo.CompletedAt = null.Time{}
+	if todo.Completed {
+		todo.CompletedAt = null.TimeFrom(time.Now())
+	}
 
-	if err := h.todoService.UpdateTodo(todo).Error; err != nil {
-		return &utils.INTERNAL_SERVER_ERROR
+	if err := h.todoService.UpdateTodo(todo, locals.JwtPayload(c).AccountID); err != nil {
+		return statusError(err)
 	}
 
 	return adaptor.HTTPHandler(templ.Handler(view.TodoCompleteToggle(*todo)))(c)
@@ -142,11 +258,39 @@ func (h *Handler) VTodosComplete(c *fiber.Ctx) error {
 
 func (h *Handler) VTodosDelete(c *fiber.Ctx) error {
 	id := c.Params("id")
-	h.todoService.DeleteTodoByID(id)
+
+	todo := &model.Todo{}
+	if err := h.findTodoWithRole(c, todo, id, model.SHARE_ROLE_OWNER); err != nil {
+		return err
+	}
+
+	if err := h.deleteTodo(c, todo); err != nil {
+		return err
+	}
 
 	return c.Status(http.StatusOK).SendString("")
 }
 
+// VTodosAssigned lists the todos assigned to the account across all spaces and shares
+func (h *Handler) VTodosAssigned(c *fiber.Ctx) error {
+	todos := []model.Todo{}
+	if err := h.todoService.FindAssignedTodos(&todos, locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.TodosAssignedPage(h.GetBaseData(c), todos)))(c)
+}
+
+// VTodosShared lists the todos other accounts shared with the account
+func (h *Handler) VTodosShared(c *fiber.Ctx) error {
+	todos := []model.SharedTodo{}
+	if err := h.todoService.FindSharedTodos(&todos, locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return adaptor.HTTPHandler(templ.Handler(view.TodosSharedPage(h.GetBaseData(c), todos)))(c)
+}
+
 func (h *Handler) VLogin(c *fiber.Ctx) error {
 	return adaptor.HTTPHandler(templ.Handler(view.LoginPage(h.GetBaseData(c))))(c)
 }
@@ -160,24 +304,40 @@ func (h *Handler) VLoginPost(c *fiber.Ctx) error {
 		return adaptor.HTTPHandler(templ.Handler(view.LoginPageWithError(baseData, "Please check your input and try again.")))(c)
 	}
 
+	if err := h.checkLoginThrottle(c, remoteData.Email); err != nil {
+		// Return login page with "too many attempts" error
+		baseData := h.GetBaseData(c)
+		return adaptor.HTTPHandler(templ.Handler(view.LoginPageWithError(baseData, "Too many failed login attempts. Please try again later.")))(c)
+	}
+
 	account := &model.Account{}
 	if err := h.accountService.FindAccountByEmail(account, remoteData.Email).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// Return login page with "account not found" error
+			// Return the same error as for a wrong password so the page doesn't reveal which accounts exist
+			model.CheckPasswordHash(remoteData.Password, dummyPasswordHash)
+			h.recordLoginAttempt(c, remoteData.Email, nil, model.LOGIN_FAILURE_UNKNOWN_ACCOUNT)
 			baseData := h.GetBaseData(c)
-			return adaptor.HTTPHandler(templ.Handler(view.LoginPageWithError(baseData, "No account found with this email address.")))(c)
+			return adaptor.HTTPHandler(templ.Handler(view.LoginPageWithError(baseData, "Incorrect email or password. Please try again.")))(c)
 		}
 		// Return login page with generic error
 		baseData := h.GetBaseData(c)
 		return adaptor.HTTPHandler(templ.Handler(view.LoginPageWithError(baseData, "An error occurred. Please try again.")))(c)
 	}
 
-	if !model.CheckPasswordHash(remoteData.Password, account.Password) {
+	ok, needsRehash := model.VerifyPassword(remoteData.Password, account.Password)
+	if !ok {
 		// Return login page with "wrong password" error
+		h.recordLoginAttempt(c, remoteData.Email, account, model.LOGIN_FAILURE_WRONG_PASSWORD)
 		baseData := h.GetBaseData(c)
 		return adaptor.HTTPHandler(templ.Handler(view.LoginPageWithError(baseData, "Incorrect email or password. Please try again.")))(c)
 	}
 
+	if needsRehash {
+		h.rehashPassword(account, remoteData.Password)
+	}
+
+	h.recordLoginAttempt(c, remoteData.Email, account, "")
+
 	auth, err := jwt.Generate(account)
 	if err != nil {
 		// Return login page with generic error
@@ -186,6 +346,33 @@ func (h *Handler) VLoginPost(c *fiber.Ctx) error {
 	}
 
 	//Set cookie and return 200
+	setAuthCookies(c, auth)
+
+	c.Response().Header.Set("HX-Redirect", loginRedirect(c))
+
+	return c.Status(http.StatusOK).SendString("")
+}
+
+// VLoginPasskeyPost finishes a passkey login started from the login page and sets the auth cookies
+func (h *Handler) VLoginPasskeyPost(c *fiber.Ctx) error {
+	account, err := h.webAuthnService.FinishLogin(c.Body())
+	if err != nil {
+		return err
+	}
+
+	auth, err := jwt.Generate(account)
+	if err != nil {
+		return err
+	}
+
+	setAuthCookies(c, auth)
+
+	c.Response().Header.Set("HX-Redirect", "/")
+
+	return c.Status(http.StatusOK).SendString("")
+}
+
+func setAuthCookies(c *fiber.Ctx, auth types.AuthResponseBody) {
 	c.Cookie(&fiber.Cookie{
 		Name:     "go-todo-api_auth",
 		Value:    auth.Token,
@@ -203,10 +390,6 @@ func (h *Handler) VLoginPost(c *fiber.Ctx) error {
 		SameSite: "Lax",
 		MaxAge:   86400, // 24 hours
 	})
-
-	c.Response().Header.Set("HX-Redirect", "/")
-
-	return c.Status(http.StatusOK).SendString("")
 }
 
 func (h *Handler) VLogout(c *fiber.Ctx) error {
@@ -235,7 +418,7 @@ func (h *Handler) VLogout(c *fiber.Ctx) error {
 }
 
 func (h *Handler) VRegister(c *fiber.Ctx) error {
-	return adaptor.HTTPHandler(templ.Handler(view.RegisterPage(h.GetBaseData(c))))(c)
+	return adaptor.HTTPHandler(templ.Handler(view.RegisterPage(h.GetBaseData(c), config.REGISTRATION_MODE, c.Query("invite"))))(c)
 }
 
 func (h *Handler) VRegisterPost(c *fiber.Ctx) error {
@@ -245,33 +428,20 @@ func (h *Handler) VRegisterPost(c *fiber.Ctx) error {
 		return err
 	}
 
-	// Check if passwords match
-	if remoteData.Password != remoteData.ConfirmPassword {
-		return utils.RequestErrorFrom(&utils.VALIDATION_ERROR, "Password and confirm password do not match")
-	}
-
-	// Check if user already exists
-	existingAccount := &model.Account{}
-	if err := h.accountService.FindAccountByEmail(existingAccount, remoteData.Email).Error; err == nil {
-		return &utils.ACCOUNT_WITH_EMAIL_ALREADY_EXISTS
-	}
-
-	// Hash the password
-	hashedPassword, err := model.HashPassword(remoteData.Password)
+	account, violations, err := h.registerAccount(c, remoteData)
 	if err != nil {
+		var requestError *utils.RequestError
+		if errors.As(err, &requestError) {
+			message := requestError.Message
+			if requestError.Detail != "" {
+				message = requestError.Detail + "."
+			}
+			return renderFormErrors(c, "#register-errors", []string{message})
+		}
 		return err
 	}
-
-	// Create new account
-	account := &model.Account{
-		Email:     remoteData.Email,
-		Password:  hashedPassword,
-		Firstname: remoteData.Firstname,
-		Lastname:  remoteData.Lastname,
-	}
-
-	if err := h.accountService.CreateAccount(account).Error; err != nil {
-		return err
+	if len(violations) > 0 {
+		return renderFormErrors(c, "#register-errors", violationMessages(violations))
 	}
 
 	// Generate JWT token for automatic login
@@ -280,18 +450,7 @@ func (h *Handler) VRegisterPost(c *fiber.Ctx) error {
 		return err
 	}
 
-	// Set cookies
-	c.Cookie(&fiber.Cookie{
-		Name:     "go-todo-api_auth",
-		Value:    auth.Token,
-		HTTPOnly: true,
-	})
-
-	c.Cookie(&fiber.Cookie{
-		Name:     "go-todo-api_refresh",
-		Value:    auth.RefreshToken,
-		HTTPOnly: true,
-	})
+	setAuthCookies(c, auth)
 
 	// Redirect to home page
 	c.Response().Header.Set("HX-Redirect", "/")
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+// WebAuthnRegisterBegin godoc
+//
+//	@Summary		Begin passkey registration
+//	@Description	Returns the PublicKeyCredentialCreationOptions for navigator.credentials.create()
+//	@Tags			auth
+//	@Produce		json
+//	@Success		200	{object}	object
+//	@Security		BearerAuth
+//	@Router			/auth/webauthn/register/begin [post]
+func (h *Handler) WebAuthnRegisterBegin(c *fiber.Ctx) error {
+	account := &model.Account{}
+	if err := h.accountService.FindAccountByID(account, locals.JwtPayload(c).AccountID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &utils.NOT_FOUND
+		}
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	options, err := h.webAuthnService.BeginRegistration(account)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(options)
+}
+
+// WebAuthnRegisterFinish godoc
+//
+//	@Summary		Finish passkey registration
+//	@Description	Verifies the attestation returned by navigator.credentials.create() and stores the passkey
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	query		string	false	"Display name of the passkey"
+//	@Success		200		{object}	types.CreateWebAuthnCredentialResponse
+//	@Security		BearerAuth
+//	@Router			/auth/webauthn/register/finish [post]
+func (h *Handler) WebAuthnRegisterFinish(c *fiber.Ctx) error {
+	account := &model.Account{}
+	if err := h.accountService.FindAccountByID(account, locals.JwtPayload(c).AccountID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &utils.NOT_FOUND
+		}
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	credential, err := h.webAuthnService.FinishRegistration(account, c.Query("name"), c.Body())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&types.CreateWebAuthnCredentialResponse{
+		Credential: *credential,
+	})
+}
+
+// WebAuthnLoginBegin godoc
+//
+//	@Summary		Begin passkey login
+//	@Description	Returns the PublicKeyCredentialRequestOptions for navigator.credentials.get(). Without an email a discoverable login is started.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			account	body		types.WebAuthnLoginBeginDTO	false	"Account"
+//	@Success		200		{object}	object
+//	@Router			/auth/webauthn/login/begin [post]
+func (h *Handler) WebAuthnLoginBegin(c *fiber.Ctx) error {
+	remoteData := &types.WebAuthnLoginBeginDTO{}
+
+	if len(c.Body()) > 0 {
+		if err := ParseBodyAndValidate(c, remoteData, *h.validator); err != nil {
+			return err
+		}
+	}
+
+	// Unknown emails and accounts without passkeys fall back to a discoverable login, so the response doesn't reveal
+	// which accounts exist
+	var account *model.Account
+	if remoteData.Email != "" {
+		found := &model.Account{}
+		if err := h.accountService.FindAccountByEmail(found, remoteData.Email).Error; err == nil {
+			account = found
+		}
+	}
+
+	options, err := h.webAuthnService.BeginLogin(account)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(options)
+}
+
+// WebAuthnLoginFinish godoc
+//
+//	@Summary		Finish passkey login
+//	@Description	Verifies the assertion returned by navigator.credentials.get() and issues tokens
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	types.AuthResponse
+//	@Router			/auth/webauthn/login/finish [post]
+func (h *Handler) WebAuthnLoginFinish(c *fiber.Ctx) error {
+	account, err := h.webAuthnService.FinishLogin(c.Body())
+	if err != nil {
+		return err
+	}
+
+	auth, err := jwt.Generate(account)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&types.AuthResponse{
+		Auth: auth,
+	})
+}
+
+// GetWebAuthnCredentials godoc
+//
+//	@Summary	List passkeys
+//	@Tags		auth
+//	@Produce	json
+//	@Success	200	{object}	types.GetWebAuthnCredentialsResponse
+//	@Security	BearerAuth
+//	@Router		/auth/webauthn/credentials [get]
+func (h *Handler) GetWebAuthnCredentials(c *fiber.Ctx) error {
+	credentials := []model.WebAuthnCredential{}
+
+	if err := h.webAuthnService.FindCredentials(&credentials, locals.JwtPayload(c).AccountID).Error; err != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+
+	return c.JSON(&types.GetWebAuthnCredentialsResponse{
+		Credentials: credentials,
+	})
+}
+
+// DeleteWebAuthnCredential godoc
+//
+//	@Summary	Delete passkey
+//	@Tags		auth
+//	@Param		id	path		int	true	"Credential ID"
+//	@Success	204	{object}	nil	"No Content"
+//	@Security	BearerAuth
+//	@Router		/auth/webauthn/credentials/{id} [delete]
+func (h *Handler) DeleteWebAuthnCredential(c *fiber.Ctx) error {
+	remoteId := c.Params("id")
+	if remoteId == "" {
+		return &utils.BAD_REQUEST
+	}
+
+	result := h.webAuthnService.DeleteCredentialByID(remoteId, locals.JwtPayload(c).AccountID)
+	if result.Error != nil {
+		return &utils.INTERNAL_SERVER_ERROR
+	}
+	if result.RowsAffected == 0 {
+		return &utils.NOT_FOUND
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
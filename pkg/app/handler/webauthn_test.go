@@ -0,0 +1,186 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/test"
+)
+
+func TestWebAuthnHandlerRegisterAndLogin(t *testing.T) {
+	// Setup
+	pw, _ := model.HashPassword("123456")
+	account := &model.Account{
+		Email:     "webauthn.login@turbomeet.xyz",
+		Password:  pw,
+		Firstname: "WebAuthn",
+		Lastname:  "Login",
+	}
+	accountService := service.NewAccountService(DB)
+	accountService.CreateAccount(account)
+
+	auth, _ := jwt.Generate(account)
+	authToken := auth.Token
+
+	authenticator := test.NewSoftAuthenticator(config.WEBAUTHN_RP_ID, config.WEBAUTHN_RP_ORIGINS[0])
+
+	post := func(path string, body []byte, token string) *http.Response {
+		req, _ := http.NewRequest("POST", path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, _ := App.Test(req)
+		return res
+	}
+
+	t.Run("registration should be unauthorized", func(t *testing.T) {
+		res := post("/api/auth/webauthn/register/begin", nil, "")
+
+		if res.StatusCode != 401 {
+			t.Errorf("Expected status code 401, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should register a passkey", func(t *testing.T) {
+		res := post("/api/auth/webauthn/register/begin", nil, authToken)
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		options, _ := io.ReadAll(res.Body)
+		credential, err := authenticator.Create(options)
+		if err != nil {
+			t.Fatalf("Software authenticator failed: %v", err)
+		}
+
+		res = post("/api/auth/webauthn/register/finish?name=Laptop", credential, authToken)
+		if res.StatusCode != 200 {
+			body, _ := io.ReadAll(res.Body)
+			t.Fatalf("Expected status code 200, got %d: %s", res.StatusCode, body)
+		}
+
+		result := types.CreateWebAuthnCredentialResponse{}
+		bodyBytes, _ := io.ReadAll(res.Body)
+		if err := json.Unmarshal(bodyBytes, &result); err != nil {
+			t.Fatalf("Error unmarshalling response: %v", err)
+		}
+
+		if result.Credential.Name != "Laptop" || result.Credential.AccountID != account.ID {
+			t.Errorf("Unexpected credential %+v", result.Credential)
+		}
+	})
+
+	t.Run("should login with the passkey", func(t *testing.T) {
+		res := post("/api/auth/webauthn/login/begin", []byte(`{"email":"webauthn.login@turbomeet.xyz"}`), "")
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		options, _ := io.ReadAll(res.Body)
+		assertion, err := authenticator.Get(options)
+		if err != nil {
+			t.Fatalf("Software authenticator failed: %v", err)
+		}
+
+		res = post("/api/auth/webauthn/login/finish", assertion, "")
+		if res.StatusCode != 200 {
+			body, _ := io.ReadAll(res.Body)
+			t.Fatalf("Expected status code 200, got %d: %s", res.StatusCode, body)
+		}
+
+		result := types.AuthResponse{}
+		bodyBytes, _ := io.ReadAll(res.Body)
+		if err := json.Unmarshal(bodyBytes, &result); err != nil {
+			t.Fatalf("Error unmarshalling response: %v", err)
+		}
+
+		payload, err := jwt.Verify(result.Auth.Token)
+		if err != nil || payload.AccountID != account.ID {
+			t.Errorf("Expected a token for account %d, got %+v (%v)", account.ID, payload, err)
+		}
+
+		// The same assertion must not be accepted twice
+		res = post("/api/auth/webauthn/login/finish", assertion, "")
+		if res.StatusCode != 400 {
+			t.Errorf("Expected replayed assertion to fail with 400, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("should login without an email (discoverable)", func(t *testing.T) {
+		res := post("/api/auth/webauthn/login/begin", nil, "")
+		options, _ := io.ReadAll(res.Body)
+
+		assertion, err := authenticator.Get(options)
+		if err != nil {
+			t.Fatalf("Software authenticator failed: %v", err)
+		}
+
+		res = post("/api/auth/webauthn/login/finish", assertion, "")
+		if res.StatusCode != 200 {
+			body, _ := io.ReadAll(res.Body)
+			t.Fatalf("Expected status code 200, got %d: %s", res.StatusCode, body)
+		}
+	})
+
+	t.Run("should reject a signature counter that did not increase", func(t *testing.T) {
+		res := post("/api/auth/webauthn/login/begin", nil, "")
+		options, _ := io.ReadAll(res.Body)
+
+		// Simulate a cloned authenticator replaying an older counter value
+		authenticator.SignCount = 0
+		assertion, _ := authenticator.Get(options)
+
+		res = post("/api/auth/webauthn/login/finish", assertion, "")
+		if res.StatusCode != 401 {
+			t.Errorf("Expected status code 401, got %d", res.StatusCode)
+		}
+	})
+
+	// Cleanup
+	test.ClearTables(DB, []string{"web_authn_credentials", "web_authn_sessions"})
+	test.ClearAllTables(DB)
+}
+
+func TestWebAuthnHandlerLoginBeginEnumeration(t *testing.T) {
+	// Setup
+	pw, _ := model.HashPassword("123456")
+	account := &model.Account{Email: "webauthn.nopasskey@turbomeet.xyz", Password: pw}
+	service.NewAccountService(DB).CreateAccount(account)
+
+	begin := func(email string) map[string]any {
+		res := send("POST", "/api/auth/webauthn/login/begin", "", &types.WebAuthnLoginBeginDTO{Email: email})
+		if res.StatusCode != 200 {
+			t.Fatalf("Expected status code 200, got %d", res.StatusCode)
+		}
+
+		options := map[string]any{}
+		json.NewDecoder(res.Body).Decode(&options)
+
+		// The challenge is random, everything else has to match
+		delete(options["publicKey"].(map[string]any), "challenge")
+		return options
+	}
+
+	t.Run("should answer known emails without passkeys like unknown emails", func(t *testing.T) {
+		unknown := begin("webauthn.unknown@turbomeet.xyz")
+		known := begin("webauthn.nopasskey@turbomeet.xyz")
+
+		if !reflect.DeepEqual(unknown, known) {
+			t.Errorf("Expected identical responses, got %v and %v", unknown, known)
+		}
+	})
+
+	// Cleanup
+	test.ClearTables(DB, []string{"web_authn_sessions"})
+	test.ClearAllTables(DB)
+}
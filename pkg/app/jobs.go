@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/service"
+)
+
+// every runs the job right away and then every interval until the context is done. Failed runs are logged
+// and retried on the next tick.
+func every(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(ctx); err != nil {
+			log.Printf("%s failed: %v", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeTrash permanently deletes the todos that are in the trash for longer than TRASH_RETENTION
+func purgeTrash(ts service.ITodoService, ats service.IAttachmentService) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		todoIDs, err := ts.FindExpiredTrash(time.Now().Add(-config.TRASH_RETENTION))
+		if err != nil || len(todoIDs) == 0 {
+			return err
+		}
+
+		if err := ats.DeleteTodoAttachments(ctx, todoIDs...); err != nil {
+			return err
+		}
+
+		return ts.PurgeTodos(todoIDs...)
+	}
+}
+
+// autoArchive archives completed todos by the auto-archive rules of their accounts
+func autoArchive(ts service.ITodoService) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := ts.AutoArchive(time.Now())
+		return err
+	}
+}
+
+// rebalancePositions spreads the positions of spaces whose manual order got long keys
+func rebalancePositions(ts service.ITodoService) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := ts.RebalancePositions(config.POSITION_REBALANCE_LENGTH)
+		return err
+	}
+}
@@ -1,9 +1,11 @@
 package model
 
 import (
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/password"
 	"github.com/nleiva/go-todo-api/utils"
 
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -15,6 +17,10 @@ type Account struct {
 	Lastname    string `gorm:"" json:"lastname" x-search:"true"`
 	TokenSecret string `gorm:"type:varchar(8)" json:"-"`
 	Permission  uint64 `gorm:"default:0" json:"permission"`
+	// AutoArchiveDays archives completed todos of the account that many days after completion, 0 turns it off
+	AutoArchiveDays uint `gorm:"default:0" json:"autoArchiveDays"`
+	// Timezone is the IANA name of the account's timezone, quick-add dates are relative to it. Empty means UTC.
+	Timezone string `gorm:"size:64;not null;default:''" json:"timezone"`
 
 	Todos []Todo `gorm:"foreignKey:AccountID" json:"todos"`
 }
@@ -25,15 +31,35 @@ func (account *Account) New(remote Account) {
 	account.Lastname = remote.Lastname
 }
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 10)
-	return string(bytes), err
+// Location returns the timezone of the account, UTC if it is not set or unknown
+func (account *Account) Location() *time.Location {
+	location, err := time.LoadLocation(account.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+// HashPassword hashes the password with the configured algorithm (see password.Current)
+func HashPassword(pw string) (string, error) {
+	return password.Hash(pw)
 }
 
 // CheckPasswordHash compare password with hash
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+func CheckPasswordHash(pw, hash string) bool {
+	ok, _ := VerifyPassword(pw, hash)
+	return ok
+}
+
+// VerifyPassword compares password with hash and reports whether the hash should be upgraded to the current settings
+func VerifyPassword(pw, hash string) (ok bool, needsRehash bool) {
+	ok, needsRehash, err := password.Verify(pw, hash)
+	if err != nil {
+		return false, false
+	}
+
+	return ok, needsRehash
 }
 
 const ALLOWED_SECRET_TOKEN_CHARS = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
@@ -0,0 +1,15 @@
+package model
+
+import "gorm.io/gorm"
+
+// Attachment is a file uploaded to a todo. The content is kept in the blob store under its SHA-256 hash, so
+// attachments with the same content share one blob.
+type Attachment struct {
+	gorm.Model
+	TodoID      uint   `gorm:"not null;index" json:"fkTodoId"`
+	AccountID   uint   `gorm:"not null" json:"fkAccountId"`
+	Filename    string `gorm:"not null;size:255" json:"filename"`
+	ContentType string `gorm:"not null;size:127" json:"contentType"`
+	Size        int64  `gorm:"not null" json:"size"`
+	Hash        string `gorm:"not null;size:64;index" json:"hash"`
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// mentionPattern matches @email mentions, e.g. "thanks @jane@turbomeet.xyz"
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.@])@([\w.+-]+@[\w-]+(?:\.[\w-]+)+)`)
+
+// Comment is a markdown message in the discussion of a todo
+type Comment struct {
+	gorm.Model
+	TodoID    uint   `gorm:"not null;index" json:"fkTodoId"`
+	AccountID uint   `gorm:"not null" json:"fkAccountId"`
+	Body      string `gorm:"type:text;not null" json:"body"`
+
+	Account *Account `json:"account,omitempty"`
+}
+
+// Mentions returns the lowercased emails mentioned in the body, each once
+func (c *Comment) Mentions() []string {
+	mentions := []string{}
+	seen := map[string]bool{}
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(c.Body, -1) {
+		email := strings.ToLower(match[1])
+		if !seen[email] {
+			seen[email] = true
+			mentions = append(mentions, email)
+		}
+	}
+
+	return mentions
+}
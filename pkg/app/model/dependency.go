@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// TodoDependency records that a todo can't start before another todo of its space is done. The links between
+// todos never form a cycle.
+type TodoDependency struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	TodoID      uint      `gorm:"not null;uniqueIndex:idx_todo_dependency" json:"fkTodoId"`
+	BlockedByID uint      `gorm:"not null;uniqueIndex:idx_todo_dependency;index" json:"fkBlockedById"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
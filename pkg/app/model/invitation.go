@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Registration modes (see config.REGISTRATION_MODE)
+const (
+	REGISTRATION_OPEN        = "open"
+	REGISTRATION_INVITE_ONLY = "invite-only"
+	REGISTRATION_CLOSED      = "closed"
+)
+
+// Invitation lets new accounts register while registration is invite-only. Accounts registered with an
+// invitation get its permission. Only the hash of the code is stored, the code is shown once on creation.
+type Invitation struct {
+	gorm.Model
+	CodeHash    string    `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	CreatedByID uint      `gorm:"not null;index" json:"fkCreatedById"`
+	Permission  uint64    `gorm:"default:0" json:"permission"`
+	MaxUses     int       `gorm:"not null" json:"maxUses"`
+	Uses        int       `gorm:"default:0" json:"uses"`
+	ExpiresAt   time.Time `gorm:"not null" json:"expiresAt"`
+}
+
+// Usable reports whether the invitation is neither expired nor used up
+func (i *Invitation) Usable() bool {
+	return i.Uses < i.MaxUses && time.Now().Before(i.ExpiresAt)
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	LOGIN_FAILURE_UNKNOWN_ACCOUNT = "unknown_account"
+	LOGIN_FAILURE_WRONG_PASSWORD  = "wrong_password"
+	LOGIN_FAILURE_THROTTLED       = "throttled"
+)
+
+// LoginAttempt records a password login so repeated failures can be throttled and reviewed by admins
+type LoginAttempt struct {
+	gorm.Model
+	Email     string `gorm:"index;not null" json:"email" x-search:"true"`
+	IP        string `gorm:"index;not null" json:"ip" x-search:"true"`
+	AccountID *uint  `gorm:"index" json:"fkAccountId"`
+	Success   bool   `gorm:"default:false" json:"success"`
+	Reason    string `gorm:"" json:"reason"`
+}
+
+// SortColumns are the columns the failed attempts can be ordered by
+func (attempt *LoginAttempt) SortColumns() []string {
+	return []string{"id", "email", "ip", "created_at"}
+}
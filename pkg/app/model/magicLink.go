@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// MagicLink is a single use sign-in link sent by email. Only hashes are stored: of the token in the link
+// and of the nonce in the cookie of the browser that requested the link.
+type MagicLink struct {
+	gorm.Model
+	TokenHash   string    `gorm:"uniqueIndex;size:64;not null"`
+	AccountID   uint      `gorm:"not null;index"`
+	BrowserHash string    `gorm:"size:64;not null"`
+	ExpiresAt   time.Time `gorm:"not null"`
+	UsedAt      null.Time `gorm:""`
+}
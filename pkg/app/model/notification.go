@@ -0,0 +1,24 @@
+package model
+
+import (
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// Kinds of notifications
+const (
+	NOTIFICATION_ASSIGNED   = "assigned"
+	NOTIFICATION_UNASSIGNED = "unassigned"
+	NOTIFICATION_MENTIONED  = "mentioned"
+)
+
+// Notification tells an account about something another account did
+type Notification struct {
+	gorm.Model
+	AccountID uint      `gorm:"not null;index" json:"fkAccountId"`
+	ActorID   uint      `gorm:"not null" json:"fkActorId"`
+	TodoID    *uint     `gorm:"index" json:"fkTodoId"`
+	Kind      string    `gorm:"not null;size:32" json:"kind"`
+	Message   string    `gorm:"not null" json:"message"`
+	ReadAt    null.Time `gorm:"" json:"readAt" swaggertype:"string" format:"date-time"`
+}
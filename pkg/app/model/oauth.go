@@ -0,0 +1,70 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+const (
+	OAUTH_TOKEN_ACCESS  = "access_token"
+	OAUTH_TOKEN_REFRESH = "refresh_token"
+)
+
+// OAuthClient is a third party application registered by an account. Confidential clients authenticate with a
+// secret and may use the client credentials grant, acting as the account that registered them.
+type OAuthClient struct {
+	gorm.Model
+	AccountID    uint   `gorm:"not null;index" json:"fkAccountId"`
+	ClientID     string `gorm:"uniqueIndex;size:64;not null" json:"clientId"`
+	SecretHash   string `gorm:"" json:"-"`
+	Name         string `gorm:"not null" json:"name"`
+	RedirectURIs string `gorm:"" json:"redirectUris"`  // space delimited
+	Scope        string `gorm:"not null" json:"scope"` // space delimited scopes the client may request
+	Confidential bool   `gorm:"default:false" json:"confidential"`
+}
+
+// HasRedirectURI reports whether the uri was registered, uris have to match exactly
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	for _, registered := range strings.Fields(c.RedirectURIs) {
+		if registered == uri {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OAuthAuthorizationCode is issued after the account consented and exchanged once for tokens
+type OAuthAuthorizationCode struct {
+	gorm.Model
+	CodeHash      string    `gorm:"uniqueIndex;size:64;not null"`
+	ClientID      string    `gorm:"not null"`
+	AccountID     uint      `gorm:"not null"`
+	RedirectURI   string    `gorm:"not null"`
+	Scope         string    `gorm:"not null"`
+	CodeChallenge string    `gorm:"not null"`
+	ExpiresAt     time.Time `gorm:"not null"`
+}
+
+// OAuthToken tracks issued access and refresh tokens for introspection and revocation.
+// Access tokens are stored by their jti, refresh tokens by their hash. Tokens of the same grant share the GrantID,
+// revoking a refresh token revokes the whole grant.
+type OAuthToken struct {
+	gorm.Model
+	TokenHash string    `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	Type      string    `gorm:"not null" json:"type"`
+	GrantID   string    `gorm:"index;size:64;not null" json:"-"`
+	ClientID  string    `gorm:"index;not null" json:"clientId"`
+	AccountID uint      `gorm:"not null;index" json:"fkAccountId"`
+	Scope     string    `gorm:"not null" json:"scope"`
+	ExpiresAt time.Time `gorm:"not null" json:"expiresAt"`
+	RevokedAt null.Time `gorm:"" json:"revokedAt" swaggertype:"string" format:"date-time"`
+}
+
+// Active reports whether the token is neither expired nor revoked
+func (t *OAuthToken) Active() bool {
+	return !t.RevokedAt.Valid && time.Now().Before(t.ExpiresAt)
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// OIDCIdentity links an account to the subject of an external identity provider
+type OIDCIdentity struct {
+	gorm.Model
+	AccountID  uint      `gorm:"not null;index" json:"fkAccountId"`
+	Issuer     string    `gorm:"uniqueIndex:idx_oidc_identity_subject;size:255;not null" json:"issuer"`
+	Subject    string    `gorm:"uniqueIndex:idx_oidc_identity_subject;size:255;not null" json:"subject"`
+	Email      string    `gorm:"" json:"email"`
+	LastUsedAt null.Time `gorm:"" json:"lastUsedAt" swaggertype:"string" format:"date-time"`
+}
+
+// OIDCSession stores the state of a running authorization code flow.
+// Sessions are single use and are removed as soon as the provider redirects back.
+type OIDCSession struct {
+	gorm.Model
+	State        string    `gorm:"uniqueIndex;size:255;not null"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+}
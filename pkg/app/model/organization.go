@@ -0,0 +1,67 @@
+package model
+
+import "gorm.io/gorm"
+
+// Roles of an account in an organization. Members work on the todos of the organization, admins also manage
+// the memberships and owners can additionally grant ownership and delete the organization.
+const (
+	ORGANIZATION_ROLE_MEMBER = "member"
+	ORGANIZATION_ROLE_ADMIN  = "admin"
+	ORGANIZATION_ROLE_OWNER  = "owner"
+)
+
+var organizationRoleRanks = map[string]int{
+	ORGANIZATION_ROLE_MEMBER: 1,
+	ORGANIZATION_ROLE_ADMIN:  2,
+	ORGANIZATION_ROLE_OWNER:  3,
+}
+
+// Organization is a shared workspace, its todos are visible to all of its members
+type Organization struct {
+	gorm.Model
+	Name string `gorm:"not null" json:"name" validate:"required,min=1,max=100"`
+}
+
+// Membership gives an account a role in an organization
+type Membership struct {
+	gorm.Model
+	OrganizationID uint   `gorm:"not null;uniqueIndex:idx_membership_organization_account" json:"fkOrganizationId"`
+	AccountID      uint   `gorm:"not null;uniqueIndex:idx_membership_organization_account;index" json:"fkAccountId"`
+	Role           string `gorm:"not null;size:16" json:"role"`
+
+	Organization *Organization `json:"organization,omitempty"`
+	Account      *Account      `json:"account,omitempty"`
+}
+
+// ValidOrganizationRole reports whether role is one of the organization roles
+func ValidOrganizationRole(role string) bool {
+	_, ok := organizationRoleRanks[role]
+	return ok
+}
+
+// HasRole reports whether the role of the membership is at least the given role
+func (m *Membership) HasRole(role string) bool {
+	return organizationRoleRanks[m.Role] >= organizationRoleRanks[role]
+}
+
+// ShareRole returns the role the membership gives for the todos of the organization. Members edit them, admins
+// and owners own them.
+func (m *Membership) ShareRole() string {
+	if m.HasRole(ORGANIZATION_ROLE_ADMIN) {
+		return SHARE_ROLE_OWNER
+	}
+
+	return SHARE_ROLE_EDITOR
+}
+
+// Space is the tenant todos belong to: the personal space of an account or an organization the account is a
+// member of. OrganizationID is zero for the personal space.
+type Space struct {
+	AccountID      uint
+	OrganizationID uint
+}
+
+// IsPersonal reports whether the space is the personal space of the account
+func (s Space) IsPersonal() bool {
+	return s.OrganizationID == 0
+}
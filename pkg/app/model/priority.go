@@ -0,0 +1,140 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/app/types/pagination"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TODO_PRIORITY_NONE   = "none"
+	TODO_PRIORITY_LOW    = "low"
+	TODO_PRIORITY_MEDIUM = "medium"
+	TODO_PRIORITY_HIGH   = "high"
+	TODO_PRIORITY_URGENT = "urgent"
+)
+
+// TodoPriorities are the priority levels from lowest to highest
+var TodoPriorities = []string{
+	TODO_PRIORITY_NONE,
+	TODO_PRIORITY_LOW,
+	TODO_PRIORITY_MEDIUM,
+	TODO_PRIORITY_HIGH,
+	TODO_PRIORITY_URGENT,
+}
+
+// TODO_ORDER_SMART sorts open todos first and those by their smart score, most important first. The score adds
+// up the priority, how close the due date is and the age of the todo, see todoDueScores and todoAgeScores.
+const TODO_ORDER_SMART = "smart"
+
+// todoPriorityWeight is the score of every priority level above none
+const todoPriorityWeight = 2
+
+// todoDueScores raise the score of todos as their due date nears, the first entry the due date is within counts.
+// Overdue todos are within 0.
+var todoDueScores = []struct {
+	Within time.Duration
+	Score  int
+}{
+	{0, 6},
+	{24 * time.Hour, 4},
+	{3 * 24 * time.Hour, 2},
+	{7 * 24 * time.Hour, 1},
+}
+
+// todoAgeScores raise the score of todos that were created a while ago so they do not linger, the first entry
+// the todo is older than counts
+var todoAgeScores = []struct {
+	Older time.Duration
+	Score int
+}{
+	{30 * 24 * time.Hour, 2},
+	{7 * 24 * time.Hour, 1},
+}
+
+// PriorityRank returns the position of the priority in TodoPriorities, -1 for unknown priorities
+func PriorityRank(priority string) int {
+	for i, level := range TodoPriorities {
+		if level == priority {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ValidPriority reports whether priority is one of TodoPriorities
+func ValidPriority(priority string) bool {
+	return PriorityRank(priority) >= 0
+}
+
+// priorityRankSQL maps the priority column to its rank so priorities compare and sort by level
+func priorityRankSQL() string {
+	var sql strings.Builder
+	sql.WriteString("(CASE priority")
+	for i, level := range TodoPriorities[1:] {
+		sql.WriteString(" WHEN '" + level + "' THEN " + strconv.Itoa(i+1))
+	}
+	sql.WriteString(" ELSE 0 END)")
+
+	return sql.String()
+}
+
+// smartScore returns the SQL of the smart score at now
+func smartScore(now time.Time) clause.Expr {
+	vars := []any{}
+
+	var sql strings.Builder
+	sql.WriteString(priorityRankSQL() + " * " + strconv.Itoa(todoPriorityWeight))
+
+	sql.WriteString(" + (CASE WHEN due_at IS NULL THEN 0")
+	for _, due := range todoDueScores {
+		sql.WriteString(" WHEN due_at < ? THEN " + strconv.Itoa(due.Score))
+		vars = append(vars, now.Add(due.Within))
+	}
+	sql.WriteString(" ELSE 0 END)")
+
+	sql.WriteString(" + (CASE")
+	for _, age := range todoAgeScores {
+		sql.WriteString(" WHEN created_at < ? THEN " + strconv.Itoa(age.Score))
+		vars = append(vars, now.Add(-age.Older))
+	}
+	sql.WriteString(" ELSE 0 END)")
+
+	return clause.Expr{SQL: sql.String(), Vars: vars}
+}
+
+// OrderBy sorts priorities by their level and adds the smart order, other keys are plain columns
+func (todo *Todo) OrderBy(key string, direction string) (clause.Expr, bool) {
+	switch key {
+	case "priority":
+		return clause.Expr{SQL: priorityRankSQL() + " " + direction}, true
+	case TODO_ORDER_SMART:
+		score := smartScore(time.Now())
+		return clause.Expr{
+			SQL:  "completed asc, " + score.SQL + " desc, due_at IS NULL, due_at asc, created_at asc",
+			Vars: score.Vars,
+		}, true
+	}
+
+	return clause.Expr{}, false
+}
+
+// FilterBy compares priorities by their level for range filters such as [priority][gte]=high, other filters
+// are plain comparisons
+func (todo *Todo) FilterBy(filter pagination.FilterEntry) (clause.Expr, bool) {
+	if filter.Key != "priority" || !ValidPriority(filter.Value) {
+		return clause.Expr{}, false
+	}
+
+	operators := map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+	operator, ok := operators[filter.Operator]
+	if !ok {
+		return clause.Expr{}, false
+	}
+
+	return clause.Expr{SQL: priorityRankSQL() + " " + operator + " ?", Vars: []any{PriorityRank(filter.Value)}}, true
+}
@@ -0,0 +1,40 @@
+package model
+
+import "gorm.io/gorm"
+
+// Roles of a share. Viewers can read the todo, editors can also change it and owners can also delete it and
+// share it with more accounts. Accounts have the owner role for all todos of their personal space and the role
+// of their membership for the todos of an organization, see Membership.ShareRole.
+const (
+	SHARE_ROLE_VIEWER = "viewer"
+	SHARE_ROLE_EDITOR = "editor"
+	SHARE_ROLE_OWNER  = "owner"
+)
+
+var shareRoleRanks = map[string]int{
+	SHARE_ROLE_VIEWER: 1,
+	SHARE_ROLE_EDITOR: 2,
+	SHARE_ROLE_OWNER:  3,
+}
+
+// TodoShare grants an account a role for a todo outside of its spaces
+type TodoShare struct {
+	gorm.Model
+	TodoID     uint   `gorm:"not null;uniqueIndex:idx_todo_share_todo_account" json:"fkTodoId"`
+	AccountID  uint   `gorm:"not null;uniqueIndex:idx_todo_share_todo_account;index" json:"fkAccountId"`
+	Role       string `gorm:"not null;size:16" json:"role"`
+	SharedByID uint   `gorm:"not null" json:"fkSharedById"`
+
+	Account *Account `json:"account,omitempty"`
+}
+
+// SharedTodo is a todo shared with an account together with the role of the share
+type SharedTodo struct {
+	Todo      `gorm:"embedded"`
+	ShareRole string `json:"shareRole"`
+}
+
+// ShareRoleAllows reports whether role is at least the required role
+func ShareRoleAllows(role string, required string) bool {
+	return shareRoleRanks[role] >= shareRoleRanks[required]
+}
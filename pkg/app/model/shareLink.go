@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// Filters of the todos shown by a share link, they match the tabs of the todos page
+const (
+	SHARE_LINK_STATUS_ALL       = "all"
+	SHARE_LINK_STATUS_ACTIVE    = "active"
+	SHARE_LINK_STATUS_COMPLETED = "completed"
+)
+
+// ShareLink shows a filtered, read-only list of the todos of a space to anyone with the link. Only the hash of
+// the token is stored, the link is shown once on creation.
+type ShareLink struct {
+	gorm.Model
+	TokenHash      string `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	AccountID      uint   `gorm:"not null;index" json:"fkAccountId"`
+	OrganizationID *uint  `gorm:"index" json:"fkOrganizationId"`
+	Name           string `gorm:"not null" json:"name"`
+	Status         string `gorm:"not null;size:16" json:"status"`
+	Search         string `gorm:"" json:"search"`
+	// PasswordHash is empty for links without password
+	PasswordHash string    `gorm:"" json:"-"`
+	ExpiresAt    null.Time `gorm:"" json:"expiresAt" swaggertype:"string" format:"date-time"`
+	RevokedAt    null.Time `gorm:"" json:"revokedAt" swaggertype:"string" format:"date-time"`
+	Views        int       `gorm:"default:0" json:"views"`
+	LastViewedAt null.Time `gorm:"" json:"lastViewedAt" swaggertype:"string" format:"date-time"`
+}
+
+// ShareLinkAttempt records a password entered for a link. They are throttled per link and IP like logins, but are
+// kept apart from the login attempts so neither counts against the other.
+type ShareLinkAttempt struct {
+	gorm.Model
+	ShareLinkID uint   `gorm:"index;not null" json:"fkShareLinkId"`
+	IP          string `gorm:"index;not null" json:"ip"`
+	Success     bool   `gorm:"default:false" json:"success"`
+	Reason      string `gorm:"" json:"reason"`
+}
+
+// HasPassword reports whether the link is password protected
+func (l *ShareLink) HasPassword() bool {
+	return l.PasswordHash != ""
+}
+
+// SearchCondition is the LIKE pattern of the todos matching the search of the link, it has to be used with ESCAPE '!'
+func (l *ShareLink) SearchCondition() string {
+	return "%" + likeEscaper.Replace(l.Search) + "%"
+}
+
+// Active reports whether the link is neither revoked nor expired
+func (l *ShareLink) Active() bool {
+	return !l.RevokedAt.Valid && (!l.ExpiresAt.Valid || time.Now().Before(l.ExpiresAt.Time))
+}
+
+// Space returns the space the todos of the link are taken from
+func (l *ShareLink) Space() Space {
+	space := Space{AccountID: l.AccountID}
+	if l.OrganizationID != nil {
+		space.OrganizationID = *l.OrganizationID
+	}
+
+	return space
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// Status is a step in the workflow of a space, every todo is in one status of its space. Statuses are ordered by
+// position and todos in a status that counts as done are completed.
+type Status struct {
+	gorm.Model
+	AccountID      uint   `gorm:"not null;index" json:"fkAccountId"`
+	OrganizationID *uint  `gorm:"index" json:"fkOrganizationId"`
+	Name           string `gorm:"not null;size:64" json:"name" validate:"required,min=1,max=64"`
+	Position       int    `gorm:"not null;default:0" json:"position"`
+	Color          string `gorm:"not null;size:7" json:"color" validate:"omitempty,hexcolor"`
+	IsDone         bool   `gorm:"not null;default:false" json:"isDone"`
+	// SeedKey is set on the statuses created from DefaultStatuses, it is unique so a space can't get the defaults
+	// twice. Statuses created later don't have one.
+	SeedKey *string `gorm:"size:96;uniqueIndex" json:"-"`
+}
+
+// StatusSeedKey identifies the default status of the name in the space
+func StatusSeedKey(space Space, name string) string {
+	if space.IsPersonal() {
+		return "account:" + strconv.FormatUint(uint64(space.AccountID), 10) + ":" + name
+	}
+
+	return "organization:" + strconv.FormatUint(uint64(space.OrganizationID), 10) + ":" + name
+}
+
+// StatusTransition allows moving todos from one status to another. Todos in a status without transitions can be
+// moved to every status, a transition to the status itself alone allows no moves.
+type StatusTransition struct {
+	ID           uint `gorm:"primarykey" json:"id"`
+	FromStatusID uint `gorm:"not null;uniqueIndex:idx_status_transition" json:"fkFromStatusId"`
+	ToStatusID   uint `gorm:"not null;uniqueIndex:idx_status_transition" json:"fkToStatusId"`
+}
+
+// DefaultStatuses are created for spaces that don't have a workflow yet
+var DefaultStatuses = []Status{
+	{Name: "Backlog", Position: 0, Color: "#6b7280"},
+	{Name: "In progress", Position: 1, Color: "#2563eb"},
+	{Name: "Review", Position: 2, Color: "#d97706"},
+	{Name: "Done", Position: 3, Color: "#16a34a", IsDone: true},
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// NamePattern matches tag and project names, quick-add recognizes them with it too
+var NamePattern = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+// TAG_MAX_LENGTH keeps the 20 tags of a todo within the size of the column, with their separators
+const TAG_MAX_LENGTH = 50
+
+// likeEscaper escapes the LIKE wildcards, patterns using it have to be used with ESCAPE '!'
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// Tags are the labels of a todo. They are stored as a comma separated list with a leading and a trailing comma,
+// so a single tag matches TagCondition.
+type Tags []string
+
+// NewTags lowercases the tags and drops duplicates
+func NewTags(tags []string) Tags {
+	result := Tags{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+
+	return result
+}
+
+// ValidTag reports whether tag is a valid tag name, tags are named like quick-add names
+func ValidTag(tag string) bool {
+	return NamePattern.MatchString(tag) && utf8.RuneCountInString(tag) <= TAG_MAX_LENGTH
+}
+
+// TagCondition is the LIKE pattern of todos with the tag, it has to be used with ESCAPE '!'
+func TagCondition(tag string) string {
+	return "%," + likeEscaper.Replace(strings.ToLower(tag)) + ",%"
+}
+
+func (tags Tags) GormDataType() string {
+	return "string"
+}
+
+func (tags Tags) Value() (driver.Value, error) {
+	if len(tags) == 0 {
+		return "", nil
+	}
+
+	return "," + strings.Join(tags, ",") + ",", nil
+}
+
+func (tags *Tags) Scan(value any) error {
+	var stored string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("unsupported tags value %T", value)
+	}
+
+	*tags = Tags{}
+	for _, tag := range strings.Split(stored, ",") {
+		if tag != "" {
+			*tags = append(*tags, tag)
+		}
+	}
+
+	return nil
+}
+
+// MarshalJSON writes todos without tags as an empty list
+func (tags Tags) MarshalJSON() ([]byte, error) {
+	if tags == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal([]string(tags))
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+	"gopkg.in/guregu/null.v4/zero"
+	"gorm.io/gorm"
+)
+
+// TodoTemplate is a reusable todo tree of a space, e.g. a release checklist. It is captured from an existing todo
+// with its subitems and instantiated with a base date the due offsets of its items are relative to.
+type TodoTemplate struct {
+	gorm.Model
+	AccountID      uint   `gorm:"not null;index" json:"fkAccountId"`
+	OrganizationID *uint  `gorm:"index" json:"fkOrganizationId"`
+	Name           string `gorm:"not null;size:128" json:"name"`
+
+	// Items are ordered by position, the first one is the root of the tree
+	Items []TodoTemplateItem `gorm:"foreignKey:TemplateID" json:"items"`
+}
+
+// TodoTemplateItem is a todo of a template, ParentID is the item it is a subitem of
+type TodoTemplateItem struct {
+	ID               uint     `gorm:"primarykey" json:"id"`
+	TemplateID       uint     `gorm:"not null;index" json:"fkTemplateId"`
+	ParentID         *uint    `gorm:"" json:"fkParentId"`
+	Position         int      `gorm:"not null;default:0" json:"position"`
+	Title            string   `gorm:"not null" json:"title"`
+	Description      string   `gorm:"" json:"description"`
+	Priority         string   `gorm:"size:16;not null;default:'none'" json:"priority"`
+	Tags             Tags     `gorm:"size:1024;not null;default:''" json:"tags" swaggertype:"array,string"`
+	EstimatedMinutes null.Int `gorm:"" json:"estimatedMinutes" swaggertype:"integer"`
+	// DueOffsetMinutes is the due date relative to the base date, items without it get no due date
+	DueOffsetMinutes null.Int `gorm:"" json:"dueOffsetMinutes" swaggertype:"integer"`
+}
+
+// TemplateBase is the time the due offsets of a template captured from the todo are relative to: its due date or,
+// without one, its creation
+func TemplateBase(root *Todo) time.Time {
+	if root.DueAt.Valid {
+		return root.DueAt.Time
+	}
+
+	return root.CreatedAt
+}
+
+// NewTemplateItem captures the todo as an item, its due date becomes an offset to base
+func NewTemplateItem(todo *Todo, base time.Time) TodoTemplateItem {
+	item := TodoTemplateItem{
+		Title:            todo.Title.String,
+		Description:      todo.Description.String,
+		Priority:         todo.Priority,
+		Tags:             NewTags(todo.Tags),
+		EstimatedMinutes: todo.EstimatedMinutes,
+	}
+	if todo.DueAt.Valid {
+		item.DueOffsetMinutes = null.IntFrom(int64(todo.DueAt.Time.Sub(base).Round(time.Minute) / time.Minute))
+	}
+
+	return item
+}
+
+// Todo returns a new todo of the item with its due date relative to base
+func (item *TodoTemplateItem) Todo(base time.Time) *Todo {
+	todo := &Todo{}
+	todo.New(Todo{
+		Title:            zero.StringFrom(item.Title),
+		Description:      zero.StringFrom(item.Description),
+		Priority:         item.Priority,
+		Tags:             item.Tags,
+		EstimatedMinutes: item.EstimatedMinutes,
+	})
+	if item.DueOffsetMinutes.Valid {
+		todo.DueAt = null.TimeFrom(base.Add(time.Duration(item.DueOffsetMinutes.Int64) * time.Minute))
+	}
+
+	return todo
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// TimeEntry is time an account spent on a todo, either tracked with a timer or entered manually. The entry of a
+// running timer has no EndedAt yet, an account runs at most one timer at a time.
+type TimeEntry struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	TodoID    uint      `gorm:"not null;index" json:"fkTodoId"`
+	AccountID uint      `gorm:"not null;index" json:"fkAccountId"`
+	StartedAt time.Time `gorm:"not null;index" json:"startedAt"`
+	EndedAt   null.Time `gorm:"index" json:"endedAt" swaggertype:"string" format:"date-time"`
+	Note      string    `gorm:"size:255" json:"note"`
+	// RunningAccountID is the account of a running timer and unset once it stops, it is unique so concurrent
+	// starts can't leave an account with two running timers
+	RunningAccountID *uint     `gorm:"uniqueIndex" json:"-"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+
+	Account *Account `json:"account,omitempty"`
+	Todo    *Todo    `json:"todo,omitempty"`
+}
+
+// Start makes the entry the running timer of its account
+func (entry *TimeEntry) Start(now time.Time) {
+	accountID := entry.AccountID
+	entry.StartedAt = now
+	entry.EndedAt = null.Time{}
+	entry.RunningAccountID = &accountID
+}
+
+// Stop ends the running timer of the entry
+func (entry *TimeEntry) Stop(now time.Time) {
+	entry.EndedAt = null.TimeFrom(now)
+	entry.RunningAccountID = nil
+}
+
+// Running reports whether the entry belongs to a timer that was not stopped yet
+func (entry *TimeEntry) Running() bool {
+	return !entry.EndedAt.Valid
+}
+
+// Duration returns the tracked time, running timers count until now
+func (entry *TimeEntry) Duration(now time.Time) time.Duration {
+	end := now
+	if entry.EndedAt.Valid {
+		end = entry.EndedAt.Time
+	}
+	if end.Before(entry.StartedAt) {
+		return 0
+	}
+
+	return end.Sub(entry.StartedAt)
+}
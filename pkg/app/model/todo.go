@@ -2,6 +2,9 @@ package model
 
 import (
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/guregu/null.v4"
@@ -15,9 +18,35 @@ type Todo struct {
 	Description zero.String `gorm:"" json:"description" x-search:"true" swaggertype:"string"`
 	Completed   bool        `gorm:"default:false" json:"completed"`
 	CompletedAt null.Time   `gorm:"" json:"completedAt" swaggertype:"string" format:"date-time"`
+	DueAt       null.Time   `gorm:"index" json:"dueAt" swaggertype:"string" format:"date-time"`
+	// Priority is one of TodoPriorities, empty values are stored as none
+	Priority string `gorm:"size:16;not null;default:'none';index" json:"priority" validate:"omitempty,priority"`
+	Tags     Tags   `gorm:"size:1024;not null;default:''" json:"tags" swaggertype:"array,string" validate:"max=20,dive,tag"`
+	// EstimatedMinutes is the expected effort, compared to the time tracked in TimeEntry
+	EstimatedMinutes null.Int `gorm:"" json:"estimatedMinutes" swaggertype:"integer" validate:"omitempty,min=0"`
+	// StatusID is the step of the todo in the workflow of its space. It is changed through the status endpoint,
+	// completing or reopening a todo moves it to the first done or open status.
+	StatusID *uint `gorm:"index" json:"fkStatusId"`
+	// Position is the rank key of the todo in the manual order of its space, see package rank. It is changed
+	// through the move endpoint only.
+	Position string `gorm:"size:255;not null;default:'';index" json:"position"`
+	// ArchivedAt hides a todo from the default lists, it is changed through the archive endpoints only
+	ArchivedAt null.Time `gorm:"index" json:"archivedAt" swaggertype:"string" format:"date-time"`
 
 	AccountID uint `gorm:"not null" json:"fkAccountId"`
 	// Account   Account
+
+	// OrganizationID is set for todos of an organization, personal todos only belong to the account
+	OrganizationID *uint `gorm:"index" json:"fkOrganizationId"`
+	// AssigneeID is an account with access to the todo, it is changed through the assignee endpoint only
+	AssigneeID *uint `gorm:"index" json:"fkAssigneeId"`
+	// ParentID makes the todo a subitem of another todo of its space, it is set on creation only
+	ParentID *uint `gorm:"index" json:"fkParentId"`
+
+	// IsBlocked is set for todos with open blockers when they are returned, see TodoDependency
+	IsBlocked bool `gorm:"-" json:"isBlocked"`
+	// TimerRunning is set when the account the todo is returned to tracks time on it
+	TimerRunning bool `gorm:"-" json:"timerRunning"`
 }
 
 func (todo *Todo) New(remote Todo) {
@@ -25,6 +54,36 @@ func (todo *Todo) New(remote Todo) {
 	todo.Description = remote.Description
 	todo.Completed = remote.Completed
 	todo.CompletedAt = remote.CompletedAt
+	todo.DueAt = remote.DueAt
+	todo.Priority = remote.Priority
+	if todo.Priority == "" {
+		todo.Priority = TODO_PRIORITY_NONE
+	}
+	todo.EstimatedMinutes = remote.EstimatedMinutes
+	todo.Tags = NewTags(remote.Tags)
+}
+
+// TODO_POSITION_MAX_LENGTH is the longest rank key, moves that need longer keys rebalance the space first
+const TODO_POSITION_MAX_LENGTH = 255
+
+// Space returns the space the todo belongs to
+func (todo *Todo) Space() Space {
+	space := Space{AccountID: todo.AccountID}
+	if todo.OrganizationID != nil {
+		space.OrganizationID = *todo.OrganizationID
+	}
+
+	return space
+}
+
+// PurgeAt is the time a deleted todo is purged from the trash
+func (todo *Todo) PurgeAt(retention time.Duration) time.Time {
+	return todo.DeletedAt.Time.Add(retention)
+}
+
+// SortColumns are the columns todo lists can be ordered by, priority and smart are handled by OrderBy
+func (todo *Todo) SortColumns() []string {
+	return []string{"id", "title", "completed", "completed_at", "due_at", "estimated_minutes", "position", "archived_at", "created_at", "updated_at"}
 }
 
 type todoColumn struct {
@@ -82,7 +141,90 @@ var TodoColumns = []todoColumn{
 			if !todo.CompletedAt.Valid {
 				return "", nil
 			}
-			return todo.CompletedAt.ValueOrZero().String(), nil
+			return todo.CompletedAt.ValueOrZero().Format(time.RFC3339Nano), nil
+		},
+	},
+	{
+		Name: "due_at",
+		UnmarshalValue: func(todo *Todo, val string) error {
+			if val == "" {
+				todo.DueAt.Valid = false
+				return nil
+			}
+			t, err := time.Parse(time.RFC3339, val)
+			if err != nil {
+				return err
+			}
+			todo.DueAt = null.TimeFrom(t)
+			return nil
+		},
+		MarshalValue: func(todo *Todo) (string, error) {
+			if !todo.DueAt.Valid {
+				return "", nil
+			}
+			return todo.DueAt.ValueOrZero().Format(time.RFC3339Nano), nil
+		},
+	},
+	{
+		// Priority none is written as an empty value
+		Name: "priority",
+		UnmarshalValue: func(todo *Todo, val string) error {
+			if val == "" {
+				todo.Priority = TODO_PRIORITY_NONE
+				return nil
+			}
+			if !ValidPriority(val) {
+				return fmt.Errorf("unknown priority %q", val)
+			}
+			todo.Priority = val
+			return nil
+		},
+		MarshalValue: func(todo *Todo) (string, error) {
+			if todo.Priority == TODO_PRIORITY_NONE {
+				return "", nil
+			}
+			return todo.Priority, nil
+		},
+	},
+	{
+		Name: "estimated_minutes",
+		UnmarshalValue: func(todo *Todo, val string) error {
+			if val == "" {
+				todo.EstimatedMinutes.Valid = false
+				return nil
+			}
+			minutes, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return err
+			}
+			if minutes < 0 {
+				return fmt.Errorf("negative estimate %d", minutes)
+			}
+			todo.EstimatedMinutes = null.IntFrom(minutes)
+			return nil
+		},
+		MarshalValue: func(todo *Todo) (string, error) {
+			if !todo.EstimatedMinutes.Valid {
+				return "", nil
+			}
+			return strconv.FormatInt(todo.EstimatedMinutes.Int64, 10), nil
+		},
+	},
+	{
+		// Tags are separated by spaces
+		Name: "tags",
+		UnmarshalValue: func(todo *Todo, val string) error {
+			tags := NewTags(strings.Fields(val))
+			for _, tag := range tags {
+				if !ValidTag(tag) {
+					return fmt.Errorf("invalid tag %q", tag)
+				}
+			}
+			todo.Tags = tags
+			return nil
+		},
+		MarshalValue: func(todo *Todo) (string, error) {
+			return strings.Join(todo.Tags, " "), nil
 		},
 	},
 }
@@ -100,22 +242,30 @@ func (c *Todo) MarshalRecord() ([]string, error) {
 	return record, nil
 }
 
-// checkCarColumns returns an error if record doesn't have the right number
-// of columns.
-func checkColumns(record []string) error {
-	if got, want := len(record), len(TodoColumns); got != want {
-		return fmt.Errorf("bad number of columns: got=%d want=%d", got, want)
+// ParseTodoHeader returns the column names of a CSV header for UnmarshalNamedRecord. Names are matched case
+// insensitively, a header without a title column is rejected.
+func ParseTodoHeader(header []string) ([]string, error) {
+	names := make([]string, len(header))
+	for i, name := range header {
+		names[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
 	}
-	return nil
+	if !slices.Contains(names, "title") {
+		return nil, fmt.Errorf("missing column %q", "title")
+	}
+
+	return names, nil
 }
 
-// UnmarshalRecord decodes the given car record or returns an error.
-func (c *Todo) UnmarshalRecord(record []string) error {
-	if err := checkColumns(record); err != nil {
-		return err
-	}
-	for i, col := range TodoColumns {
-		if err := col.UnmarshalValue(c, record[i]); err != nil {
+// UnmarshalNamedRecord decodes a record with the columns named by the header (see ParseTodoHeader). Files of
+// older versions with fewer columns can be imported: missing columns are decoded like empty fields and unknown
+// ones are ignored.
+func (c *Todo) UnmarshalNamedRecord(header []string, record []string) error {
+	for _, col := range TodoColumns {
+		val := ""
+		if i := slices.Index(header, col.Name); i >= 0 && i < len(record) {
+			val = record[i]
+		}
+		if err := col.UnmarshalValue(c, val); err != nil {
 			return fmt.Errorf("column=%q: %w", col.Name, err)
 		}
 	}
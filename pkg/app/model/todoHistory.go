@@ -0,0 +1,110 @@
+package model
+
+import (
+	"time"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// Actions recorded in the history of a todo
+const (
+	TODO_HISTORY_CREATED    = "created"
+	TODO_HISTORY_UPDATED    = "updated"
+	TODO_HISTORY_COMPLETED  = "completed"
+	TODO_HISTORY_REOPENED   = "reopened"
+	TODO_HISTORY_DELETED    = "deleted"
+	TODO_HISTORY_RESTORED   = "restored"
+	TODO_HISTORY_REVERTED   = "reverted"
+	TODO_HISTORY_ASSIGNED   = "assigned"
+	TODO_HISTORY_ARCHIVED   = "archived"
+	TODO_HISTORY_UNARCHIVED = "unarchived"
+	TODO_HISTORY_MOVED      = "moved"
+)
+
+// TodoHistory is an entry in the history of a todo. All entries of one change share the revision, a number
+// counting up per todo, and a revision changes every field at most once. Field, From and To describe a changed
+// value: the columns of TodoColumns, assignee_id, archived_at or status, the name of the status. Only the columns of
+// TodoColumns are restored by RestoreRevision.
+type TodoHistory struct {
+	ID        uint        `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time   `json:"createdAt"`
+	TodoID    uint        `gorm:"not null;index;uniqueIndex:idx_todo_history_revision" json:"fkTodoId"`
+	Revision  uint        `gorm:"not null;default:0;uniqueIndex:idx_todo_history_revision" json:"revision"`
+	ActorID   uint        `gorm:"not null" json:"fkActorId"`
+	Action    string      `gorm:"not null;size:32" json:"action"`
+	Field     string      `gorm:"size:64;uniqueIndex:idx_todo_history_revision" json:"field"`
+	From      null.String `json:"from" swaggertype:"string"`
+	To        null.String `json:"to" swaggertype:"string"`
+
+	Actor *Account `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+}
+
+// TodoChange is a changed field of a todo. From is null for new todos.
+type TodoChange struct {
+	Field string
+	From  null.String
+	To    null.String
+}
+
+// DiffTodos compares the fields of TodoColumns, before is nil for new todos
+func DiffTodos(before *Todo, after *Todo) ([]TodoChange, error) {
+	changes := []TodoChange{}
+
+	for _, col := range TodoColumns {
+		to, err := col.MarshalValue(after)
+		if err != nil {
+			return nil, err
+		}
+
+		if before == nil {
+			if to != "" && to != "false" {
+				changes = append(changes, TodoChange{Field: col.Name, To: null.StringFrom(to)})
+			}
+			continue
+		}
+
+		from, err := col.MarshalValue(before)
+		if err != nil {
+			return nil, err
+		}
+		if from != to {
+			changes = append(changes, TodoChange{Field: col.Name, From: null.StringFrom(from), To: null.StringFrom(to)})
+		}
+	}
+
+	return changes, nil
+}
+
+// RestoreRevision sets the fields of TodoColumns to their values after the revision. history has to contain all
+// entries of the todo, oldest first. Values a field had before its first recorded change are taken from the From of
+// that change, fields that never changed are left as they are.
+func (todo *Todo) RestoreRevision(history []TodoHistory, revision uint) error {
+	for _, col := range TodoColumns {
+		var value null.String
+		for _, entry := range history {
+			if entry.Field != col.Name {
+				continue
+			}
+			if entry.Revision <= revision {
+				value = entry.To
+				if !value.Valid {
+					value = null.StringFrom("")
+				}
+				continue
+			}
+			if !value.Valid {
+				value = entry.From
+			}
+			break
+		}
+
+		if !value.Valid {
+			continue
+		}
+		if err := col.UnmarshalValue(todo, value.String); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -65,3 +65,94 @@ func TestTodoModelWriteRemote(t *testing.T) {
 		}
 	})
 }
+
+func TestTodoModelPriorityRecord(t *testing.T) {
+	todo := model.Todo{}
+	todo.New(model.Todo{Title: zero.StringFrom("Title")})
+	if todo.Priority != model.TODO_PRIORITY_NONE {
+		t.Errorf("Expected Priority to be %v, got %v", model.TODO_PRIORITY_NONE, todo.Priority)
+	}
+
+	todo.Priority = model.TODO_PRIORITY_HIGH
+	record, err := todo.MarshalRecord()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := make([]string, len(model.TodoColumns))
+	for i, column := range model.TodoColumns {
+		header[i] = column.Name
+	}
+
+	decoded := model.Todo{}
+	if err := decoded.UnmarshalNamedRecord(header, record); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Priority != model.TODO_PRIORITY_HIGH {
+		t.Errorf("Expected Priority to be %v, got %v", model.TODO_PRIORITY_HIGH, decoded.Priority)
+	}
+
+	for i, column := range model.TodoColumns {
+		if column.Name == "priority" {
+			record[i] = "critical"
+		}
+	}
+	if err := decoded.UnmarshalNamedRecord(header, record); err == nil {
+		t.Errorf("Expected unknown priorities to be rejected")
+	}
+}
+
+func TestTodoModelTags(t *testing.T) {
+	tags := model.NewTags([]string{"Errands", " home ", "errands", ""})
+	if len(tags) != 2 || tags[0] != "errands" || tags[1] != "home" {
+		t.Fatalf("Expected lowercased tags without duplicates, got %v", tags)
+	}
+
+	stored, _ := tags.Value()
+	if stored != ",errands,home," {
+		t.Errorf("Expected tags to be stored between commas, got %v", stored)
+	}
+
+	scanned := model.Tags{}
+	if err := scanned.Scan(stored); err != nil {
+		t.Fatal(err)
+	}
+	if len(scanned) != 2 || scanned[1] != "home" {
+		t.Errorf("Expected the stored tags, got %v", scanned)
+	}
+}
+
+func TestTodoModelNamedRecord(t *testing.T) {
+	t.Run("legacy columns", func(t *testing.T) {
+		header, err := model.ParseTodoHeader([]string{"title", "description", "completed", "completed_at"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		todo := model.Todo{}
+		if err := todo.UnmarshalNamedRecord(header, []string{"Title", "Description", "true", ""}); err != nil {
+			t.Fatal(err)
+		}
+		if todo.Title.String != "Title" || !todo.Completed || todo.Priority != model.TODO_PRIORITY_NONE || todo.DueAt.Valid {
+			t.Errorf("Expected the legacy fields and defaults for the rest, got %+v", todo)
+		}
+	})
+
+	t.Run("columns by name", func(t *testing.T) {
+		header, _ := model.ParseTodoHeader([]string{"\ufeffPriority", " Title ", "unknown"})
+
+		todo := model.Todo{}
+		if err := todo.UnmarshalNamedRecord(header, []string{"high", "Title", "ignored"}); err != nil {
+			t.Fatal(err)
+		}
+		if todo.Title.String != "Title" || todo.Priority != model.TODO_PRIORITY_HIGH {
+			t.Errorf("Expected the columns to be matched by name, got %+v", todo)
+		}
+	})
+
+	t.Run("without title", func(t *testing.T) {
+		if _, err := model.ParseTodoHeader([]string{"description"}); err == nil {
+			t.Errorf("Expected headers without title to fail")
+		}
+	})
+}
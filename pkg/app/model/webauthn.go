@@ -0,0 +1,90 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+const (
+	WEBAUTHN_CEREMONY_REGISTRATION = "registration"
+	WEBAUTHN_CEREMONY_LOGIN        = "login"
+)
+
+// WebAuthnCredential is a passkey registered by an account
+type WebAuthnCredential struct {
+	gorm.Model
+	AccountID       uint      `gorm:"not null;index" json:"fkAccountId"`
+	Name            string    `gorm:"" json:"name"`
+	CredentialID    []byte    `gorm:"uniqueIndex;size:255;not null" json:"-"`
+	PublicKey       []byte    `gorm:"not null" json:"-"`
+	AttestationType string    `gorm:"" json:"attestationType"`
+	AAGUID          []byte    `gorm:"" json:"-"`
+	SignCount       uint32    `gorm:"default:0" json:"signCount"`
+	Transports      string    `gorm:"" json:"transports"`
+	BackupEligible  bool      `gorm:"default:false" json:"backupEligible"`
+	BackupState     bool      `gorm:"default:false" json:"backupState"`
+	LastUsedAt      null.Time `gorm:"" json:"lastUsedAt" swaggertype:"string" format:"date-time"`
+}
+
+// WebAuthnSession stores the challenge of a running registration or login ceremony.
+// Sessions are single use and are removed as soon as the ceremony is finished.
+type WebAuthnSession struct {
+	gorm.Model
+	Challenge string `gorm:"uniqueIndex;size:255;not null"`
+	// AccountID is 0 for discoverable (usernameless) logins
+	AccountID uint      `gorm:"index"`
+	Ceremony  string    `gorm:"not null"`
+	Data      []byte    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
+
+// NewWebAuthnCredential converts a verified webauthn credential into its database representation
+func NewWebAuthnCredential(accountID uint, name string, credential *webauthn.Credential) *WebAuthnCredential {
+	transports := make([]string, len(credential.Transport))
+	for i, transport := range credential.Transport {
+		transports[i] = string(transport)
+	}
+
+	return &WebAuthnCredential{
+		AccountID:       accountID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      strings.Join(transports, ","),
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+}
+
+// Credential converts the stored credential back into the type used by the webauthn library
+func (c *WebAuthnCredential) Credential() webauthn.Credential {
+	transports := []protocol.AuthenticatorTransport{}
+	if c.Transports != "" {
+		for _, transport := range strings.Split(c.Transports, ",") {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+	}
+
+	return webauthn.Credential{
+		ID:              c.CredentialID,
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: c.BackupEligible,
+			BackupState:    c.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    c.AAGUID,
+			SignCount: c.SignCount,
+		},
+	}
+}
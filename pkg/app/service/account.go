@@ -1,8 +1,13 @@
 package service
 
 import (
+	"errors"
+	"strings"
+
+	"github.com/nleiva/go-todo-api/config"
 	"github.com/nleiva/go-todo-api/pkg/app/model"
 	"github.com/nleiva/go-todo-api/pkg/app/types/pagination"
+	"github.com/nleiva/go-todo-api/pkg/permission"
 	"gorm.io/gorm"
 )
 
@@ -27,6 +32,11 @@ type IAccountService interface {
 	FindAccountByEmail(dest any, email string) *gorm.DB
 
 	CreateAccount(account *model.Account) *gorm.DB
+	UpdateAccountPassword(account *model.Account, hash string) *gorm.DB
+	ChangeAccountPassword(account *model.Account, hash string) *gorm.DB
+	UpdateAutoArchive(account *model.Account, days uint) *gorm.DB
+	UpdateTimezone(account *model.Account, timezone string) *gorm.DB
+	FindOrProvisionAccount(email string, provision bool) (*model.Account, error)
 }
 
 // TODO: Maybe cleanup the base model call
@@ -58,3 +68,67 @@ func (as *AccountService) FindAccountByEmail(dest any, email string) *gorm.DB {
 func (as *AccountService) CreateAccount(account *model.Account) *gorm.DB {
 	return as.db.Model(&model.Account{}).Create(account)
 }
+
+func (as *AccountService) UpdateAccountPassword(account *model.Account, hash string) *gorm.DB {
+	account.Password = hash
+	return as.db.Model(account).Update("password", hash)
+}
+
+// ChangeAccountPassword stores a password chosen by the user and rotates the token secret,
+// so refresh tokens issued before the change stop working
+func (as *AccountService) ChangeAccountPassword(account *model.Account, hash string) *gorm.DB {
+	account.Password = hash
+	account.TokenSecret = model.GenerateSecretToken()
+
+	return as.db.Model(account).Updates(map[string]any{
+		"password":     account.Password,
+		"token_secret": account.TokenSecret,
+	})
+}
+
+// UpdateAutoArchive changes after how many days completed todos of the account are archived, 0 turns it off
+func (as *AccountService) UpdateAutoArchive(account *model.Account, days uint) *gorm.DB {
+	account.AutoArchiveDays = days
+	return as.db.Model(account).Update("auto_archive_days", days)
+}
+
+// UpdateTimezone changes the timezone quick-add dates of the account are relative to
+func (as *AccountService) UpdateTimezone(account *model.Account, timezone string) *gorm.DB {
+	account.Timezone = timezone
+	return as.db.Model(account).Update("timezone", timezone)
+}
+
+// provisioningAllowed reports whether sign-ins through a trusted source may create accounts. Like sign-up they
+// follow REGISTRATION_MODE: invite-only and closed instances only let existing accounts in.
+func provisioningAllowed() bool {
+	return config.REGISTRATION_MODE == model.REGISTRATION_OPEN
+}
+
+// FindOrProvisionAccount returns the account of an email asserted by a trusted source (the authenticating proxy).
+// Unknown emails get an account without password if provision is set and registration is open, else
+// gorm.ErrRecordNotFound is returned.
+func (as *AccountService) FindOrProvisionAccount(email string, provision bool) (*model.Account, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	account := &model.Account{}
+	err := as.db.Take(account, "LOWER(email) = ?", email).Error
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) || !provision || !provisioningAllowed() {
+		return account, err
+	}
+
+	account = &model.Account{
+		Email:       email,
+		Password:    "",
+		Permission:  permission.ACCOUNTS_READ_OWN,
+		TokenSecret: model.GenerateSecretToken(),
+	}
+	if err := as.db.Create(account).Error; err != nil {
+		// Another request provisioned the account at the same time
+		if retry := as.db.Take(account, "LOWER(email) = ?", email).Error; retry == nil {
+			return account, nil
+		}
+		return nil, err
+	}
+
+	return account, nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/blobstore"
+	"gorm.io/gorm"
+)
+
+// AttachmentService stores the files of todos. Contents are deduplicated by their SHA-256 hash, a blob is
+// removed from the store once no attachment references it anymore.
+// Instances of this service should be created using the NewAttachmentService function
+type AttachmentService struct {
+	db    *gorm.DB
+	store blobstore.BlobStore
+	// blobLocks serialize storing a blob with counting its references before deleting it, indexed by the first
+	// byte of the hash
+	blobLocks [256]sync.Mutex
+}
+
+func NewAttachmentService(db *gorm.DB, store blobstore.BlobStore) *AttachmentService {
+	return &AttachmentService{
+		db:    db,
+		store: store,
+	}
+}
+
+type IAttachmentService interface {
+	FindAttachments(dest any, todoID uint) *gorm.DB
+	FindAttachment(dest any, id string, todoID uint) *gorm.DB
+	CreateAttachment(ctx context.Context, attachment *model.Attachment, content io.ReadSeeker) error
+	OpenAttachment(ctx context.Context, attachment *model.Attachment, offset int64, length int64) (io.ReadCloser, error)
+	DeleteAttachment(ctx context.Context, attachment *model.Attachment) error
+	DeleteTodoAttachments(ctx context.Context, todoIDs ...uint) error
+}
+
+func (as *AttachmentService) FindAttachments(dest any, todoID uint) *gorm.DB {
+	return as.db.Where("todo_id = ?", todoID).Order("id").Find(dest)
+}
+
+func (as *AttachmentService) FindAttachment(dest any, id string, todoID uint) *gorm.DB {
+	return as.db.Where("id = ? AND todo_id = ?", id, todoID).Take(dest)
+}
+
+// blobLock returns the lock of the blob with the hash
+func (as *AttachmentService) blobLock(hash string) *sync.Mutex {
+	if len(hash) < 2 {
+		return &as.blobLocks[0]
+	}
+
+	index, err := strconv.ParseUint(hash[:2], 16, 8)
+	if err != nil {
+		return &as.blobLocks[0]
+	}
+
+	return &as.blobLocks[index]
+}
+
+// CreateAttachment hashes the content and stores it under the hash. Attachments with the same content share the
+// blob, it is stored again anyway as puts are idempotent and a blob being deleted can't be relied on. Hash and
+// Size of the attachment are set from the content.
+func (as *AttachmentService) CreateAttachment(ctx context.Context, attachment *model.Attachment, content io.ReadSeeker) error {
+	hash := sha256.New()
+	size, err := io.Copy(hash, content)
+	if err != nil {
+		return err
+	}
+	attachment.Hash = hex.EncodeToString(hash.Sum(nil))
+	attachment.Size = size
+
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	// The reference is created before the lock is released, deleteUnreferenced can't remove the blob in between
+	lock := as.blobLock(attachment.Hash)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := as.store.Put(ctx, attachment.Hash, content, size, attachment.ContentType); err != nil {
+		return err
+	}
+
+	return as.db.Create(attachment).Error
+}
+
+// OpenAttachment reads length bytes of the content from offset, a negative length reads to the end
+func (as *AttachmentService) OpenAttachment(ctx context.Context, attachment *model.Attachment, offset int64, length int64) (io.ReadCloser, error) {
+	return as.store.Get(ctx, attachment.Hash, offset, length)
+}
+
+func (as *AttachmentService) DeleteAttachment(ctx context.Context, attachment *model.Attachment) error {
+	if err := as.db.Unscoped().Delete(attachment).Error; err != nil {
+		return err
+	}
+
+	return as.deleteUnreferenced(ctx, attachment.Hash)
+}
+
+// DeleteTodoAttachments deletes all attachments of the todos together with blobs no other todo uses
+func (as *AttachmentService) DeleteTodoAttachments(ctx context.Context, todoIDs ...uint) error {
+	if len(todoIDs) == 0 {
+		return nil
+	}
+
+	hashes := []string{}
+	if err := as.db.Model(&model.Attachment{}).Where("todo_id IN ?", todoIDs).Distinct().Pluck("hash", &hashes).Error; err != nil {
+		return err
+	}
+
+	if err := as.db.Unscoped().Where("todo_id IN ?", todoIDs).Delete(&model.Attachment{}).Error; err != nil {
+		return err
+	}
+
+	return as.deleteUnreferenced(ctx, hashes...)
+}
+
+func (as *AttachmentService) deleteUnreferenced(ctx context.Context, hashes ...string) error {
+	for _, hash := range hashes {
+		if err := as.deleteIfUnreferenced(ctx, hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (as *AttachmentService) deleteIfUnreferenced(ctx context.Context, hash string) error {
+	lock := as.blobLock(hash)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var references int64
+	if err := as.db.Model(&model.Attachment{}).Where("hash = ?", hash).Count(&references).Error; err != nil {
+		return err
+	}
+
+	if references > 0 {
+		return nil
+	}
+
+	return as.store.Delete(ctx, hash)
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"gorm.io/gorm"
+)
+
+// CommentService stores the discussions of todos
+// Instances of this service should be created using the NewCommentService function
+type CommentService struct {
+	db *gorm.DB
+}
+
+func NewCommentService(db *gorm.DB) *CommentService {
+	return &CommentService{
+		db: db,
+	}
+}
+
+type ICommentService interface {
+	FindComments(dest any, todoID uint) *gorm.DB
+	FindComment(dest any, id string, todoID uint) *gorm.DB
+	CreateComment(comment *model.Comment) *gorm.DB
+	UpdateComment(comment *model.Comment) *gorm.DB
+	DeleteComment(comment *model.Comment) *gorm.DB
+}
+
+// FindComments lists the comments of the todo with their authors, oldest first
+func (cs *CommentService) FindComments(dest any, todoID uint) *gorm.DB {
+	return cs.db.Preload("Account").Where("todo_id = ?", todoID).Order("id").Find(dest)
+}
+
+func (cs *CommentService) FindComment(dest any, id string, todoID uint) *gorm.DB {
+	return cs.db.Preload("Account").Where("id = ? AND todo_id = ?", id, todoID).Take(dest)
+}
+
+func (cs *CommentService) CreateComment(comment *model.Comment) *gorm.DB {
+	return cs.db.Create(comment)
+}
+
+func (cs *CommentService) UpdateComment(comment *model.Comment) *gorm.DB {
+	return cs.db.Model(comment).Update("body", comment.Body)
+}
+
+func (cs *CommentService) DeleteComment(comment *model.Comment) *gorm.DB {
+	return cs.db.Delete(comment)
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"slices"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DependencyService manages the links between todos that block each other
+// Instances of this service should be created using the NewDependencyService function
+//
+// Queries that return todos take a visible condition, usually TodoService.Accessible, so accounts with a shared
+// todo don't learn about the other todos of its space.
+type DependencyService struct {
+	db *gorm.DB
+}
+
+func NewDependencyService(db *gorm.DB) *DependencyService {
+	return &DependencyService{
+		db: db,
+	}
+}
+
+type IDependencyService interface {
+	FindBlockers(dest any, todoID uint, visible *gorm.DB) *gorm.DB
+	FindBlocked(dest any, todoID uint, visible *gorm.DB) *gorm.DB
+	AddDependency(todo *model.Todo, blocker *model.Todo) error
+	RemoveDependency(todoID uint, blockerID uint) error
+	MarkBlocked(todos []model.Todo) error
+	FindGraph(space model.Space, todoID *uint, visible *gorm.DB) ([]model.Todo, []model.TodoDependency, error)
+}
+
+// FindBlockers lists the todos the todo is blocked by
+func (ds *DependencyService) FindBlockers(dest any, todoID uint, visible *gorm.DB) *gorm.DB {
+	return ds.db.Model(&model.Todo{}).
+		Joins("JOIN todo_dependencies ON todo_dependencies.blocked_by_id = todos.id").
+		Where("todo_dependencies.todo_id = ?", todoID).
+		Where(visible).
+		Order("todos.id").
+		Find(dest)
+}
+
+// FindBlocked lists the todos the todo blocks
+func (ds *DependencyService) FindBlocked(dest any, todoID uint, visible *gorm.DB) *gorm.DB {
+	return ds.db.Model(&model.Todo{}).
+		Joins("JOIN todo_dependencies ON todo_dependencies.todo_id = todos.id").
+		Where("todo_dependencies.blocked_by_id = ?", todoID).
+		Where(visible).
+		Order("todos.id").
+		Find(dest)
+}
+
+// AddDependency blocks the todo by another todo of its space. Links that exist already are kept, links that
+// close a cycle are rejected.
+func (ds *DependencyService) AddDependency(todo *model.Todo, blocker *model.Todo) error {
+	if todo.ID == blocker.ID || todo.Space() != blocker.Space() {
+		return &utils.TODO_DEPENDENCY_INVALID
+	}
+
+	return ds.db.Transaction(func(tx *gorm.DB) error {
+		// Both todos stay locked until the link is stored, so links added at the same time in the opposite
+		// direction can't both pass the cycle check. They are locked in id order to avoid deadlocks.
+		locked := []model.Todo{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", []uint{todo.ID, blocker.ID}).Order("id").Find(&locked).Error; err != nil {
+			return err
+		}
+
+		// The new link closes a cycle if the blocker already waits for the todo
+		seen := []uint{blocker.ID}
+		for frontier := seen; len(frontier) > 0; {
+			next := []uint{}
+			if err := tx.Model(&model.TodoDependency{}).Where("todo_id IN ?", frontier).Pluck("blocked_by_id", &next).Error; err != nil {
+				return err
+			}
+
+			frontier = []uint{}
+			for _, id := range next {
+				if id == todo.ID {
+					return &utils.TODO_DEPENDENCY_CYCLE
+				}
+				if !slices.Contains(seen, id) {
+					seen = append(seen, id)
+					frontier = append(frontier, id)
+				}
+			}
+		}
+
+		return tx.Where(&model.TodoDependency{TodoID: todo.ID, BlockedByID: blocker.ID}).
+			FirstOrCreate(&model.TodoDependency{}).Error
+	})
+}
+
+// RemoveDependency unblocks the todo from the blocker, links that don't exist return gorm.ErrRecordNotFound
+func (ds *DependencyService) RemoveDependency(todoID uint, blockerID uint) error {
+	result := ds.db.Where("todo_id = ? AND blocked_by_id = ?", todoID, blockerID).Delete(&model.TodoDependency{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// openBlockers returns the todos with blockers that are neither completed nor deleted
+func openBlockers(tx *gorm.DB, todoIDs []uint) ([]uint, error) {
+	blocked := []uint{}
+	err := tx.Model(&model.TodoDependency{}).
+		Joins("JOIN todos ON todos.id = todo_dependencies.blocked_by_id AND todos.deleted_at IS NULL AND todos.completed = ?", false).
+		Where("todo_dependencies.todo_id IN ?", todoIDs).
+		Distinct().
+		Pluck("todo_dependencies.todo_id", &blocked).Error
+
+	return blocked, err
+}
+
+// MarkBlocked sets IsBlocked of the todos
+func (ds *DependencyService) MarkBlocked(todos []model.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	todoIDs := make([]uint, len(todos))
+	for i, todo := range todos {
+		todoIDs[i] = todo.ID
+	}
+
+	blocked, err := openBlockers(ds.db, todoIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range todos {
+		todos[i].IsBlocked = slices.Contains(blocked, todos[i].ID)
+	}
+
+	return nil
+}
+
+// FindGraph returns the todos and links of the dependency graph of the space, or only the part connected to the
+// todo if todoID is set. Todos that aren't visible are left out together with their links.
+func (ds *DependencyService) FindGraph(space model.Space, todoID *uint, visible *gorm.DB) ([]model.Todo, []model.TodoDependency, error) {
+	inSpace := todosIn(ds.db, space).Select("id").Where("deleted_at IS NULL").Where(visible)
+
+	dependencies := []model.TodoDependency{}
+	err := ds.db.Where("todo_id IN (?) AND blocked_by_id IN (?)", inSpace, inSpace).Order("id").Find(&dependencies).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	todoIDs := []uint{}
+	if todoID != nil {
+		// Walk the links in both directions starting at the todo
+		todoIDs = append(todoIDs, *todoID)
+		for added := true; added; {
+			added = false
+			for _, dependency := range dependencies {
+				hasTodo, hasBlocker := slices.Contains(todoIDs, dependency.TodoID), slices.Contains(todoIDs, dependency.BlockedByID)
+				if hasTodo != hasBlocker {
+					todoIDs = append(todoIDs, dependency.TodoID, dependency.BlockedByID)
+					todoIDs = slices.Compact(slices.Sorted(slices.Values(todoIDs)))
+					added = true
+				}
+			}
+		}
+		dependencies = slices.DeleteFunc(dependencies, func(dependency model.TodoDependency) bool {
+			return !slices.Contains(todoIDs, dependency.TodoID)
+		})
+	} else {
+		for _, dependency := range dependencies {
+			todoIDs = append(todoIDs, dependency.TodoID, dependency.BlockedByID)
+		}
+	}
+
+	todos := []model.Todo{}
+	if len(todoIDs) > 0 {
+		if err := ds.db.Where("id IN ?", todoIDs).Order("id").Find(&todos).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return todos, dependencies, ds.MarkBlocked(todos)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+// InvitationService manages the invitation codes used for invite-only registration
+// Instances of this service should be created using the NewInvitationService function
+type InvitationService struct {
+	db *gorm.DB
+}
+
+func NewInvitationService(db *gorm.DB) *InvitationService {
+	return &InvitationService{
+		db: db,
+	}
+}
+
+type IInvitationService interface {
+	CreateInvitation(invitation *model.Invitation) (code string, err error)
+	FindInvitations(dest any, createdByID uint, all bool) *gorm.DB
+	DeleteInvitationByID(id string, createdByID uint, all bool) *gorm.DB
+
+	CreateAccountWithInvitation(code string, account *model.Account) error
+}
+
+// CreateInvitation generates the code and stores the invitation, the code is returned only here
+func (is *InvitationService) CreateInvitation(invitation *model.Invitation) (string, error) {
+	code := randomToken(18)
+	invitation.CodeHash = hashToken(code)
+
+	if err := is.db.Create(invitation).Error; err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// FindInvitations lists the invitations created by the account, or every invitation if all is set (admins)
+func (is *InvitationService) FindInvitations(dest any, createdByID uint, all bool) *gorm.DB {
+	query := is.db.Model(&model.Invitation{}).Order("created_at desc")
+	if !all {
+		query = query.Where("created_by_id = ?", createdByID)
+	}
+
+	return query.Find(dest)
+}
+
+func (is *InvitationService) DeleteInvitationByID(id string, createdByID uint, all bool) *gorm.DB {
+	query := is.db.Unscoped().Where("id = ?", id)
+	if !all {
+		query = query.Where("created_by_id = ?", createdByID)
+	}
+
+	return query.Delete(&model.Invitation{})
+}
+
+// CreateAccountWithInvitation redeems the code and creates the account with the permission of the invitation.
+// Both happen in one transaction, so a failed registration doesn't use up the invitation.
+func (is *InvitationService) CreateAccountWithInvitation(code string, account *model.Account) error {
+	return is.db.Transaction(func(tx *gorm.DB) error {
+		invitation := &model.Invitation{}
+		if err := tx.Take(invitation, "code_hash = ?", hashToken(code)).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &utils.INVITATION_INVALID
+			}
+			return err
+		}
+
+		// The condition makes the increment safe against concurrent registrations with the last use
+		result := tx.Model(invitation).
+			Where("uses < max_uses AND expires_at > ?", time.Now()).
+			UpdateColumn("uses", gorm.Expr("uses + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return &utils.INVITATION_INVALID
+		}
+
+		account.Permission = invitation.Permission
+
+		return tx.Create(account).Error
+	})
+}
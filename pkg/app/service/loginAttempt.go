@@ -0,0 +1,115 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"gorm.io/gorm"
+)
+
+// LoginAttemptService records password logins and decides when an email or IP has to be throttled
+// Instances of this service should be created using the NewLoginAttemptService function
+type LoginAttemptService struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptService(db *gorm.DB) *LoginAttemptService {
+	return &LoginAttemptService{
+		db: db,
+	}
+}
+
+type ILoginAttemptService interface {
+	RetryAfter(email string, ip string) (time.Duration, error)
+	RecordAttempt(attempt *model.LoginAttempt) *gorm.DB
+}
+
+// NormalizeEmail makes sure throttling can't be bypassed by changing the case of the email
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// RetryAfter returns how long the caller has to wait before the next login attempt for the email and IP is accepted.
+// Zero means the attempt may go ahead.
+func (ls *LoginAttemptService) RetryAfter(email string, ip string) (time.Duration, error) {
+	byEmail, err := retryAfter(ls.db, &model.LoginAttempt{}, "email = ?", NormalizeEmail(email), config.LOGIN_MAX_FAILURES, true)
+	if err != nil {
+		return 0, err
+	}
+
+	// A successful login doesn't reset the IP counter, else an attacker could reset it with their own account
+	byIP, err := retryAfter(ls.db, &model.LoginAttempt{}, "ip = ?", ip, config.LOGIN_MAX_FAILURES_PER_IP, false)
+	if err != nil {
+		return 0, err
+	}
+
+	return max(byEmail, byIP), nil
+}
+
+func (ls *LoginAttemptService) RecordAttempt(attempt *model.LoginAttempt) *gorm.DB {
+	attempt.Email = NormalizeEmail(attempt.Email)
+
+	return ls.db.Create(attempt)
+}
+
+// retryAfter counts the failures of the attempts table inside the failure window (optionally only those after the
+// last success). Attempts rejected by the throttle itself are not counted so hammering doesn't extend the lockout forever.
+func retryAfter(db *gorm.DB, attempts any, condition string, value any, maxFailures int, resetOnSuccess bool) (time.Duration, error) {
+	now := time.Now()
+	since := now.Add(-config.LOGIN_FAILURE_WINDOW)
+
+	if resetOnSuccess {
+		lastSuccess := []time.Time{}
+		err := db.Model(attempts).Where(condition, value).Where("success = ? AND created_at > ?", true, since).Order("created_at desc").Limit(1).Pluck("created_at", &lastSuccess).Error
+		if err != nil {
+			return 0, err
+		}
+		if len(lastSuccess) > 0 {
+			since = lastSuccess[0]
+		}
+	}
+
+	failures := func() *gorm.DB {
+		return db.Model(attempts).
+			Where(condition, value).
+			Where("success = ? AND reason <> ? AND created_at > ?", false, model.LOGIN_FAILURE_THROTTLED, since)
+	}
+
+	var count int64
+	if err := failures().Count(&count).Error; err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	lastFailure := []time.Time{}
+	if err := failures().Order("created_at desc").Limit(1).Pluck("created_at", &lastFailure).Error; err != nil {
+		return 0, err
+	}
+
+	return ThrottleDelay(int(count), maxFailures, lastFailure[0], now), nil
+}
+
+// ThrottleDelay returns the remaining wait time after the given amount of consecutive failures.
+// The delay doubles with every failure and turns into a lockout once maxFailures is reached.
+func ThrottleDelay(failures int, maxFailures int, lastFailure time.Time, now time.Time) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+
+	wait := config.LOGIN_LOCKOUT_DURATION
+	if maxFailures <= 0 || failures < maxFailures {
+		// Cap the shift so large failure counts can't overflow
+		wait = min(config.LOGIN_THROTTLE_DELAY<<min(failures-1, 16), config.LOGIN_LOCKOUT_DURATION)
+	}
+
+	until := lastFailure.Add(wait)
+	if now.Before(until) {
+		return until.Sub(now)
+	}
+
+	return 0
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/mailer"
+	"github.com/nleiva/go-todo-api/utils"
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// MagicLinkService sends passwordless sign-in links by email and redeems them
+// Instances of this service should be created using the NewMagicLinkService function
+type MagicLinkService struct {
+	db     *gorm.DB
+	mailer mailer.Mailer
+}
+
+func NewMagicLinkService(db *gorm.DB, m mailer.Mailer) *MagicLinkService {
+	return &MagicLinkService{
+		db:     db,
+		mailer: m,
+	}
+}
+
+type IMagicLinkService interface {
+	RequestLink(ctx context.Context, email string, browserNonce string) (string, error)
+	RedeemLink(token string, browserNonce string) (*model.Account, error)
+}
+
+// RequestLink emails a sign-in link if an account with the email exists. The returned nonce has to be stored
+// in the requesting browser, the link only works together with it. A browser that already has a nonce keeps it,
+// so a repeated request doesn't break the link sent before. Unknown emails and requests during the cooldown are
+// silently ignored so the response doesn't reveal which accounts exist.
+func (ms *MagicLinkService) RequestLink(ctx context.Context, email string, browserNonce string) (string, error) {
+	if browserNonce == "" {
+		browserNonce = randomToken(32)
+	}
+
+	account := &model.Account{}
+	if err := ms.db.Take(account, "email = ?", strings.TrimSpace(email)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return browserNonce, nil
+		}
+		return "", err
+	}
+
+	var recent int64
+	ms.db.Model(&model.MagicLink{}).
+		Where("account_id = ? AND created_at > ?", account.ID, time.Now().Add(-config.MAGIC_LINK_COOLDOWN)).
+		Count(&recent)
+	if recent > 0 {
+		return browserNonce, nil
+	}
+
+	// Only the newest link works, older unused links of the account are removed
+	ms.db.Unscoped().Where("account_id = ? OR expires_at < ?", account.ID, time.Now()).Delete(&model.MagicLink{})
+
+	token := randomToken(32)
+	err := ms.db.Create(&model.MagicLink{
+		TokenHash:   hashToken(token),
+		AccountID:   account.ID,
+		BrowserHash: hashToken(browserNonce),
+		ExpiresAt:   time.Now().Add(config.MAGIC_LINK_EXP),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+
+	link := strings.TrimSuffix(config.APP_URL, "/") + "/auth/magic/callback?token=" + token
+
+	return browserNonce, ms.mailer.Send(ctx, mailer.Message{
+		To:      account.Email,
+		Subject: "Your sign-in link",
+		Text: "Open this link to sign in to Go TODO API:\n\n" + link + "\n\n" +
+			"The link works once, only in the browser you requested it from, and expires in " + config.MAGIC_LINK_EXP.String() + ".\n" +
+			"If you didn't request it, you can ignore this email.",
+	})
+}
+
+// RedeemLink checks the link and the browser binding and marks the link as used
+func (ms *MagicLinkService) RedeemLink(token string, browserNonce string) (*model.Account, error) {
+	link := &model.MagicLink{}
+	if err := ms.db.Take(link, "token_hash = ?", hashToken(token)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.MAGIC_LINK_INVALID
+		}
+		return nil, err
+	}
+
+	if link.UsedAt.Valid || time.Now().After(link.ExpiresAt) {
+		return nil, &utils.MAGIC_LINK_INVALID
+	}
+
+	// A forwarded link doesn't work, the link stays valid for the browser that requested it
+	if browserNonce == "" || subtle.ConstantTimeCompare([]byte(hashToken(browserNonce)), []byte(link.BrowserHash)) != 1 {
+		return nil, &utils.MAGIC_LINK_OTHER_BROWSER
+	}
+
+	// The condition makes sure concurrent requests can't use the link twice
+	result := ms.db.Model(link).Where("used_at IS NULL").Update("used_at", null.TimeFrom(time.Now()))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, &utils.MAGIC_LINK_INVALID
+	}
+
+	account := &model.Account{}
+	if err := ms.db.Take(account, "id = ?", link.AccountID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.MAGIC_LINK_INVALID
+		}
+		return nil, err
+	}
+
+	return account, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"gorm.io/gorm"
+)
+
+// NotificationService stores the notifications of accounts
+// Instances of this service should be created using the NewNotificationService function
+type NotificationService struct {
+	db *gorm.DB
+}
+
+func NewNotificationService(db *gorm.DB) *NotificationService {
+	return &NotificationService{
+		db: db,
+	}
+}
+
+type INotificationService interface {
+	Notify(notification *model.Notification) *gorm.DB
+	FindNotifications(dest any, accountID uint, unreadOnly bool) *gorm.DB
+	CountUnread(accountID uint) (int64, error)
+	MarkRead(id string, accountID uint) *gorm.DB
+	MarkAllRead(accountID uint) *gorm.DB
+}
+
+// Notify stores the notification, accounts aren't notified about their own actions
+func (ns *NotificationService) Notify(notification *model.Notification) *gorm.DB {
+	if notification.AccountID == notification.ActorID {
+		return ns.db
+	}
+
+	return ns.db.Create(notification)
+}
+
+// FindNotifications lists the notifications of the account, newest first
+func (ns *NotificationService) FindNotifications(dest any, accountID uint, unreadOnly bool) *gorm.DB {
+	query := ns.db.Model(&model.Notification{}).Where("account_id = ?", accountID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	return query.Order("created_at desc").Limit(100).Find(dest)
+}
+
+func (ns *NotificationService) CountUnread(accountID uint) (int64, error) {
+	var count int64
+	err := ns.db.Model(&model.Notification{}).Where("account_id = ? AND read_at IS NULL", accountID).Count(&count).Error
+
+	return count, err
+}
+
+func (ns *NotificationService) MarkRead(id string, accountID uint) *gorm.DB {
+	return ns.db.Model(&model.Notification{}).Where("id = ? AND account_id = ?", id, accountID).Update("read_at", time.Now())
+}
+
+func (ns *NotificationService) MarkAllRead(accountID uint) *gorm.DB {
+	return ns.db.Model(&model.Notification{}).Where("account_id = ? AND read_at IS NULL", accountID).Update("read_at", time.Now())
+}
@@ -0,0 +1,443 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/app/types"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+	"github.com/nleiva/go-todo-api/pkg/permission"
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// OAuthService is the authorization server for third party clients (RFC 6749, PKCE RFC 7636,
+// introspection RFC 7662 and revocation RFC 7009). Access tokens are JWTs signed by pkg/jwt.
+// Instances of this service should be created using the NewOAuthService function
+type OAuthService struct {
+	db *gorm.DB
+}
+
+func NewOAuthService(db *gorm.DB) *OAuthService {
+	return &OAuthService{
+		db: db,
+	}
+}
+
+type IOAuthService interface {
+	CreateClient(client *model.OAuthClient) (secret string, err error)
+	FindClients(dest any, accountID uint) *gorm.DB
+	DeleteClientByID(id string, accountID uint) error
+	AuthenticateClient(clientID string, secret string) (*model.OAuthClient, error)
+
+	ValidateAuthorizationRequest(consent *types.OAuthConsent) (client *model.OAuthClient, err *types.OAuthError, redirect bool)
+	CreateAuthorizationCode(client *model.OAuthClient, accountID uint, consent *types.OAuthConsent) (string, error)
+
+	ExchangeAuthorizationCode(client *model.OAuthClient, code string, redirectURI string, codeVerifier string) (*types.OAuthTokenResponse, error)
+	ClientCredentials(client *model.OAuthClient, scope string) (*types.OAuthTokenResponse, error)
+	RefreshToken(client *model.OAuthClient, refreshToken string, scope string) (*types.OAuthTokenResponse, error)
+
+	Introspect(token string) *types.OAuthIntrospectionResponse
+	Revoke(client *model.OAuthClient, token string) error
+	IsTokenRevoked(tokenID string) bool
+}
+
+func oauthError(code string, description string) *types.OAuthError {
+	status := fiber.StatusBadRequest
+	if code == "invalid_client" {
+		status = fiber.StatusUnauthorized
+	}
+
+	return &types.OAuthError{Code: code, Description: description, StatusCode: status}
+}
+
+// randomToken returns a url safe random string with n bytes of entropy
+func randomToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashToken hashes high entropy secrets (client secrets, codes, refresh tokens) before they are stored.
+// A fast hash is enough because the inputs can't be guessed.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateClient generates the client id (and secret for confidential clients) and stores the client
+func (o *OAuthService) CreateClient(client *model.OAuthClient) (string, error) {
+	client.ClientID = randomToken(16)
+
+	secret := ""
+	if client.Confidential {
+		secret = randomToken(32)
+		client.SecretHash = hashToken(secret)
+	}
+
+	if err := o.db.Create(client).Error; err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+func (o *OAuthService) FindClients(dest any, accountID uint) *gorm.DB {
+	return o.db.Model(&model.OAuthClient{}).Where("account_id = ?", accountID).Order("created_at asc").Find(dest)
+}
+
+// DeleteClientByID removes the client together with its codes and tokens
+func (o *OAuthService) DeleteClientByID(id string, accountID uint) error {
+	return o.db.Transaction(func(tx *gorm.DB) error {
+		client := &model.OAuthClient{}
+		if err := tx.Take(client, "id = ? AND account_id = ?", id, accountID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("client_id = ?", client.ClientID).Delete(&model.OAuthAuthorizationCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("client_id = ?", client.ClientID).Delete(&model.OAuthToken{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(client).Error
+	})
+}
+
+// AuthenticateClient checks the credentials of a confidential client. Public clients authenticate with their id only.
+func (o *OAuthService) AuthenticateClient(clientID string, secret string) (*model.OAuthClient, error) {
+	client := &model.OAuthClient{}
+	if err := o.db.Take(client, "client_id = ?", clientID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauthError("invalid_client", "unknown client")
+		}
+		return nil, err
+	}
+
+	if client.Confidential && subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, oauthError("invalid_client", "invalid client credentials")
+	}
+
+	return client, nil
+}
+
+// ValidateAuthorizationRequest checks the request of the consent page and fills in defaults (redirect uri and scope).
+// redirect is false while the client or redirect uri are not trusted yet, the error must then be shown to the user
+// instead of redirecting to an unverified uri.
+func (o *OAuthService) ValidateAuthorizationRequest(consent *types.OAuthConsent) (*model.OAuthClient, *types.OAuthError, bool) {
+	client := &model.OAuthClient{}
+	if err := o.db.Take(client, "client_id = ?", consent.ClientID).Error; err != nil {
+		return nil, oauthError("invalid_request", "unknown client"), false
+	}
+
+	if consent.RedirectURI == "" {
+		if registered := strings.Fields(client.RedirectURIs); len(registered) == 1 {
+			consent.RedirectURI = registered[0]
+		}
+	}
+	if !client.HasRedirectURI(consent.RedirectURI) {
+		return nil, oauthError("invalid_request", "redirect_uri is not registered for this client"), false
+	}
+
+	if consent.ResponseType != "code" {
+		return client, oauthError("unsupported_response_type", "only the code response type is supported"), true
+	}
+
+	// PKCE is required for every client, only S256 is accepted
+	if consent.CodeChallenge == "" || consent.CodeChallengeMethod != "S256" {
+		return client, oauthError("invalid_request", "code_challenge with code_challenge_method S256 is required"), true
+	}
+
+	scope, oauthErr := resolveScope(client, consent.Scope)
+	if oauthErr != nil {
+		return client, oauthErr, true
+	}
+	consent.Scope = scope
+
+	return client, nil, true
+}
+
+// resolveScope defaults to every scope of the client and rejects scopes the client wasn't registered for
+func resolveScope(client *model.OAuthClient, requested string) (string, *types.OAuthError) {
+	if strings.TrimSpace(requested) == "" {
+		return client.Scope, nil
+	}
+
+	scopes := permission.ParseScope(requested)
+	for _, scope := range scopes {
+		if !permission.HasScope(client.Scope, scope) {
+			return "", oauthError("invalid_scope", "scope "+scope+" is not allowed for this client")
+		}
+	}
+
+	return strings.Join(scopes, " "), nil
+}
+
+func (o *OAuthService) CreateAuthorizationCode(client *model.OAuthClient, accountID uint, consent *types.OAuthConsent) (string, error) {
+	code := randomToken(32)
+
+	// Clean up codes that were never exchanged
+	o.db.Unscoped().Where("expires_at < ?", time.Now()).Delete(&model.OAuthAuthorizationCode{})
+
+	err := o.db.Create(&model.OAuthAuthorizationCode{
+		CodeHash:      hashToken(code),
+		ClientID:      client.ClientID,
+		AccountID:     accountID,
+		RedirectURI:   consent.RedirectURI,
+		Scope:         consent.Scope,
+		CodeChallenge: consent.CodeChallenge,
+		ExpiresAt:     time.Now().Add(config.OAUTH_CODE_EXP),
+	}).Error
+
+	return code, err
+}
+
+func (o *OAuthService) ExchangeAuthorizationCode(client *model.OAuthClient, code string, redirectURI string, codeVerifier string) (*types.OAuthTokenResponse, error) {
+	stored := &model.OAuthAuthorizationCode{}
+	if err := o.db.Take(stored, "code_hash = ?", hashToken(code)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauthError("invalid_grant", "unknown or already used code")
+		}
+		return nil, err
+	}
+
+	// Codes are single use, only the request that deletes the code may continue
+	result := o.db.Unscoped().Delete(stored)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, oauthError("invalid_grant", "unknown or already used code")
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, oauthError("invalid_grant", "code expired")
+	}
+	if stored.ClientID != client.ClientID || stored.RedirectURI != redirectURI {
+		return nil, oauthError("invalid_grant", "code was issued to another client or redirect_uri")
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != stored.CodeChallenge {
+		return nil, oauthError("invalid_grant", "code_verifier does not match the code_challenge")
+	}
+
+	return issueTokens(o.db, client, stored.AccountID, stored.Scope, randomToken(16), true)
+}
+
+// ClientCredentials issues a token to a confidential client acting as the account that registered it
+func (o *OAuthService) ClientCredentials(client *model.OAuthClient, scope string) (*types.OAuthTokenResponse, error) {
+	if !client.Confidential {
+		return nil, oauthError("unauthorized_client", "only confidential clients may use client_credentials")
+	}
+
+	resolved, oauthErr := resolveScope(client, scope)
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+
+	return issueTokens(o.db, client, client.AccountID, resolved, randomToken(16), false)
+}
+
+// RefreshToken rotates the refresh token, the used one is revoked. The scope can only be narrowed.
+func (o *OAuthService) RefreshToken(client *model.OAuthClient, refreshToken string, scope string) (*types.OAuthTokenResponse, error) {
+	stored := &model.OAuthToken{}
+	err := o.db.Take(stored, "token_hash = ? AND type = ?", hashToken(refreshToken), model.OAUTH_TOKEN_REFRESH).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauthError("invalid_grant", "unknown refresh token")
+		}
+		return nil, err
+	}
+
+	if stored.ClientID != client.ClientID {
+		return nil, oauthError("invalid_grant", "refresh token was issued to another client")
+	}
+
+	if !stored.Active() {
+		// A revoked refresh token being used again hints at a stolen token, revoke the whole grant
+		if stored.RevokedAt.Valid {
+			o.revokeGrant(stored.GrantID)
+		}
+		return nil, oauthError("invalid_grant", "refresh token expired or revoked")
+	}
+
+	resolved := stored.Scope
+	if strings.TrimSpace(scope) != "" {
+		for _, s := range permission.ParseScope(scope) {
+			if !permission.HasScope(stored.Scope, s) {
+				return nil, oauthError("invalid_scope", "scope "+s+" was not granted")
+			}
+		}
+		resolved = strings.Join(permission.ParseScope(scope), " ")
+	}
+
+	var response *types.OAuthTokenResponse
+	reused := false
+	err = o.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.OAuthToken{}).Where("id = ? AND revoked_at IS NULL", stored.ID).Update("revoked_at", null.TimeFrom(time.Now()))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			reused = true
+			return nil
+		}
+
+		var err error
+		response, err = issueTokens(tx, client, stored.AccountID, resolved, stored.GrantID, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		// Another request rotated the refresh token first, using it twice is treated like a reuse
+		o.revokeGrant(stored.GrantID)
+		return nil, oauthError("invalid_grant", "refresh token expired or revoked")
+	}
+
+	return response, nil
+}
+
+// issueTokens stores and returns a new access token, and a refresh token if asked, of the grant
+func issueTokens(tx *gorm.DB, client *model.OAuthClient, accountID uint, scope string, grantID string, withRefresh bool) (*types.OAuthTokenResponse, error) {
+	account := &model.Account{}
+	if err := tx.Take(account, "id = ?", accountID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, oauthError("invalid_grant", "account no longer exists")
+		}
+		return nil, err
+	}
+
+	accessToken, tokenID, err := jwt.GenerateOAuth(account, client.ClientID, scope, config.OAUTH_ACCESS_TOKEN_EXP)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &types.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(config.OAUTH_ACCESS_TOKEN_EXP.Seconds()),
+		Scope:       scope,
+	}
+
+	tokens := []model.OAuthToken{{
+		TokenHash: tokenID,
+		Type:      model.OAUTH_TOKEN_ACCESS,
+		GrantID:   grantID,
+		ClientID:  client.ClientID,
+		AccountID: account.ID,
+		Scope:     scope,
+		ExpiresAt: time.Now().Add(config.OAUTH_ACCESS_TOKEN_EXP),
+	}}
+
+	if withRefresh {
+		response.RefreshToken = randomToken(32)
+		tokens = append(tokens, model.OAuthToken{
+			TokenHash: hashToken(response.RefreshToken),
+			Type:      model.OAUTH_TOKEN_REFRESH,
+			GrantID:   grantID,
+			ClientID:  client.ClientID,
+			AccountID: account.ID,
+			Scope:     scope,
+			ExpiresAt: time.Now().Add(config.OAUTH_REFRESH_TOKEN_EXP),
+		})
+	}
+
+	if err := tx.Create(&tokens).Error; err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// findToken looks the token up as access token (JWT, by its jti) or as refresh token (by its hash)
+func (o *OAuthService) findToken(token string) (*model.OAuthToken, error) {
+	stored := &model.OAuthToken{}
+
+	if payload, err := jwt.Verify(token); err == nil {
+		if payload.Type != jwt.TYPE_OAUTH || payload.TokenID == "" {
+			return nil, gorm.ErrRecordNotFound
+		}
+
+		return stored, o.db.Take(stored, "token_hash = ? AND type = ?", payload.TokenID, model.OAUTH_TOKEN_ACCESS).Error
+	}
+
+	return stored, o.db.Take(stored, "token_hash = ? AND type = ?", hashToken(token), model.OAUTH_TOKEN_REFRESH).Error
+}
+
+func (o *OAuthService) Introspect(token string) *types.OAuthIntrospectionResponse {
+	stored, err := o.findToken(token)
+	if err != nil || !stored.Active() {
+		return &types.OAuthIntrospectionResponse{Active: false}
+	}
+
+	account := &model.Account{}
+	if err := o.db.Take(account, "id = ?", stored.AccountID).Error; err != nil {
+		return &types.OAuthIntrospectionResponse{Active: false}
+	}
+
+	return &types.OAuthIntrospectionResponse{
+		Active:    true,
+		Scope:     stored.Scope,
+		ClientID:  stored.ClientID,
+		Username:  account.Email,
+		TokenType: stored.Type,
+		Exp:       stored.ExpiresAt.Unix(),
+		Iat:       stored.CreatedAt.Unix(),
+		Sub:       strconv.FormatUint(uint64(account.ID), 10),
+	}
+}
+
+// Revoke revokes a token of the client. Revoking a refresh token also revokes the access tokens of its grant.
+// Unknown tokens and tokens of other clients are ignored, as required by RFC 7009.
+func (o *OAuthService) Revoke(client *model.OAuthClient, token string) error {
+	stored, err := o.findToken(token)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if stored.ClientID != client.ClientID {
+		return nil
+	}
+
+	if stored.Type == model.OAUTH_TOKEN_REFRESH {
+		return o.revokeGrant(stored.GrantID)
+	}
+
+	return o.db.Model(stored).Update("revoked_at", null.TimeFrom(time.Now())).Error
+}
+
+func (o *OAuthService) revokeGrant(grantID string) error {
+	return o.db.Model(&model.OAuthToken{}).
+		Where("grant_id = ? AND revoked_at IS NULL", grantID).
+		Update("revoked_at", null.TimeFrom(time.Now())).Error
+}
+
+// IsTokenRevoked is used by the middleware for every request with an OAuth access token.
+// Unknown tokens count as revoked.
+func (o *OAuthService) IsTokenRevoked(tokenID string) bool {
+	stored := &model.OAuthToken{}
+	if err := o.db.Take(stored, "token_hash = ? AND type = ?", tokenID, model.OAUTH_TOKEN_ACCESS).Error; err != nil {
+		return true
+	}
+
+	return stored.RevokedAt.Valid
+}
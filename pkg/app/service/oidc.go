@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/oidc"
+	"github.com/nleiva/go-todo-api/pkg/permission"
+	"github.com/nleiva/go-todo-api/utils"
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// OIDCService signs accounts in through an external OpenID Connect provider
+// Instances of this service should be created using the NewOIDCService function
+type OIDCService struct {
+	db *gorm.DB
+}
+
+func NewOIDCService(db *gorm.DB) *OIDCService {
+	return &OIDCService{
+		db: db,
+	}
+}
+
+type IOIDCService interface {
+	Enabled() bool
+	BeginLogin(ctx context.Context) (state string, authURL string, err error)
+	FinishLogin(ctx context.Context, state string, code string) (*model.Account, error)
+}
+
+// Enabled reports whether a provider is configured
+func (o *OIDCService) Enabled() bool {
+	return config.OIDC_ISSUER_URL != ""
+}
+
+// client is built from the configuration on every call so configuration changes (and tests) don't need a restart.
+// Provider metadata and keys are cached by the oidc package.
+func (o *OIDCService) client() *oidc.Client {
+	return oidc.NewClient(config.OIDC_ISSUER_URL, config.OIDC_CLIENT_ID, config.OIDC_CLIENT_SECRET, config.OIDC_REDIRECT_URL, config.OIDC_SCOPES)
+}
+
+// BeginLogin stores a new session and returns its state together with the URL of the provider's login page
+func (o *OIDCService) BeginLogin(ctx context.Context) (string, string, error) {
+	if !o.Enabled() {
+		return "", "", &utils.OIDC_DISABLED
+	}
+
+	session := &model.OIDCSession{
+		State:        oidc.RandomString(32),
+		Nonce:        oidc.RandomString(32),
+		CodeVerifier: oidc.RandomString(32),
+		ExpiresAt:    time.Now().Add(config.OIDC_STATE_EXP),
+	}
+
+	authURL, err := o.client().AuthCodeURL(ctx, session.State, session.Nonce, session.CodeVerifier)
+	if err != nil {
+		return "", "", utils.RequestErrorFrom(&utils.OIDC_LOGIN_FAILED, err.Error())
+	}
+
+	// Clean up flows that were started but never finished
+	o.db.Unscoped().Where("expires_at < ?", time.Now()).Delete(&model.OIDCSession{})
+
+	if err := o.db.Create(session).Error; err != nil {
+		return "", "", err
+	}
+
+	return session.State, authURL, nil
+}
+
+// FinishLogin exchanges the code of the provider's redirect and returns the linked (or newly provisioned) account
+func (o *OIDCService) FinishLogin(ctx context.Context, state string, code string) (*model.Account, error) {
+	if !o.Enabled() {
+		return nil, &utils.OIDC_DISABLED
+	}
+
+	session, err := o.takeSession(state)
+	if err != nil {
+		return nil, err
+	}
+
+	client := o.client()
+
+	tokens, err := client.Exchange(ctx, code, session.CodeVerifier)
+	if err != nil {
+		return nil, utils.RequestErrorFrom(&utils.OIDC_LOGIN_FAILED, err.Error())
+	}
+
+	claims, err := client.VerifyIDToken(ctx, tokens.IDToken, session.Nonce)
+	if err != nil {
+		return nil, utils.RequestErrorFrom(&utils.OIDC_LOGIN_FAILED, err.Error())
+	}
+
+	// Providers may keep the ID token small and only return profile claims from the userinfo endpoint
+	if claims.String(config.OIDC_CLAIM_EMAIL) == "" && tokens.AccessToken != "" {
+		userinfo, err := client.Userinfo(ctx, tokens.AccessToken)
+		if err != nil {
+			return nil, utils.RequestErrorFrom(&utils.OIDC_LOGIN_FAILED, err.Error())
+		}
+
+		if userinfo.String("sub") == claims.String("sub") {
+			for name, value := range userinfo {
+				if _, exists := claims[name]; !exists {
+					claims[name] = value
+				}
+			}
+		}
+	}
+
+	return o.resolveAccount(claims.String("iss"), claims)
+}
+
+// resolveAccount finds the account linked to the subject. Unknown subjects are linked to the account with the same
+// email or get a new account, depending on the configuration.
+func (o *OIDCService) resolveAccount(issuer string, claims oidc.Claims) (*model.Account, error) {
+	subject := claims.String("sub")
+	if subject == "" {
+		return nil, utils.RequestErrorFrom(&utils.OIDC_LOGIN_FAILED, "missing sub claim")
+	}
+
+	email := NormalizeEmail(claims.String(config.OIDC_CLAIM_EMAIL))
+
+	identity := &model.OIDCIdentity{}
+	err := o.db.Take(identity, "issuer = ? AND subject = ?", issuer, subject).Error
+	if err == nil {
+		account := &model.Account{}
+		if err := o.db.Take(account, "id = ?", identity.AccountID).Error; err != nil {
+			return nil, err
+		}
+
+		o.db.Model(identity).Updates(map[string]any{
+			"email":        email,
+			"last_used_at": null.TimeFrom(time.Now()),
+		})
+
+		return account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if email == "" {
+		return nil, utils.RequestErrorFrom(&utils.OIDC_LOGIN_FAILED, "missing "+config.OIDC_CLAIM_EMAIL+" claim")
+	}
+
+	account := &model.Account{}
+	err = o.db.Take(account, "LOWER(email) = ?", email).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	exists := err == nil
+	if exists && config.OIDC_REQUIRE_VERIFIED_EMAIL && !claims.Bool("email_verified") {
+		// Linking an unverified email would let anyone who can set that email at the provider take over the account
+		return nil, &utils.OIDC_EMAIL_NOT_VERIFIED
+	}
+	if !exists && (!config.OIDC_AUTO_PROVISION || !provisioningAllowed()) {
+		return nil, &utils.OIDC_ACCOUNT_NOT_FOUND
+	}
+
+	err = o.db.Transaction(func(tx *gorm.DB) error {
+		if !exists {
+			account = &model.Account{
+				Email:     email,
+				Firstname: claims.String(config.OIDC_CLAIM_FIRSTNAME),
+				Lastname:  claims.String(config.OIDC_CLAIM_LASTNAME),
+				// No password, the account can only sign in through the provider (or a passkey) until one is set
+				Password:    "",
+				Permission:  permission.ACCOUNTS_READ_OWN,
+				TokenSecret: model.GenerateSecretToken(),
+			}
+			if err := tx.Create(account).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&model.OIDCIdentity{
+			AccountID:  account.ID,
+			Issuer:     issuer,
+			Subject:    subject,
+			Email:      email,
+			LastUsedAt: null.TimeFrom(time.Now()),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// takeSession loads and removes the session of the given state so every redirect can only be used once
+func (o *OIDCService) takeSession(state string) (*model.OIDCSession, error) {
+	if state == "" {
+		return nil, &utils.OIDC_STATE_INVALID
+	}
+
+	session := &model.OIDCSession{}
+	if err := o.db.Take(session, "state = ?", state).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.OIDC_STATE_INVALID
+		}
+		return nil, err
+	}
+
+	// Only the request that deletes the session may use it, concurrent callbacks with the same state fail
+	result := o.db.Unscoped().Delete(session)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, &utils.OIDC_STATE_INVALID
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		return nil, &utils.OIDC_STATE_INVALID
+	}
+
+	return session, nil
+}
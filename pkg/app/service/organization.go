@@ -0,0 +1,182 @@
+package service
+
+import (
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+// OrganizationService manages organizations and the memberships of accounts in them
+// Instances of this service should be created using the NewOrganizationService function
+type OrganizationService struct {
+	db *gorm.DB
+}
+
+func NewOrganizationService(db *gorm.DB) *OrganizationService {
+	return &OrganizationService{
+		db: db,
+	}
+}
+
+type IOrganizationService interface {
+	CreateOrganization(organization *model.Organization, ownerID uint) error
+	UpdateOrganization(organization *model.Organization) *gorm.DB
+	DeleteOrganization(organizationID uint) error
+
+	FindMemberships(dest any, accountID uint) *gorm.DB
+	FindMembership(dest *model.Membership, organizationID string, accountID uint) *gorm.DB
+	FindMembers(dest any, organizationID uint) *gorm.DB
+	AddMember(membership *model.Membership) error
+	UpdateMemberRole(organizationID uint, accountID uint, role string) error
+	RemoveMember(organizationID uint, accountID uint) error
+}
+
+// CreateOrganization stores the organization and makes the account its owner
+func (ors *OrganizationService) CreateOrganization(organization *model.Organization, ownerID uint) error {
+	return ors.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&model.Membership{
+			OrganizationID: organization.ID,
+			AccountID:      ownerID,
+			Role:           model.ORGANIZATION_ROLE_OWNER,
+		}).Error
+	})
+}
+
+func (ors *OrganizationService) UpdateOrganization(organization *model.Organization) *gorm.DB {
+	return ors.db.Save(organization)
+}
+
+// DeleteOrganization deletes the organization together with its memberships, todos and their shares, share
+// links, statuses and templates
+func (ors *OrganizationService) DeleteOrganization(organizationID uint) error {
+	return ors.db.Transaction(func(tx *gorm.DB) error {
+		todos := tx.Model(&model.Todo{}).Select("id").Where("organization_id = ?", organizationID)
+		if err := tx.Unscoped().Where("todo_id IN (?)", todos).Delete(&model.TodoShare{}).Error; err != nil {
+			return err
+		}
+
+		links := tx.Unscoped().Model(&model.ShareLink{}).Select("id").Where("organization_id = ?", organizationID)
+		if err := tx.Unscoped().Where("share_link_id IN (?)", links).Delete(&model.ShareLinkAttempt{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("organization_id = ?", organizationID).Delete(&model.ShareLink{}).Error; err != nil {
+			return err
+		}
+
+		statuses := tx.Unscoped().Model(&model.Status{}).Select("id").Where("organization_id = ?", organizationID)
+		if err := tx.Where("from_status_id IN (?) OR to_status_id IN (?)", statuses, statuses).Delete(&model.StatusTransition{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("organization_id = ?", organizationID).Delete(&model.Status{}).Error; err != nil {
+			return err
+		}
+
+		templates := tx.Unscoped().Model(&model.TodoTemplate{}).Select("id").Where("organization_id = ?", organizationID)
+		if err := tx.Where("template_id IN (?)", templates).Delete(&model.TodoTemplateItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("organization_id = ?", organizationID).Delete(&model.TodoTemplate{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("organization_id = ?", organizationID).Delete(&model.Todo{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("organization_id = ?", organizationID).Delete(&model.Membership{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&model.Organization{}, organizationID).Error
+	})
+}
+
+// FindMemberships lists the memberships of the account with their organizations
+func (ors *OrganizationService) FindMemberships(dest any, accountID uint) *gorm.DB {
+	return ors.db.Preload("Organization").Where("account_id = ?", accountID).Order("organization_id").Find(dest)
+}
+
+func (ors *OrganizationService) FindMembership(dest *model.Membership, organizationID string, accountID uint) *gorm.DB {
+	return ors.db.Preload("Organization").Where("organization_id = ? AND account_id = ?", organizationID, accountID).Take(dest)
+}
+
+// FindMembers lists the memberships of the organization with their accounts
+func (ors *OrganizationService) FindMembers(dest any, organizationID uint) *gorm.DB {
+	return ors.db.Preload("Account").Where("organization_id = ?", organizationID).Order("id").Find(dest)
+}
+
+func (ors *OrganizationService) AddMember(membership *model.Membership) error {
+	var count int64
+	if err := ors.db.Model(&model.Membership{}).Where("organization_id = ? AND account_id = ?", membership.OrganizationID, membership.AccountID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return &utils.ORGANIZATION_ALREADY_MEMBER
+	}
+
+	return ors.db.Create(membership).Error
+}
+
+// UpdateMemberRole changes the role of a member, an organization always keeps at least one owner
+func (ors *OrganizationService) UpdateMemberRole(organizationID uint, accountID uint, role string) error {
+	return ors.db.Transaction(func(tx *gorm.DB) error {
+		membership, err := takeMembership(tx, organizationID, accountID)
+		if err != nil {
+			return err
+		}
+
+		if membership.Role == model.ORGANIZATION_ROLE_OWNER && role != model.ORGANIZATION_ROLE_OWNER {
+			if err := ensureOtherOwner(tx, organizationID, accountID); err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(membership).Update("role", role).Error
+	})
+}
+
+// RemoveMember removes the account from the organization, an organization always keeps at least one owner
+func (ors *OrganizationService) RemoveMember(organizationID uint, accountID uint) error {
+	return ors.db.Transaction(func(tx *gorm.DB) error {
+		membership, err := takeMembership(tx, organizationID, accountID)
+		if err != nil {
+			return err
+		}
+
+		if membership.Role == model.ORGANIZATION_ROLE_OWNER {
+			if err := ensureOtherOwner(tx, organizationID, accountID); err != nil {
+				return err
+			}
+		}
+
+		return tx.Unscoped().Delete(membership).Error
+	})
+}
+
+func takeMembership(tx *gorm.DB, organizationID uint, accountID uint) (*model.Membership, error) {
+	membership := &model.Membership{}
+	if err := tx.Where("organization_id = ? AND account_id = ?", organizationID, accountID).Take(membership).Error; err != nil {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+func ensureOtherOwner(tx *gorm.DB, organizationID uint, accountID uint) error {
+	var owners int64
+	err := tx.Model(&model.Membership{}).
+		Where("organization_id = ? AND account_id <> ? AND role = ?", organizationID, accountID, model.ORGANIZATION_ROLE_OWNER).
+		Count(&owners).Error
+	if err != nil {
+		return err
+	}
+	if owners == 0 {
+		return &utils.ORGANIZATION_LAST_OWNER
+	}
+
+	return nil
+}
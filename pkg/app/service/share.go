@@ -0,0 +1,53 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"gorm.io/gorm"
+)
+
+// ShareService manages the grants of todos to other accounts
+// Instances of this service should be created using the NewShareService function
+type ShareService struct {
+	db *gorm.DB
+}
+
+func NewShareService(db *gorm.DB) *ShareService {
+	return &ShareService{
+		db: db,
+	}
+}
+
+type IShareService interface {
+	ShareTodo(share *model.TodoShare) error
+	FindShares(dest any, todoID uint) *gorm.DB
+	DeleteShare(todoID uint, accountID uint) *gorm.DB
+}
+
+// ShareTodo grants the account the role, an existing share of the todo with the account gets the new role
+func (ss *ShareService) ShareTodo(share *model.TodoShare) error {
+	existing := &model.TodoShare{}
+	err := ss.db.Where("todo_id = ? AND account_id = ?", share.TodoID, share.AccountID).Take(existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ss.db.Create(share).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	existing.Role = share.Role
+	existing.SharedByID = share.SharedByID
+	*share = *existing
+
+	return ss.db.Save(share).Error
+}
+
+// FindShares lists the shares of the todo with their accounts
+func (ss *ShareService) FindShares(dest any, todoID uint) *gorm.DB {
+	return ss.db.Preload("Account").Where("todo_id = ?", todoID).Order("id").Find(dest)
+}
+
+func (ss *ShareService) DeleteShare(todoID uint, accountID uint) *gorm.DB {
+	return ss.db.Unscoped().Where("todo_id = ? AND account_id = ?", todoID, accountID).Delete(&model.TodoShare{})
+}
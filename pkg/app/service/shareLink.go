@@ -0,0 +1,127 @@
+package service
+
+import (
+	"time"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"gorm.io/gorm"
+)
+
+// ShareLinkService manages the public read-only links to todo lists
+// Instances of this service should be created using the NewShareLinkService function
+type ShareLinkService struct {
+	db *gorm.DB
+}
+
+func NewShareLinkService(db *gorm.DB) *ShareLinkService {
+	return &ShareLinkService{
+		db: db,
+	}
+}
+
+type IShareLinkService interface {
+	CreateLink(link *model.ShareLink, space model.Space) (token string, err error)
+	FindLinks(dest any, space model.Space) *gorm.DB
+	FindLinkByID(dest *model.ShareLink, id string, space model.Space) *gorm.DB
+	RevokeLink(id string, space model.Space) *gorm.DB
+	FindLinkByToken(dest *model.ShareLink, token string) *gorm.DB
+	RecordView(link *model.ShareLink) *gorm.DB
+	FindLinkTodos(dest any, link *model.ShareLink, inSpace *gorm.DB) *gorm.DB
+	RetryAfter(link *model.ShareLink, ip string) (time.Duration, error)
+	RecordAttempt(attempt *model.ShareLinkAttempt) *gorm.DB
+}
+
+// CreateLink generates the token and stores the link for the space, the token is returned only here
+func (sls *ShareLinkService) CreateLink(link *model.ShareLink, space model.Space) (string, error) {
+	token := randomToken(24)
+	link.TokenHash = hashToken(token)
+	link.AccountID = space.AccountID
+	link.OrganizationID = nil
+	if !space.IsPersonal() {
+		organizationID := space.OrganizationID
+		link.OrganizationID = &organizationID
+	}
+
+	if err := sls.db.Create(link).Error; err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// FindLinks lists the links the account created in the space, newest first
+func (sls *ShareLinkService) FindLinks(dest any, space model.Space) *gorm.DB {
+	return sls.inSpace(space).Order("created_at desc").Find(dest)
+}
+
+func (sls *ShareLinkService) FindLinkByID(dest *model.ShareLink, id string, space model.Space) *gorm.DB {
+	return sls.inSpace(space).Where("id = ?", id).Take(dest)
+}
+
+func (sls *ShareLinkService) inSpace(space model.Space) *gorm.DB {
+	query := sls.db.Model(&model.ShareLink{}).Where("account_id = ?", space.AccountID)
+	if space.IsPersonal() {
+		return query.Where("organization_id IS NULL")
+	}
+
+	return query.Where("organization_id = ?", space.OrganizationID)
+}
+
+// RevokeLink revokes a link the account created in the space, revoked links are kept with their view count
+func (sls *ShareLinkService) RevokeLink(id string, space model.Space) *gorm.DB {
+	return sls.inSpace(space).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+}
+
+func (sls *ShareLinkService) FindLinkByToken(dest *model.ShareLink, token string) *gorm.DB {
+	return sls.db.Where("token_hash = ?", hashToken(token)).Take(dest)
+}
+
+func (sls *ShareLinkService) RecordView(link *model.ShareLink) *gorm.DB {
+	return sls.db.Model(link).UpdateColumns(map[string]any{
+		"views":          gorm.Expr("views + 1"),
+		"last_viewed_at": time.Now(),
+	})
+}
+
+// FindLinkTodos lists the todos matching the filter of the link, archived todos are left out. inSpace limits the
+// todos to the space of the link (see TodoService.InSpace), so links stop showing organization todos once their
+// creator left.
+func (sls *ShareLinkService) FindLinkTodos(dest any, link *model.ShareLink, inSpace *gorm.DB) *gorm.DB {
+	query := sls.db.Model(&model.Todo{}).Where(inSpace).Where("archived_at IS NULL")
+
+	switch link.Status {
+	case model.SHARE_LINK_STATUS_ACTIVE:
+		query = query.Where("completed = ?", false)
+	case model.SHARE_LINK_STATUS_COMPLETED:
+		query = query.Where("completed = ?", true)
+	}
+
+	if link.Search != "" {
+		query = query.Where("title LIKE ? ESCAPE '!' OR description LIKE ? ESCAPE '!'", link.SearchCondition(), link.SearchCondition())
+	}
+
+	return query.Order("created_at desc").Find(dest)
+}
+
+// RetryAfter returns how long the caller has to wait before the next password for the link is accepted. The limits of
+// the logins apply, counted on the attempts of the links only.
+func (sls *ShareLinkService) RetryAfter(link *model.ShareLink, ip string) (time.Duration, error) {
+	byLink, err := retryAfter(sls.db, &model.ShareLinkAttempt{}, "share_link_id = ?", link.ID, config.LOGIN_MAX_FAILURES, true)
+	if err != nil {
+		return 0, err
+	}
+
+	byIP, err := retryAfter(sls.db, &model.ShareLinkAttempt{}, "ip = ?", ip, config.LOGIN_MAX_FAILURES_PER_IP, false)
+	if err != nil {
+		return 0, err
+	}
+
+	return max(byLink, byIP), nil
+}
+
+func (sls *ShareLinkService) RecordAttempt(attempt *model.ShareLinkAttempt) *gorm.DB {
+	return sls.db.Create(attempt)
+}
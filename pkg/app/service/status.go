@@ -0,0 +1,404 @@
+package service
+
+import (
+	"slices"
+	"time"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/utils"
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// StatusService manages the workflow statuses of spaces and the allowed transitions between them
+// Instances of this service should be created using the NewStatusService function
+type StatusService struct {
+	db *gorm.DB
+}
+
+func NewStatusService(db *gorm.DB) *StatusService {
+	return &StatusService{
+		db: db,
+	}
+}
+
+type IStatusService interface {
+	FindStatuses(space model.Space) ([]model.Status, error)
+	FindStatus(dest *model.Status, id string, space model.Space) *gorm.DB
+	CreateStatus(status *model.Status, space model.Space) error
+	UpdateStatus(status *model.Status, actorID uint) error
+	DeleteStatus(status *model.Status) error
+	FindTransitions(space model.Space) ([]model.StatusTransition, error)
+	SetTransitions(status *model.Status, toStatusIDs []uint) error
+	CountTodos(space model.Space) (map[uint]int64, error)
+}
+
+// statusesIn returns the condition limiting statuses to the space
+func statusesIn(tx *gorm.DB, space model.Space) *gorm.DB {
+	if space.IsPersonal() {
+		return tx.Model(&model.Status{}).Where("organization_id IS NULL AND account_id = ?", space.AccountID)
+	}
+
+	return tx.Model(&model.Status{}).Where("organization_id = ?", space.OrganizationID)
+}
+
+// todosIn returns all todos of the space including those in the trash
+func todosIn(tx *gorm.DB, space model.Space) *gorm.DB {
+	if space.IsPersonal() {
+		return tx.Unscoped().Model(&model.Todo{}).Where("organization_id IS NULL AND account_id = ?", space.AccountID)
+	}
+
+	return tx.Unscoped().Model(&model.Todo{}).Where("organization_id = ?", space.OrganizationID)
+}
+
+// ensureStatuses returns the statuses of the space ordered by position. Spaces without statuses get the
+// DefaultStatuses and their todos are moved to the first open or done status by their completion.
+func ensureStatuses(tx *gorm.DB, space model.Space) ([]model.Status, error) {
+	statuses := []model.Status{}
+	if err := statusesIn(tx, space).Order("position, id").Find(&statuses).Error; err != nil {
+		return nil, err
+	}
+	if len(statuses) > 0 {
+		return statuses, nil
+	}
+
+	for _, status := range model.DefaultStatuses {
+		status.AccountID = space.AccountID
+		if !space.IsPersonal() {
+			organizationID := space.OrganizationID
+			status.OrganizationID = &organizationID
+		}
+		seedKey := model.StatusSeedKey(space, status.Name)
+		status.SeedKey = &seedKey
+		statuses = append(statuses, status)
+	}
+	// Requests seeding the same space at the same time conflict on the seed keys, only one of them succeeds
+	if err := tx.Create(&statuses).Error; err != nil {
+		return nil, err
+	}
+
+	for _, done := range []bool{false, true} {
+		status := firstStatus(statuses, done)
+		if err := todosIn(tx, space).Where("status_id IS NULL AND completed = ?", done).Update("status_id", status.ID).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return statuses, nil
+}
+
+// firstStatus returns the first status that is done or open, the statuses are ordered by position
+func firstStatus(statuses []model.Status, done bool) *model.Status {
+	for i := range statuses {
+		if statuses[i].IsDone == done {
+			return &statuses[i]
+		}
+	}
+
+	return nil
+}
+
+func findStatus(statuses []model.Status, id *uint) *model.Status {
+	if id == nil {
+		return nil
+	}
+	for i := range statuses {
+		if statuses[i].ID == *id {
+			return &statuses[i]
+		}
+	}
+
+	return nil
+}
+
+// allowedTransitions returns the statuses todos in the status can be moved to, nil allows all statuses
+func allowedTransitions(tx *gorm.DB, fromStatusID uint) ([]uint, error) {
+	toStatusIDs := []uint{}
+	if err := tx.Model(&model.StatusTransition{}).Where("from_status_id = ?", fromStatusID).Pluck("to_status_id", &toStatusIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(toStatusIDs) == 0 {
+		return nil, nil
+	}
+
+	return toStatusIDs, nil
+}
+
+// syncStatus keeps the status and the completion of a todo in line before it is saved. New todos and todos
+// without a status of their space go to the first status matching their completion. Todos that moved to another
+// status take the completion of the status, todos that were completed or reopened move to the first allowed
+// status that is done or open.
+func syncStatus(tx *gorm.DB, before *model.Todo, todo *model.Todo) error {
+	statuses, err := ensureStatuses(tx, todo.Space())
+	if err != nil {
+		return err
+	}
+
+	current := findStatus(statuses, todo.StatusID)
+	switch {
+	case current == nil:
+		if status := firstStatus(statuses, todo.Completed); status != nil {
+			todo.StatusID = &status.ID
+		}
+	case before != nil && (before.StatusID == nil || *before.StatusID != current.ID):
+		todo.Completed = current.IsDone
+	case current.IsDone != todo.Completed:
+		allowed, err := allowedTransitions(tx, current.ID)
+		if err != nil {
+			return err
+		}
+
+		var target *model.Status
+		for i := range statuses {
+			if statuses[i].IsDone == todo.Completed && (allowed == nil || slices.Contains(allowed, statuses[i].ID)) {
+				target = &statuses[i]
+				break
+			}
+		}
+		if target == nil {
+			return &utils.STATUS_TRANSITION_NOT_ALLOWED
+		}
+		todo.StatusID = &target.ID
+	}
+
+	if !todo.Completed {
+		todo.CompletedAt = null.Time{}
+	} else if !todo.CompletedAt.Valid {
+		todo.CompletedAt = null.TimeFrom(time.Now())
+	}
+
+	return nil
+}
+
+// statusChange describes a move between two statuses for the history
+func statusChange(tx *gorm.DB, from *uint, to *uint) (model.TodoChange, error) {
+	change := model.TodoChange{Field: "status"}
+
+	for i, id := range []*uint{from, to} {
+		if id == nil {
+			continue
+		}
+		status := &model.Status{}
+		if err := tx.Unscoped().Take(status, *id).Error; err != nil {
+			return change, err
+		}
+		if i == 0 {
+			change.From = null.StringFrom(status.Name)
+		} else {
+			change.To = null.StringFrom(status.Name)
+		}
+	}
+
+	return change, nil
+}
+
+// FindStatuses lists the statuses of the space ordered by position, spaces without statuses get the defaults
+func (ss *StatusService) FindStatuses(space model.Space) ([]model.Status, error) {
+	var statuses []model.Status
+
+	err := ss.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		statuses, err = ensureStatuses(tx, space)
+		return err
+	})
+
+	return statuses, err
+}
+
+func (ss *StatusService) FindStatus(dest *model.Status, id string, space model.Space) *gorm.DB {
+	return statusesIn(ss.db, space).Where("id = ?", id).Take(dest)
+}
+
+// CreateStatus adds the status to the workflow of the space
+func (ss *StatusService) CreateStatus(status *model.Status, space model.Space) error {
+	return ss.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := ensureStatuses(tx, space); err != nil {
+			return err
+		}
+
+		status.AccountID = space.AccountID
+		status.OrganizationID = nil
+		if !space.IsPersonal() {
+			organizationID := space.OrganizationID
+			status.OrganizationID = &organizationID
+		}
+
+		return tx.Create(status).Error
+	})
+}
+
+// UpdateStatus saves the status. Changing whether it counts as done completes or reopens its todos with a
+// revision in their history, a workflow keeps at least one open and one done status. With
+// DEPENDENCIES_BLOCK_COMPLETION a status can't become done while one of its todos is blocked.
+func (ss *StatusService) UpdateStatus(status *model.Status, actorID uint) error {
+	return ss.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(status).Error; err != nil {
+			return err
+		}
+
+		if err := ensureOpenAndDone(tx, status); err != nil {
+			return err
+		}
+
+		todos := []model.Todo{}
+		if err := tx.Where("status_id = ? AND completed <> ?", status.ID, status.IsDone).Find(&todos).Error; err != nil {
+			return err
+		}
+
+		action := model.TODO_HISTORY_REOPENED
+		completedAt := null.Time{}
+		if status.IsDone {
+			action = model.TODO_HISTORY_COMPLETED
+			completedAt = null.TimeFrom(time.Now())
+		}
+
+		for _, before := range todos {
+			todo := before
+			todo.Completed = status.IsDone
+			todo.CompletedAt = completedAt
+
+			changes, err := model.DiffTodos(&before, &todo)
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Model(&todo).Updates(map[string]any{"completed": todo.Completed, "completed_at": todo.CompletedAt}).Error; err != nil {
+				return err
+			}
+
+			if err := recordHistory(tx, todo.ID, actorID, action, changes); err != nil {
+				return err
+			}
+		}
+
+		// Checked once all todos are completed, so todos blocked only by todos of the same status don't count
+		if config.DEPENDENCIES_BLOCK_COMPLETION && status.IsDone && len(todos) > 0 {
+			todoIDs := make([]uint, len(todos))
+			for i, todo := range todos {
+				todoIDs[i] = todo.ID
+			}
+
+			blocked, err := openBlockers(tx, todoIDs)
+			if err != nil {
+				return err
+			}
+			if len(blocked) > 0 {
+				return &utils.STATUS_TODOS_BLOCKED
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteStatus deletes a status without todos together with its transitions
+func (ss *StatusService) DeleteStatus(status *model.Status) error {
+	return ss.db.Transaction(func(tx *gorm.DB) error {
+		var todos int64
+		if err := tx.Model(&model.Todo{}).Where("status_id = ?", status.ID).Count(&todos).Error; err != nil {
+			return err
+		}
+		if todos > 0 {
+			return &utils.STATUS_IN_USE
+		}
+
+		if err := tx.Delete(status).Error; err != nil {
+			return err
+		}
+
+		if err := ensureOpenAndDone(tx, status); err != nil {
+			return err
+		}
+
+		return tx.Where("from_status_id = ? OR to_status_id = ?", status.ID, status.ID).Delete(&model.StatusTransition{}).Error
+	})
+}
+
+// ensureOpenAndDone checks that the workflow of the status still has an open and a done status
+func ensureOpenAndDone(tx *gorm.DB, status *model.Status) error {
+	space := model.Space{AccountID: status.AccountID}
+	if status.OrganizationID != nil {
+		space.OrganizationID = *status.OrganizationID
+	}
+
+	for _, done := range []bool{false, true} {
+		var count int64
+		if err := statusesIn(tx, space).Where("is_done = ?", done).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return &utils.STATUS_REQUIRED
+		}
+	}
+
+	return nil
+}
+
+// FindTransitions lists the transitions between the statuses of the space
+func (ss *StatusService) FindTransitions(space model.Space) ([]model.StatusTransition, error) {
+	transitions := []model.StatusTransition{}
+	statuses := statusesIn(ss.db, space).Select("id")
+	err := ss.db.Where("from_status_id IN (?)", statuses).Order("from_status_id, to_status_id").Find(&transitions).Error
+
+	return transitions, err
+}
+
+// SetTransitions replaces the statuses todos in the status can be moved to, no statuses allow all moves. The
+// statuses have to be in the same space. A list of only the status itself keeps its todos where they are.
+func (ss *StatusService) SetTransitions(status *model.Status, toStatusIDs []uint) error {
+	space := model.Space{AccountID: status.AccountID}
+	if status.OrganizationID != nil {
+		space.OrganizationID = *status.OrganizationID
+	}
+
+	// Sorted copies the list, the caller's slice isn't changed
+	toStatusIDs = slices.Compact(slices.Sorted(slices.Values(toStatusIDs)))
+
+	return ss.db.Transaction(func(tx *gorm.DB) error {
+		if len(toStatusIDs) > 0 {
+			var count int64
+			if err := statusesIn(tx, space).Where("id IN ?", toStatusIDs).Count(&count).Error; err != nil {
+				return err
+			}
+			if int(count) != len(toStatusIDs) {
+				return gorm.ErrRecordNotFound
+			}
+		}
+
+		if err := tx.Where("from_status_id = ?", status.ID).Delete(&model.StatusTransition{}).Error; err != nil {
+			return err
+		}
+
+		transitions := []model.StatusTransition{}
+		for _, toStatusID := range toStatusIDs {
+			transitions = append(transitions, model.StatusTransition{FromStatusID: status.ID, ToStatusID: toStatusID})
+		}
+		if len(transitions) == 0 {
+			return nil
+		}
+
+		return tx.Create(&transitions).Error
+	})
+}
+
+// CountTodos counts the todos of the space that are not archived by their status
+func (ss *StatusService) CountTodos(space model.Space) (map[uint]int64, error) {
+	rows := []struct {
+		StatusID uint
+		Count    int64
+	}{}
+	err := todosIn(ss.db, space).
+		Where("deleted_at IS NULL AND archived_at IS NULL AND status_id IS NOT NULL").
+		Select("status_id, COUNT(*) AS count").Group("status_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[uint]int64{}
+	for _, row := range rows {
+		counts[row.StatusID] = row.Count
+	}
+
+	return counts, nil
+}
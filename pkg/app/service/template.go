@@ -0,0 +1,172 @@
+package service
+
+import (
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+// TEMPLATE_MAX_ITEMS limits the size of the todo trees captured as templates
+const TEMPLATE_MAX_ITEMS = 200
+
+// TemplateService captures todo trees as templates and creates todos from them
+// Instances of this service should be created using the NewTemplateService function
+type TemplateService struct {
+	db *gorm.DB
+}
+
+func NewTemplateService(db *gorm.DB) *TemplateService {
+	return &TemplateService{
+		db: db,
+	}
+}
+
+type ITemplateService interface {
+	FindTemplates(dest any, space model.Space) *gorm.DB
+	FindTemplate(dest *model.TodoTemplate, id string, space model.Space) *gorm.DB
+	CreateTemplate(template *model.TodoTemplate, root *model.Todo) error
+	DeleteTemplate(template *model.TodoTemplate) error
+	InstantiateTemplate(template *model.TodoTemplate, space model.Space, base time.Time) ([]model.Todo, error)
+}
+
+// templatesIn returns the condition limiting templates to the space
+func templatesIn(tx *gorm.DB, space model.Space) *gorm.DB {
+	if space.IsPersonal() {
+		return tx.Model(&model.TodoTemplate{}).Where("organization_id IS NULL AND account_id = ?", space.AccountID)
+	}
+
+	return tx.Model(&model.TodoTemplate{}).Where("organization_id = ?", space.OrganizationID)
+}
+
+func orderItems(tx *gorm.DB) *gorm.DB {
+	return tx.Order("position")
+}
+
+// FindTemplates lists the templates of the space with their items by name
+func (tps *TemplateService) FindTemplates(dest any, space model.Space) *gorm.DB {
+	return templatesIn(tps.db, space).Preload("Items", orderItems).Order("name, id").Find(dest)
+}
+
+func (tps *TemplateService) FindTemplate(dest *model.TodoTemplate, id string, space model.Space) *gorm.DB {
+	return templatesIn(tps.db, space).Preload("Items", orderItems).Where("id = ?", id).Take(dest)
+}
+
+// CreateTemplate captures the root todo and its subitems, depth first in the order of the list, in the space
+// of the root. Due dates are stored relative to model.TemplateBase of the root.
+func (tps *TemplateService) CreateTemplate(template *model.TodoTemplate, root *model.Todo) error {
+	space := root.Space()
+	template.AccountID = space.AccountID
+	template.OrganizationID = root.OrganizationID
+	template.Items = nil
+
+	return tps.db.Transaction(func(tx *gorm.DB) error {
+		todos, err := todoTree(tx, root)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Create(template).Error; err != nil {
+			return err
+		}
+
+		base := model.TemplateBase(root)
+		itemIDs := map[uint]uint{}
+		for i, todo := range todos {
+			item := model.NewTemplateItem(&todo, base)
+			item.TemplateID = template.ID
+			item.Position = i
+			if todo.ParentID != nil && todo.ID != root.ID {
+				parentID := itemIDs[*todo.ParentID]
+				item.ParentID = &parentID
+			}
+
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+			itemIDs[todo.ID] = item.ID
+			template.Items = append(template.Items, item)
+		}
+
+		return nil
+	})
+}
+
+// todoTree returns the root followed by its subitems in the space of the root depth first, subitems in the trash
+// are left out
+func todoTree(tx *gorm.DB, root *model.Todo) ([]model.Todo, error) {
+	space := root.Space()
+	tree := []model.Todo{}
+	visited := map[uint]bool{}
+
+	var walk func(todo model.Todo) error
+	walk = func(todo model.Todo) error {
+		if visited[todo.ID] {
+			return nil
+		}
+		if len(tree) == TEMPLATE_MAX_ITEMS {
+			return &utils.TEMPLATE_TOO_LARGE
+		}
+		visited[todo.ID] = true
+		tree = append(tree, todo)
+
+		children := []model.Todo{}
+		if err := todosIn(tx, space).Where("parent_id = ? AND deleted_at IS NULL", todo.ID).Order("position, id").Find(&children).Error; err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return tree, walk(*root)
+}
+
+// DeleteTemplate deletes the template with its items for good, todos created from it are kept
+func (tps *TemplateService) DeleteTemplate(template *model.TodoTemplate) error {
+	return tps.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", template.ID).Delete(&model.TodoTemplateItem{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(template).Error
+	})
+}
+
+// InstantiateTemplate creates the todos of the template in the space with due dates relative to base. The root
+// is returned first, subitems are linked to the todos of their parent items.
+func (tps *TemplateService) InstantiateTemplate(template *model.TodoTemplate, space model.Space, base time.Time) ([]model.Todo, error) {
+	todos := []model.Todo{}
+
+	err := tps.db.Transaction(func(tx *gorm.DB) error {
+		todoIDs := map[uint]uint{}
+		for _, item := range template.Items {
+			todo := item.Todo(base)
+			if item.ParentID != nil {
+				parentID, ok := todoIDs[*item.ParentID]
+				if !ok {
+					return &utils.TEMPLATE_INVALID
+				}
+				todo.ParentID = &parentID
+			}
+
+			if err := createTodo(tx, todo, space); err != nil {
+				return err
+			}
+			todoIDs[item.ID] = todo.ID
+			todos = append(todos, *todo)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
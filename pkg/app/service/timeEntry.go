@@ -0,0 +1,167 @@
+package service
+
+import (
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/utils"
+	"gorm.io/gorm"
+)
+
+// TimeEntryService tracks the time accounts spend on todos
+// Instances of this service should be created using the NewTimeEntryService function
+type TimeEntryService struct {
+	db *gorm.DB
+}
+
+func NewTimeEntryService(db *gorm.DB) *TimeEntryService {
+	return &TimeEntryService{
+		db: db,
+	}
+}
+
+type ITimeEntryService interface {
+	FindEntries(dest any, todoID uint) *gorm.DB
+	FindRunning(accountID uint) (*model.TimeEntry, error)
+	StartTimer(todo *model.Todo, accountID uint) (*model.TimeEntry, *model.TimeEntry, error)
+	StopTimer(todoID uint, accountID uint) (*model.TimeEntry, error)
+	CreateEntry(entry *model.TimeEntry) error
+	DeleteEntry(todoID uint, entryID uint, accountID uint) error
+	MarkRunning(todos []model.Todo, accountID uint) error
+	FindTimesheet(dest any, space model.Space, from time.Time, to time.Time) *gorm.DB
+	FindReportTodos(dest any, space model.Space, ids []uint) *gorm.DB
+}
+
+// FindEntries lists the time entries of the todo with their accounts, oldest first
+func (tes *TimeEntryService) FindEntries(dest any, todoID uint) *gorm.DB {
+	return tes.db.Preload("Account").Where("todo_id = ?", todoID).Order("started_at, id").Find(dest)
+}
+
+// FindRunning returns the running timer of the account, nil if there is none
+func (tes *TimeEntryService) FindRunning(accountID uint) (*model.TimeEntry, error) {
+	return findRunning(tes.db, accountID)
+}
+
+func findRunning(tx *gorm.DB, accountID uint) (*model.TimeEntry, error) {
+	entries := []model.TimeEntry{}
+	if err := tx.Where("running_account_id = ?", accountID).Limit(1).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	return &entries[0], nil
+}
+
+// StartTimer starts tracking time on the todo. A timer the account runs on another todo is stopped first and
+// returned as well, starting the timer that already runs keeps it.
+func (tes *TimeEntryService) StartTimer(todo *model.Todo, accountID uint) (*model.TimeEntry, *model.TimeEntry, error) {
+	var entry, stopped *model.TimeEntry
+
+	err := tes.db.Transaction(func(tx *gorm.DB) error {
+		running, err := findRunning(tx, accountID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if running != nil {
+			if running.TodoID == todo.ID {
+				entry = running
+				return nil
+			}
+
+			running.Stop(now)
+			if err := tx.Save(running).Error; err != nil {
+				return err
+			}
+			stopped = running
+		}
+
+		entry = &model.TimeEntry{TodoID: todo.ID, AccountID: accountID}
+		entry.Start(now)
+
+		// Requests starting a timer of the account at the same time conflict on the running account, only one of
+		// them succeeds
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entry, stopped, nil
+}
+
+// StopTimer stops the timer the account runs on the todo, whatever state the todo is in
+func (tes *TimeEntryService) StopTimer(todoID uint, accountID uint) (*model.TimeEntry, error) {
+	running, err := findRunning(tes.db, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if running == nil || running.TodoID != todoID {
+		return nil, &utils.TIMER_NOT_RUNNING
+	}
+
+	running.Stop(time.Now())
+	if err := tes.db.Save(running).Error; err != nil {
+		return nil, err
+	}
+
+	return running, nil
+}
+
+// CreateEntry adds time that was not tracked with a timer, manual entries have to end after they start
+func (tes *TimeEntryService) CreateEntry(entry *model.TimeEntry) error {
+	if !entry.EndedAt.Valid || !entry.EndedAt.Time.After(entry.StartedAt) {
+		return &utils.TIME_ENTRY_INVALID
+	}
+
+	return tes.db.Create(entry).Error
+}
+
+// DeleteEntry deletes an entry the account tracked on the todo
+func (tes *TimeEntryService) DeleteEntry(todoID uint, entryID uint, accountID uint) error {
+	result := tes.db.Where("id = ? AND todo_id = ? AND account_id = ?", entryID, todoID, accountID).Delete(&model.TimeEntry{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// MarkRunning sets TimerRunning of the todo the account tracks time on
+func (tes *TimeEntryService) MarkRunning(todos []model.Todo, accountID uint) error {
+	running, err := tes.FindRunning(accountID)
+	if err != nil || running == nil {
+		return err
+	}
+
+	for i := range todos {
+		todos[i].TimerRunning = todos[i].ID == running.TodoID
+	}
+
+	return nil
+}
+
+// FindTimesheet lists the entries on todos of the space that started between from and to, with their todos and
+// accounts. Todos in the trash are included as the time was spent anyway. The bounds are converted to the local
+// timezone the times are stored in, see NowFunc of the database.
+func (tes *TimeEntryService) FindTimesheet(dest any, space model.Space, from time.Time, to time.Time) *gorm.DB {
+	return tes.db.
+		Preload("Account").
+		Preload("Todo", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }).
+		Where("todo_id IN (?)", todosIn(tes.db, space).Select("id")).
+		Where("started_at >= ? AND started_at < ?", from.Local(), to.Local()).
+		Order("started_at, id").
+		Find(dest)
+}
+
+// FindReportTodos finds the todos of the ids in the space to roll up the time of their subitems, todos in the trash
+// are included like in FindTimesheet
+func (tes *TimeEntryService) FindReportTodos(dest any, space model.Space, ids []uint) *gorm.DB {
+	return todosIn(tes.db, space).Where("id IN ?", ids).Find(dest)
+}
@@ -1,14 +1,27 @@
 package service
 
 import (
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/nleiva/go-todo-api/config"
 	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/rank"
 	"github.com/nleiva/go-todo-api/utils"
+	"gopkg.in/guregu/null.v4"
 	"gopkg.in/guregu/null.v4/zero"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // /TodoService is a service for managing accounts in the database
 // Instances of this service should be created using the NewTodoService function
+//
+// Every query is limited to a model.Space, so todos of other accounts and organizations can't be read or
+// changed even if a handler passes the id of a foreign todo. The only way out of a space are todos shared
+// with the account, see FindTodoWithRole.
 type TodoService struct {
 	db *gorm.DB
 }
@@ -21,38 +34,725 @@ func NewTodoService(db *gorm.DB) *TodoService {
 
 // TODO: Maybe move this to a separate file (own package?)
 type ITodoService interface {
-	FindTodos(dest any, accountID uint) *gorm.DB
-	FindTodoByID(dest any, id string, accountID uint) *gorm.DB
-	CreateTodo(todo *model.Todo) *gorm.DB
-	UpdateTodo(todo *model.Todo) *gorm.DB
-	DeleteTodoByID(id string) *gorm.DB
-	CreateRandomTodo(accountID uint) *gorm.DB
+	InSpace(space model.Space) *gorm.DB
+	FindTodos(dest any, space model.Space) *gorm.DB
+	FindTodoByID(dest any, id string, space model.Space) *gorm.DB
+	FindTodoWithRole(dest *model.Todo, id string, space model.Space) (role string, err error)
+	FindSharedTodos(dest any, accountID uint) *gorm.DB
+	Accessible(accountID uint) *gorm.DB
+	CanAccess(todo *model.Todo, accountID uint) (bool, error)
+	FindAssignedTodos(dest any, accountID uint) *gorm.DB
+	AssignTodo(todo *model.Todo, assigneeID *uint, actorID uint) error
+	FindHistory(dest any, todoID uint) *gorm.DB
+	RevertTodo(todo *model.Todo, revision uint, actorID uint) error
+	CountTodos(space model.Space) (total int64, completed int64, err error)
+	CreateTodo(todo *model.Todo, space model.Space) error
+	UpdateTodo(todo *model.Todo, actorID uint) error
+	MoveTodo(todo *model.Todo, statusID uint, actorID uint) error
+	ReorderTodo(todo *model.Todo, afterID *uint, beforeID *uint) error
+	RebalancePositions(maxLength int) (int64, error)
+	DeleteTodo(todo *model.Todo, actorID uint) error
+	FindTrash(dest any, space model.Space) *gorm.DB
+	FindTrashedTodo(dest any, id string, space model.Space) *gorm.DB
+	RestoreTodo(todo *model.Todo, actorID uint) error
+	FindExpiredTrash(before time.Time) ([]uint, error)
+	ArchiveTodo(todo *model.Todo, actorID uint) error
+	UnarchiveTodo(todo *model.Todo, actorID uint) error
+	ArchiveCompleted(space model.Space, actorID uint) (int64, error)
+	AutoArchive(now time.Time) (int64, error)
+	PurgeTodos(todoIDs ...uint) error
+	CreateRandomTodo(space model.Space) error
+}
+
+// InSpace returns the condition limiting todos to the space. Organization todos additionally require a
+// membership of the account, so a space with a foreign organization matches nothing.
+func (ts *TodoService) InSpace(space model.Space) *gorm.DB {
+	if space.IsPersonal() {
+		return ts.db.Where("todos.organization_id IS NULL AND todos.account_id = ?", space.AccountID)
+	}
+
+	memberships := ts.db.Model(&model.Membership{}).Select("organization_id").Where("account_id = ?", space.AccountID)
+
+	return ts.db.Where("todos.organization_id = ? AND todos.organization_id IN (?)", space.OrganizationID, memberships)
+}
+
+func (ts *TodoService) FindTodos(dest any, space model.Space) *gorm.DB {
+	return ts.db.Model(&model.Todo{}).Where(ts.InSpace(space)).Find(dest)
+}
+
+func (ts *TodoService) FindTodoByID(dest any, id string, space model.Space) *gorm.DB {
+	return ts.db.Model(&model.Todo{}).Where("id = ?", id).Where(ts.InSpace(space)).Take(dest)
+}
+
+// FindTodoWithRole finds a todo of the space or one shared with the account of the space and returns the role
+// of the account for it. Todos of the personal space are owned, todos of an organization have the role of the
+// membership and shared todos the role of their share.
+func (ts *TodoService) FindTodoWithRole(dest *model.Todo, id string, space model.Space) (string, error) {
+	err := ts.FindTodoByID(dest, id, space).Error
+	if err == nil {
+		if space.IsPersonal() {
+			return model.SHARE_ROLE_OWNER, nil
+		}
+
+		membership := &model.Membership{}
+		if err := ts.db.Where("organization_id = ? AND account_id = ?", space.OrganizationID, space.AccountID).Take(membership).Error; err != nil {
+			return "", err
+		}
+
+		return membership.ShareRole(), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	share := &model.TodoShare{}
+	if err := ts.db.Where("todo_id = ? AND account_id = ?", id, space.AccountID).Take(share).Error; err != nil {
+		return "", err
+	}
+
+	if err := ts.db.Take(dest, share.TodoID).Error; err != nil {
+		return "", err
+	}
+
+	return share.Role, nil
+}
+
+// FindSharedTodos lists the todos shared with the account with the role of each share, newest shares first
+func (ts *TodoService) FindSharedTodos(dest any, accountID uint) *gorm.DB {
+	return ts.db.Model(&model.Todo{}).
+		Select("todos.*, todo_shares.role AS share_role").
+		Joins("JOIN todo_shares ON todo_shares.todo_id = todos.id AND todo_shares.deleted_at IS NULL").
+		Where("todo_shares.account_id = ?", accountID).
+		Order("todo_shares.created_at desc").
+		Find(dest)
+}
+
+// Accessible returns the condition for all todos the account can see: those of its personal space, of its
+// organizations and those shared with it
+func (ts *TodoService) Accessible(accountID uint) *gorm.DB {
+	memberships := ts.db.Model(&model.Membership{}).Select("organization_id").Where("account_id = ?", accountID)
+	shares := ts.db.Model(&model.TodoShare{}).Select("todo_id").Where("account_id = ?", accountID)
+
+	return ts.db.Where("todos.organization_id IS NULL AND todos.account_id = ?", accountID).
+		Or("todos.organization_id IN (?)", memberships).
+		Or("todos.id IN (?)", shares)
+}
+
+// CanAccess reports whether the account can see the todo
+func (ts *TodoService) CanAccess(todo *model.Todo, accountID uint) (bool, error) {
+	var count int64
+	err := ts.db.Model(&model.Todo{}).Where("id = ?", todo.ID).Where(ts.Accessible(accountID)).Count(&count).Error
+
+	return count > 0, err
+}
+
+// FindAssignedTodos lists the todos assigned to the account it still has access to, newest first
+func (ts *TodoService) FindAssignedTodos(dest any, accountID uint) *gorm.DB {
+	return ts.db.Model(&model.Todo{}).
+		Where("assignee_id = ?", accountID).
+		Where(ts.Accessible(accountID)).
+		Order("created_at desc").
+		Find(dest)
+}
+
+// AssignTodo changes the assignee of the todo and records the change in its history
+func (ts *TodoService) AssignTodo(todo *model.Todo, assigneeID *uint, actorID uint) error {
+	return ts.db.Transaction(func(tx *gorm.DB) error {
+		change := model.TodoChange{
+			Field: "assignee_id",
+			From:  accountIDString(todo.AssigneeID),
+			To:    accountIDString(assigneeID),
+		}
+
+		if err := tx.Model(todo).Update("assignee_id", assigneeID).Error; err != nil {
+			return err
+		}
+		todo.AssigneeID = assigneeID
+
+		return recordHistory(tx, todo.ID, actorID, model.TODO_HISTORY_ASSIGNED, []model.TodoChange{change})
+	})
+}
+
+// FindHistory lists the history of the todo with the actors, oldest first
+func (ts *TodoService) FindHistory(dest any, todoID uint) *gorm.DB {
+	return ts.db.Preload("Actor").Where("todo_id = ?", todoID).Order("id").Find(dest)
+}
+
+// recordHistory stores the changes as the next revision of the todo. Changes without fields, like deleting,
+// are recorded as a single entry. The entries of the todo are locked while the revision is counted, changes
+// recorded at the same time that still get the same revision conflict on the unique index of the revision.
+func recordHistory(tx *gorm.DB, todoID uint, actorID uint, action string, changes []model.TodoChange) error {
+	var revision uint
+	if err := tx.Model(&model.TodoHistory{}).Clauses(clause.Locking{Strength: "UPDATE"}).Where("todo_id = ?", todoID).Select("COALESCE(MAX(revision), 0)").Scan(&revision).Error; err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		changes = []model.TodoChange{{}}
+	}
+
+	entries := make([]model.TodoHistory, len(changes))
+	for i, change := range changes {
+		entries[i] = model.TodoHistory{
+			TodoID:   todoID,
+			Revision: revision + 1,
+			ActorID:  actorID,
+			Action:   action,
+			Field:    change.Field,
+			From:     change.From,
+			To:       change.To,
+		}
+	}
+
+	return tx.Create(&entries).Error
+}
+
+// RevertTodo restores the fields of the todo to their values after the revision and records that as a new
+// revision. Revisions that don't exist return gorm.ErrRecordNotFound.
+func (ts *TodoService) RevertTodo(todo *model.Todo, revision uint, actorID uint) error {
+	history := []model.TodoHistory{}
+	if err := ts.db.Where("todo_id = ?", todo.ID).Order("revision, id").Find(&history).Error; err != nil {
+		return err
+	}
+
+	found := false
+	for _, entry := range history {
+		found = found || entry.Revision == revision
+	}
+	if !found {
+		return gorm.ErrRecordNotFound
+	}
+
+	if err := todo.RestoreRevision(history, revision); err != nil {
+		return err
+	}
+
+	return ts.saveTodo(todo, actorID, model.TODO_HISTORY_REVERTED)
+}
+
+func historyTime(t null.Time) null.String {
+	if !t.Valid {
+		return null.String{}
+	}
+
+	return null.StringFrom(t.Time.Format(time.RFC3339Nano))
+}
+
+func accountIDString(id *uint) null.String {
+	if id == nil {
+		return null.String{}
+	}
+
+	return null.StringFrom(strconv.FormatUint(uint64(*id), 10))
+}
+
+// CountTodos counts all and the completed todos of the space
+func (ts *TodoService) CountTodos(space model.Space) (int64, int64, error) {
+	var total, completed int64
+
+	if err := ts.db.Model(&model.Todo{}).Where(ts.InSpace(space)).Count(&total).Error; err != nil {
+		return 0, 0, err
+	}
+
+	if err := ts.db.Model(&model.Todo{}).Where(ts.InSpace(space)).Where("completed = ?", true).Count(&completed).Error; err != nil {
+		return 0, 0, err
+	}
+
+	return total, completed, nil
+}
+
+// CreateTodo stores the todo in the space, the account of the space is recorded as its creator and as the
+// actor of the first revision
+func (ts *TodoService) CreateTodo(todo *model.Todo, space model.Space) error {
+	return ts.db.Transaction(func(tx *gorm.DB) error {
+		return createTodo(tx, todo, space)
+	})
+}
+
+func createTodo(tx *gorm.DB, todo *model.Todo, space model.Space) error {
+	todo.AccountID = space.AccountID
+	todo.OrganizationID = nil
+	if !space.IsPersonal() {
+		organizationID := space.OrganizationID
+		todo.OrganizationID = &organizationID
+	}
+	if todo.Priority == "" {
+		todo.Priority = model.TODO_PRIORITY_NONE
+	}
+
+	if err := syncStatus(tx, nil, todo); err != nil {
+		return err
+	}
+
+	position, err := firstPosition(tx, todo.Space())
+	if err != nil {
+		return err
+	}
+	todo.Position = position
+
+	if err := tx.Create(todo).Error; err != nil {
+		return err
+	}
+
+	changes, err := model.DiffTodos(nil, todo)
+	if err != nil {
+		return err
+	}
+
+	return recordHistory(tx, todo.ID, space.AccountID, model.TODO_HISTORY_CREATED, changes)
+}
+
+// UpdateTodo saves the todo and records the changed fields as a new revision. Changes of only the completion
+// are recorded as completed or reopened.
+func (ts *TodoService) UpdateTodo(todo *model.Todo, actorID uint) error {
+	return ts.saveTodo(todo, actorID, model.TODO_HISTORY_UPDATED)
+}
+
+func (ts *TodoService) saveTodo(todo *model.Todo, actorID uint, action string) error {
+	return ts.db.Transaction(func(tx *gorm.DB) error {
+		before := &model.Todo{}
+		if err := tx.Take(before, todo.ID).Error; err != nil {
+			return err
+		}
+
+		if err := syncStatus(tx, before, todo); err != nil {
+			return err
+		}
+
+		if config.DEPENDENCIES_BLOCK_COMPLETION && todo.Completed && !before.Completed {
+			blocked, err := openBlockers(tx, []uint{todo.ID})
+			if err != nil {
+				return err
+			}
+			if len(blocked) > 0 {
+				return &utils.TODO_BLOCKED
+			}
+		}
+
+		changes, err := model.DiffTodos(before, todo)
+		if err != nil {
+			return err
+		}
+		if !equalIDs(before.StatusID, todo.StatusID) {
+			change, err := statusChange(tx, before.StatusID, todo.StatusID)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, change)
+		}
+
+		if err := tx.Save(todo).Error; err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+
+		if action == model.TODO_HISTORY_UPDATED && onlyCompletion(changes) {
+			action = model.TODO_HISTORY_REOPENED
+			if todo.Completed {
+				action = model.TODO_HISTORY_COMPLETED
+			}
+		}
+
+		return recordHistory(tx, todo.ID, actorID, action, changes)
+	})
+}
+
+func onlyCompletion(changes []model.TodoChange) bool {
+	for _, change := range changes {
+		if change.Field != "completed" && change.Field != "completed_at" && change.Field != "status" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MoveTodo moves the todo to another status of its space if the workflow allows it, the todo is completed or
+// reopened by the status
+func (ts *TodoService) MoveTodo(todo *model.Todo, statusID uint, actorID uint) error {
+	status := &model.Status{}
+	if err := statusesIn(ts.db, todo.Space()).Where("id = ?", statusID).Take(status).Error; err != nil {
+		return err
+	}
+
+	if todo.StatusID != nil && *todo.StatusID != status.ID {
+		allowed, err := allowedTransitions(ts.db, *todo.StatusID)
+		if err != nil {
+			return err
+		}
+		if allowed != nil && !slices.Contains(allowed, status.ID) {
+			return &utils.STATUS_TRANSITION_NOT_ALLOWED
+		}
+	}
+
+	todo.StatusID = &status.ID
+
+	return ts.saveTodo(todo, actorID, model.TODO_HISTORY_MOVED)
+}
+
+// ReorderTodo moves the todo in the manual order of its space between the todo afterID and the todo beforeID,
+// one of them is enough to place it right after or before a todo. Only the position of the todo changes unless
+// the keys ran out of room and the space has to be rebalanced.
+func (ts *TodoService) ReorderTodo(todo *model.Todo, afterID *uint, beforeID *uint) error {
+	if (afterID != nil && *afterID == todo.ID) || (beforeID != nil && *beforeID == todo.ID) {
+		return &utils.TODO_NEIGHBORS_INVALID
+	}
+
+	return ts.db.Transaction(func(tx *gorm.DB) error {
+		space := todo.Space()
+		if err := ensurePositions(tx, space); err != nil {
+			return err
+		}
+
+		for rebalanced := false; ; rebalanced = true {
+			low, high, err := neighborPositions(tx, space, todo.ID, afterID, beforeID)
+			if err != nil {
+				return err
+			}
+
+			position, err := rank.Between(low, high)
+			if err == nil && len(position) <= model.TODO_POSITION_MAX_LENGTH {
+				todo.Position = position
+				return tx.Model(todo).UpdateColumn("position", position).Error
+			}
+
+			// Equal keys of concurrent moves and long keys are fixed by rebalancing, neighbors out of order are not
+			if rebalanced || (err != nil && low > high) {
+				return &utils.TODO_NEIGHBORS_INVALID
+			}
+			if err := rebalancePositions(tx, space); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// neighborPositions returns the positions the todo is moved between. A missing neighbor is the next todo in the
+// order, or the start or end of the list if there is none.
+func neighborPositions(tx *gorm.DB, space model.Space, todoID uint, afterID *uint, beforeID *uint) (string, string, error) {
+	var low, high string
+
+	neighbors := func() *gorm.DB {
+		return todosIn(tx, space).Where("deleted_at IS NULL AND id <> ?", todoID)
+	}
+
+	if afterID == nil && beforeID == nil {
+		return "", "", &utils.TODO_NEIGHBORS_INVALID
+	}
+
+	find := func(id uint) (string, error) {
+		todo := &model.Todo{}
+		if err := neighbors().Where("id = ?", id).Take(todo).Error; err != nil {
+			return "", err
+		}
+		return todo.Position, nil
+	}
+
+	var err error
+	if afterID != nil {
+		if low, err = find(*afterID); err != nil {
+			return "", "", err
+		}
+	}
+	if beforeID != nil {
+		if high, err = find(*beforeID); err != nil {
+			return "", "", err
+		}
+	}
+
+	next := &model.Todo{}
+	if beforeID == nil {
+		err = neighbors().Where("position > ?", low).Order("position").Limit(1).Find(next).Error
+		high = next.Position
+	} else if afterID == nil {
+		err = neighbors().Where("position < ?", high).Order("position desc").Limit(1).Find(next).Error
+		low = next.Position
+	}
+
+	return low, high, err
+}
+
+// firstPosition returns a position before all todos of the space, new todos are put on top of the list
+func firstPosition(tx *gorm.DB, space model.Space) (string, error) {
+	if err := ensurePositions(tx, space); err != nil {
+		return "", err
+	}
+
+	first := &model.Todo{}
+	if err := todosIn(tx, space).Where("deleted_at IS NULL").Order("position").Limit(1).Find(first).Error; err != nil {
+		return "", err
+	}
+
+	position, err := rank.Between("", first.Position)
+	if err != nil || len(position) > model.TODO_POSITION_MAX_LENGTH {
+		if err := rebalancePositions(tx, space); err != nil {
+			return "", err
+		}
+		return firstPosition(tx, space)
+	}
+
+	return position, nil
+}
+
+// ensurePositions gives todos created before the manual order a position, keeping them in their old order of
+// newest first
+func ensurePositions(tx *gorm.DB, space model.Space) error {
+	var missing int64
+	if err := todosIn(tx, space).Where("position = ''").Count(&missing).Error; err != nil || missing == 0 {
+		return err
+	}
+
+	return rebalancePositions(tx, space)
+}
+
+// rebalancePositions spreads the positions of all todos of the space evenly, keeping their order
+func rebalancePositions(tx *gorm.DB, space model.Space) error {
+	todoIDs := []uint{}
+	err := todosIn(tx, space).
+		Order("CASE WHEN position = '' THEN 0 ELSE 1 END, position, created_at desc, id desc").
+		Pluck("id", &todoIDs).Error
+	if err != nil {
+		return err
+	}
+
+	for i, position := range rank.Spread(len(todoIDs)) {
+		if err := tx.Unscoped().Model(&model.Todo{}).Where("id = ?", todoIDs[i]).UpdateColumn("position", position).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RebalancePositions rebalances the spaces with positions longer than maxLength and returns their number
+func (ts *TodoService) RebalancePositions(maxLength int) (int64, error) {
+	long := ts.db.Unscoped().Model(&model.Todo{}).Where("LENGTH(position) > ? OR position = ''", maxLength)
+
+	spaces := []model.Space{}
+	accountIDs := []uint{}
+	if err := long.Session(&gorm.Session{}).Where("organization_id IS NULL").Distinct().Pluck("account_id", &accountIDs).Error; err != nil {
+		return 0, err
+	}
+	for _, accountID := range accountIDs {
+		spaces = append(spaces, model.Space{AccountID: accountID})
+	}
+
+	organizationIDs := []uint{}
+	if err := long.Session(&gorm.Session{}).Where("organization_id IS NOT NULL").Distinct().Pluck("organization_id", &organizationIDs).Error; err != nil {
+		return 0, err
+	}
+	for _, organizationID := range organizationIDs {
+		spaces = append(spaces, model.Space{OrganizationID: organizationID})
+	}
+
+	for i, space := range spaces {
+		if err := ts.db.Transaction(func(tx *gorm.DB) error {
+			return rebalancePositions(tx, space)
+		}); err != nil {
+			return int64(i), err
+		}
+	}
+
+	return int64(len(spaces)), nil
 }
 
-func (ts *TodoService) FindTodos(dest any, accountID uint) *gorm.DB {
-	return ts.db.Find(dest, "account_id = ?", accountID)
+func equalIDs(a *uint, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+// DeleteTodo moves the todo with its shares to the trash. Callers check the access first, see FindTodoWithRole.
+func (ts *TodoService) DeleteTodo(todo *model.Todo, actorID uint) error {
+	return ts.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("todo_id = ?", todo.ID).Delete(&model.TodoShare{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(todo).Error; err != nil {
+			return err
+		}
+
+		return recordHistory(tx, todo.ID, actorID, model.TODO_HISTORY_DELETED, nil)
+	})
 }
 
-func (ts *TodoService) FindTodoByID(dest any, id string, accountID uint) *gorm.DB {
-	return ts.db.Model(&model.Todo{}).Where("id = ? AND account_id = ?", id, accountID).Take(dest)
+// FindTrash lists the deleted todos of the space, most recently deleted first
+func (ts *TodoService) FindTrash(dest any, space model.Space) *gorm.DB {
+	return ts.db.Unscoped().Model(&model.Todo{}).
+		Where(ts.InSpace(space)).
+		Where("todos.deleted_at IS NOT NULL").
+		Order("todos.deleted_at desc").
+		Find(dest)
 }
 
-func (ts *TodoService) CreateTodo(todo *model.Todo) *gorm.DB {
-	return ts.db.Create(todo)
+// FindTrashedTodo finds a deleted todo of the space
+func (ts *TodoService) FindTrashedTodo(dest any, id string, space model.Space) *gorm.DB {
+	return ts.db.Unscoped().Model(&model.Todo{}).
+		Where("id = ?", id).
+		Where(ts.InSpace(space)).
+		Where("todos.deleted_at IS NOT NULL").
+		Take(dest)
 }
 
-func (ts *TodoService) UpdateTodo(todo *model.Todo) *gorm.DB {
-	return ts.db.Save(todo)
+// RestoreTodo takes the todo with the shares deleted together with it out of the trash
+func (ts *TodoService) RestoreTodo(todo *model.Todo, actorID uint) error {
+	return ts.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&model.TodoShare{}).Where("todo_id = ? AND deleted_at IS NOT NULL", todo.ID).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(todo).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		todo.DeletedAt = gorm.DeletedAt{}
+
+		return recordHistory(tx, todo.ID, actorID, model.TODO_HISTORY_RESTORED, nil)
+	})
 }
 
-func (ts *TodoService) DeleteTodoByID(id string) *gorm.DB {
-	return ts.db.Delete(&model.Todo{}, id)
+// FindExpiredTrash returns the ids of all todos deleted before the time, regardless of their space
+func (ts *TodoService) FindExpiredTrash(before time.Time) ([]uint, error) {
+	ids := []uint{}
+	err := ts.db.Unscoped().Model(&model.Todo{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Pluck("id", &ids).Error
+
+	return ids, err
 }
 
-func (ts *TodoService) CreateRandomTodo(accountID uint) *gorm.DB {
-	return ts.db.Create(&model.Todo{
+// PurgeTodos permanently deletes the todos with their shares, history, comments and notifications. Attachments
+// are kept in a blob store and deleted by the AttachmentService.
+func (ts *TodoService) PurgeTodos(todoIDs ...uint) error {
+	if len(todoIDs) == 0 {
+		return nil
+	}
+
+	return ts.db.Transaction(func(tx *gorm.DB) error {
+		for _, related := range []any{&model.TodoShare{}, &model.TodoHistory{}, &model.Comment{}, &model.Notification{}, &model.TimeEntry{}} {
+			if err := tx.Unscoped().Where("todo_id IN ?", todoIDs).Delete(related).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("todo_id IN ? OR blocked_by_id IN ?", todoIDs, todoIDs).Delete(&model.TodoDependency{}).Error; err != nil {
+			return err
+		}
+
+		// Subitems of purged todos become top level todos
+		if err := tx.Unscoped().Model(&model.Todo{}).Where("parent_id IN ?", todoIDs).Update("parent_id", nil).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Where("id IN ?", todoIDs).Delete(&model.Todo{}).Error
+	})
+}
+
+// ArchiveTodo archives the todo and records it in the history
+func (ts *TodoService) ArchiveTodo(todo *model.Todo, actorID uint) error {
+	archivedAt := null.TimeFrom(time.Now())
+
+	if err := ts.db.Transaction(func(tx *gorm.DB) error {
+		return archiveTodos(tx, []uint{todo.ID}, todo.ArchivedAt, archivedAt, actorID)
+	}); err != nil {
+		return err
+	}
+	todo.ArchivedAt = archivedAt
+
+	return nil
+}
+
+// UnarchiveTodo takes the todo out of the archive and records it in the history
+func (ts *TodoService) UnarchiveTodo(todo *model.Todo, actorID uint) error {
+	if err := ts.db.Transaction(func(tx *gorm.DB) error {
+		return archiveTodos(tx, []uint{todo.ID}, todo.ArchivedAt, null.Time{}, actorID)
+	}); err != nil {
+		return err
+	}
+	todo.ArchivedAt = null.Time{}
+
+	return nil
+}
+
+// ArchiveCompleted archives all completed todos of the space and returns how many were archived
+func (ts *TodoService) ArchiveCompleted(space model.Space, actorID uint) (int64, error) {
+	todoIDs := []uint{}
+
+	err := ts.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Todo{}).Where(ts.InSpace(space)).Where("completed = ? AND archived_at IS NULL", true).Pluck("id", &todoIDs).Error; err != nil {
+			return err
+		}
+
+		return archiveTodos(tx, todoIDs, null.Time{}, null.TimeFrom(time.Now()), actorID)
+	})
+
+	return int64(len(todoIDs)), err
+}
+
+// AutoArchive archives the todos completed more than AutoArchiveDays ago for every account with the rule turned
+// on. The rule of an account applies to the todos it created, the account is recorded as the actor. Todos
+// completed without a completion time count as completed at their last update.
+func (ts *TodoService) AutoArchive(now time.Time) (int64, error) {
+	accounts := []model.Account{}
+	if err := ts.db.Where("auto_archive_days > 0").Find(&accounts).Error; err != nil {
+		return 0, err
+	}
+
+	var archived int64
+	for _, account := range accounts {
+		cutoff := now.AddDate(0, 0, -int(account.AutoArchiveDays))
+
+		err := ts.db.Transaction(func(tx *gorm.DB) error {
+			todoIDs := []uint{}
+			if err := tx.Model(&model.Todo{}).
+				Where("account_id = ? AND completed = ? AND archived_at IS NULL", account.ID, true).
+				Where("COALESCE(completed_at, updated_at) < ?", cutoff).
+				Pluck("id", &todoIDs).Error; err != nil {
+				return err
+			}
+			archived += int64(len(todoIDs))
+
+			return archiveTodos(tx, todoIDs, null.Time{}, null.TimeFrom(now), account.ID)
+		})
+		if err != nil {
+			return archived, err
+		}
+	}
+
+	return archived, nil
+}
+
+// archiveTodos changes the archive time of the todos from the previous one, a null time unarchives them. Every
+// todo gets a revision in its history.
+func archiveTodos(tx *gorm.DB, todoIDs []uint, from null.Time, archivedAt null.Time, actorID uint) error {
+	if len(todoIDs) == 0 {
+		return nil
+	}
+
+	if err := tx.Model(&model.Todo{}).Where("id IN ?", todoIDs).Update("archived_at", archivedAt).Error; err != nil {
+		return err
+	}
+
+	action := model.TODO_HISTORY_UNARCHIVED
+	change := model.TodoChange{Field: "archived_at", From: historyTime(from), To: historyTime(archivedAt)}
+	if archivedAt.Valid {
+		action = model.TODO_HISTORY_ARCHIVED
+	}
+
+	for _, todoID := range todoIDs {
+		if err := recordHistory(tx, todoID, actorID, action, []model.TodoChange{change}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ts *TodoService) CreateRandomTodo(space model.Space) error {
+	return ts.CreateTodo(&model.Todo{
 		Title:       zero.StringFrom(utils.RandomString(100, "")),
 		Description: zero.StringFrom(utils.RandomString(100, "")),
-		AccountID:   accountID,
-	})
+	}, space)
 }
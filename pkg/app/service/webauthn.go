@@ -0,0 +1,322 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/utils"
+	"gopkg.in/guregu/null.v4"
+	"gorm.io/gorm"
+)
+
+// WebAuthnService runs the passkey registration and login ceremonies
+// Instances of this service should be created using the NewWebAuthnService function
+type WebAuthnService struct {
+	db       *gorm.DB
+	webAuthn *webauthn.WebAuthn
+}
+
+func NewWebAuthnService(db *gorm.DB) *WebAuthnService {
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          config.WEBAUTHN_RP_ID,
+		RPDisplayName: config.WEBAUTHN_RP_DISPLAY_NAME,
+		RPOrigins:     config.WEBAUTHN_RP_ORIGINS,
+	})
+	if err != nil {
+		panic("[NewWebAuthnService]::invalid relying party configuration: " + err.Error())
+	}
+
+	return &WebAuthnService{
+		db:       db,
+		webAuthn: w,
+	}
+}
+
+type IWebAuthnService interface {
+	BeginRegistration(account *model.Account) (*protocol.CredentialCreation, error)
+	FinishRegistration(account *model.Account, name string, body []byte) (*model.WebAuthnCredential, error)
+	BeginLogin(account *model.Account) (*protocol.CredentialAssertion, error)
+	FinishLogin(body []byte) (*model.Account, error)
+
+	FindCredentials(dest any, accountID uint) *gorm.DB
+	DeleteCredentialByID(id string, accountID uint) *gorm.DB
+}
+
+// webAuthnUser adapts an account and its stored credentials to the webauthn.User interface
+type webAuthnUser struct {
+	account     *model.Account
+	credentials []model.WebAuthnCredential
+}
+
+// WebAuthnID is the user handle. The account id is used so discoverable logins can resolve the account directly.
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(strconv.FormatUint(uint64(u.account.ID), 10))
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.account.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if u.account.Firstname != "" || u.account.Lastname != "" {
+		return u.account.Firstname + " " + u.account.Lastname
+	}
+
+	return u.account.Email
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(u.credentials))
+	for i, credential := range u.credentials {
+		credentials[i] = credential.Credential()
+	}
+
+	return credentials
+}
+
+func (ws *WebAuthnService) BeginRegistration(account *model.Account) (*protocol.CredentialCreation, error) {
+	user, err := ws.loadUser(account)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't let the same authenticator register twice for one account
+	exclusions := make([]protocol.CredentialDescriptor, len(user.credentials))
+	for i, credential := range user.WebAuthnCredentials() {
+		exclusions[i] = credential.Descriptor()
+	}
+
+	options, session, err := ws.webAuthn.BeginRegistration(
+		user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ws.storeSession(account.ID, model.WEBAUTHN_CEREMONY_REGISTRATION, session); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+func (ws *WebAuthnService) FinishRegistration(account *model.Account, name string, body []byte) (*model.WebAuthnCredential, error) {
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(body)
+	if err != nil {
+		return nil, utils.RequestErrorFrom(&utils.WEBAUTHN_VERIFICATION_FAILED, err.Error())
+	}
+
+	session, err := ws.takeSession(parsed.Response.CollectedClientData.Challenge, model.WEBAUTHN_CEREMONY_REGISTRATION)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.UserID == nil || string(session.UserID) != strconv.FormatUint(uint64(account.ID), 10) {
+		return nil, &utils.WEBAUTHN_CHALLENGE_INVALID
+	}
+
+	user, err := ws.loadUser(account)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := ws.webAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return nil, utils.RequestErrorFrom(&utils.WEBAUTHN_VERIFICATION_FAILED, webAuthnErrorDetail(err))
+	}
+
+	if name == "" {
+		name = "Passkey"
+	}
+
+	stored := model.NewWebAuthnCredential(account.ID, name, credential)
+	if err := ws.db.Create(stored).Error; err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// BeginLogin starts a login ceremony. When no account is given, or the account has no passkeys, a discoverable
+// login is started and the authenticator decides which passkey is used. Accounts without passkeys get the same
+// response as unknown emails, so it doesn't reveal which accounts exist.
+func (ws *WebAuthnService) BeginLogin(account *model.Account) (*protocol.CredentialAssertion, error) {
+	var user *webAuthnUser
+	var err error
+	if account != nil {
+		if user, err = ws.loadUser(account); err != nil {
+			return nil, err
+		}
+	}
+
+	var options *protocol.CredentialAssertion
+	var session *webauthn.SessionData
+	var accountID uint
+
+	if user == nil || len(user.credentials) == 0 {
+		if options, session, err = ws.webAuthn.BeginDiscoverableLogin(); err != nil {
+			return nil, err
+		}
+	} else {
+		if options, session, err = ws.webAuthn.BeginLogin(user); err != nil {
+			return nil, utils.RequestErrorFrom(&utils.WEBAUTHN_VERIFICATION_FAILED, webAuthnErrorDetail(err))
+		}
+
+		accountID = account.ID
+	}
+
+	if err := ws.storeSession(accountID, model.WEBAUTHN_CEREMONY_LOGIN, session); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// FinishLogin verifies the assertion and returns the account the passkey belongs to
+func (ws *WebAuthnService) FinishLogin(body []byte) (*model.Account, error) {
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(body)
+	if err != nil {
+		return nil, utils.RequestErrorFrom(&utils.WEBAUTHN_VERIFICATION_FAILED, err.Error())
+	}
+
+	session, err := ws.takeSession(parsed.Response.CollectedClientData.Challenge, model.WEBAUTHN_CEREMONY_LOGIN)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *webAuthnUser
+	var credential *webauthn.Credential
+
+	if session.UserID == nil {
+		credential, err = ws.webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			accountID, err := strconv.ParseUint(string(userHandle), 10, 32)
+			if err != nil {
+				return nil, err
+			}
+
+			account := &model.Account{}
+			if err := ws.db.Take(account, "id = ?", accountID).Error; err != nil {
+				return nil, err
+			}
+
+			user, err = ws.loadUser(account)
+			return user, err
+		}, *session, parsed)
+	} else {
+		account := &model.Account{}
+		if err := ws.db.Take(account, "id = ?", string(session.UserID)).Error; err != nil {
+			return nil, utils.RequestErrorFrom(&utils.WEBAUTHN_VERIFICATION_FAILED, err.Error())
+		}
+
+		if user, err = ws.loadUser(account); err != nil {
+			return nil, err
+		}
+
+		credential, err = ws.webAuthn.ValidateLogin(user, *session, parsed)
+	}
+	if err != nil {
+		return nil, utils.RequestErrorFrom(&utils.WEBAUTHN_VERIFICATION_FAILED, webAuthnErrorDetail(err))
+	}
+
+	// A counter that did not increase means the private key might have been cloned
+	if credential.Authenticator.CloneWarning {
+		return nil, utils.RequestErrorFrom(&utils.WEBAUTHN_VERIFICATION_FAILED, "signature counter did not increase")
+	}
+
+	if err := ws.db.Model(&model.WebAuthnCredential{}).
+		Where("credential_id = ? AND account_id = ?", credential.ID, user.account.ID).
+		Updates(map[string]any{
+			"sign_count":   credential.Authenticator.SignCount,
+			"backup_state": credential.Flags.BackupState,
+			"last_used_at": null.TimeFrom(time.Now()),
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	return user.account, nil
+}
+
+func (ws *WebAuthnService) FindCredentials(dest any, accountID uint) *gorm.DB {
+	return ws.db.Model(&model.WebAuthnCredential{}).Where("account_id = ?", accountID).Order("created_at asc").Find(dest)
+}
+
+func (ws *WebAuthnService) DeleteCredentialByID(id string, accountID uint) *gorm.DB {
+	return ws.db.Unscoped().Where("id = ? AND account_id = ?", id, accountID).Delete(&model.WebAuthnCredential{})
+}
+
+func (ws *WebAuthnService) loadUser(account *model.Account) (*webAuthnUser, error) {
+	user := &webAuthnUser{account: account}
+
+	if err := ws.FindCredentials(&user.credentials, account.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (ws *WebAuthnService) storeSession(accountID uint, ceremony string, session *webauthn.SessionData) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	// Clean up ceremonies that were started but never finished
+	ws.db.Unscoped().Where("expires_at < ?", time.Now()).Delete(&model.WebAuthnSession{})
+
+	return ws.db.Create(&model.WebAuthnSession{
+		Challenge: session.Challenge,
+		AccountID: accountID,
+		Ceremony:  ceremony,
+		Data:      data,
+		ExpiresAt: time.Now().Add(config.WEBAUTHN_CHALLENGE_EXP),
+	}).Error
+}
+
+// takeSession loads and removes the session of the given challenge so every challenge can only be answered once
+func (ws *WebAuthnService) takeSession(challenge string, ceremony string) (*webauthn.SessionData, error) {
+	stored := &model.WebAuthnSession{}
+	if err := ws.db.Take(stored, "challenge = ? AND ceremony = ?", challenge, ceremony).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &utils.WEBAUTHN_CHALLENGE_INVALID
+		}
+		return nil, err
+	}
+
+	// Only the request that deletes the session may use it, concurrent answers to the same challenge fail
+	result := ws.db.Unscoped().Delete(stored)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, &utils.WEBAUTHN_CHALLENGE_INVALID
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, &utils.WEBAUTHN_CHALLENGE_INVALID
+	}
+
+	session := &webauthn.SessionData{}
+	if err := json.Unmarshal(stored.Data, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// webAuthnErrorDetail extracts the more helpful details from the errors returned by the webauthn library
+func webAuthnErrorDetail(err error) string {
+	var protocolError *protocol.Error
+	if errors.As(err, &protocolError) && protocolError.Details != "" {
+		return protocolError.Details
+	}
+
+	return err.Error()
+}
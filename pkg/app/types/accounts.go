@@ -15,6 +15,12 @@ type GetAccountResponse struct {
 }
 
 type GetMeResponse struct {
-	Account model.Account    `json:"account"`
-	Auth    AuthResponseBody `json:"auth"`
+	Account model.Account `json:"account"`
+	// Auth are fresh first party tokens, OAuth tokens only get the profile
+	Auth *AuthResponseBody `json:"auth,omitempty"`
+}
+
+type GetLoginAttemptsResponse struct {
+	LoginAttempts []model.LoginAttempt `json:"loginAttempts"`
+	Meta          pagination.Meta      `json:"_meta"`
 }
@@ -0,0 +1,14 @@
+package types
+
+type ArchiveCompletedResponse struct {
+	Archived int64 `json:"archived"`
+}
+
+// AutoArchiveDTO is the auto-archive rule of the account, completed todos are archived after Days, 0 turns it off
+type AutoArchiveDTO struct {
+	Days uint `json:"days" form:"days" validate:"max=3650"`
+}
+
+type AutoArchiveResponse struct {
+	Days uint `json:"days"`
+}
@@ -0,0 +1,20 @@
+package types
+
+import "github.com/nleiva/go-todo-api/pkg/app/model"
+
+type AssignTodoDTO struct {
+	// AssigneeID is the account to assign, null unassigns the todo
+	AssigneeID *uint `json:"assigneeId"`
+}
+
+type GetAssignedTodosResponse struct {
+	Todos []model.Todo `json:"todos"`
+}
+
+type GetTodoHistoryResponse struct {
+	History []model.TodoHistory `json:"history"`
+}
+
+type GetNotificationsResponse struct {
+	Notifications []model.Notification `json:"notifications"`
+}
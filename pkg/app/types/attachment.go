@@ -0,0 +1,11 @@
+package types
+
+import "github.com/nleiva/go-todo-api/pkg/app/model"
+
+type AttachmentResponse struct {
+	Attachment model.Attachment `json:"attachment"`
+}
+
+type GetAttachmentsResponse struct {
+	Attachments []model.Attachment `json:"attachments"`
+}
@@ -11,16 +11,25 @@ type LoginDTO struct {
 
 type RegisterDTOBody struct {
 	Email           string `json:"email" form:"email" validate:"required,email"`
-	Password        string `json:"password" form:"password" validate:"required,min=6,max=100"`
-	ConfirmPassword string `json:"confirmPassword" form:"confirmPassword" validate:"required,min=6,max=100"`
+	Password        string `json:"password" form:"password" validate:"required"`
+	ConfirmPassword string `json:"confirmPassword" form:"confirmPassword" validate:"required"`
 	Firstname       string `json:"firstname" form:"firstname" validate:"omitempty,min=2"`
 	Lastname        string `json:"lastname" form:"lastname" validate:"omitempty,min=2"`
+	// InvitationCode is required while registration is invite-only (see config.REGISTRATION_MODE)
+	InvitationCode string `json:"invitationCode" form:"invitationCode" validate:"omitempty"`
 }
 
 type RegisterDTO struct {
 	Account RegisterDTOBody `json:"account"`
 }
 
+// ChangePasswordDTO is checked against the password policy (see password.Policy) instead of validator tags
+type ChangePasswordDTO struct {
+	CurrentPassword string `json:"currentPassword" form:"currentPassword" validate:"required"`
+	Password        string `json:"password" form:"password" validate:"required"`
+	ConfirmPassword string `json:"confirmPassword" form:"confirmPassword" validate:"required"`
+}
+
 type AuthResponseBody struct {
 	Token        string `json:"token"`
 	RefreshToken string `json:"refreshToken"`
@@ -29,3 +38,7 @@ type AuthResponseBody struct {
 type AuthResponse struct {
 	Auth AuthResponseBody `json:"auth"`
 }
+
+type MagicLinkRequestDTO struct {
+	Email string `json:"email" form:"email" validate:"required,email"`
+}
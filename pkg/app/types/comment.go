@@ -0,0 +1,16 @@
+package types
+
+import "github.com/nleiva/go-todo-api/pkg/app/model"
+
+type CommentDTO struct {
+	// Body in markdown, mention accounts with @email
+	Body string `json:"body" form:"body" validate:"required,max=10000"`
+}
+
+type CommentResponse struct {
+	Comment model.Comment `json:"comment"`
+}
+
+type GetCommentsResponse struct {
+	Comments []model.Comment `json:"comments"`
+}
@@ -0,0 +1,21 @@
+package types
+
+import "github.com/nleiva/go-todo-api/pkg/app/model"
+
+type AddDependencyDTO struct {
+	// BlockedByID is the todo that has to be done first, it has to be in the same space
+	BlockedByID uint `json:"blockedById" form:"blockedById" validate:"required"`
+}
+
+type GetDependenciesResponse struct {
+	// BlockedBy are the todos the todo waits for
+	BlockedBy []model.Todo `json:"blockedBy"`
+	// Blocks are the todos waiting for the todo
+	Blocks []model.Todo `json:"blocks"`
+}
+
+// DependencyGraph is the JSON format of the dependency graph, edges point from the blocker to the blocked todo
+type DependencyGraph struct {
+	Nodes []model.Todo           `json:"nodes"`
+	Edges []model.TodoDependency `json:"edges"`
+}
@@ -0,0 +1,24 @@
+package types
+
+import "github.com/nleiva/go-todo-api/pkg/app/model"
+
+type CreateInvitationDTO struct {
+	// MaxUses defaults to a single use
+	MaxUses int `json:"maxUses" validate:"omitempty,min=1,max=1000"`
+	// ExpiresIn is a duration like "72h", it defaults to INVITATION_EXP
+	ExpiresIn string `json:"expiresIn" validate:"omitempty"`
+	// Permission of the accounts registered with the invitation, defaults to reading the own account
+	Permission *uint64 `json:"permission"`
+}
+
+type CreateInvitationResponse struct {
+	Invitation model.Invitation `json:"invitation"`
+	// Code is only returned once, only its hash is stored
+	Code string `json:"code"`
+	// URL opens the registration page with the code filled in
+	URL string `json:"url"`
+}
+
+type GetInvitationsResponse struct {
+	Invitations []model.Invitation `json:"invitations"`
+}
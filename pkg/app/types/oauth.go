@@ -0,0 +1,65 @@
+package types
+
+import "github.com/nleiva/go-todo-api/pkg/app/model"
+
+type CreateOAuthClientDTO struct {
+	Name         string   `json:"name" validate:"required,min=2,max=100"`
+	RedirectURIs []string `json:"redirectUris" validate:"omitempty,dive,url"`
+	Scopes       []string `json:"scopes" validate:"required,min=1,dive,required"`
+	Confidential bool     `json:"confidential"`
+}
+
+type CreateOAuthClientResponse struct {
+	Client model.OAuthClient `json:"client"`
+	// ClientSecret is only returned once, only its hash is stored
+	ClientSecret string `json:"clientSecret,omitempty"`
+}
+
+type GetOAuthClientsResponse struct {
+	Clients []model.OAuthClient `json:"clients"`
+}
+
+// OAuthTokenResponse is the successful response of the token endpoint (RFC 6749 section 5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// OAuthIntrospectionResponse is the response of the introspection endpoint (RFC 7662 section 2.2).
+// Inactive tokens only return active=false.
+type OAuthIntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+}
+
+// OAuthError is a protocol error of the authorization server (RFC 6749 section 5.2).
+// It is returned in the OAuth format instead of the RequestError format so standard client libraries understand it.
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	StatusCode  int    `json:"-"`
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// OAuthConsent is the authorization request shown on the consent page and posted back with the decision
+type OAuthConsent struct {
+	ClientID            string `query:"client_id" form:"client_id"`
+	RedirectURI         string `query:"redirect_uri" form:"redirect_uri"`
+	ResponseType        string `query:"response_type" form:"response_type"`
+	Scope               string `query:"scope" form:"scope"`
+	State               string `query:"state" form:"state"`
+	CodeChallenge       string `query:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" form:"code_challenge_method"`
+}
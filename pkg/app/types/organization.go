@@ -0,0 +1,49 @@
+package types
+
+import "github.com/nleiva/go-todo-api/pkg/app/model"
+
+type CreateOrganizationDTO struct {
+	Organization OrganizationDTOBody `json:"organization"`
+}
+
+type UpdateOrganizationDTO struct {
+	Organization OrganizationDTOBody `json:"organization"`
+}
+
+type OrganizationDTOBody struct {
+	Name string `json:"name" form:"name" validate:"required,min=1,max=100"`
+}
+
+type OrganizationResponse struct {
+	Organization model.Organization `json:"organization"`
+}
+
+type GetOrganizationsResponse struct {
+	// Memberships of the account, each with its organization
+	Memberships []model.Membership `json:"memberships"`
+}
+
+type GetOrganizationResponse struct {
+	Organization model.Organization `json:"organization"`
+	// Members of the organization, each with its account
+	Members []model.Membership `json:"members"`
+}
+
+type AddMemberDTO struct {
+	Email string `json:"email" validate:"required,email"`
+	// Role defaults to member
+	Role string `json:"role" validate:"omitempty,oneof=member admin owner"`
+}
+
+type UpdateMemberDTO struct {
+	Role string `json:"role" validate:"required,oneof=member admin owner"`
+}
+
+type MemberResponse struct {
+	Member model.Membership `json:"member"`
+}
+
+type SwitchOrganizationDTO struct {
+	// OrganizationID selects the organization, 0 selects the personal space
+	OrganizationID uint `json:"organizationId" form:"organizationId"`
+}
@@ -0,0 +1,27 @@
+package types
+
+import (
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"github.com/nleiva/go-todo-api/pkg/quickadd"
+)
+
+// QuickAddDTO is the text of a new todo with its date, priority, tags and project, e.g.
+// "Buy milk tomorrow 5pm !high #errands +home"
+type QuickAddDTO struct {
+	Text string `json:"text" form:"text" validate:"required,max=1000"`
+}
+
+type QuickAddResponse struct {
+	Todo model.Todo `json:"todo"`
+	// Tokens are the parts of the text that were recognized, the rest is the title
+	Tokens []quickadd.Token `json:"tokens"`
+}
+
+// TimezoneDTO is the IANA name of the account's timezone, e.g. Europe/Berlin. Empty means UTC.
+type TimezoneDTO struct {
+	Timezone string `json:"timezone" form:"timezone" validate:"omitempty,timezone"`
+}
+
+type TimezoneResponse struct {
+	Timezone string `json:"timezone"`
+}
@@ -0,0 +1,22 @@
+package types
+
+import "github.com/nleiva/go-todo-api/pkg/app/model"
+
+type ShareTodoDTO struct {
+	Email string `json:"email" validate:"required,email"`
+	// Role defaults to viewer
+	Role string `json:"role" validate:"omitempty,oneof=viewer editor owner"`
+}
+
+type ShareTodoResponse struct {
+	Share model.TodoShare `json:"share"`
+}
+
+type GetTodoSharesResponse struct {
+	// Shares of the todo, each with its account
+	Shares []model.TodoShare `json:"shares"`
+}
+
+type GetSharedTodosResponse struct {
+	Todos []model.SharedTodo `json:"todos"`
+}
@@ -0,0 +1,41 @@
+package types
+
+import (
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"gopkg.in/guregu/null.v4"
+)
+
+type CreateShareLinkDTO struct {
+	Name string `json:"name" form:"name" validate:"required,min=1,max=100"`
+	// Status filters the todos, defaults to all
+	Status string `json:"status" form:"status" validate:"omitempty,oneof=all active completed"`
+	// Search only shows todos with the text in their title or description
+	Search string `json:"search" form:"search" validate:"max=100"`
+	// Password protects the link if set
+	Password string `json:"password" form:"password" validate:"omitempty,min=8,max=128"`
+	// ExpiresIn is a duration like "72h", links don't expire without it
+	ExpiresIn string `json:"expiresIn" form:"expiresIn"`
+}
+
+type CreateShareLinkResponse struct {
+	ShareLink model.ShareLink `json:"shareLink"`
+	// URL is only returned once, only the hash of its token is stored
+	URL string `json:"url"`
+}
+
+type GetShareLinksResponse struct {
+	ShareLinks []model.ShareLink `json:"shareLinks"`
+}
+
+// PublicTodo is the part of a todo shown through share links
+type PublicTodo struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Completed   bool      `json:"completed"`
+	CompletedAt null.Time `json:"completedAt" swaggertype:"string" format:"date-time"`
+}
+
+type PublicShareLinkResponse struct {
+	Name  string       `json:"name"`
+	Todos []PublicTodo `json:"todos"`
+}
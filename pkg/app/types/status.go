@@ -0,0 +1,39 @@
+package types
+
+import "github.com/nleiva/go-todo-api/pkg/app/model"
+
+type StatusDTO struct {
+	Name     string `json:"name" form:"name" validate:"required,min=1,max=64"`
+	Position int    `json:"position" form:"position"`
+	// Color is a hex color like #2563eb
+	Color string `json:"color" form:"color" validate:"omitempty,hexcolor"`
+	// IsDone completes the todos in the status
+	IsDone bool `json:"isDone" form:"isDone"`
+}
+
+// StatusWithCount is a status with the number of todos in it
+type StatusWithCount struct {
+	model.Status
+	Todos int64 `json:"todos"`
+}
+
+type GetStatusesResponse struct {
+	// Statuses of the current space ordered by position
+	Statuses []StatusWithCount `json:"statuses"`
+	// Transitions allowed between the statuses, statuses without transitions allow all moves
+	Transitions []model.StatusTransition `json:"transitions"`
+}
+
+type StatusResponse struct {
+	Status model.Status `json:"status"`
+}
+
+type SetTransitionsDTO struct {
+	// ToStatusIDs are the statuses todos in the status can be moved to, empty allows all statuses and only the
+	// status itself none
+	ToStatusIDs []uint `json:"toStatusIds"`
+}
+
+type MoveTodoDTO struct {
+	StatusID uint `json:"statusId" form:"statusId" validate:"required"`
+}
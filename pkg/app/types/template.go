@@ -0,0 +1,28 @@
+package types
+
+import "github.com/nleiva/go-todo-api/pkg/app/model"
+
+// CreateTemplateDTO captures the todo with its subitems as a template of the current space
+type CreateTemplateDTO struct {
+	TodoID uint   `json:"fkTodoId" form:"fkTodoId" validate:"required"`
+	Name   string `json:"name" form:"name" validate:"required,min=1,max=128"`
+}
+
+// InstantiateTemplateDTO is the base date the due offsets of the items are relative to, a time in RFC 3339 or a
+// date like 2006-01-02 for the end of that day in the account's timezone. Empty means now.
+type InstantiateTemplateDTO struct {
+	BaseDate string `json:"baseDate" form:"baseDate"`
+}
+
+type GetTemplatesResponse struct {
+	Templates []model.TodoTemplate `json:"templates"`
+}
+
+type TemplateResponse struct {
+	Template model.TodoTemplate `json:"template"`
+}
+
+type InstantiateTemplateResponse struct {
+	// Todos created from the items, the root first
+	Todos []model.Todo `json:"todos"`
+}
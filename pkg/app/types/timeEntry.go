@@ -0,0 +1,62 @@
+package types
+
+import (
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+	"gopkg.in/guregu/null.v4"
+)
+
+// CreateTimeEntryDTO adds time spent on a todo without a timer, the end is given either directly or as minutes
+type CreateTimeEntryDTO struct {
+	StartedAt time.Time  `json:"startedAt" validate:"required"`
+	EndedAt   *time.Time `json:"endedAt" validate:"required_without=Minutes"`
+	Minutes   int        `json:"minutes" validate:"required_without=EndedAt,min=0"`
+	Note      string     `json:"note" validate:"max=255"`
+}
+
+type TimeEntryResponse struct {
+	Entry model.TimeEntry `json:"entry"`
+}
+
+type TimerResponse struct {
+	// Timer is the running timer, null if the account doesn't track time at the moment
+	Timer *model.TimeEntry `json:"timer"`
+	// Stopped is the timer that was stopped to start Timer, as an account runs one timer at a time
+	Stopped *model.TimeEntry `json:"stopped,omitempty"`
+}
+
+// TimeTotal compares the time tracked on a todo with its estimate
+type TimeTotal struct {
+	TodoID           uint     `json:"fkTodoId"`
+	Title            string   `json:"title"`
+	EstimatedSeconds null.Int `json:"estimatedSeconds" swaggertype:"integer"`
+	TrackedSeconds   int64    `json:"trackedSeconds"`
+	// OverEstimate is set when more time was tracked than estimated
+	OverEstimate bool `json:"overEstimate"`
+}
+
+type GetTodoTimeResponse struct {
+	TimeTotal
+	Entries []model.TimeEntry `json:"entries"`
+}
+
+// TimeReportTotal is the time tracked on a todo of the report with the time of its subitems rolled up
+type TimeReportTotal struct {
+	TimeTotal
+	ParentID *uint `json:"fkParentId"`
+	// SubitemSeconds is the time tracked on the subitems of the todo on all levels, OverEstimate compares the
+	// estimate with TrackedSeconds and SubitemSeconds together
+	SubitemSeconds int64 `json:"subitemSeconds"`
+}
+
+// TimeReportResponse is the timesheet of a space, Todos are the totals per todo and TrackedSeconds the total of
+// the space within the range. Projects are organizations, so the report of an organization is the report of its
+// project. Parents of the todos are listed before their subitems, even without time of their own.
+type TimeReportResponse struct {
+	From           time.Time         `json:"from"`
+	To             time.Time         `json:"to"`
+	Todos          []TimeReportTotal `json:"todos"`
+	TrackedSeconds int64             `json:"trackedSeconds"`
+	Entries        []model.TimeEntry `json:"entries"`
+}
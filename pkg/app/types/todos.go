@@ -33,3 +33,10 @@ type UpdateTodoResponse struct {
 type ImportCSVTodosResponse struct {
 	Errors []error `json:"errors"`
 }
+
+type ReorderTodoDTO struct {
+	// AfterID is the todo the moved todo should follow, the todo right before it in the list
+	AfterID *uint `json:"afterId" form:"afterId" validate:"required_without=BeforeID"`
+	// BeforeID is the todo the moved todo should precede, the todo right after it in the list
+	BeforeID *uint `json:"beforeId" form:"beforeId" validate:"required_without=AfterID"`
+}
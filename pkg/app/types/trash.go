@@ -0,0 +1,17 @@
+package types
+
+import (
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+)
+
+// TrashedTodo is a deleted todo with the time it is purged at
+type TrashedTodo struct {
+	model.Todo
+	PurgeAt time.Time `json:"purgeAt"`
+}
+
+type GetTrashResponse struct {
+	Todos []TrashedTodo `json:"todos"`
+}
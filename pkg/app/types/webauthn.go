@@ -0,0 +1,16 @@
+package types
+
+import "github.com/nleiva/go-todo-api/pkg/app/model"
+
+type WebAuthnLoginBeginDTO struct {
+	// Email is optional. Without it a discoverable (usernameless) login is started.
+	Email string `json:"email" form:"email" validate:"omitempty,email"`
+}
+
+type GetWebAuthnCredentialsResponse struct {
+	Credentials []model.WebAuthnCredential `json:"credentials"`
+}
+
+type CreateWebAuthnCredentialResponse struct {
+	Credential model.WebAuthnCredential `json:"credential"`
+}
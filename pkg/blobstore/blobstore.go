@@ -0,0 +1,55 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/nleiva/go-todo-api/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps file contents by key. The implementation is chosen with BLOB_STORE (local or s3), see Configured.
+type BlobStore interface {
+	// Put stores the content under the key, existing content is replaced
+	Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+	// Get reads length bytes of the content starting at offset, a negative length reads to the end.
+	// Unknown keys return ErrNotFound.
+	Get(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error)
+	// Delete removes the content, unknown keys are ignored
+	Delete(ctx context.Context, key string) error
+}
+
+var (
+	configured     BlobStore
+	configuredOnce sync.Once
+)
+
+// Configured returns the blob store selected by the configuration. It is created once and shared.
+func Configured() BlobStore {
+	configuredOnce.Do(func() {
+		switch config.BLOB_STORE {
+		case "s3":
+			configured = &S3Store{
+				Endpoint:        config.S3_ENDPOINT,
+				Region:          config.S3_REGION,
+				Bucket:          config.S3_BUCKET,
+				AccessKeyID:     config.S3_ACCESS_KEY_ID,
+				SecretAccessKey: config.S3_SECRET_ACCESS_KEY,
+			}
+		default:
+			configured = &LocalStore{
+				Root: config.BLOB_LOCAL_PATH,
+			}
+		}
+	})
+
+	return configured
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
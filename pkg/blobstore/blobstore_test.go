@@ -0,0 +1,96 @@
+package blobstore_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/pkg/blobstore"
+)
+
+func testBlobStore(t *testing.T, store blobstore.BlobStore) {
+	ctx := context.Background()
+	key := "3a7bd3e2360a3d29eea436fcfb7e44c735d117c42d1c1835420b6b9942dd4f1b"
+
+	read := func(offset int64, length int64) string {
+		reader, err := store.Get(ctx, key, offset, length)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer reader.Close()
+
+		content, _ := io.ReadAll(reader)
+		return string(content)
+	}
+
+	if err := store.Put(ctx, key, strings.NewReader("hello world"), 11, "text/plain"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := read(0, -1); got != "hello world" {
+		t.Errorf("Expected the full content, got %q", got)
+	}
+	if got := read(6, 5); got != "world" {
+		t.Errorf("Expected a range of the content, got %q", got)
+	}
+	if got := read(6, -1); got != "world" {
+		t.Errorf("Expected the content from an offset, got %q", got)
+	}
+
+	if err := store.Put(ctx, key, strings.NewReader("replaced"), 8, "text/plain"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := read(0, -1); got != "replaced" {
+		t.Errorf("Expected the replaced content, got %q", got)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := store.Get(ctx, key, 0, -1); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Expected deleting unknown keys to succeed, got %v", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	store := &blobstore.LocalStore{Root: t.TempDir()}
+	testBlobStore(t, store)
+
+	if err := store.Put(context.Background(), "../escape", strings.NewReader("x"), 1, "text/plain"); err == nil {
+		t.Errorf("Expected keys outside of the root to be rejected")
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &blobstore.FakeS3{AccessKeyID: "test-key", SecretAccessKey: "test-secret", Region: "eu-central-1"}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	testBlobStore(t, &blobstore.S3Store{
+		Endpoint:        server.URL,
+		Region:          "eu-central-1",
+		Bucket:          "attachments",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+	})
+
+	wrongSecret := &blobstore.S3Store{
+		Endpoint:        server.URL,
+		Region:          "eu-central-1",
+		Bucket:          "attachments",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "wrong",
+	}
+	if err := wrongSecret.Put(context.Background(), "key", strings.NewReader("x"), 1, "text/plain"); err == nil {
+		t.Errorf("Expected requests with a wrong signature to fail")
+	}
+	if fake.Len() != 0 {
+		t.Errorf("Expected no objects to be stored, got %d", fake.Len())
+	}
+}
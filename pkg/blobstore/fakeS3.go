@@ -0,0 +1,93 @@
+package blobstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var authorizationPattern = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([^,]+), Signature=([0-9a-f]+)$`)
+
+// FakeS3 is an in-process S3 compatible server meant for tests. It keeps the objects of all buckets in memory,
+// supports PUT, GET (with ranges), HEAD and DELETE of objects and rejects requests with invalid signatures.
+type FakeS3 struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Region          string
+
+	mu      sync.Mutex
+	objects map[string]fakeS3Object
+}
+
+type fakeS3Object struct {
+	content     []byte
+	contentType string
+	modified    time.Time
+}
+
+// Len returns the number of stored objects
+func (f *FakeS3) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.objects)
+}
+
+func (f *FakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.authorized(r) {
+		http.Error(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.objects == nil {
+		f.objects = map[string]fakeS3Object{}
+	}
+	name := r.URL.Path
+
+	switch r.Method {
+	case http.MethodPut:
+		content, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.objects[name] = fakeS3Object{content: content, contentType: r.Header.Get("Content-Type"), modified: time.Now()}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		object, ok := f.objects[name]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		http.ServeContent(w, r, "", object.modified, bytes.NewReader(object.content))
+	case http.MethodDelete:
+		delete(f.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *FakeS3) authorized(r *http.Request) bool {
+	match := authorizationPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if match == nil || match[1] != f.AccessKeyID || match[3] != f.Region {
+		return false
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, match[2]) {
+		return false
+	}
+
+	expected := s3Signature(r, strings.Split(match[4], ";"), f.SecretAccessKey, f.Region, amzDate)
+	return hmac.Equal([]byte(expected), []byte(match[5]))
+}
@@ -50,7 +50,35 @@ func (m *MySQL) connect(dsn string) error {
 }
 
 func (m *MySQL) AutoMigrate() error {
-	return m.db.AutoMigrate(&model.Account{}, &model.Todo{})
+	return m.db.AutoMigrate(
+		&model.Account{},
+		&model.Todo{},
+		&model.WebAuthnCredential{},
+		&model.WebAuthnSession{},
+		&model.LoginAttempt{},
+		&model.OIDCIdentity{},
+		&model.OIDCSession{},
+		&model.OAuthClient{},
+		&model.OAuthAuthorizationCode{},
+		&model.OAuthToken{},
+		&model.Invitation{},
+		&model.MagicLink{},
+		&model.Organization{},
+		&model.Membership{},
+		&model.TodoShare{},
+		&model.ShareLink{},
+		&model.ShareLinkAttempt{},
+		&model.TodoHistory{},
+		&model.Notification{},
+		&model.Comment{},
+		&model.Attachment{},
+		&model.Status{},
+		&model.StatusTransition{},
+		&model.TodoDependency{},
+		&model.TimeEntry{},
+		&model.TodoTemplate{},
+		&model.TodoTemplateItem{},
+	)
 }
 
 func (m *MySQL) Disconnect() {
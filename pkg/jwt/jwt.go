@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/nleiva/go-todo-api/config"
@@ -21,6 +22,11 @@ type TokenPayload struct {
 	Type       string
 	Secret     string
 	Permission uint64
+
+	// Only set for OAuth access tokens (TYPE_OAUTH)
+	Scope    string
+	ClientID string
+	TokenID  string
 }
 
 const (
@@ -30,6 +36,18 @@ const (
 	CLAIM_TYPE       = "type"
 	CLAIM_SECRET     = "tokenSecret"
 	CLAIM_PERMISSION = "permission"
+	CLAIM_SCOPE      = "scope"
+	CLAIM_CLIENT_ID  = "client_id"
+	CLAIM_TOKEN_ID   = "jti"
+)
+
+const (
+	TYPE_AUTH    = "auth"
+	TYPE_REFRESH = "refresh"
+	// TYPE_OAUTH tokens are issued to third party clients by the authorization server and are limited by their scope
+	TYPE_OAUTH = "oauth"
+	// TYPE_PROXY payloads aren't tokens, they are created for requests authenticated by a trusted proxy
+	TYPE_PROXY = "proxy"
 )
 
 // Instance of the pub/priv key sets. We keep them in memory so we don't have IO overhead on every request
@@ -53,7 +71,7 @@ func Generate(account *model.Account) (types.AuthResponseBody, error) {
 		IssuedAt(time.Now()).
 		Expiration(time.Now().Add(config.JWT_TOKEN_EXP)).
 		Claim(CLAIM_ACCOUNT_ID, account.ID).
-		Claim(CLAIM_TYPE, "auth").
+		Claim(CLAIM_TYPE, TYPE_AUTH).
 		Claim(CLAIM_SECRET, account.TokenSecret).
 		Claim(CLAIM_PERMISSION, account.Permission).
 		Build()
@@ -66,27 +84,21 @@ func Generate(account *model.Account) (types.AuthResponseBody, error) {
 		IssuedAt(time.Now()).
 		Expiration(time.Now().Add(config.JWT_REFRESH_EXP)).
 		Claim(CLAIM_ACCOUNT_ID, account.ID).
-		Claim(CLAIM_TYPE, "refresh").
+		Claim(CLAIM_TYPE, TYPE_REFRESH).
 		Claim(CLAIM_SECRET, account.TokenSecret).
 		Build()
 	if err != nil {
 		return types.AuthResponseBody{}, utils.RequestErrorFrom(&utils.TOKEN_GENERATION_ERROR, err.Error())
 	}
 
-	// Get the last key in the set
-	jwkKey, ok := JWKS.PRV_SET.Key(JWKS.PRV_SET.Len() - 1)
-	if !ok {
-		return types.AuthResponseBody{}, utils.RequestErrorFrom(&utils.TOKEN_GENERATION_ERROR, "failed to get last key in set")
-	}
-
-	signed, err := jwt.Sign(token, jwt.WithKey(jwa.ES256K, jwkKey))
+	signed, err := sign(token)
 	if err != nil {
-		return types.AuthResponseBody{}, utils.RequestErrorFrom(&utils.TOKEN_GENERATION_ERROR, err.Error())
+		return types.AuthResponseBody{}, err
 	}
 
-	signedRefresh, err := jwt.Sign(refreshToken, jwt.WithKey(jwa.ES256K, jwkKey))
+	signedRefresh, err := sign(refreshToken)
 	if err != nil {
-		return types.AuthResponseBody{}, utils.RequestErrorFrom(&utils.TOKEN_GENERATION_ERROR, err.Error())
+		return types.AuthResponseBody{}, err
 	}
 
 	return types.AuthResponseBody{
@@ -95,6 +107,49 @@ func Generate(account *model.Account) (types.AuthResponseBody, error) {
 	}, nil
 }
 
+// GenerateOAuth generates an access token for a third party client acting on behalf of the account.
+// The token id (jti) is returned so the token can be tracked for introspection and revocation.
+func GenerateOAuth(account *model.Account, clientID string, scope string, exp time.Duration) (token string, tokenID string, err error) {
+	tokenID = uuid.NewString()
+
+	built, err := jwt.NewBuilder().
+		Issuer(`github.com/nleiva/go-todo-api`).
+		IssuedAt(time.Now()).
+		Expiration(time.Now().Add(exp)).
+		JwtID(tokenID).
+		Claim(CLAIM_ACCOUNT_ID, account.ID).
+		Claim(CLAIM_TYPE, TYPE_OAUTH).
+		Claim(CLAIM_PERMISSION, account.Permission).
+		Claim(CLAIM_SCOPE, scope).
+		Claim(CLAIM_CLIENT_ID, clientID).
+		Build()
+	if err != nil {
+		return "", "", utils.RequestErrorFrom(&utils.TOKEN_GENERATION_ERROR, err.Error())
+	}
+
+	signed, err := sign(built)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(signed), tokenID, nil
+}
+
+// sign signs the token with the last key in the set
+func sign(token jwt.Token) ([]byte, error) {
+	jwkKey, ok := JWKS.PRV_SET.Key(JWKS.PRV_SET.Len() - 1)
+	if !ok {
+		return nil, utils.RequestErrorFrom(&utils.TOKEN_GENERATION_ERROR, "failed to get last key in set")
+	}
+
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.ES256K, jwkKey))
+	if err != nil {
+		return nil, utils.RequestErrorFrom(&utils.TOKEN_GENERATION_ERROR, err.Error())
+	}
+
+	return signed, nil
+}
+
 // Parse parses the jwt token (Validate against the public keys and return the token)
 func Parse(token string) (jwt.Token, error) {
 	// When parsing we do it against the public key
@@ -107,6 +162,17 @@ func Parse(token string) (jwt.Token, error) {
 	return tok, nil
 }
 
+// ProxyPayload returns the payload of a request the trusted proxy authenticated for the account
+func ProxyPayload(account *model.Account) *TokenPayload {
+	return &TokenPayload{
+		Valid:      true,
+		AccountID:  account.ID,
+		Type:       TYPE_PROXY,
+		Secret:     account.TokenSecret,
+		Permission: account.Permission,
+	}
+}
+
 // Verify Verifies the token and returns the payload
 func Verify(token string) (*TokenPayload, error) {
 	tok, err := Parse(token)
@@ -119,12 +185,30 @@ func Verify(token string) (*TokenPayload, error) {
 		return nil, err
 	}
 
+	accountID, ok := claims[CLAIM_ACCOUNT_ID].(float64)
+	if !ok {
+		return nil, fmt.Errorf("missing %s claim", CLAIM_ACCOUNT_ID)
+	}
+	tokenType, ok := claims[CLAIM_TYPE].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing %s claim", CLAIM_TYPE)
+	}
+
+	// Refresh tokens don't carry the permission claim, OAuth tokens no secret
+	permission, _ := claims[CLAIM_PERMISSION].(float64)
+	secret, _ := claims[CLAIM_SECRET].(string)
+	scope, _ := claims[CLAIM_SCOPE].(string)
+	clientID, _ := claims[CLAIM_CLIENT_ID].(string)
+
 	return &TokenPayload{
 		Valid:      true,
-		AccountID:  uint(claims[CLAIM_ACCOUNT_ID].(float64)),
-		Type:       claims[CLAIM_TYPE].(string),
-		Secret:     claims[CLAIM_SECRET].(string),
-		Permission: uint64(claims[CLAIM_PERMISSION].(float64)),
+		AccountID:  uint(accountID),
+		Type:       tokenType,
+		Secret:     secret,
+		Permission: uint64(permission),
+		Scope:      scope,
+		ClientID:   clientID,
+		TokenID:    tok.JwtID(),
 	}, nil
 }
 
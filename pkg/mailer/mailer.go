@@ -0,0 +1,129 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nleiva/go-todo-api/config"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Mailer delivers emails. The implementation is chosen with MAILER (smtp, log or memory), see Configured.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+var (
+	configured     Mailer
+	configuredOnce sync.Once
+)
+
+// Configured returns the mailer selected by the configuration. It is created once and shared,
+// so tests using the memory mailer see the messages sent by the app.
+func Configured() Mailer {
+	configuredOnce.Do(func() {
+		switch config.MAILER {
+		case "smtp":
+			configured = &SMTPMailer{
+				Host:     config.SMTP_HOST,
+				Port:     config.SMTP_PORT,
+				Username: config.SMTP_USERNAME,
+				Password: config.SMTP_PASSWORD,
+				From:     config.MAIL_FROM,
+			}
+		case "memory":
+			configured = &MemoryMailer{}
+		default:
+			configured = &LogMailer{}
+		}
+	})
+
+	return configured
+}
+
+// SMTPMailer sends through an SMTP server, authenticating with PLAIN auth when a username is set.
+// net/smtp upgrades to TLS with STARTTLS when the server supports it.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	body := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + message.To,
+		"Subject: " + message.Subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		message.Text,
+	}, "\r\n")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, m.From, []string{message.To}, []byte(body))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LogMailer prints emails to stdout instead of sending them, meant for development
+type LogMailer struct{}
+
+func (m *LogMailer) Send(ctx context.Context, message Message) error {
+	fmt.Printf("[mailer] to=%s subject=%q\n%s\n", message.To, message.Subject, message.Text)
+	return nil
+}
+
+// MemoryMailer keeps the emails in memory, meant for tests
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// Last returns the last email sent to the address
+func (m *MemoryMailer) Last(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i], true
+		}
+	}
+
+	return Message{}, false
+}
@@ -0,0 +1,99 @@
+// Package markdown renders the small subset of markdown used in comments. Raw HTML in the source is always
+// escaped, so the output can be embedded into pages as is.
+//
+// Supported are paragraphs, line breaks, "-" and "*" bullet lists, fenced code blocks, `code`, **bold**,
+// *italic* and [links](https://example.com) with http and https urls.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	linkPattern   = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^\s)]+)\)`)
+	boldPattern   = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	italicPattern = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+)
+
+// Render converts the markdown source to HTML
+func Render(source string) string {
+	lines := strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n")
+
+	var out strings.Builder
+	var paragraph []string
+	inList := false
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + strings.Join(paragraph, "<br>") + "</p>")
+			paragraph = nil
+		}
+		if inList {
+			out.WriteString("</ul>")
+			inList = false
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			code := []string{}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			out.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>")
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* "):
+			if len(paragraph) > 0 {
+				flush()
+			}
+			if !inList {
+				out.WriteString("<ul>")
+				inList = true
+			}
+			out.WriteString("<li>" + inline(trimmed[2:]) + "</li>")
+		default:
+			if inList {
+				flush()
+			}
+			paragraph = append(paragraph, inline(trimmed))
+		}
+	}
+	flush()
+
+	return out.String()
+}
+
+// inline formats a single line, text inside backticks is kept as is
+func inline(text string) string {
+	parts := strings.Split(text, "`")
+
+	var out strings.Builder
+	for i, part := range parts {
+		escaped := html.EscapeString(part)
+		switch {
+		case i%2 == 1 && i < len(parts)-1:
+			out.WriteString("<code>" + escaped + "</code>")
+		case i%2 == 1:
+			// Unclosed backtick
+			out.WriteString("`" + format(escaped))
+		default:
+			out.WriteString(format(escaped))
+		}
+	}
+
+	return out.String()
+}
+
+func format(escaped string) string {
+	escaped = linkPattern.ReplaceAllString(escaped, `<a href="$2" rel="nofollow noopener noreferrer" target="_blank">$1</a>`)
+	escaped = boldPattern.ReplaceAllString(escaped, "<strong>$1</strong>")
+	return italicPattern.ReplaceAllString(escaped, "<em>$1</em>")
+}
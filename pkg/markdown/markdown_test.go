@@ -0,0 +1,33 @@
+package markdown_test
+
+import (
+	"testing"
+
+	"github.com/nleiva/go-todo-api/pkg/markdown"
+)
+
+func TestMarkdownRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{"paragraphs", "first\nline\n\nsecond", "<p>first<br>line</p><p>second</p>"},
+		{"emphasis", "**bold** and *italic*", "<p><strong>bold</strong> and <em>italic</em></p>"},
+		{"code", "run `**go** test`", "<p>run <code>**go** test</code></p>"},
+		{"unclosed code", "a ` b", "<p>a ` b</p>"},
+		{"list", "todo:\n- one\n* two", "<p>todo:</p><ul><li>one</li><li>two</li></ul>"},
+		{"fenced code", "```\n<b>x</b>\n```", "<pre><code>&lt;b&gt;x&lt;/b&gt;</code></pre>"},
+		{"link", "[docs](https://example.com/a?b=1&c=2)", `<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer" target="_blank">docs</a></p>`},
+		{"no javascript links", "[x](javascript:alert(1))", "<p>[x](javascript:alert(1))</p>"},
+		{"escapes html", `<script>alert("x")</script>`, "<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdown.Render(tt.source); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -1,16 +1,29 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/nleiva/go-todo-api/pkg/jwt"
 	"github.com/nleiva/go-todo-api/pkg/middleware/locals"
+	"github.com/nleiva/go-todo-api/pkg/permission"
 	"github.com/nleiva/go-todo-api/utils"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 func Protected(c *fiber.Ctx) error {
+	if payload, err := proxyPayload(c); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.RequestErrorFrom(&utils.UNAUTHORIZED, "no account for the email of the proxy")
+		}
+		return &utils.INTERNAL_SERVER_ERROR
+	} else if payload != nil {
+		c.Locals(locals.KEY_PAYLOAD, payload)
+		return c.Next()
+	}
+
 	authHeader := c.Get("Authorization")
 	authCookie := c.Cookies("go-todo-api_auth")
 
@@ -35,12 +48,58 @@ func Protected(c *fiber.Ctx) error {
 		return utils.RequestErrorFrom(&utils.UNAUTHORIZED, err.Error())
 	}
 
+	if payload.Type == jwt.TYPE_OAUTH {
+		if TokenRevoked == nil || TokenRevoked(payload.TokenID) {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return utils.RequestErrorFrom(&utils.UNAUTHORIZED, "token revoked")
+		}
+
+		if needs := requiredScope(c.Method(), c.Route().Path); needs == "" || !permission.HasScope(payload.Scope, needs) {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="insufficient_scope", scope="`+needs+`"`)
+			return &utils.OAUTH_INSUFFICIENT_SCOPE
+		}
+	}
+
 	c.Locals(locals.KEY_PAYLOAD, payload)
 
 	return c.Next()
 }
 
+// TokenRevoked reports whether the OAuth access token with the id was revoked. It is set by the app
+// to the lookup of the authorization server, OAuth tokens are rejected while it is nil.
+var TokenRevoked func(tokenID string) bool
+
+// scopeRoutes maps the routes OAuth tokens may use to the scope they need, keyed by method and route pattern.
+// Routes that aren't listed can only be used with first party tokens, so new endpoints like sharing or purging
+// todos stay out of reach of third party clients until they are added here.
+var scopeRoutes = map[string]string{
+	"GET /api/todos":         permission.SCOPE_TODOS_READ,
+	"GET /api/todos/:id":     permission.SCOPE_TODOS_READ,
+	"POST /api/todos":        permission.SCOPE_TODOS_WRITE,
+	"POST /api/todos/quick":  permission.SCOPE_TODOS_WRITE,
+	"POST /api/todos/random": permission.SCOPE_TODOS_WRITE,
+	"PUT /api/todos/:id":     permission.SCOPE_TODOS_WRITE,
+	"DELETE /api/todos/:id":  permission.SCOPE_TODOS_WRITE,
+	"GET /api/accounts":      permission.SCOPE_ACCOUNTS_READ,
+	"GET /api/accounts/:id":  permission.SCOPE_ACCOUNTS_READ,
+	"GET /api/auth/me":       permission.SCOPE_PROFILE,
+}
+
+// requiredScope returns the scope needed for the route, empty when OAuth tokens aren't allowed at all
+func requiredScope(method string, route string) string {
+	if method == fiber.MethodHead {
+		method = fiber.MethodGet
+	}
+
+	return scopeRoutes[method+" "+strings.TrimSuffix(route, "/")]
+}
+
 func LoadAuth(c *fiber.Ctx) error {
+	if payload, _ := proxyPayload(c); payload != nil {
+		c.Locals(locals.KEY_PAYLOAD, payload)
+		return c.Next()
+	}
+
 	authHeader := c.Get("Authorization")
 	authCookie := c.Cookies("go-todo-api_auth")
 
@@ -62,7 +121,8 @@ func LoadAuth(c *fiber.Ctx) error {
 
 	payload, err := jwt.Verify(token)
 
-	if err == nil {
+	// The pages are for the account itself, third party tokens are ignored
+	if err == nil && payload.Type != jwt.TYPE_OAUTH {
 		c.Locals(locals.KEY_PAYLOAD, payload)
 	}
 
@@ -77,7 +77,8 @@ func parseSearch(search string) string {
 
 // parseOrders parses the order string and returns a slice of OrderEntry
 // The allowed format is ?order[id]=asc or ?order=id or ?order[id]=desc
-// If the direction is not specified, asc is used
+// If the direction is not specified, asc is used. Keys and directions are checked against the model when the
+// query is built.
 func parseOrders(c *fiber.Ctx) []pagination.OrderEntry {
 	orders := []pagination.OrderEntry{}
 
@@ -85,13 +86,19 @@ func parseOrders(c *fiber.Ctx) []pagination.OrderEntry {
 
 	queryArgs.VisitAll(func(key, value []byte) {
 		keyStr := string(key)
+		if keyStr == "order" && len(value) > 0 {
+			orders = append(orders, pagination.OrderEntry{
+				Key:       string(value),
+				Direction: "asc",
+			})
+			return
+		}
 		if len(keyStr) > 5 && strings.HasPrefix(keyStr, "order") {
 			entries := strings.Split(keyStr[6:], "]")
 
-			direction := "asc"
-
-			if value != nil && string(value) == "desc" {
-				direction = string(value)
+			direction := strings.ToLower(string(value))
+			if direction == "" {
+				direction = "asc"
 			}
 
 			orders = append(orders, pagination.OrderEntry{
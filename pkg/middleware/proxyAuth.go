@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/jwt"
+)
+
+// ProxyAccount resolves an email asserted by the authenticating proxy to the payload of its account (see
+// jwt.ProxyPayload). It is set by the app, header authentication stays off while it is nil.
+var ProxyAccount func(email string) (*jwt.TokenPayload, error)
+
+// proxyPayload returns the payload for a request the trusted proxy authenticated (see PROXY_AUTH_*).
+// It returns nil when header authentication doesn't apply, the request then falls back to the tokens.
+func proxyPayload(c *fiber.Ctx) (*jwt.TokenPayload, error) {
+	if !config.PROXY_AUTH_ENABLED || ProxyAccount == nil {
+		return nil, nil
+	}
+
+	email := strings.TrimSpace(c.Get(config.PROXY_AUTH_EMAIL_HEADER))
+	if email == "" {
+		return nil, nil
+	}
+
+	// c.IP() is the address of the peer, not a forwarded one, so clients can't fake being the proxy.
+	// Headers of other peers are ignored.
+	if !isTrustedProxy(c.IP()) {
+		return nil, nil
+	}
+
+	return ProxyAccount(email)
+}
+
+func isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, cidr := range config.PROXY_AUTH_TRUSTED_CIDRS {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			// Single addresses are accepted as well
+			single, err := netip.ParseAddr(cidr)
+			if err != nil {
+				continue
+			}
+			prefix = netip.PrefixFrom(single, single.BitLen())
+		}
+
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,290 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwxjwk "github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// Client is an OpenID Connect relying party using the authorization code flow with PKCE.
+// Instances should be created using the NewClient function
+type Client struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+}
+
+// Discovery is the subset of the provider metadata (/.well-known/openid-configuration) the client needs
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+// Tokens is the response of the token endpoint
+type Tokens struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Claims are the claims of the ID token (merged with the userinfo response if requested)
+type Claims map[string]any
+
+var (
+	ErrDiscovery    = errors.New("oidc discovery failed")
+	ErrExchange     = errors.New("oidc code exchange failed")
+	ErrInvalidToken = errors.New("invalid oidc id token")
+)
+
+// discoveryCacheDuration limits how long provider metadata and keys are reused before they are fetched again
+const discoveryCacheDuration = time.Hour
+
+type cachedDiscovery struct {
+	discovery *Discovery
+	keys      jwxjwk.Set
+	fetchedAt time.Time
+}
+
+var (
+	discoveryCache   = map[string]*cachedDiscovery{}
+	discoveryCacheMu sync.Mutex
+)
+
+func NewClient(issuer string, clientID string, clientSecret string, redirectURL string, scopes []string) *Client {
+	return &Client{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// RandomString returns a url safe random string with n bytes of entropy, used for state, nonce and PKCE verifier
+func RandomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// CodeChallenge derives the S256 PKCE code challenge from the verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Discover loads the provider metadata and keys. Results are cached per issuer.
+func (c *Client) Discover(ctx context.Context) (*Discovery, jwxjwk.Set, error) {
+	discoveryCacheMu.Lock()
+	defer discoveryCacheMu.Unlock()
+
+	if cached, ok := discoveryCache[c.Issuer]; ok && time.Since(cached.fetchedAt) < discoveryCacheDuration {
+		return cached.discovery, cached.keys, nil
+	}
+
+	discovery := &Discovery{}
+	if err := c.getJSON(ctx, c.Issuer+"/.well-known/openid-configuration", "", discovery); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+
+	// The issuer in the metadata has to match exactly, else tokens of another issuer could be accepted
+	if strings.TrimSuffix(discovery.Issuer, "/") != c.Issuer {
+		return nil, nil, fmt.Errorf("%w: issuer mismatch %q", ErrDiscovery, discovery.Issuer)
+	}
+
+	keys, err := jwxjwk.Fetch(ctx, discovery.JwksURI, jwxjwk.WithHTTPClient(c.HTTPClient))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+
+	discoveryCache[c.Issuer] = &cachedDiscovery{discovery: discovery, keys: keys, fetchedAt: time.Now()}
+
+	return discovery, keys, nil
+}
+
+// AuthCodeURL returns the URL of the authorization endpoint the browser is redirected to
+func (c *Client) AuthCodeURL(ctx context.Context, state string, nonce string, codeVerifier string) (string, error) {
+	discovery, _, err := c.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.ClientID},
+		"redirect_uri":          {c.RedirectURL},
+		"scope":                 {strings.Join(c.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens at the token endpoint
+func (c *Client) Exchange(ctx context.Context, code string, codeVerifier string) (*Tokens, error) {
+	discovery, _, err := c.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d: %s", ErrExchange, res.StatusCode, body)
+	}
+
+	tokens := &Tokens{}
+	if err := json.Unmarshal(body, tokens); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id_token in response", ErrExchange)
+	}
+
+	return tokens, nil
+}
+
+// VerifyIDToken checks signature, issuer, audience, expiry and nonce of the ID token and returns its claims
+func (c *Client) VerifyIDToken(ctx context.Context, rawIDToken string, nonce string) (Claims, error) {
+	discovery, keys, err := c.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(
+		[]byte(rawIDToken),
+		jwt.WithKeySet(keys, jws.WithInferAlgorithmFromKey(true)),
+		jwt.WithValidate(true),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(c.ClientID),
+		jwt.WithAcceptableSkew(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	claims, err := token.AsMap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// Userinfo loads the claims of the userinfo endpoint, used when the ID token doesn't contain the mapped claims
+func (c *Client) Userinfo(ctx context.Context, accessToken string) (Claims, error) {
+	discovery, _, err := c.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if discovery.UserinfoEndpoint == "" {
+		return Claims{}, nil
+	}
+
+	claims := Claims{}
+	if err := c.getJSON(ctx, discovery.UserinfoEndpoint, accessToken, &claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, endpoint string, bearer string, dest any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", endpoint, res.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(dest)
+}
+
+// String returns the claim as string, empty when missing or not a string
+func (c Claims) String(name string) string {
+	value, _ := c[name].(string)
+	return value
+}
+
+// Bool returns the claim as bool. Some providers send booleans as strings ("true").
+func (c Claims) Bool(name string) bool {
+	switch value := c[name].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+
+	return false
+}
@@ -0,0 +1,161 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nleiva/go-todo-api/config"
+)
+
+// BREACHED_PREFIX_LENGTH is the length of the SHA-1 prefix the corpus is partitioned by (same as the HIBP range API)
+const BREACHED_PREFIX_LENGTH = 5
+
+// BreachedCorpus looks up passwords in a local corpus of breached SHA-1 hashes using the k-anonymity layout:
+// hashes are grouped by their 5 character hex prefix and only the remaining 35 character suffix is stored.
+//
+// Two layouts are supported:
+//   - a directory with one file per prefix (e.g. "21BD1" or "21BD1.txt") containing "SUFFIX:COUNT" lines,
+//     as produced by the HIBP downloader. Only the file of the requested prefix is read.
+//   - a single file with "SHA1" or "SHA1:COUNT" lines which is loaded into memory. Meant for small corpora.
+//
+// Instances should be created using the OpenBreachedCorpus function
+type BreachedCorpus struct {
+	dir      string
+	prefixes map[string]map[string]struct{}
+}
+
+var ErrInvalidBreachedCorpus = errors.New("invalid breached password corpus")
+
+// OpenBreachedCorpus opens the corpus at path, which can be a directory or a single file (see BreachedCorpus)
+func OpenBreachedCorpus(path string) (*BreachedCorpus, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return &BreachedCorpus{dir: path}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	corpus := &BreachedCorpus{prefixes: map[string]map[string]struct{}{}}
+
+	err = scanHashes(file, func(hash string) error {
+		if len(hash) != sha1.Size*2 {
+			return ErrInvalidBreachedCorpus
+		}
+
+		prefix, suffix := hash[:BREACHED_PREFIX_LENGTH], hash[BREACHED_PREFIX_LENGTH:]
+		if corpus.prefixes[prefix] == nil {
+			corpus.prefixes[prefix] = map[string]struct{}{}
+		}
+		corpus.prefixes[prefix][suffix] = struct{}{}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return corpus, nil
+}
+
+// Contains reports whether the SHA-1 of the password is part of the corpus
+func (b *BreachedCorpus) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:BREACHED_PREFIX_LENGTH], hash[BREACHED_PREFIX_LENGTH:]
+
+	if b.dir == "" {
+		_, found := b.prefixes[prefix][suffix]
+		return found, nil
+	}
+
+	return b.rangeContains(prefix, suffix)
+}
+
+// rangeContains scans the file of a single prefix, a missing file means no hash with that prefix was breached
+func (b *BreachedCorpus) rangeContains(prefix string, suffix string) (bool, error) {
+	for _, name := range []string{prefix, prefix + ".txt", strings.ToLower(prefix), strings.ToLower(prefix) + ".txt"} {
+		file, err := os.Open(filepath.Join(b.dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		defer file.Close()
+
+		found := false
+		err = scanHashes(file, func(hash string) error {
+			if hash == suffix {
+				found = true
+				return io.EOF
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, io.EOF) {
+			return false, err
+		}
+
+		return found, nil
+	}
+
+	return false, nil
+}
+
+// scanHashes calls fn with the upper case hash of every non empty line, dropping the optional ":COUNT"
+func scanHashes(r io.Reader, fn func(hash string) error) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" {
+			continue
+		}
+
+		if err := fn(strings.ToUpper(hash)); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+var (
+	breachedCorpusOnce sync.Once
+	breachedCorpus     *BreachedCorpus
+)
+
+// ConfiguredBreachedCorpus returns the corpus at PASSWORD_BREACHED_CORPUS_PATH, it is opened once on first use.
+// Returns nil when no path is configured or the corpus can't be opened, which disables the check.
+func ConfiguredBreachedCorpus() *BreachedCorpus {
+	breachedCorpusOnce.Do(func() {
+		if config.PASSWORD_BREACHED_CORPUS_PATH == "" {
+			return
+		}
+
+		corpus, err := OpenBreachedCorpus(config.PASSWORD_BREACHED_CORPUS_PATH)
+		if err != nil {
+			log.Println("Error opening breached password corpus: "+config.PASSWORD_BREACHED_CORPUS_PATH, err)
+			return
+		}
+
+		breachedCorpus = corpus
+	})
+
+	return breachedCorpus
+}
@@ -0,0 +1,54 @@
+package password
+
+import (
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const calibrationRuns = 3
+
+// CalibrateArgon2id returns the argon2id parameters whose hashing takes at least the target duration on this host.
+// Memory (KiB) and parallelism are kept fixed and the iterations are raised until the target is reached.
+func CalibrateArgon2id(target time.Duration, memory uint32, parallelism uint8) *Argon2id {
+	hasher := &Argon2id{
+		Memory:      memory,
+		Iterations:  1,
+		Parallelism: parallelism,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+
+	for hasher.Iterations < 64 && measure(hasher) < target {
+		hasher.Iterations++
+	}
+
+	return hasher
+}
+
+// CalibrateBcrypt returns the lowest bcrypt cost whose hashing takes at least the target duration on this host
+func CalibrateBcrypt(target time.Duration) *Bcrypt {
+	hasher := &Bcrypt{Cost: bcrypt.DefaultCost}
+
+	for hasher.Cost < bcrypt.MaxCost && measure(hasher) < target {
+		hasher.Cost++
+	}
+
+	return hasher
+}
+
+// measure returns the median duration of hashing a password with the hasher
+func measure(hasher Hasher) time.Duration {
+	durations := make([]time.Duration, calibrationRuns)
+
+	for i := range durations {
+		start := time.Now()
+		hasher.Hash("calibration-password")
+		durations[i] = time.Since(start)
+	}
+
+	slices.Sort(durations)
+
+	return durations[calibrationRuns/2]
+}
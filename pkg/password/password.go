@@ -0,0 +1,91 @@
+package password
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/nleiva/go-todo-api/config"
+)
+
+// Hasher hashes passwords into a self describing string. The encoded hash carries the algorithm and its
+// parameters, so hashes created with older settings can still be verified and upgraded later.
+type Hasher interface {
+	// Algorithm is the identifier of the algorithm as used in the encoded hash (e.g. "argon2id")
+	Algorithm() string
+	// Hash returns the encoded hash of the password
+	Hash(password string) (string, error)
+	// Verify compares the password against an encoded hash of this algorithm
+	Verify(password string, encoded string) (bool, error)
+	// NeedsRehash reports whether the encoded hash was created with different parameters than the hasher uses
+	NeedsRehash(encoded string) bool
+}
+
+const (
+	ALGORITHM_ARGON2ID = "argon2id"
+	ALGORITHM_BCRYPT   = "bcrypt"
+)
+
+var ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+
+// Current returns the hasher that is used for new hashes, configured through the PASSWORD_* variables
+func Current() Hasher {
+	if config.PASSWORD_HASH_ALGORITHM == ALGORITHM_BCRYPT {
+		return configuredBcrypt()
+	}
+
+	return configuredArgon2id()
+}
+
+// Hash hashes the password with the current hasher
+func Hash(password string) (string, error) {
+	return Current().Hash(password)
+}
+
+// Verify checks the password against an encoded hash of any supported algorithm.
+// needsRehash is true when the password matched but the hash should be replaced by one of the current hasher.
+func Verify(password string, encoded string) (ok bool, needsRehash bool, err error) {
+	hasher, err := identify(encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	ok, err = hasher.Verify(password, encoded)
+	if err != nil || !ok {
+		return false, false, err
+	}
+
+	current := Current()
+	if hasher.Algorithm() != current.Algorithm() {
+		return true, true, nil
+	}
+
+	return true, current.NeedsRehash(encoded), nil
+}
+
+// identify picks the hasher from the prefix of the encoded hash
+func identify(encoded string) (Hasher, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$"+ALGORITHM_ARGON2ID+"$"):
+		return configuredArgon2id(), nil
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return configuredBcrypt(), nil
+	}
+
+	return nil, ErrUnknownAlgorithm
+}
+
+func configuredArgon2id() *Argon2id {
+	return &Argon2id{
+		Memory:      uint32(config.PASSWORD_ARGON2_MEMORY),
+		Iterations:  uint32(config.PASSWORD_ARGON2_ITERATIONS),
+		Parallelism: uint8(config.PASSWORD_ARGON2_PARALLELISM),
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+func configuredBcrypt() *Bcrypt {
+	return &Bcrypt{
+		Cost: config.PASSWORD_BCRYPT_COST,
+	}
+}
@@ -0,0 +1,124 @@
+package password_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/config"
+	"github.com/nleiva/go-todo-api/pkg/password"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	hashers := []password.Hasher{
+		&password.Argon2id{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		&password.Bcrypt{Cost: bcrypt.MinCost},
+	}
+
+	for _, hasher := range hashers {
+		t.Run(hasher.Algorithm(), func(t *testing.T) {
+			encoded, err := hasher.Hash("123456")
+			if err != nil {
+				t.Fatalf("Error hashing password: %v", err)
+			}
+
+			if ok, err := hasher.Verify("123456", encoded); !ok || err != nil {
+				t.Errorf("Expected password to match, got %t (%v)", ok, err)
+			}
+			if ok, _ := hasher.Verify("654321", encoded); ok {
+				t.Errorf("Expected wrong password not to match")
+			}
+			if hasher.NeedsRehash(encoded) {
+				t.Errorf("Expected a fresh hash not to need a rehash")
+			}
+
+			other, _ := hasher.Hash("123456")
+			if other == encoded {
+				t.Errorf("Expected hashes of the same password to differ by salt")
+			}
+		})
+	}
+
+	t.Run("argon2id should need a rehash when parameters change", func(t *testing.T) {
+		old := &password.Argon2id{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+		encoded, _ := old.Hash("123456")
+
+		if !strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$") {
+			t.Errorf("Unexpected encoding %s", encoded)
+		}
+
+		current := &password.Argon2id{Memory: 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+		if !current.NeedsRehash(encoded) {
+			t.Errorf("Expected a rehash after increasing the iterations")
+		}
+		if ok, _ := current.Verify("123456", encoded); !ok {
+			t.Errorf("Expected hashes with old parameters to still verify")
+		}
+	})
+
+	t.Run("argon2id should reject malformed hashes", func(t *testing.T) {
+		hasher := &password.Argon2id{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+		if _, err := hasher.Verify("123456", "$argon2id$v=19$m=1024$abc"); err == nil {
+			t.Errorf("Expected an error for a malformed hash")
+		}
+	})
+}
+
+func TestPasswordVerify(t *testing.T) {
+	defer func(algorithm string, memory int, iterations int) {
+		config.PASSWORD_HASH_ALGORITHM = algorithm
+		config.PASSWORD_ARGON2_MEMORY = memory
+		config.PASSWORD_ARGON2_ITERATIONS = iterations
+	}(config.PASSWORD_HASH_ALGORITHM, config.PASSWORD_ARGON2_MEMORY, config.PASSWORD_ARGON2_ITERATIONS)
+
+	config.PASSWORD_HASH_ALGORITHM = password.ALGORITHM_ARGON2ID
+	config.PASSWORD_ARGON2_MEMORY = 1024
+	config.PASSWORD_ARGON2_ITERATIONS = 1
+
+	t.Run("should upgrade legacy bcrypt hashes", func(t *testing.T) {
+		legacy, _ := bcrypt.GenerateFromPassword([]byte("123456"), 10)
+
+		ok, needsRehash, err := password.Verify("123456", string(legacy))
+		if !ok || !needsRehash || err != nil {
+			t.Errorf("Expected match with rehash, got %t, %t (%v)", ok, needsRehash, err)
+		}
+
+		ok, needsRehash, _ = password.Verify("654321", string(legacy))
+		if ok || needsRehash {
+			t.Errorf("Expected no match and no rehash, got %t, %t", ok, needsRehash)
+		}
+	})
+
+	t.Run("should not rehash current hashes", func(t *testing.T) {
+		encoded, _ := password.Hash("123456")
+
+		ok, needsRehash, err := password.Verify("123456", encoded)
+		if !ok || needsRehash || err != nil {
+			t.Errorf("Expected match without rehash, got %t, %t (%v)", ok, needsRehash, err)
+		}
+	})
+
+	t.Run("should rehash after the configuration changed", func(t *testing.T) {
+		encoded, _ := password.Hash("123456")
+		config.PASSWORD_ARGON2_ITERATIONS = 2
+
+		if _, needsRehash, _ := password.Verify("123456", encoded); !needsRehash {
+			t.Errorf("Expected a rehash after increasing the iterations")
+		}
+	})
+
+	t.Run("should reject unknown algorithms", func(t *testing.T) {
+		if _, _, err := password.Verify("123456", "$md5$abc"); err != password.ErrUnknownAlgorithm {
+			t.Errorf("Expected ErrUnknownAlgorithm, got %v", err)
+		}
+	})
+}
+
+func BenchmarkPasswordHash(b *testing.B) {
+	hasher := password.Current()
+
+	for i := 0; i < b.N; i++ {
+		hasher.Hash("123456")
+	}
+}
@@ -0,0 +1,144 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nleiva/go-todo-api/config"
+)
+
+const (
+	RULE_MIN_LENGTH    = "min_length"
+	RULE_MAX_LENGTH    = "max_length"
+	RULE_LOWERCASE     = "lowercase"
+	RULE_UPPERCASE     = "uppercase"
+	RULE_DIGIT         = "digit"
+	RULE_SYMBOL        = "symbol"
+	RULE_PERSONAL_INFO = "personal_info"
+	RULE_BREACHED      = "breached"
+)
+
+// Violation is a single rule of the policy the password doesn't satisfy
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Policy describes the requirements for new passwords. Existing passwords are not checked against it on login.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// RequiredClasses contains the character classes (RULE_LOWERCASE, RULE_UPPERCASE, RULE_DIGIT, RULE_SYMBOL)
+	// that must appear at least once
+	RequiredClasses []string
+	// DisallowPersonalInfo rejects passwords containing the email (or its local part) or the name of the account
+	DisallowPersonalInfo bool
+	// Breached is consulted last, nil disables the check
+	Breached *BreachedCorpus
+}
+
+// personalInfoMinLength ignores short names like "Al" that would reject too many passwords
+const personalInfoMinLength = 3
+
+// ConfiguredPolicy returns the policy configured through the PASSWORD_* variables
+func ConfiguredPolicy() *Policy {
+	return &Policy{
+		MinLength:            config.PASSWORD_MIN_LENGTH,
+		MaxLength:            config.PASSWORD_MAX_LENGTH,
+		RequiredClasses:      config.PASSWORD_REQUIRED_CHARACTER_CLASSES,
+		DisallowPersonalInfo: config.PASSWORD_DISALLOW_PERSONAL_INFO,
+		Breached:             ConfiguredBreachedCorpus(),
+	}
+}
+
+// Check returns every rule the password violates, an empty result means the password is accepted.
+// personalInfo are values of the account (email, first and last name) the password must not contain.
+func (p *Policy) Check(password string, personalInfo ...string) []Violation {
+	violations := []Violation{}
+
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RULE_MIN_LENGTH,
+			Message: fmt.Sprintf("Password must be at least %d characters long.", p.MinLength),
+		})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, Violation{
+			Rule:    RULE_MAX_LENGTH,
+			Message: fmt.Sprintf("Password must be at most %d characters long.", p.MaxLength),
+		})
+	}
+
+	for _, class := range p.RequiredClasses {
+		class = strings.TrimSpace(class)
+		matches, message := characterClass(class)
+		if matches == nil {
+			continue
+		}
+
+		if !strings.ContainsFunc(password, matches) {
+			violations = append(violations, Violation{Rule: class, Message: message})
+		}
+	}
+
+	if p.DisallowPersonalInfo && containsPersonalInfo(password, personalInfo) {
+		violations = append(violations, Violation{
+			Rule:    RULE_PERSONAL_INFO,
+			Message: "Password must not contain your email address or name.",
+		})
+	}
+
+	// The breach check is only worth it for otherwise valid passwords
+	if len(violations) == 0 && p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err == nil && breached {
+			violations = append(violations, Violation{
+				Rule:    RULE_BREACHED,
+				Message: "Password appeared in a data breach, please choose a different one.",
+			})
+		}
+	}
+
+	return violations
+}
+
+func characterClass(class string) (func(rune) bool, string) {
+	switch class {
+	case RULE_LOWERCASE:
+		return unicode.IsLower, "Password must contain a lowercase letter."
+	case RULE_UPPERCASE:
+		return unicode.IsUpper, "Password must contain an uppercase letter."
+	case RULE_DIGIT:
+		return unicode.IsDigit, "Password must contain a digit."
+	case RULE_SYMBOL:
+		return func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+		}, "Password must contain a symbol."
+	}
+
+	return nil, ""
+}
+
+func containsPersonalInfo(password string, personalInfo []string) bool {
+	password = strings.ToLower(password)
+
+	for _, info := range personalInfo {
+		info = strings.ToLower(strings.TrimSpace(info))
+
+		candidates := []string{info}
+		if local, _, found := strings.Cut(info, "@"); found {
+			candidates = append(candidates, local)
+		}
+
+		for _, candidate := range candidates {
+			if utf8.RuneCountInString(candidate) >= personalInfoMinLength && strings.Contains(password, candidate) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
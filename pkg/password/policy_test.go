@@ -0,0 +1,124 @@
+package password_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/pkg/password"
+)
+
+func rules(violations []password.Violation) []string {
+	result := []string{}
+	for _, violation := range violations {
+		result = append(result, violation.Rule)
+	}
+	return result
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := &password.Policy{
+		MinLength:            8,
+		MaxLength:            20,
+		RequiredClasses:      []string{password.RULE_UPPERCASE, password.RULE_DIGIT, password.RULE_SYMBOL},
+		DisallowPersonalInfo: true,
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		expected []string
+	}{
+		{"valid", "Correct-Horse-9", []string{}},
+		{"too short", "Ab1!", []string{password.RULE_MIN_LENGTH}},
+		{"too long", "Correct-Horse-Battery-9", []string{password.RULE_MAX_LENGTH}},
+		{"missing classes", "correcthorse", []string{password.RULE_UPPERCASE, password.RULE_DIGIT, password.RULE_SYMBOL}},
+		{"email local part", "Jane.Doe-2024", []string{password.RULE_PERSONAL_INFO}},
+		{"name", "Xx-Smithers-9", []string{password.RULE_PERSONAL_INFO}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rules(policy.Check(tt.password, "jane.doe@turbomeet.xyz", "Jane", "Smithers"))
+
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Expected violations %v, got %v", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("Expected violations %v, got %v", tt.expected, got)
+				}
+			}
+		})
+	}
+
+	t.Run("should count characters instead of bytes", func(t *testing.T) {
+		short := &password.Policy{MinLength: 4, MaxLength: 4}
+
+		if violations := short.Check("ääää"); len(violations) != 0 {
+			t.Errorf("Expected no violations, got %v", rules(violations))
+		}
+	})
+}
+
+func TestPasswordBreachedCorpus(t *testing.T) {
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	t.Run("single file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "breached.txt")
+		os.WriteFile(path, []byte("5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8:3861493\n7C4A8D09CA3762AF61E59520943DC26494F8941B\n"), 0644)
+
+		corpus, err := password.OpenBreachedCorpus(path)
+		if err != nil {
+			t.Fatalf("Error opening corpus: %v", err)
+		}
+
+		if found, _ := corpus.Contains("password"); !found {
+			t.Errorf("Expected password to be breached")
+		}
+		if found, _ := corpus.Contains("123456"); !found {
+			t.Errorf("Expected 123456 to be breached")
+		}
+		if found, _ := corpus.Contains("Correct-Horse-9"); found {
+			t.Errorf("Expected Correct-Horse-9 not to be breached")
+		}
+	})
+
+	t.Run("range directory", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "5BAA6"), []byte("003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\r\n"), 0644)
+
+		corpus, err := password.OpenBreachedCorpus(dir)
+		if err != nil {
+			t.Fatalf("Error opening corpus: %v", err)
+		}
+
+		if found, err := corpus.Contains("password"); !found || err != nil {
+			t.Errorf("Expected password to be breached, got %t (%v)", found, err)
+		}
+		if found, err := corpus.Contains("123456"); found || err != nil {
+			t.Errorf("Expected a missing prefix file to mean not breached, got %t (%v)", found, err)
+		}
+	})
+
+	t.Run("policy should report breached passwords", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "breached.txt")
+		os.WriteFile(path, []byte("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\n"), 0644)
+		corpus, _ := password.OpenBreachedCorpus(path)
+
+		policy := &password.Policy{MinLength: 8, Breached: corpus}
+		got := rules(policy.Check("password"))
+
+		if len(got) != 1 || got[0] != password.RULE_BREACHED {
+			t.Errorf("Expected breached violation, got %v", got)
+		}
+	})
+
+	t.Run("should reject malformed files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "breached.txt")
+		os.WriteFile(path, []byte("not-a-hash\n"), 0644)
+
+		if _, err := password.OpenBreachedCorpus(path); err != password.ErrInvalidBreachedCorpus {
+			t.Errorf("Expected ErrInvalidBreachedCorpus, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package permission
+
+import (
+	"slices"
+	"strings"
+)
+
+// OAuth scopes limit what a third party client can do with an access token on behalf of an account.
+// The account permissions still apply, a scope never grants more than the account is allowed to do.
+const (
+	SCOPE_PROFILE       = "profile"
+	SCOPE_ACCOUNTS_READ = "accounts:read"
+	SCOPE_TODOS_READ    = "todos:read"
+	SCOPE_TODOS_WRITE   = "todos:write"
+)
+
+// Scope describes a scope on the consent page
+type Scope struct {
+	Name        string
+	Description string
+}
+
+// SCOPES are all scopes clients can request, in the order they are shown on the consent page
+var SCOPES = []Scope{
+	{Name: SCOPE_PROFILE, Description: "See your name and email address"},
+	{Name: SCOPE_ACCOUNTS_READ, Description: "See the accounts you have access to"},
+	{Name: SCOPE_TODOS_READ, Description: "See your todos"},
+	{Name: SCOPE_TODOS_WRITE, Description: "Create, change and delete your todos"},
+}
+
+// ParseScope splits a space delimited scope string (as used by OAuth) and drops duplicates
+func ParseScope(scope string) []string {
+	scopes := []string{}
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+
+	return scopes
+}
+
+// HasScope reports whether the space delimited scope string contains the scope
+func HasScope(scope string, needs string) bool {
+	return slices.Contains(strings.Fields(scope), needs)
+}
+
+// IsKnownScope reports whether the scope is one of SCOPES
+func IsKnownScope(name string) bool {
+	for _, scope := range SCOPES {
+		if scope.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,411 @@
+// Package quickadd recognizes due dates, priorities, tags and a project in the text of a new todo, e.g.
+// "Buy milk tomorrow 5pm !high #errands +home". The recognized words are removed from the title and returned
+// as tokens so a UI can highlight them.
+//
+// Dates are relative to the time passed to Parse, in its location. Dates without a time are due at the end of
+// the day. A weekday is the next such day after today, "next" and a weekday is the one a week later. Abbreviated
+// weekdays like "sun" or "wed" are words of their own too, they are only dates after "on" or "next" or with a
+// time. Month names need a day, like "may 5", or "on 5 may" with the day first, so "we may leave" has no date.
+package quickadd
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nleiva/go-todo-api/pkg/app/model"
+)
+
+// Kinds of tokens
+const (
+	TOKEN_DATE     = "date"
+	TOKEN_PRIORITY = "priority"
+	TOKEN_TAG      = "tag"
+	TOKEN_PROJECT  = "project"
+)
+
+// Token is a recognized part of the text. Start and End are offsets in runes, Value is the normalized value:
+// the due date in RFC 3339 or the priority, tag or project name.
+type Token struct {
+	Kind  string `json:"kind"`
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Value string `json:"value"`
+}
+
+// Result is the parsed text. Only the first date, priority and project are recognized, later ones stay in the
+// title.
+type Result struct {
+	Title    string
+	DueAt    *time.Time
+	Priority string
+	Tags     []string
+	Project  string
+	Tokens   []Token
+}
+
+var (
+	clockPattern    = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)$`)
+	clock24Pattern  = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+	isoDatePattern  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	dayOfMonthRegex = regexp.MustCompile(`^(\d{1,2})(st|nd|rd|th)?$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January,
+	"feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"may": time.May,
+	"jun": time.June, "june": time.June,
+	"jul": time.July, "july": time.July,
+	"aug": time.August, "august": time.August,
+	"sep": time.September, "sept": time.September, "september": time.September,
+	"oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November,
+	"dec": time.December, "december": time.December,
+}
+
+// word is a whitespace separated part of the text, key is lowercased without trailing punctuation
+type word struct {
+	text  string
+	key   string
+	start int
+	end   int
+}
+
+// Parse recognizes the tokens in text at now. priorities are the names accepted after "!".
+func Parse(text string, now time.Time, priorities []string) Result {
+	words := split(text)
+	used := make([]bool, len(words))
+	result := Result{Tags: []string{}, Tokens: []Token{}}
+
+	for i := 0; i < len(words); i++ {
+		key := words[i].key
+
+		switch {
+		case strings.HasPrefix(key, "!") && result.Priority == "" && slices.Contains(priorities, key[1:]):
+			result.Priority = key[1:]
+			result.Tokens = append(result.Tokens, token(TOKEN_PRIORITY, words[i:i+1], result.Priority))
+			used[i] = true
+
+		case strings.HasPrefix(key, "#") && model.NamePattern.MatchString(key[1:]):
+			if !slices.Contains(result.Tags, key[1:]) {
+				result.Tags = append(result.Tags, key[1:])
+			}
+			result.Tokens = append(result.Tokens, token(TOKEN_TAG, words[i:i+1], key[1:]))
+			used[i] = true
+
+		case strings.HasPrefix(key, "+") && result.Project == "" && model.NamePattern.MatchString(key[1:]):
+			result.Project = key[1:]
+			result.Tokens = append(result.Tokens, token(TOKEN_PROJECT, words[i:i+1], result.Project))
+			used[i] = true
+
+		case result.DueAt == nil:
+			n, due, ok := parseDate(words[i:], now)
+			if !ok {
+				continue
+			}
+			result.DueAt = &due
+			result.Tokens = append(result.Tokens, token(TOKEN_DATE, words[i:i+n], due.Format(time.RFC3339)))
+			for j := i; j < i+n; j++ {
+				used[j] = true
+			}
+			i += n - 1
+		}
+	}
+
+	title := []string{}
+	for i, w := range words {
+		if !used[i] {
+			title = append(title, w.text)
+		}
+	}
+	result.Title = strings.Join(title, " ")
+
+	return result
+}
+
+func split(text string) []word {
+	words := []word{}
+	start := -1
+	offset := 0
+	for i, r := range text + " " {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				w := text[start:i]
+				key := strings.TrimRightFunc(strings.ToLower(w), func(r rune) bool { return strings.ContainsRune(",.;", r) })
+				words = append(words, word{text: w, key: key, start: offset - utf8.RuneCountInString(w), end: offset})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+		offset++
+	}
+
+	return words
+}
+
+func token(kind string, words []word, value string) Token {
+	texts := make([]string, len(words))
+	for i, w := range words {
+		texts[i] = w.text
+	}
+
+	return Token{Kind: kind, Text: strings.Join(texts, " "), Start: words[0].start, End: words[len(words)-1].end, Value: value}
+}
+
+// parseDate reads a date with an optional time or a time alone from the start of words and returns how many
+// words it used
+func parseDate(words []word, now time.Time) (int, time.Time, bool) {
+	n, day, exact, ok := parseDay(words, now, false)
+	if exact {
+		return n, day, ok
+	}
+
+	if !ok {
+		m, hour, minute, ok := parseTime(words)
+		if !ok {
+			return 0, now, false
+		}
+
+		// The day may follow the time, as in "5pm tomorrow"
+		if n, day, exact, ok := parseDay(words[m:], now, true); ok && !exact {
+			return m + n, at(day, hour, minute), true
+		}
+
+		// A time alone is today, or tomorrow if it passed already
+		due := at(now, hour, minute)
+		if !due.After(now) {
+			due = due.AddDate(0, 0, 1)
+		}
+		return m, due, true
+	}
+
+	if m, hour, minute, ok := parseTime(words[n:]); ok {
+		return n + m, at(day, hour, minute), true
+	}
+
+	return n, endOfDay(day), true
+}
+
+// parseDay reads a day from the start of words. exact is set for expressions that include the time of day,
+// like "tonight" or "in 2 hours". timed is set when a time came before the day, abbreviated weekdays are only
+// read with a time.
+func parseDay(words []word, now time.Time, timed bool) (n int, day time.Time, exact bool, ok bool) {
+	key := func(i int) string {
+		if i < len(words) {
+			return words[i].key
+		}
+		return ""
+	}
+
+	switch first := key(0); {
+	case first == "on":
+		if weekday, ok := weekdays[key(1)]; ok {
+			return 2, nextWeekday(now, weekday), false, true
+		}
+		if date, ok := monthDay(key(1), key(2), now, true); ok {
+			return 3, date, false, true
+		}
+	case first == "today":
+		return 1, now, false, true
+	case first == "tonight":
+		return 1, at(now, 20, 0), true, true
+	case first == "tomorrow" || first == "tmrw":
+		return 1, now.AddDate(0, 0, 1), false, true
+	case first == "next":
+		if weekday, ok := weekdays[key(1)]; ok {
+			return 2, nextWeekday(now, weekday).AddDate(0, 0, 7), false, true
+		}
+		switch key(1) {
+		case "week":
+			return 2, nextWeekday(now, time.Monday), false, true
+		case "month":
+			return 2, time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location()), false, true
+		}
+	case first == "in":
+		if date, ok := monthDay(key(1), key(2), now, true); ok {
+			return 3, date, false, true
+		}
+		return parseDuration(key(1), key(2), now)
+	case isoDatePattern.MatchString(first):
+		if date, err := time.ParseInLocation("2006-01-02", first, now.Location()); err == nil {
+			return 1, date, false, true
+		}
+	default:
+		if weekday, ok := weekdays[first]; ok {
+			if !isAbbreviation(first, weekday) || timed {
+				return 1, nextWeekday(now, weekday), false, true
+			}
+			if _, _, _, ok := parseTime(words[1:]); ok {
+				return 1, nextWeekday(now, weekday), false, true
+			}
+		}
+		if date, ok := monthDay(first, key(1), now, false); ok {
+			return 2, date, false, true
+		}
+	}
+
+	return 0, now, false, false
+}
+
+// monthDay reads "may 5" style dates, and "5 may" only after "on" or "in" (dayFirst) as numbers before a month
+// name are usually counts, like "12 may be free"
+func monthDay(first string, second string, now time.Time, dayFirst bool) (time.Time, bool) {
+	if month, ok := months[first]; ok {
+		if day, ok := dayOfMonth(second); ok {
+			return nextDate(now, month, day)
+		}
+	}
+	if day, ok := dayOfMonth(first); ok && dayFirst {
+		if month, ok := months[second]; ok {
+			return nextDate(now, month, day)
+		}
+	}
+
+	return now, false
+}
+
+// parseDuration reads "in 3 days" style offsets, amount is a number or "a"
+func parseDuration(amount string, unit string, now time.Time) (int, time.Time, bool, bool) {
+	count, err := strconv.Atoi(amount)
+	if amount == "a" || amount == "an" {
+		count, err = 1, nil
+	}
+	if err != nil || count < 0 {
+		return 0, now, false, false
+	}
+
+	switch strings.TrimSuffix(unit, "s") {
+	case "min", "minute":
+		return 3, now.Add(time.Duration(count) * time.Minute), true, true
+	case "hr", "hour":
+		return 3, now.Add(time.Duration(count) * time.Hour), true, true
+	case "day":
+		return 3, now.AddDate(0, 0, count), false, true
+	case "week":
+		return 3, now.AddDate(0, 0, 7*count), false, true
+	case "month":
+		return 3, now.AddDate(0, count, 0), false, true
+	}
+
+	return 0, now, false, false
+}
+
+// parseTime reads "5pm", "5:30 pm", "17:00", "noon" or "midnight" from the start of words, optionally after "at"
+func parseTime(words []word) (n int, hour int, minute int, ok bool) {
+	offset := 0
+	if len(words) > 0 && words[0].key == "at" {
+		offset = 1
+	}
+	if len(words) <= offset {
+		return 0, 0, 0, false
+	}
+
+	key := words[offset].key
+	if len(words) > offset+1 && (words[offset+1].key == "am" || words[offset+1].key == "pm") {
+		if _, err := strconv.Atoi(strings.ReplaceAll(key, ":", "")); err == nil {
+			key += words[offset+1].key
+			offset++
+		}
+	}
+
+	switch {
+	case key == "noon":
+		return offset + 1, 12, 0, true
+	case key == "midnight":
+		return offset + 1, 23, 59, true
+	}
+
+	if match := clockPattern.FindStringSubmatch(key); match != nil {
+		hour, _ = strconv.Atoi(match[1])
+		if match[2] != "" {
+			minute, _ = strconv.Atoi(match[2])
+		}
+		if hour < 1 || hour > 12 || minute > 59 {
+			return 0, 0, 0, false
+		}
+		if hour == 12 {
+			hour = 0
+		}
+		if match[3] == "pm" {
+			hour += 12
+		}
+		return offset + 1, hour, minute, true
+	}
+
+	if match := clock24Pattern.FindStringSubmatch(key); match != nil {
+		hour, _ = strconv.Atoi(match[1])
+		minute, _ = strconv.Atoi(match[2])
+		if hour > 23 || minute > 59 {
+			return 0, 0, 0, false
+		}
+		return offset + 1, hour, minute, true
+	}
+
+	return 0, 0, 0, false
+}
+
+func dayOfMonth(key string) (int, bool) {
+	match := dayOfMonthRegex.FindStringSubmatch(key)
+	if match == nil {
+		return 0, false
+	}
+	day, _ := strconv.Atoi(match[1])
+
+	return day, day >= 1 && day <= 31
+}
+
+// nextWeekday returns the next day with the weekday after today, a week from today for today's weekday
+func nextWeekday(now time.Time, weekday time.Weekday) time.Time {
+	days := (int(weekday) - int(now.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+
+	return now.AddDate(0, 0, days)
+}
+
+// isAbbreviation reports whether key is a short form of the weekday like "wed"
+func isAbbreviation(key string, weekday time.Weekday) bool {
+	return key != strings.ToLower(weekday.String())
+}
+
+// nextDate returns the date this year, or in the next year it exists in if it passed already. Days the month
+// never has, like feb 31, are not ok.
+func nextDate(now time.Time, month time.Month, day int) (time.Time, bool) {
+	// February 29 comes back within 8 years
+	for year := now.Year(); year <= now.Year()+8; year++ {
+		date := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+		if date.Month() == month && !endOfDay(date).Before(now) {
+			return date, true
+		}
+	}
+
+	return now, false
+}
+
+func at(day time.Time, hour int, minute int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+}
+
+func endOfDay(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 23, 59, 59, 0, day.Location())
+}
@@ -0,0 +1,84 @@
+package quickadd_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nleiva/go-todo-api/pkg/quickadd"
+)
+
+var priorities = []string{"none", "low", "medium", "high", "urgent"}
+
+func TestQuickAddDates(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	// A Wednesday afternoon
+	now := time.Date(2024, 3, 6, 14, 30, 0, 0, berlin)
+
+	tests := []struct {
+		text     string
+		title    string
+		expected time.Time
+	}{
+		{"Call mom tomorrow 5pm", "Call mom", time.Date(2024, 3, 7, 17, 0, 0, 0, berlin)},
+		{"Call mom 5pm tomorrow", "Call mom", time.Date(2024, 3, 7, 17, 0, 0, 0, berlin)},
+		{"Report due next fri", "Report due", time.Date(2024, 3, 15, 23, 59, 59, 0, berlin)},
+		{"Report due on fri", "Report due", time.Date(2024, 3, 8, 23, 59, 59, 0, berlin)},
+		{"Report due friday", "Report due", time.Date(2024, 3, 8, 23, 59, 59, 0, berlin)},
+		{"Brunch sun 11am", "Brunch", time.Date(2024, 3, 10, 11, 0, 0, 0, berlin)},
+		{"Brunch 11am sun", "Brunch", time.Date(2024, 3, 10, 11, 0, 0, 0, berlin)},
+		{"Leap day feb 29", "Leap day", time.Date(2028, 2, 29, 23, 59, 59, 0, berlin)},
+		{"Review wed at 9:15", "Review", time.Date(2024, 3, 13, 9, 15, 0, 0, berlin)},
+		{"Renew passport in 3 days", "Renew passport", time.Date(2024, 3, 9, 23, 59, 59, 0, berlin)},
+		{"Check oven in 2 hours", "Check oven", time.Date(2024, 3, 6, 16, 30, 0, 0, berlin)},
+		{"Standup at 9 am", "Standup", time.Date(2024, 3, 7, 9, 0, 0, 0, berlin)},
+		{"Dinner tonight", "Dinner", time.Date(2024, 3, 6, 20, 0, 0, 0, berlin)},
+		{"Taxes 2024-04-15", "Taxes", time.Date(2024, 4, 15, 23, 59, 59, 0, berlin)},
+		{"Birthday feb 2", "Birthday", time.Date(2025, 2, 2, 23, 59, 59, 0, berlin)},
+		{"Party on 12 may", "Party", time.Date(2024, 5, 12, 23, 59, 59, 0, berlin)},
+		{"Meeting on may 5 at 10am", "Meeting", time.Date(2024, 5, 5, 10, 0, 0, 0, berlin)},
+		{"Pay rent, tomorrow.", "Pay rent,", time.Date(2024, 3, 7, 23, 59, 59, 0, berlin)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			result := quickadd.Parse(tt.text, now, priorities)
+			if result.Title != tt.title {
+				t.Errorf("Expected title %q, got %q", tt.title, result.Title)
+			}
+			if result.DueAt == nil || !result.DueAt.Equal(tt.expected) {
+				t.Errorf("Expected due date %v, got %v", tt.expected, result.DueAt)
+			}
+		})
+	}
+
+	for _, text := range []string{"Meet in the park", "Read chapter 5", "Look at home", "Buy sun cream", "Pack sat phone", "Party feb 31", "Meet on 31 apr", "Ask if we may leave early", "Room 12 may be free"} {
+		if result := quickadd.Parse(text, now, priorities); result.DueAt != nil || result.Title != text {
+			t.Errorf("Expected no date in %q, got %v", text, result.DueAt)
+		}
+	}
+}
+
+func TestQuickAddTokens(t *testing.T) {
+	now := time.Date(2024, 3, 6, 14, 30, 0, 0, time.UTC)
+
+	result := quickadd.Parse("Buy café beans !high #errands #Errands +home !low", now, priorities)
+	if result.Title != "Buy café beans !low" {
+		t.Errorf("Expected later priorities to stay in the title, got %q", result.Title)
+	}
+	if result.Priority != "high" || result.Project != "home" || !slices.Equal(result.Tags, []string{"errands"}) {
+		t.Errorf("Expected priority, project and a single tag, got %+v", result)
+	}
+
+	if len(result.Tokens) != 4 {
+		t.Fatalf("Expected 4 tokens, got %+v", result.Tokens)
+	}
+	first := result.Tokens[0]
+	if first.Kind != quickadd.TOKEN_PRIORITY || first.Text != "!high" || first.Start != 15 || first.End != 20 {
+		t.Errorf("Expected the priority with rune offsets, got %+v", first)
+	}
+
+	if result := quickadd.Parse("Fix !critical bug #", now, priorities); result.Priority != "" || len(result.Tokens) != 0 {
+		t.Errorf("Expected unknown priorities and empty tags to be ignored, got %+v", result)
+	}
+}
@@ -0,0 +1,108 @@
+// Package rank generates keys for manually ordered lists. Keys are base 36 fractions written without the
+// leading "0." and compared as plain strings, so a key between any two others can always be found and moving
+// an item only changes its own key.
+//
+// Keys never end in "0", the smallest digit, as no key could be put before "a0" and "a" otherwise.
+package rank
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+var ErrInvalidRange = errors.New("rank: keys are invalid or not in order")
+
+// Between returns a key that sorts after a and before b. An empty a is the start and an empty b the end of
+// the list.
+func Between(a string, b string) (string, error) {
+	if !valid(a) || !valid(b) || (b != "" && a >= b) {
+		return "", ErrInvalidRange
+	}
+
+	return midpoint(a, b), nil
+}
+
+// Spread returns n keys in order that are evenly spaced, used to rebalance a list whose keys grew long
+func Spread(n int) []string {
+	keys := make([]string, n)
+	if n == 0 {
+		return keys
+	}
+
+	// Leave about a digit of room between neighbors
+	length := 1
+	space := big.NewInt(int64(len(digits)))
+	limit := big.NewInt(int64(n+1) * int64(len(digits)))
+	for space.Cmp(limit) < 0 {
+		space.Mul(space, big.NewInt(int64(len(digits))))
+		length++
+	}
+
+	step := new(big.Int).Div(space, big.NewInt(int64(n+1)))
+	value := new(big.Int)
+	for i := range keys {
+		value.Add(value, step)
+		key := value.Text(len(digits))
+		keys[i] = strings.TrimRight(strings.Repeat("0", length-len(key))+key, "0")
+	}
+
+	return keys
+}
+
+func valid(key string) bool {
+	for _, c := range key {
+		if !strings.ContainsRune(digits, c) {
+			return false
+		}
+	}
+
+	return !strings.HasSuffix(key, "0")
+}
+
+// midpoint finds the shortest key between a and b, a missing digit of a counts as "0" and an empty b as the
+// end of the list
+func midpoint(a string, b string) string {
+	if b != "" {
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(tail(a, n), b[n:])
+		}
+	}
+
+	low := strings.IndexByte(digits, digitAt(a, 0))
+	high := len(digits)
+	if b != "" {
+		high = strings.IndexByte(digits, b[0])
+	}
+
+	if high-low > 1 {
+		return string(digits[(low+high+1)/2])
+	}
+	if len(b) > 1 {
+		return b[:1]
+	}
+
+	return string(digits[low]) + midpoint(tail(a, 1), "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+
+	return digits[0]
+}
+
+func tail(key string, i int) string {
+	if i < len(key) {
+		return key[i:]
+	}
+
+	return ""
+}
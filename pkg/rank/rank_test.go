@@ -0,0 +1,78 @@
+package rank_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nleiva/go-todo-api/pkg/rank"
+)
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected string
+	}{
+		{"empty list", "", "", "i"},
+		{"after", "i", "", "r"},
+		{"before", "", "i", "9"},
+		{"between", "a", "c", "b"},
+		{"neighbors", "a", "b", "ai"},
+		{"before a short key", "", "01", "00i"},
+		{"longer lower key", "az", "b", "azi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rank.Between(tt.a, tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+			if got <= tt.a || (tt.b != "" && got >= tt.b) {
+				t.Errorf("Expected %q to be between %q and %q", got, tt.a, tt.b)
+			}
+		})
+	}
+
+	for _, keys := range [][2]string{{"b", "a"}, {"a", "a"}, {"a0", ""}, {"A", ""}} {
+		if _, err := rank.Between(keys[0], keys[1]); err == nil {
+			t.Errorf("Expected %q and %q to be rejected", keys[0], keys[1])
+		}
+	}
+}
+
+func TestRankRepeatedInserts(t *testing.T) {
+	// Inserting at the same spot over and over grows the keys slowly
+	low, high := "a", "b"
+	for range 100 {
+		key, err := rank.Between(low, high)
+		if err != nil {
+			t.Fatal(err)
+		}
+		high = key
+	}
+	if len(high) > 25 {
+		t.Errorf("Expected keys to stay short, got %d characters", len(high))
+	}
+}
+
+func TestRankSpread(t *testing.T) {
+	for _, n := range []int{0, 1, 35, 36, 1000} {
+		keys := rank.Spread(n)
+		if len(keys) != n {
+			t.Fatalf("Expected %d keys, got %d", n, len(keys))
+		}
+		if !slices.IsSorted(keys) || len(slices.Compact(slices.Clone(keys))) != n {
+			t.Errorf("Expected %d distinct sorted keys", n)
+		}
+		for _, key := range keys {
+			if _, err := rank.Between(key, ""); err != nil {
+				t.Errorf("Expected %q to be a valid key", key)
+			}
+		}
+	}
+}
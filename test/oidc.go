@@ -0,0 +1,251 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/nleiva/go-todo-api/pkg/oidc"
+)
+
+// MockIdP is an in-process OpenID Connect provider. Every authorization request is approved right away
+// for the user described by Claims, so tests can run the full authorization code flow without network.
+type MockIdP struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+	// Claims are added to the ID token and the userinfo response of the next logins ("sub" is required)
+	Claims map[string]any
+	// UserinfoOnlyClaims are left out of the ID token, like providers that keep ID tokens small
+	UserinfoOnlyClaims []string
+
+	key          jwk.Key
+	mu           sync.Mutex
+	codes        map[string]mockAuthorization
+	accessTokens map[string]map[string]any
+}
+
+type mockAuthorization struct {
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	claims        map[string]any
+}
+
+func NewMockIdP(clientID string, clientSecret string) *MockIdP {
+	raw, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	key, err := jwk.FromRaw(raw)
+	if err != nil {
+		panic(err)
+	}
+	key.Set(jwk.KeyIDKey, "mock-idp")
+	key.Set(jwk.AlgorithmKey, jwa.ES256)
+
+	m := &MockIdP{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Claims:       map[string]any{},
+		key:          key,
+		codes:        map[string]mockAuthorization{},
+		accessTokens: map[string]map[string]any{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/userinfo", m.userinfo)
+	m.Server = httptest.NewServer(mux)
+
+	return m
+}
+
+func (m *MockIdP) Issuer() string {
+	return m.Server.URL
+}
+
+func (m *MockIdP) Close() {
+	m.Server.Close()
+}
+
+// Authorize follows the authorization URL like a browser would and returns the redirect back to the client
+func (m *MockIdP) Authorize(authURL string) (*url.URL, error) {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get(authURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	return res.Location()
+}
+
+func (m *MockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                m.Issuer(),
+		"authorization_endpoint":                m.Issuer() + "/authorize",
+		"token_endpoint":                        m.Issuer() + "/token",
+		"userinfo_endpoint":                     m.Issuer() + "/userinfo",
+		"jwks_uri":                              m.Issuer() + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"ES256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (m *MockIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("response_type") != "code" || query.Get("client_id") != m.ClientID || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	code := oidc.RandomString(16)
+
+	m.mu.Lock()
+	claims := map[string]any{}
+	for name, value := range m.Claims {
+		claims[name] = value
+	}
+	m.codes[code] = mockAuthorization{
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		claims:        claims,
+	}
+	m.mu.Unlock()
+
+	redirect := redirectURI.Query()
+	redirect.Set("code", code)
+	redirect.Set("state", query.Get("state"))
+	redirectURI.RawQuery = redirect.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (m *MockIdP) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != m.ClientID || clientSecret != m.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	// Codes are single use
+	m.mu.Lock()
+	authorization, found := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	if !found ||
+		authorization.redirectURI != r.PostForm.Get("redirect_uri") ||
+		authorization.codeChallenge != oidc.CodeChallenge(r.PostForm.Get("code_verifier")) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	builder := jwt.NewBuilder().
+		Issuer(m.Issuer()).
+		Audience([]string{m.ClientID}).
+		IssuedAt(time.Now()).
+		Expiration(time.Now().Add(5*time.Minute)).
+		Claim("nonce", authorization.nonce)
+	for name, value := range authorization.claims {
+		if !slices.Contains(m.UserinfoOnlyClaims, name) {
+			builder = builder.Claim(name, value)
+		}
+	}
+
+	token, err := builder.Build()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	idToken, err := jwt.Sign(token, jwt.WithKey(jwa.ES256, m.key))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	accessToken := oidc.RandomString(16)
+	m.mu.Lock()
+	m.accessTokens[accessToken] = authorization.claims
+	m.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"id_token":     string(idToken),
+		"expires_in":   300,
+	})
+}
+
+func (m *MockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	public, err := m.key.PublicKey()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	set := jwk.NewSet()
+	set.AddKey(public)
+
+	writeJSON(w, http.StatusOK, set)
+}
+
+func (m *MockIdP) userinfo(w http.ResponseWriter, r *http.Request) {
+	token := ""
+	if header := r.Header.Get("Authorization"); len(header) > len("Bearer ") {
+		token = header[len("Bearer "):]
+	}
+
+	m.mu.Lock()
+	claims, found := m.accessTokens[token]
+	m.mu.Unlock()
+
+	if !found {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, claims)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -52,6 +52,31 @@ func ClearTables(db *gorm.DB, tables []string) {
 
 func ClearAllTables(db *gorm.DB) {
 	// For SQLite, we can just delete all records from tables
+	db.Exec("DELETE FROM login_attempts")
+	db.Exec("DELETE FROM oidc_identities")
+	db.Exec("DELETE FROM oidc_sessions")
+	db.Exec("DELETE FROM o_auth_clients")
+	db.Exec("DELETE FROM o_auth_authorization_codes")
+	db.Exec("DELETE FROM o_auth_tokens")
+	db.Exec("DELETE FROM invitations")
+	db.Exec("DELETE FROM magic_links")
+	db.Exec("DELETE FROM memberships")
+	db.Exec("DELETE FROM organizations")
+	db.Exec("DELETE FROM web_authn_credentials")
+	db.Exec("DELETE FROM web_authn_sessions")
+	db.Exec("DELETE FROM todo_shares")
+	db.Exec("DELETE FROM share_link_attempts")
+	db.Exec("DELETE FROM share_links")
+	db.Exec("DELETE FROM todo_histories")
+	db.Exec("DELETE FROM notifications")
+	db.Exec("DELETE FROM comments")
+	db.Exec("DELETE FROM attachments")
+	db.Exec("DELETE FROM status_transitions")
+	db.Exec("DELETE FROM todo_dependencies")
+	db.Exec("DELETE FROM time_entries")
+	db.Exec("DELETE FROM todo_template_items")
+	db.Exec("DELETE FROM todo_templates")
+	db.Exec("DELETE FROM statuses")
 	db.Exec("DELETE FROM todos")
 	db.Exec("DELETE FROM accounts")
 }
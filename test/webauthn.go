@@ -0,0 +1,156 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+)
+
+// SoftAuthenticator is a software WebAuthn authenticator holding a single ES256 passkey.
+// It answers the options returned by the API the same way navigator.credentials would.
+type SoftAuthenticator struct {
+	RPID   string
+	Origin string
+
+	CredentialID []byte
+	UserHandle   []byte
+	SignCount    uint32
+
+	key *ecdsa.PrivateKey
+}
+
+func NewSoftAuthenticator(rpID string, origin string) *SoftAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	credentialID := make([]byte, 32)
+	rand.Read(credentialID)
+
+	return &SoftAuthenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		CredentialID: credentialID,
+		key:          key,
+	}
+}
+
+// Create answers the JSON encoded protocol.CredentialCreation with a "none" attestation
+func (a *SoftAuthenticator) Create(options []byte) ([]byte, error) {
+	creation := &protocol.CredentialCreation{}
+	if err := json.Unmarshal(options, creation); err != nil {
+		return nil, err
+	}
+
+	userID, _ := creation.Response.User.ID.(string)
+	userHandle, err := base64.RawURLEncoding.DecodeString(userID)
+	if err != nil {
+		return nil, err
+	}
+	a.UserHandle = userHandle
+
+	clientData, err := a.clientData("webauthn.create", creation.Response.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := webauthncbor.Marshal(map[int]any{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Attested credential data: AAGUID, credential id length, credential id, public key
+	attested := make([]byte, 16)
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.CredentialID)))
+	attested = append(attested, a.CredentialID...)
+	attested = append(attested, publicKey...)
+
+	authData := a.authenticatorData(protocol.FlagUserPresent|protocol.FlagUserVerified|protocol.FlagAttestedCredentialData, attested)
+
+	attestationObject, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(a.CredentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.CredentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(attestationObject),
+		},
+	})
+}
+
+// Get answers the JSON encoded protocol.CredentialAssertion and increments the signature counter
+func (a *SoftAuthenticator) Get(options []byte) ([]byte, error) {
+	assertion := &protocol.CredentialAssertion{}
+	if err := json.Unmarshal(options, assertion); err != nil {
+		return nil, err
+	}
+
+	clientData, err := a.clientData("webauthn.get", assertion.Response.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	a.SignCount++
+	authData := a.authenticatorData(protocol.FlagUserPresent|protocol.FlagUserVerified, nil)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(a.CredentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.CredentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+			"signature":         base64.RawURLEncoding.EncodeToString(signature),
+			"userHandle":        base64.RawURLEncoding.EncodeToString(a.UserHandle),
+		},
+	})
+}
+
+func (a *SoftAuthenticator) clientData(ceremony string, challenge protocol.URLEncodedBase64) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":      ceremony,
+		"challenge": challenge.String(),
+		"origin":    a.Origin,
+	})
+}
+
+func (a *SoftAuthenticator) authenticatorData(flags protocol.AuthenticatorFlags, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, byte(flags))
+	data = binary.BigEndian.AppendUint32(data, a.SignCount)
+
+	return append(data, attested...)
+}
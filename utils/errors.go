@@ -29,11 +29,76 @@ var (
 	VALIDATION_ERROR = RequestError{Code: 1000, StatusCode: fiber.StatusBadRequest, Message: "Validation error."}
 	TIME_PARSE_ERROR = RequestError{Code: 1001, StatusCode: fiber.StatusInternalServerError, Message: ""}
 
-	AUTH_LOGIN_WRONG_PASSWORD = RequestError{Code: 1050, StatusCode: fiber.StatusUnauthorized, Message: "Wrong password."}
+	AUTH_LOGIN_WRONG_PASSWORD = RequestError{Code: 1050, StatusCode: fiber.StatusUnauthorized, Message: "Invalid email or password."}
 	WRONG_REFRESH_TOKEN       = RequestError{Code: 1051, StatusCode: fiber.StatusUnauthorized, Message: "Wrong refresh token."}
 	TOKEN_GENERATION_ERROR    = RequestError{Code: 1052, StatusCode: fiber.StatusInternalServerError, Message: "Failed to generate token."}
+	AUTH_LOGIN_THROTTLED      = RequestError{Code: 1053, StatusCode: fiber.StatusTooManyRequests, Message: "Too many failed login attempts. Please try again later."}
+
+	WEBAUTHN_CHALLENGE_INVALID   = RequestError{Code: 1060, StatusCode: fiber.StatusBadRequest, Message: "Unknown or expired passkey challenge."}
+	WEBAUTHN_VERIFICATION_FAILED = RequestError{Code: 1061, StatusCode: fiber.StatusUnauthorized, Message: "Passkey verification failed."}
+
+	OIDC_DISABLED           = RequestError{Code: 1080, StatusCode: fiber.StatusNotFound, Message: "Single sign-on is not configured."}
+	OIDC_STATE_INVALID      = RequestError{Code: 1081, StatusCode: fiber.StatusBadRequest, Message: "Unknown or expired single sign-on request."}
+	OIDC_LOGIN_FAILED       = RequestError{Code: 1082, StatusCode: fiber.StatusUnauthorized, Message: "Single sign-on failed."}
+	OIDC_EMAIL_NOT_VERIFIED = RequestError{Code: 1083, StatusCode: fiber.StatusForbidden, Message: "The identity provider did not verify your email address."}
+	OIDC_ACCOUNT_NOT_FOUND  = RequestError{Code: 1084, StatusCode: fiber.StatusForbidden, Message: "No account exists for this email address."}
+
+	MAGIC_LINK_INVALID       = RequestError{Code: 1085, StatusCode: fiber.StatusBadRequest, Message: "The sign-in link is invalid, expired or was already used."}
+	MAGIC_LINK_OTHER_BROWSER = RequestError{Code: 1086, StatusCode: fiber.StatusForbidden, Message: "Please open the sign-in link in the browser you requested it from."}
+
+	OAUTH_CLIENT_INVALID     = RequestError{Code: 1090, StatusCode: fiber.StatusBadRequest, Message: "Invalid OAuth client."}
+	OAUTH_INSUFFICIENT_SCOPE = RequestError{Code: 1091, StatusCode: fiber.StatusForbidden, Message: "The access token does not grant the scope required for this request."}
+
+	PASSWORD_POLICY_VIOLATION  = RequestError{Code: 1070, StatusCode: fiber.StatusBadRequest, Message: "Password does not meet the requirements."}
+	PASSWORD_CURRENT_WRONG     = RequestError{Code: 1071, StatusCode: fiber.StatusBadRequest, Message: "Current password is incorrect."}
+	PASSWORD_CURRENT_THROTTLED = RequestError{Code: 1072, StatusCode: fiber.StatusTooManyRequests, Message: "Too many wrong current passwords. Please try again later."}
 
 	ACCOUNT_WITH_EMAIL_ALREADY_EXISTS = RequestError{Code: 1100, StatusCode: fiber.StatusBadRequest, Message: "An account with this email already exists."}
+	REGISTRATION_CLOSED               = RequestError{Code: 1101, StatusCode: fiber.StatusForbidden, Message: "Registration is closed."}
+	INVITATION_REQUIRED               = RequestError{Code: 1102, StatusCode: fiber.StatusForbidden, Message: "Registration requires an invitation code."}
+	INVITATION_INVALID                = RequestError{Code: 1103, StatusCode: fiber.StatusForbidden, Message: "The invitation code is invalid, expired or used up."}
+	INVITATION_PERMISSION_EXCEEDED    = RequestError{Code: 1104, StatusCode: fiber.StatusForbidden, Message: "Invitations can't grant permissions you don't have."}
+
+	ORGANIZATION_NOT_MEMBER     = RequestError{Code: 1110, StatusCode: fiber.StatusForbidden, Message: "You are not a member of this organization."}
+	ORGANIZATION_ROLE_REQUIRED  = RequestError{Code: 1111, StatusCode: fiber.StatusForbidden, Message: "Your role in the organization does not allow this."}
+	ORGANIZATION_LAST_OWNER     = RequestError{Code: 1112, StatusCode: fiber.StatusBadRequest, Message: "An organization needs at least one owner."}
+	ORGANIZATION_ALREADY_MEMBER = RequestError{Code: 1113, StatusCode: fiber.StatusBadRequest, Message: "The account is already a member of the organization."}
+
+	SHARE_ROLE_REQUIRED = RequestError{Code: 1120, StatusCode: fiber.StatusForbidden, Message: "Your access to this todo does not allow this."}
+	SHARE_WITH_SELF     = RequestError{Code: 1121, StatusCode: fiber.StatusBadRequest, Message: "You can't share a todo with yourself."}
+
+	SHARE_LINK_PASSWORD_REQUIRED  = RequestError{Code: 1125, StatusCode: fiber.StatusUnauthorized, Message: "This link is password protected."}
+	SHARE_LINK_PASSWORD_WRONG     = RequestError{Code: 1126, StatusCode: fiber.StatusUnauthorized, Message: "The password of the link is incorrect."}
+	SHARE_LINK_PASSWORD_THROTTLED = RequestError{Code: 1127, StatusCode: fiber.StatusTooManyRequests, Message: "Too many wrong passwords for this link. Please try again later."}
+
+	ASSIGNEE_INVALID = RequestError{Code: 1130, StatusCode: fiber.StatusBadRequest, Message: "Todos can only be assigned to accounts with access to them."}
+
+	COMMENT_AUTHOR_REQUIRED = RequestError{Code: 1135, StatusCode: fiber.StatusForbidden, Message: "Only the author can change this comment."}
+
+	ATTACHMENT_TOO_LARGE        = RequestError{Code: 1140, StatusCode: fiber.StatusRequestEntityTooLarge, Message: "The file is too large."}
+	ATTACHMENT_TYPE_NOT_ALLOWED = RequestError{Code: 1141, StatusCode: fiber.StatusUnsupportedMediaType, Message: "Files of this type can't be attached."}
+	RANGE_NOT_SATISFIABLE       = RequestError{Code: 1142, StatusCode: fiber.StatusRequestedRangeNotSatisfiable, Message: "The requested range is not satisfiable."}
+
+	STATUS_IN_USE                 = RequestError{Code: 1145, StatusCode: fiber.StatusConflict, Message: "The status still has todos, move them to another status first."}
+	STATUS_REQUIRED               = RequestError{Code: 1146, StatusCode: fiber.StatusBadRequest, Message: "A workflow needs at least one open and one done status."}
+	STATUS_TRANSITION_NOT_ALLOWED = RequestError{Code: 1147, StatusCode: fiber.StatusUnprocessableEntity, Message: "The workflow does not allow this status change."}
+	STATUS_TODOS_BLOCKED          = RequestError{Code: 1148, StatusCode: fiber.StatusConflict, Message: "The status has todos that are blocked by todos that are not done yet."}
+
+	TODO_NEIGHBORS_INVALID = RequestError{Code: 1150, StatusCode: fiber.StatusBadRequest, Message: "The todo can't be moved between these todos."}
+
+	TODO_DEPENDENCY_INVALID = RequestError{Code: 1155, StatusCode: fiber.StatusBadRequest, Message: "Todos can only depend on other todos of their space."}
+	TODO_DEPENDENCY_CYCLE   = RequestError{Code: 1156, StatusCode: fiber.StatusConflict, Message: "The dependency would create a cycle."}
+	TODO_BLOCKED            = RequestError{Code: 1157, StatusCode: fiber.StatusConflict, Message: "The todo is blocked by todos that are not done yet."}
+
+	TIMER_NOT_RUNNING  = RequestError{Code: 1160, StatusCode: fiber.StatusConflict, Message: "No timer is running for this todo."}
+	TIME_ENTRY_INVALID = RequestError{Code: 1161, StatusCode: fiber.StatusBadRequest, Message: "Time entries have to end after they start."}
+
+	QUICK_ADD_TITLE_REQUIRED  = RequestError{Code: 1165, StatusCode: fiber.StatusBadRequest, Message: "The todo needs a title besides its date, priority, tags and project."}
+	QUICK_ADD_PROJECT_UNKNOWN = RequestError{Code: 1166, StatusCode: fiber.StatusBadRequest, Message: "None of your organizations is named like the project."}
+
+	TODO_PARENT_INVALID = RequestError{Code: 1170, StatusCode: fiber.StatusBadRequest, Message: "Subitems need a parent todo in the same space."}
+	TEMPLATE_TOO_LARGE  = RequestError{Code: 1171, StatusCode: fiber.StatusBadRequest, Message: "The todo has too many subitems for a template."}
+	TEMPLATE_INVALID    = RequestError{Code: 1172, StatusCode: fiber.StatusConflict, Message: "The items of the template don't form a tree."}
 )
 
 // Error from var Error but pass details